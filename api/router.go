@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -9,15 +10,25 @@ import (
 	"github.com/Nauman-S/Internal-Transfers-System/codes"
 	"github.com/Nauman-S/Internal-Transfers-System/config"
 	"github.com/Nauman-S/Internal-Transfers-System/service/account"
+	"github.com/Nauman-S/Internal-Transfers-System/service/fee_schedules"
+	"github.com/Nauman-S/Internal-Transfers-System/service/fx_rates"
+	"github.com/Nauman-S/Internal-Transfers-System/service/holds"
+	"github.com/Nauman-S/Internal-Transfers-System/service/risk_rules"
+	"github.com/Nauman-S/Internal-Transfers-System/service/standing_orders"
+	"github.com/Nauman-S/Internal-Transfers-System/service/sweep_rules"
 	"github.com/Nauman-S/Internal-Transfers-System/service/transactions"
 	"github.com/Nauman-S/Internal-Transfers-System/rest_handler"
 )
 
+// requestTimeout bounds how long a request may run
+const requestTimeout = 5 * time.Second
+
 func InitRouter(appConfig *config.ApplicationConfig) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	r := gin.New()
-	r.Use(timeoutHF(5 * time.Second))
+	r.Use(deadlineHF(requestTimeout))
+	r.Use(timeoutHF(requestTimeout))
 	r.Use(gin.Recovery())
 	r.Use(gin.Logger())
 	
@@ -34,18 +45,104 @@ func InitRouter(appConfig *config.ApplicationConfig) *gin.Engine {
 	accountsAPI := r.Group("/accounts")
 	{
 		accountsAPI.POST("/", handler.HandleMiddleware(account.CreateAccount))
+		accountsAPI.GET("/overdrawn", handler.HandleMiddleware(account.ListOverdrawnAccounts))
 		accountsAPI.GET("/:account_id", handler.HandleMiddleware(account.GetAccountByID))
+		accountsAPI.GET("/:account_id/limits", handler.HandleMiddleware(account.GetAccountLimits))
+		accountsAPI.GET("/:account_id/postings", handler.HandleMiddleware(account.ListAccountPostings))
+		accountsAPI.PUT("/:account_id/limits", handler.HandleMiddleware(account.SetAccountLimits))
+		accountsAPI.PUT("/:account_id/overdraft", handler.HandleMiddleware(account.SetOverdraftLimit))
+		accountsAPI.PUT("/:account_id/balance-shards", handler.HandleMiddleware(account.SetBalanceShards))
 	}
 
 	transactionsAPI := r.Group("/transactions")
 	{
 		transactionsAPI.POST("/", handler.HandleMiddleware(transactions.CreateTransfer))
+		transactionsAPI.POST("/quote", handler.HandleMiddleware(transactions.QuoteTransfer))
+		transactionsAPI.POST("/batch", handler.HandleMiddleware(transactions.CreateBatchTransfer))
+		transactionsAPI.POST("/split", handler.HandleMiddleware(transactions.CreateSplitPayment))
+		transactionsAPI.GET("/split/:split_payment_id", handler.HandleMiddleware(transactions.GetSplitPaymentByID))
+		transactionsAPI.GET("/", handler.HandleMiddleware(transactions.ListTransfers))
+		transactionsAPI.GET("/scheduled", handler.HandleMiddleware(transactions.ListScheduledTransfers))
+		transactionsAPI.GET("/scheduled/:scheduled_transfer_id", handler.HandleMiddleware(transactions.GetScheduledTransferByID))
+		transactionsAPI.POST("/scheduled/:scheduled_transfer_id/cancel", handler.HandleMiddleware(transactions.CancelScheduledTransfer))
+		transactionsAPI.GET("/async/:async_transfer_id", handler.HandleMiddleware(transactions.GetAsyncTransferByID))
+		transactionsAPI.GET("/approvals", handler.HandleMiddleware(transactions.ListApprovals))
+		transactionsAPI.GET("/approvals/:approval_id", handler.HandleMiddleware(transactions.GetApprovalByID))
+		transactionsAPI.POST("/approvals/:approval_id/approve", handler.HandleMiddleware(transactions.ApproveTransfer))
+		transactionsAPI.POST("/approvals/:approval_id/reject", handler.HandleMiddleware(transactions.RejectTransfer))
+		transactionsAPI.GET("/:transaction_id", handler.HandleMiddleware(transactions.GetTransferByID))
+		transactionsAPI.GET("/:transaction_id/journal", handler.HandleMiddleware(transactions.GetTransferJournal))
+		transactionsAPI.POST("/:transaction_id/reverse", handler.HandleMiddleware(transactions.ReverseTransfer))
+	}
+
+	holdsAPI := r.Group("/holds")
+	{
+		holdsAPI.POST("/", handler.HandleMiddleware(holds.AuthorizeHold))
+		holdsAPI.GET("/:hold_id", handler.HandleMiddleware(holds.GetHoldByID))
+		holdsAPI.POST("/:hold_id/capture", handler.HandleMiddleware(holds.CaptureHold))
+		holdsAPI.POST("/:hold_id/void", handler.HandleMiddleware(holds.VoidHold))
+	}
+
+	standingOrdersAPI := r.Group("/standing-orders")
+	{
+		standingOrdersAPI.POST("/", handler.HandleMiddleware(standing_orders.CreateStandingOrder))
+		standingOrdersAPI.GET("/", handler.HandleMiddleware(standing_orders.ListStandingOrders))
+		standingOrdersAPI.GET("/:standing_order_id", handler.HandleMiddleware(standing_orders.GetStandingOrderByID))
+		standingOrdersAPI.GET("/:standing_order_id/runs", handler.HandleMiddleware(standing_orders.ListStandingOrderRuns))
+		standingOrdersAPI.POST("/:standing_order_id/cancel", handler.HandleMiddleware(standing_orders.CancelStandingOrder))
+	}
+
+	sweepRulesAPI := r.Group("/sweep-rules")
+	{
+		sweepRulesAPI.POST("/", handler.HandleMiddleware(sweep_rules.CreateSweepRule))
+		sweepRulesAPI.GET("/", handler.HandleMiddleware(sweep_rules.ListSweepRules))
+		sweepRulesAPI.GET("/:sweep_rule_id", handler.HandleMiddleware(sweep_rules.GetSweepRuleByID))
+		sweepRulesAPI.POST("/:sweep_rule_id/cancel", handler.HandleMiddleware(sweep_rules.CancelSweepRule))
+	}
+
+	riskRulesAPI := r.Group("/risk-rules")
+	{
+		riskRulesAPI.POST("/", handler.HandleMiddleware(risk_rules.CreateRiskRule))
+		riskRulesAPI.GET("/", handler.HandleMiddleware(risk_rules.ListRiskRules))
+		riskRulesAPI.POST("/reload", handler.HandleMiddleware(risk_rules.ReloadRiskRules))
+		riskRulesAPI.GET("/:risk_rule_id", handler.HandleMiddleware(risk_rules.GetRiskRuleByID))
+		riskRulesAPI.GET("/:risk_rule_id/flags", handler.HandleMiddleware(risk_rules.ListRiskRuleFlags))
+		riskRulesAPI.POST("/:risk_rule_id/enable", handler.HandleMiddleware(risk_rules.EnableRiskRule))
+		riskRulesAPI.POST("/:risk_rule_id/disable", handler.HandleMiddleware(risk_rules.DisableRiskRule))
+	}
+
+	fxRatesAPI := r.Group("/fx-rates")
+	{
+		fxRatesAPI.POST("/", handler.HandleMiddleware(fx_rates.CreateFXRate))
+		fxRatesAPI.GET("/", handler.HandleMiddleware(fx_rates.ListFXRates))
+		fxRatesAPI.GET("/:fx_rate_id", handler.HandleMiddleware(fx_rates.GetFXRateByID))
+	}
+
+	feeSchedulesAPI := r.Group("/fee-schedules")
+	{
+		feeSchedulesAPI.POST("/", handler.HandleMiddleware(fee_schedules.CreateFeeSchedule))
+		feeSchedulesAPI.GET("/", handler.HandleMiddleware(fee_schedules.ListFeeSchedules))
+		feeSchedulesAPI.GET("/:fee_schedule_id", handler.HandleMiddleware(fee_schedules.GetFeeScheduleByID))
+		feeSchedulesAPI.POST("/:fee_schedule_id/deactivate", handler.HandleMiddleware(fee_schedules.DeactivateFeeSchedule))
 	}
 
 
 	return r
 }
 
+// deadlineHF gives the request context the deadline timeoutHF enforces, so
+// database work, including transaction retries, does not outlive the
+// request.
+func deadlineHF(ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), ttl)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
 func timeoutHF(ttl time.Duration) gin.HandlerFunc {
 	return timeout.New(
 		timeout.WithTimeout(ttl),
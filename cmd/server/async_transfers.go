@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	jsoniter "github.com/json-iterator/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// asyncTransferBatchSize is how many queued transfers a worker claims at
+	// a time; small batches spread the queue across the workers
+	asyncTransferBatchSize = 5
+
+	// asyncTransferTimeout bounds one attempt at applying an async transfer.
+	// It is well above the request timeout, since sparing transfers from
+	// that deadline is what async submission is for.
+	asyncTransferTimeout = 30 * time.Second
+
+	// asyncTransferLease is how long a claimed transfer may stay PROCESSING
+	// before another worker assumes its worker died and retries it
+	asyncTransferLease = 2 * time.Minute
+
+	// asyncTransferRetryBackoff is the wait before the first retry of an
+	// attempt that failed on a database error; it doubles with every attempt
+	asyncTransferRetryBackoff = time.Second
+
+	// asyncCallbackBatchSize is how many due callbacks one tick sends
+	asyncCallbackBatchSize = 20
+
+	// asyncCallbackTimeout bounds a single callback request, and
+	// asyncCallbackLease is how long a claimed callback waits before it is
+	// sent again if its sender died
+	asyncCallbackTimeout = 10 * time.Second
+	asyncCallbackLease   = time.Minute
+
+	// asyncCallbackRetryBackoff is the wait before the first callback retry;
+	// it doubles with every attempt
+	asyncCallbackRetryBackoff = 5 * time.Second
+
+	// callbackTimestampHeader and callbackSignatureHeader carry the unix time
+	// a callback was sent at and "sha256=" followed by the hex HMAC-SHA256,
+	// keyed with the callback secret, of the timestamp, a dot and the body
+	callbackTimestampHeader = "X-Callback-Timestamp"
+	callbackSignatureHeader = "X-Callback-Signature"
+)
+
+// retryableAsyncTransferCodes are failures worth another attempt. Unlike a
+// scheduled transfer, nobody chose to run an async transfer later, so
+// business declines such as insufficient funds fail it straight away.
+var retryableAsyncTransferCodes = map[int]bool{
+	codes.ErrSystem.Code:              true,
+	codes.ErrTransactionConflict.Code: true,
+}
+
+// startAsyncTransferWorkers starts the worker pool that applies transfers
+// queued with async.
+func startAsyncTransferWorkers(appConfig *config.ApplicationConfig) {
+	for i := 0; i < appConfig.AsyncTransferWorkers; i++ {
+		name := fmt.Sprintf("async transfer worker %d", i+1)
+		go runPeriodically(appConfig.Ctx, name, appConfig.AsyncTransferPollInterval, func(ctx context.Context) error {
+			return drainAsyncTransfers(ctx, appConfig)
+		})
+	}
+
+	client := newCallbackClient(appConfig.AsyncCallbackAllowPrivateHosts)
+	go runPeriodically(appConfig.Ctx, "async transfer callback sender", appConfig.AsyncCallbackPollInterval, func(ctx context.Context) error {
+		return sendDueCallbacks(ctx, appConfig, client)
+	})
+}
+
+// drainAsyncTransfers keeps claiming and applying queued transfers until the
+// queue is empty, so a busy queue is not worked off one tick at a time.
+func drainAsyncTransfers(ctx context.Context, appConfig *config.ApplicationConfig) error {
+	repo := appConfig.AsyncTransferRepository
+
+	for ctx.Err() == nil {
+		claimed, err := repo.ClaimAsyncTransfers(ctx, asyncTransferBatchSize, time.Now().Add(-asyncTransferLease))
+		if err != nil {
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		for i := range claimed {
+			if err = executeAsyncTransfer(ctx, appConfig, &claimed[i]); err != nil {
+				log.WithError(err).WithField("async_transfer_id", claimed[i].ID).Error("Failed to record async transfer outcome")
+			}
+		}
+	}
+
+	return nil
+}
+
+func executeAsyncTransfer(ctx context.Context, appConfig *config.ApplicationConfig, async *models.AsyncTransfer) error {
+	repo := appConfig.AsyncTransferRepository
+
+	attemptCtx, cancel := context.WithTimeout(ctx, asyncTransferTimeout)
+	defer cancel()
+
+	transfer, sourceBalance, destBalance, err := appConfig.TransferRepository.ProcessTransfer(attemptCtx, &models.Transfer{
+		SourceAccountID:      async.SourceAccountID,
+		DestinationAccountID: async.DestinationAccountID,
+		Amount:               async.Amount,
+		TransferReference:    async.TransferReference,
+	}, asyncTransferIdempotencyKey(async, appConfig.IdempotencyKeyTTL))
+	if err == nil {
+		log.WithFields(log.Fields{
+			"async_transfer_id":   async.ID,
+			"transaction_id":      transfer.ID,
+			"source_balance":      sourceBalance.String(),
+			"destination_balance": destBalance.String(),
+		}).Info("Async transfer completed")
+		return repo.MarkAsyncTransferCompleted(ctx, async.ID, transfer.ID, sourceBalance, destBalance)
+	}
+
+	fields := log.Fields{
+		"async_transfer_id": async.ID,
+		"attempts":          async.Attempts,
+	}
+
+	if retryableAsyncTransferCodes[codes.GetCode(err)] && async.Attempts < appConfig.AsyncTransferMaxAttempts {
+		nextAttemptAt := time.Now().Add(asyncTransferRetryBackoff << min(async.Attempts-1, 10))
+		log.WithError(err).WithFields(fields).WithField("next_attempt_at", nextAttemptAt.Format(time.RFC3339)).Warn("Async transfer attempt failed, will retry")
+		return repo.RetryAsyncTransfer(ctx, async.ID, nextAttemptAt, err)
+	}
+
+	log.WithError(err).WithFields(fields).Error("Async transfer failed")
+	return repo.MarkAsyncTransferFailed(ctx, async.ID, err)
+}
+
+// asyncTransferIdempotencyKey derives a key from the async transfer ID, so
+// an attempt whose outcome was lost replays the applied transfer instead of
+// moving the money again.
+func asyncTransferIdempotencyKey(async *models.AsyncTransfer, ttl time.Duration) *models.IdempotencyKey {
+	key := fmt.Sprintf("%s%d", models.IdempotencyKeyPrefixAsyncTransfer, async.ID)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d:%s", key, async.SourceAccountID, async.DestinationAccountID, async.Amount.String())))
+
+	return &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+}
+
+// newCallbackClient returns the client callbacks are sent with. Callback
+// URLs come from clients, so unless allowPrivateHosts is set the client
+// refuses to connect to loopback, private, link-local and unspecified
+// addresses. The check is made on the address actually dialled, so a host
+// name resolving to one is refused too, and callbacks never go through a
+// proxy that could reach them instead.
+func newCallbackClient(allowPrivateHosts bool) *http.Client {
+	dialer := &net.Dialer{Timeout: asyncCallbackTimeout}
+	if !allowPrivateHosts {
+		dialer.Control = refusePrivateAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: asyncCallbackTimeout, Transport: transport}
+}
+
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return fmt.Errorf("callback address %s is not public", address)
+	}
+	return nil
+}
+
+// sendDueCallbacks POSTs the final state of finished async transfers to
+// their callback URLs. Any 2xx response counts as delivered.
+func sendDueCallbacks(ctx context.Context, appConfig *config.ApplicationConfig, client *http.Client) error {
+	repo := appConfig.AsyncTransferRepository
+
+	due, err := repo.ClaimDueCallbacks(ctx, asyncCallbackBatchSize, asyncCallbackLease)
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		if err = sendCallback(ctx, appConfig, client, &due[i]); err != nil {
+			log.WithError(err).WithField("async_transfer_id", due[i].ID).Error("Failed to record async transfer callback outcome")
+		}
+	}
+
+	return nil
+}
+
+func sendCallback(ctx context.Context, appConfig *config.ApplicationConfig, client *http.Client, async *models.AsyncTransfer) error {
+	repo := appConfig.AsyncTransferRepository
+
+	fields := log.Fields{
+		"async_transfer_id": async.ID,
+		"callback_attempts": async.CallbackAttempts,
+	}
+
+	err := postCallback(ctx, client, appConfig.AsyncCallbackSecret, async)
+	if err == nil {
+		log.WithFields(fields).Info("Async transfer callback delivered")
+		return repo.FinishCallback(ctx, async.ID, models.CallbackStatusDelivered)
+	}
+
+	if async.CallbackAttempts < appConfig.AsyncCallbackMaxAttempts {
+		nextAttemptAt := time.Now().Add(asyncCallbackRetryBackoff << min(async.CallbackAttempts-1, 10))
+		log.WithError(err).WithFields(fields).WithField("next_attempt_at", nextAttemptAt.Format(time.RFC3339)).Warn("Async transfer callback failed, will retry")
+		return repo.RetryCallback(ctx, async.ID, nextAttemptAt)
+	}
+
+	log.WithError(err).WithFields(fields).Error("Async transfer callback failed, giving up")
+	return repo.FinishCallback(ctx, async.ID, models.CallbackStatusFailed)
+}
+
+// postCallback sends the callback of async, signed with secret when one is
+// configured so the receiver can tell it came from this service
+func postCallback(ctx context.Context, client *http.Client, secret string, async *models.AsyncTransfer) error {
+	body, err := jsoniter.Marshal(async)
+	if err != nil {
+		return fmt.Errorf("failed to encode callback: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *async.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		req.Header.Set(callbackTimestampHeader, timestamp)
+		req.Header.Set(callbackSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send callback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// standingOrderBatchSize is how many due standing orders one tick runs
+const standingOrderBatchSize = 50
+
+func startBackgroundJobs(appConfig *config.ApplicationConfig) {
+	go runPeriodically(appConfig.Ctx, "idempotency key sweeper", appConfig.IdempotencySweepInterval, func(ctx context.Context) error {
+		purged, err := appConfig.TransferRepository.PurgeExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.WithField("purged", purged).Info("Purged expired idempotency keys")
+		}
+		return nil
+	})
+
+	go runPeriodically(appConfig.Ctx, "hold expiry sweeper", appConfig.HoldExpirySweepInterval, func(ctx context.Context) error {
+		expired, err := appConfig.HoldRepository.ExpireHolds(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.WithField("expired", expired).Info("Expired holds")
+		}
+		return nil
+	})
+
+	go runPeriodically(appConfig.Ctx, "transfer approval expiry sweeper", appConfig.ApprovalExpirySweepInterval, func(ctx context.Context) error {
+		expired, err := appConfig.TransferApprovalRepository.ExpireApprovals(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.WithField("expired", expired).Info("Expired transfer approvals")
+		}
+		return nil
+	})
+
+	go runPeriodically(appConfig.Ctx, "transfer approval executor", appConfig.ApprovalExecutorPollInterval, func(ctx context.Context) error {
+		return executeStaleApprovals(ctx, appConfig)
+	})
+
+	go runPeriodically(appConfig.Ctx, "scheduled transfer executor", appConfig.ScheduledTransferPollInterval, func(ctx context.Context) error {
+		return executeDueScheduledTransfers(ctx, appConfig)
+	})
+
+	go runPeriodically(appConfig.Ctx, "standing order executor", appConfig.StandingOrderPollInterval, func(ctx context.Context) error {
+		runs, err := appConfig.StandingOrderRepository.RunDueStandingOrders(ctx, standingOrderBatchSize)
+		if err != nil {
+			return err
+		}
+		if runs > 0 {
+			log.WithField("runs", runs).Info("Ran due standing orders")
+		}
+		return nil
+	})
+
+	go runPeriodically(appConfig.Ctx, "sweep rule scheduler", appConfig.SweepRulePollInterval, func(ctx context.Context) error {
+		return runDueSweepRules(ctx, appConfig)
+	})
+
+	go runPeriodically(appConfig.Ctx, "risk rule reloader", appConfig.RiskRuleReloadInterval, func(ctx context.Context) error {
+		_, err := appConfig.RiskRuleRepository.ReloadRiskRules(ctx)
+		return err
+	})
+
+	startAsyncTransferWorkers(appConfig)
+}
+
+// runPeriodically calls job every interval until ctx is cancelled. Errors are
+// logged and the job keeps running on the next tick.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.WithFields(log.Fields{
+		"job":      name,
+		"interval": interval.String(),
+	}).Info("Background job started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.WithField("job", name).Info("Background job stopped")
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.WithError(err).WithField("job", name).Error("Background job failed")
+			}
+		}
+	}
+}
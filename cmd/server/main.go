@@ -14,20 +14,51 @@ import (
 	"github.com/Nauman-S/Internal-Transfers-System/api"
 	"github.com/Nauman-S/Internal-Transfers-System/config"
 	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	"github.com/shopspring/decimal"
 	log "github.com/sirupsen/logrus"
 )
 
 func main() {
 	log.Info("Starting payments transfers service")
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	appConfig := &config.ApplicationConfig{
-		Ctx: context.Background(),
+		Ctx:                      ctx,
+		IdempotencyKeyTTL:        getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		IdempotencySweepInterval: getEnvDuration("IDEMPOTENCY_SWEEP_INTERVAL", time.Minute),
+		HoldDefaultTTL:           getEnvDuration("HOLD_DEFAULT_TTL", 7*24*time.Hour),
+		HoldExpirySweepInterval:  getEnvDuration("HOLD_EXPIRY_SWEEP_INTERVAL", time.Minute),
+
+		ScheduledTransferPollInterval:  getEnvDuration("SCHEDULED_TRANSFER_POLL_INTERVAL", 10*time.Second),
+		ScheduledTransferMaxAttempts:   getEnvInt("SCHEDULED_TRANSFER_MAX_ATTEMPTS", 3),
+		ScheduledTransferRetryInterval: getEnvDuration("SCHEDULED_TRANSFER_RETRY_INTERVAL", 5*time.Minute),
+
+		StandingOrderPollInterval: getEnvDuration("STANDING_ORDER_POLL_INTERVAL", 30*time.Second),
+		SweepRulePollInterval:     getEnvDuration("SWEEP_RULE_POLL_INTERVAL", 30*time.Second),
+		RiskRuleReloadInterval:    getEnvDuration("RISK_RULE_RELOAD_INTERVAL", 30*time.Second),
+
+		AsyncTransferWorkers:           getEnvInt("ASYNC_TRANSFER_WORKERS", 4),
+		AsyncTransferPollInterval:      getEnvDuration("ASYNC_TRANSFER_POLL_INTERVAL", 500*time.Millisecond),
+		AsyncTransferMaxAttempts:       getEnvInt("ASYNC_TRANSFER_MAX_ATTEMPTS", 5),
+		AsyncCallbackPollInterval:      getEnvDuration("ASYNC_CALLBACK_POLL_INTERVAL", 5*time.Second),
+		AsyncCallbackMaxAttempts:       getEnvInt("ASYNC_CALLBACK_MAX_ATTEMPTS", 5),
+		AsyncCallbackSecret:            os.Getenv("ASYNC_CALLBACK_SECRET"),
+		AsyncCallbackAllowPrivateHosts: getEnvBool("ASYNC_CALLBACK_ALLOW_PRIVATE_HOSTS", false),
+
+		ApprovalThreshold:            getEnvDecimal("APPROVAL_THRESHOLD", decimal.Zero),
+		ApprovalTTL:                  getEnvDuration("APPROVAL_TTL", 24*time.Hour),
+		ApprovalExpirySweepInterval:  getEnvDuration("APPROVAL_EXPIRY_SWEEP_INTERVAL", time.Minute),
+		ApprovalExecutorPollInterval: getEnvDuration("APPROVAL_EXECUTOR_POLL_INTERVAL", 30*time.Second),
 	}
 
 	if err := initializeStorage(appConfig); err != nil {
 		log.Fatalf("failed to initialize storage: %v", err)
 	}
 
+	startBackgroundJobs(appConfig)
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", 8080),
 		Handler:      api.InitRouter(appConfig),
@@ -42,8 +73,10 @@ func main() {
 		<-sigChan
 		log.Info("Shutting down server...")
 
-		shutdownCtx, cancel := context.WithTimeout(appConfig.Ctx, 30*time.Second)
-		defer cancel()
+		cancel()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer shutdownCancel()
 
 		appConfig.DB.Close()
 
@@ -76,6 +109,12 @@ func initializeStorage(appConfig *config.ApplicationConfig) error {
 		Username: os.Getenv("DB_USER"),
 		Password: os.Getenv("DB_PASSWORD"),
 		SSLMode:  os.Getenv("DB_SSL_MODE"),
+
+		IsolationLevel: os.Getenv("DB_ISOLATION_LEVEL"),
+		LockTimeout:    getEnvDuration("DB_LOCK_TIMEOUT", 2*time.Second),
+		MaxTxRetries:   getEnvInt("DB_TX_MAX_RETRIES", 3),
+
+		TransferExecution: os.Getenv("TRANSFER_EXECUTION_MODE"),
 	})
 
 	if err != nil {
@@ -90,6 +129,51 @@ func initializeStorage(appConfig *config.ApplicationConfig) error {
 
 	appConfig.AccountRepository = storage.NewAccountRepository(db)
 	appConfig.TransferRepository = storage.NewTransferRepository(db)
+	appConfig.HoldRepository = storage.NewHoldRepository(db)
+	appConfig.ScheduledTransferRepository = storage.NewScheduledTransferRepository(db)
+	appConfig.StandingOrderRepository = storage.NewStandingOrderRepository(db)
+	appConfig.FXRateRepository = storage.NewFXRateRepository(db)
+	appConfig.FeeScheduleRepository = storage.NewFeeScheduleRepository(db)
+	appConfig.TransferApprovalRepository = storage.NewTransferApprovalRepository(db)
+	appConfig.AsyncTransferRepository = storage.NewAsyncTransferRepository(db)
+	appConfig.SweepRuleRepository = storage.NewSweepRuleRepository(db)
+	appConfig.RiskRuleRepository = storage.NewRiskRuleRepository(db)
+
+	if _, err := appConfig.RiskRuleRepository.ReloadRiskRules(appConfig.Ctx); err != nil {
+		return fmt.Errorf("failed to load risk rules: %w", err)
+	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDecimal(key string, defaultValue decimal.Decimal) decimal.Decimal {
+	value, err := decimal.NewFromString(os.Getenv(key))
+	if err != nil || value.IsNegative() {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// scheduledTransferBatchSize is how many due transfers one tick claims
+	scheduledTransferBatchSize = 50
+
+	// scheduledTransferLease is how long a claimed transfer may stay
+	// PROCESSING before another tick assumes its executor died and retries it
+	scheduledTransferLease = 5 * time.Minute
+)
+
+// retryableScheduledTransferCodes are failures that may clear up on their
+// own, such as the source being funded or an outflow window rolling on
+// before the next attempt. Anything else, like a missing account, fails the
+// schedule straight away.
+var retryableScheduledTransferCodes = map[int]bool{
+	codes.ErrSystem.Code:                true,
+	codes.ErrTransactionConflict.Code:   true,
+	codes.ErrInsufficientFunds.Code:     true,
+	codes.ErrDailyLimitExceeded.Code:    true,
+	codes.ErrWeeklyLimitExceeded.Code:   true,
+	codes.ErrMonthlyLimitExceeded.Code:  true,
+	codes.ErrVelocityLimitExceeded.Code: true,
+}
+
+// executeDueScheduledTransfers runs every due scheduled transfer through
+// ProcessTransfer and records the outcome.
+func executeDueScheduledTransfers(ctx context.Context, appConfig *config.ApplicationConfig) error {
+	repo := appConfig.ScheduledTransferRepository
+
+	due, err := repo.ClaimDueScheduledTransfers(ctx, scheduledTransferBatchSize, time.Now().Add(-scheduledTransferLease))
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		if err = executeScheduledTransfer(ctx, appConfig, &due[i]); err != nil {
+			log.WithError(err).WithField("scheduled_transfer_id", due[i].ID).Error("Failed to record scheduled transfer outcome")
+		}
+	}
+
+	return nil
+}
+
+func executeScheduledTransfer(ctx context.Context, appConfig *config.ApplicationConfig, scheduled *models.ScheduledTransfer) error {
+	repo := appConfig.ScheduledTransferRepository
+
+	transfer, sourceBalance, destBalance, err := appConfig.TransferRepository.ProcessTransfer(ctx, &models.Transfer{
+		SourceAccountID:      scheduled.SourceAccountID,
+		DestinationAccountID: scheduled.DestinationAccountID,
+		Amount:               scheduled.Amount,
+		TransferReference:    scheduled.TransferReference,
+	}, scheduledTransferIdempotencyKey(scheduled, appConfig.IdempotencyKeyTTL))
+	if err == nil {
+		log.WithFields(log.Fields{
+			"scheduled_transfer_id": scheduled.ID,
+			"transaction_id":        transfer.ID,
+			"source_balance":        sourceBalance.String(),
+			"destination_balance":   destBalance.String(),
+		}).Info("Scheduled transfer executed")
+		return repo.MarkScheduledTransferExecuted(ctx, scheduled.ID, transfer.ID)
+	}
+
+	fields := log.Fields{
+		"scheduled_transfer_id": scheduled.ID,
+		"attempts":              scheduled.Attempts,
+	}
+
+	if retryableScheduledTransferCodes[codes.GetCode(err)] && scheduled.Attempts < appConfig.ScheduledTransferMaxAttempts {
+		nextAttemptAt := time.Now().Add(appConfig.ScheduledTransferRetryInterval)
+		log.WithError(err).WithFields(fields).WithField("next_attempt_at", nextAttemptAt.Format(time.RFC3339)).Warn("Scheduled transfer attempt failed, will retry")
+		return repo.RetryScheduledTransfer(ctx, scheduled.ID, nextAttemptAt, err)
+	}
+
+	log.WithError(err).WithFields(fields).Error("Scheduled transfer failed")
+	return repo.MarkScheduledTransferFailed(ctx, scheduled.ID, err)
+}
+
+// scheduledTransferIdempotencyKey derives a key from the schedule ID, so an
+// attempt whose outcome was lost, for example because the executor crashed
+// after the commit, replays the applied transfer instead of moving the money
+// again.
+func scheduledTransferIdempotencyKey(scheduled *models.ScheduledTransfer, ttl time.Duration) *models.IdempotencyKey {
+	key := fmt.Sprintf("%s%d", models.IdempotencyKeyPrefixScheduledTransfer, scheduled.ID)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d:%s", key, scheduled.SourceAccountID, scheduled.DestinationAccountID, scheduled.Amount.String())))
+
+	return &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// sweepRuleBatchSize is how many due sweep rules one tick runs
+const sweepRuleBatchSize = 50
+
+// runDueSweepRules runs every due sweep rule through ProcessSweepRule. A
+// rule that fails with a system error is left due and tried again on the
+// next tick.
+func runDueSweepRules(ctx context.Context, appConfig *config.ApplicationConfig) error {
+	ruleIDs, err := appConfig.SweepRuleRepository.ListDueSweepRuleIDs(ctx, sweepRuleBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, ruleID := range ruleIDs {
+		rule, transfer, err := appConfig.TransferRepository.ProcessSweepRule(ctx, ruleID, appConfig.ApprovalThreshold)
+		if err != nil {
+			log.WithError(err).WithField("sweep_rule_id", ruleID).Error("Sweep rule run failed")
+			continue
+		}
+		if rule == nil {
+			continue
+		}
+
+		fields := log.Fields{
+			"sweep_rule_id": rule.ID,
+			"status":        *rule.LastRunStatus,
+			"next_run_at":   rule.NextRunAt.Format(time.RFC3339),
+		}
+		if transfer != nil {
+			fields["transaction_id"] = transfer.ID
+			fields["amount"] = transfer.Amount.String()
+		}
+		if rule.LastError != nil {
+			fields["error"] = *rule.LastError
+		}
+		log.WithFields(fields).Info("Sweep rule run recorded")
+	}
+
+	return nil
+}
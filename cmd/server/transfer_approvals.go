@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/service/transactions"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// approvalBatchSize is how many stranded approvals one tick claims
+	approvalBatchSize = 50
+
+	// approvalExecutionLease is how long an approval may stay APPROVED
+	// before the executor assumes whoever was executing it gave up. It is
+	// far above the request timeout the approving request runs under.
+	approvalExecutionLease = 5 * time.Minute
+
+	// approvalExecutionTimeout bounds one attempt at an approved transfer
+	approvalExecutionTimeout = 30 * time.Second
+)
+
+// executeStaleApprovals retries approved transfers left APPROVED, because
+// the approving request was cut off or its transfer failed on a transient
+// error, through ExecuteApprovedTransfer. A transfer that did go through
+// before the outcome was lost is replayed and linked, not moved again.
+func executeStaleApprovals(ctx context.Context, appConfig *config.ApplicationConfig) error {
+	stale, err := appConfig.TransferApprovalRepository.ClaimStaleApprovals(ctx, approvalBatchSize, time.Now().Add(-approvalExecutionLease))
+	if err != nil {
+		return err
+	}
+
+	for i := range stale {
+		attemptCtx, cancel := context.WithTimeout(ctx, approvalExecutionTimeout)
+		if _, err = transactions.ExecuteApprovedTransfer(attemptCtx, appConfig, &stale[i]); err != nil {
+			log.WithError(err).WithField("approval_id", stale[i].ID).Warn("Stale approved transfer not executed")
+		}
+		cancel()
+	}
+
+	return nil
+}
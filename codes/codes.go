@@ -50,6 +50,240 @@ var (
 		Code: 11,
 		Msg:  "destination account not found",
 	}
+	ErrIdempotencyKeyReused = CodeError{
+		Code: 12,
+		Msg:  "idempotency key already used with a different request",
+	}
+	ErrInvalidTransferID = CodeError{
+		Code: 13,
+		Msg:  "transaction ID must be a positive integer",
+	}
+	ErrTransferNotFound = CodeError{
+		Code: 14,
+		Msg:  "transaction not found",
+	}
+	ErrReverseOfReversal = CodeError{
+		Code: 15,
+		Msg:  "cannot reverse a reversal transaction",
+	}
+	ErrReversalExceedsAmount = CodeError{
+		Code: 16,
+		Msg:  "reversal amount exceeds the unreversed amount",
+	}
+	ErrInvalidTransferStatus = CodeError{
+		Code: 17,
+		Msg:  "transaction status does not allow this operation",
+	}
+
+	//Hold Codes
+	ErrInvalidHoldID = CodeError{
+		Code: 18,
+		Msg:  "hold ID must be a positive integer",
+	}
+	ErrHoldNotFound = CodeError{
+		Code: 19,
+		Msg:  "hold not found",
+	}
+	ErrHoldNotActive = CodeError{
+		Code: 20,
+		Msg:  "hold is no longer active",
+	}
+	ErrCaptureExceedsHold = CodeError{
+		Code: 21,
+		Msg:  "capture amount exceeds the held amount",
+	}
+
+	//Scheduled Transfer Codes
+	ErrInvalidScheduledTransferID = CodeError{
+		Code: 22,
+		Msg:  "scheduled transfer ID must be a positive integer",
+	}
+	ErrScheduledTransferNotFound = CodeError{
+		Code: 23,
+		Msg:  "scheduled transfer not found",
+	}
+	ErrScheduledTransferNotPending = CodeError{
+		Code: 24,
+		Msg:  "scheduled transfer is no longer pending",
+	}
+
+	//Standing Order Codes
+	ErrInvalidStandingOrderID = CodeError{
+		Code: 25,
+		Msg:  "standing order ID must be a positive integer",
+	}
+	ErrStandingOrderNotFound = CodeError{
+		Code: 26,
+		Msg:  "standing order not found",
+	}
+	ErrStandingOrderNotActive = CodeError{
+		Code: 27,
+		Msg:  "standing order is no longer active",
+	}
+
+	//FX Rate Codes
+	ErrFXRateUnavailable = CodeError{
+		Code: 28,
+		Msg:  "no exchange rate is available for the currency pair",
+	}
+	ErrInvalidFXRateID = CodeError{
+		Code: 29,
+		Msg:  "FX rate ID must be a positive integer",
+	}
+	ErrFXRateNotFound = CodeError{
+		Code: 30,
+		Msg:  "FX rate not found",
+	}
+
+	//Fee Schedule Codes
+	ErrInvalidFeeScheduleID = CodeError{
+		Code: 31,
+		Msg:  "fee schedule ID must be a positive integer",
+	}
+	ErrFeeScheduleNotFound = CodeError{
+		Code: 32,
+		Msg:  "fee schedule not found",
+	}
+	ErrFeeScheduleNotActive = CodeError{
+		Code: 33,
+		Msg:  "fee schedule is no longer active",
+	}
+
+	//Account Limit Codes
+	ErrTransactionLimitExceeded = CodeError{
+		Code: 34,
+		Msg:  "amount exceeds the per-transaction limit",
+	}
+	ErrDailyLimitExceeded = CodeError{
+		Code: 35,
+		Msg:  "amount exceeds the daily outflow limit",
+	}
+	ErrWeeklyLimitExceeded = CodeError{
+		Code: 36,
+		Msg:  "amount exceeds the weekly outflow limit",
+	}
+	ErrMonthlyLimitExceeded = CodeError{
+		Code: 37,
+		Msg:  "amount exceeds the monthly outflow limit",
+	}
+	ErrVelocityLimitExceeded = CodeError{
+		Code: 38,
+		Msg:  "too many transfers from the account",
+	}
+
+	//Transfer Approval Codes
+	ErrInvalidApprovalID = CodeError{
+		Code: 39,
+		Msg:  "approval ID must be a positive integer",
+	}
+	ErrApprovalNotFound = CodeError{
+		Code: 40,
+		Msg:  "transfer approval not found",
+	}
+	ErrApprovalNotPending = CodeError{
+		Code: 41,
+		Msg:  "transfer approval is no longer pending",
+	}
+	ErrPrincipalRequired = CodeError{
+		Code: 42,
+		Msg:  "X-Principal header is required",
+	}
+	ErrSelfApproval = CodeError{
+		Code: 43,
+		Msg:  "a transfer cannot be approved or rejected by the principal who requested it",
+	}
+	ErrApprovalRequired = CodeError{
+		Code: 44,
+		Msg:  "transfers above the approval threshold must be submitted on their own through POST /transactions",
+	}
+
+	//Transfer Reference Codes
+	ErrDuplicateClientReference = CodeError{
+		Code: 45,
+		Msg:  "client reference is already used by another transfer from the source account",
+	}
+
+	//Database Transaction Codes
+	ErrTransactionConflict = CodeError{
+		Code: 46,
+		Msg:  "the transfer kept conflicting with concurrent transfers, please retry",
+	}
+
+	//Hot Account Codes
+	ErrInvalidBalanceShards = CodeError{
+		Code: 47,
+		Msg:  "invalid number of balance shards",
+	}
+
+	//Async Transfer Codes
+	ErrInvalidAsyncTransferID = CodeError{
+		Code: 48,
+		Msg:  "async transfer ID must be a positive integer",
+	}
+	ErrAsyncTransferNotFound = CodeError{
+		Code: 49,
+		Msg:  "async transfer not found",
+	}
+
+	//Split Payment Codes
+	ErrInvalidSplitPaymentID = CodeError{
+		Code: 50,
+		Msg:  "split payment ID must be a positive integer",
+	}
+	ErrSplitPaymentNotFound = CodeError{
+		Code: 51,
+		Msg:  "split payment not found",
+	}
+
+	//Sweep Rule Codes
+	ErrInvalidSweepRuleID = CodeError{
+		Code: 52,
+		Msg:  "sweep rule ID must be a positive integer",
+	}
+	ErrSweepRuleNotFound = CodeError{
+		Code: 53,
+		Msg:  "sweep rule not found",
+	}
+	ErrSweepRuleNotActive = CodeError{
+		Code: 54,
+		Msg:  "sweep rule is no longer active",
+	}
+	ErrSweepCurrencyMismatch = CodeError{
+		Code: 55,
+		Msg:  "sweep rule accounts must hold the same currency",
+	}
+	ErrSweepAboveApprovalThreshold = CodeError{
+		Code: 62,
+		Msg:  "sweep amount is above the approval threshold",
+	}
+
+	//Risk Rule Codes
+	ErrRiskRuleDenied = CodeError{
+		Code: 56,
+		Msg:  "transfer denied by a risk rule",
+	}
+	ErrInvalidRiskRuleID = CodeError{
+		Code: 57,
+		Msg:  "risk rule ID must be a positive integer",
+	}
+	ErrRiskRuleNotFound = CodeError{
+		Code: 58,
+		Msg:  "risk rule not found",
+	}
+	ErrRiskRuleNameTaken = CodeError{
+		Code: 59,
+		Msg:  "a risk rule with this name already exists",
+	}
+	//Conditional Transfer Codes
+	ErrPreconditionFailed = CodeError{
+		Code: 60,
+		Msg:  "source account changed since it was read",
+	}
+	//Ledger Codes
+	ErrJournalNotFound = CodeError{
+		Code: 61,
+		Msg:  "transaction has no journal",
+	}
 )
 
 type CodeError struct {
@@ -2,7 +2,10 @@ package config
 
 import (
 	"context"
+	"time"
+
 	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	"github.com/shopspring/decimal"
 )
 
 type ApplicationConfig struct {
@@ -10,4 +13,67 @@ type ApplicationConfig struct {
 	DB                 *storage.DB
 	AccountRepository  *storage.AccountRepository
 	TransferRepository *storage.TransferRepository
-}
\ No newline at end of file
+	HoldRepository     *storage.HoldRepository
+
+	ScheduledTransferRepository *storage.ScheduledTransferRepository
+	StandingOrderRepository     *storage.StandingOrderRepository
+	FXRateRepository            *storage.FXRateRepository
+	FeeScheduleRepository       *storage.FeeScheduleRepository
+	TransferApprovalRepository  *storage.TransferApprovalRepository
+	AsyncTransferRepository     *storage.AsyncTransferRepository
+	SweepRuleRepository         *storage.SweepRuleRepository
+	RiskRuleRepository          *storage.RiskRuleRepository
+
+	// IdempotencyKeyTTL is how long an Idempotency-Key is honoured before the
+	// sweeper purges it and the key may be reused.
+	IdempotencyKeyTTL        time.Duration
+	IdempotencySweepInterval time.Duration
+
+	// HoldDefaultTTL is used when an authorization does not say when it expires
+	HoldDefaultTTL          time.Duration
+	HoldExpirySweepInterval time.Duration
+
+	// ScheduledTransferMaxAttempts bounds how often a scheduled transfer is
+	// tried before it is marked FAILED. Retries are spaced by
+	// ScheduledTransferRetryInterval.
+	ScheduledTransferPollInterval  time.Duration
+	ScheduledTransferMaxAttempts   int
+	ScheduledTransferRetryInterval time.Duration
+
+	StandingOrderPollInterval time.Duration
+	SweepRulePollInterval     time.Duration
+
+	// RiskRuleReloadInterval is how often the enabled risk rules are read
+	// back from the database, so rules changed there take effect without a
+	// restart.
+	RiskRuleReloadInterval time.Duration
+
+	// AsyncTransferWorkers transfers queued with async run at once, each
+	// polling for work every AsyncTransferPollInterval. A transfer whose
+	// attempts keep failing on a database error is marked FAILED after
+	// AsyncTransferMaxAttempts. Callbacks are sent every
+	// AsyncCallbackPollInterval and given up after AsyncCallbackMaxAttempts.
+	// They are signed with AsyncCallbackSecret when it is set, and only sent
+	// to public addresses unless AsyncCallbackAllowPrivateHosts is set.
+	AsyncTransferWorkers           int
+	AsyncTransferPollInterval      time.Duration
+	AsyncTransferMaxAttempts       int
+	AsyncCallbackPollInterval      time.Duration
+	AsyncCallbackMaxAttempts       int
+	AsyncCallbackSecret            string
+	AsyncCallbackAllowPrivateHosts bool
+
+	// ApprovalThreshold is the amount above which a transfer waits for a
+	// second principal to approve it; zero turns approvals off. Pending
+	// approvals expire after ApprovalTTL. Approved transfers whose execution
+	// was interrupted are retried every ApprovalExecutorPollInterval.
+	ApprovalThreshold            decimal.Decimal
+	ApprovalTTL                  time.Duration
+	ApprovalExpirySweepInterval  time.Duration
+	ApprovalExecutorPollInterval time.Duration
+}
+
+// RequiresApproval reports whether moving amount needs maker-checker approval
+func (c *ApplicationConfig) RequiresApproval(amount decimal.Decimal) bool {
+	return c.ApprovalThreshold.IsPositive() && amount.GreaterThan(c.ApprovalThreshold)
+}
@@ -0,0 +1,15 @@
+-- Idempotency keys for POST /transactions, written in the same database
+-- transaction as the transfer row they protect
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key VARCHAR(255) PRIMARY KEY,
+    request_hash CHAR(64) NOT NULL,
+    transaction_id INTEGER,
+    source_balance DECIMAL(20,8),
+    destination_balance DECIMAL(20,8),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+-- Create index for the expired key sweeper
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,6 @@
+-- Create indexes for transfer history queries, newest first per account
+CREATE INDEX IF NOT EXISTS idx_transactions_source_account ON transactions(source_account_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination_account ON transactions(destination_account_id, id DESC);
+
+-- Create index for created_at range filters
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions(created_at);
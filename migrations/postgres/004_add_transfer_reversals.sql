@@ -0,0 +1,5 @@
+-- Link compensating transfers to the transfer they reverse
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of INTEGER REFERENCES transactions(id);
+
+-- Create index for summing the reversals of a transfer
+CREATE INDEX IF NOT EXISTS idx_transactions_reversal_of ON transactions(reversal_of) WHERE reversal_of IS NOT NULL;
@@ -0,0 +1,31 @@
+-- Persist the transfer lifecycle. Existing rows were all applied immediately.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'COMPLETED'
+    CHECK (status IN ('PENDING', 'COMPLETED', 'FAILED', 'REVERSED', 'CANCELLED'));
+
+-- Code from the codes package explaining why a FAILED transfer was declined
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS failure_code INTEGER;
+
+-- Create index for status filters in transfer history
+CREATE INDEX IF NOT EXISTS idx_transactions_status ON transactions(status);
+
+-- Reject status changes the state machine does not allow, whatever the client
+CREATE OR REPLACE FUNCTION check_transaction_status_transition() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.status = OLD.status THEN
+        RETURN NEW;
+    END IF;
+
+    IF (OLD.status = 'PENDING' AND NEW.status IN ('COMPLETED', 'FAILED', 'CANCELLED'))
+        OR (OLD.status = 'COMPLETED' AND NEW.status = 'REVERSED') THEN
+        RETURN NEW;
+    END IF;
+
+    RAISE EXCEPTION 'invalid transaction status transition from % to %', OLD.status, NEW.status
+        USING ERRCODE = 'check_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_transactions_status_transition ON transactions;
+CREATE TRIGGER trg_transactions_status_transition
+    BEFORE UPDATE OF status ON transactions
+    FOR EACH ROW EXECUTE FUNCTION check_transaction_status_transition();
@@ -0,0 +1,23 @@
+-- Create holds table (two-phase authorize / capture / void)
+CREATE TABLE IF NOT EXISTS holds (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount > 0),
+    captured_amount DECIMAL(20,8),
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE'
+        CHECK (status IN ('ACTIVE', 'CAPTURED', 'VOIDED', 'EXPIRED')),
+    transaction_id INTEGER,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (account_id) REFERENCES accounts(id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+-- Create index for summing the active holds of an account (available balance)
+CREATE INDEX IF NOT EXISTS idx_holds_active_account ON holds(account_id) WHERE status = 'ACTIVE';
+
+-- Create index for the hold expiry sweeper
+CREATE INDEX IF NOT EXISTS idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'ACTIVE';
@@ -0,0 +1,12 @@
+-- Create transfer_batches table (multi-leg transfers committed atomically)
+CREATE TABLE IF NOT EXISTS transfer_batches (
+    id SERIAL PRIMARY KEY,
+    leg_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Link each leg to its batch
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS batch_id INTEGER REFERENCES transfer_batches(id);
+
+-- Create index for listing the legs of a batch
+CREATE INDEX IF NOT EXISTS idx_transactions_batch_id ON transactions(batch_id) WHERE batch_id IS NOT NULL;
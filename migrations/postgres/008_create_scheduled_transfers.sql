@@ -0,0 +1,33 @@
+-- Create scheduled_transfers table (future-dated transfers run by the executor)
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+    id SERIAL PRIMARY KEY,
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount > 0),
+    execute_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'PROCESSING', 'EXECUTED', 'FAILED', 'CANCELLED')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error_code INTEGER,
+    last_error VARCHAR(255),
+    transaction_id INTEGER,
+    idempotency_key VARCHAR(255) UNIQUE,
+    request_hash CHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    CHECK (source_account_id != destination_account_id)
+);
+
+-- Create index for the executor picking up due transfers
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_due ON scheduled_transfers(next_attempt_at) WHERE status = 'PENDING';
+
+-- Create index for recovering transfers left PROCESSING by a crashed executor
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_processing ON scheduled_transfers(updated_at) WHERE status = 'PROCESSING';
+
+-- Create indexes for listing the scheduled transfers of an account
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_source ON scheduled_transfers(source_account_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_scheduled_transfers_destination ON scheduled_transfers(destination_account_id, id DESC);
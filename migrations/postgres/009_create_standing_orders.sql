@@ -0,0 +1,51 @@
+-- Create standing_orders table (recurring transfers between two accounts)
+CREATE TABLE IF NOT EXISTS standing_orders (
+    id SERIAL PRIMARY KEY,
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount > 0),
+    frequency VARCHAR(16) NOT NULL
+        CHECK (frequency IN ('INTERVAL', 'DAILY', 'WEEKLY', 'MONTHLY', 'END_OF_MONTH')),
+    interval_seconds INTEGER CHECK (interval_seconds > 0),
+    day_of_week INTEGER CHECK (day_of_week BETWEEN 0 AND 6),
+    day_of_month INTEGER CHECK (day_of_month BETWEEN 1 AND 31),
+    start_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_at TIMESTAMP WITH TIME ZONE,
+    max_runs INTEGER CHECK (max_runs > 0),
+    executed_runs INTEGER NOT NULL DEFAULT 0,
+    failure_policy VARCHAR(8) NOT NULL DEFAULT 'SKIP' CHECK (failure_policy IN ('SKIP', 'RETRY')),
+    max_retries INTEGER NOT NULL DEFAULT 0 CHECK (max_retries >= 0),
+    retry_interval_seconds INTEGER NOT NULL DEFAULT 3600 CHECK (retry_interval_seconds > 0),
+    retry_count INTEGER NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'COMPLETED', 'CANCELLED')),
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(id),
+    CHECK (source_account_id != destination_account_id)
+);
+
+-- Create index for the executor picking up due orders
+CREATE INDEX IF NOT EXISTS idx_standing_orders_due ON standing_orders(next_attempt_at) WHERE status = 'ACTIVE';
+
+-- Create indexes for listing the standing orders of an account
+CREATE INDEX IF NOT EXISTS idx_standing_orders_source ON standing_orders(source_account_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_standing_orders_destination ON standing_orders(destination_account_id, id DESC);
+
+-- Create standing_order_runs table (execution history of each order)
+CREATE TABLE IF NOT EXISTS standing_order_runs (
+    id SERIAL PRIMARY KEY,
+    standing_order_id INTEGER NOT NULL REFERENCES standing_orders(id),
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempt INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('SUCCEEDED', 'FAILED', 'SKIPPED')),
+    transaction_id INTEGER REFERENCES transactions(id),
+    error_code INTEGER,
+    error VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create index for listing the runs of an order
+CREATE INDEX IF NOT EXISTS idx_standing_order_runs_order ON standing_order_runs(standing_order_id, id DESC);
@@ -0,0 +1,33 @@
+-- ISO-4217 currency of every account. Existing balances were all in USD.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD'
+    CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Create fx_rates table. A rate converts one unit of base_currency into
+-- quote_currency; the newest rate already in effect is used for transfers.
+CREATE TABLE IF NOT EXISTS fx_rates (
+    id SERIAL PRIMARY KEY,
+    base_currency CHAR(3) NOT NULL CHECK (base_currency ~ '^[A-Z]{3}$'),
+    quote_currency CHAR(3) NOT NULL CHECK (quote_currency ~ '^[A-Z]{3}$'),
+    rate DECIMAL(24,12) NOT NULL CHECK (rate > 0),
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (base_currency != quote_currency)
+);
+
+-- Create index for finding the current rate of a pair
+CREATE INDEX IF NOT EXISTS idx_fx_rates_pair ON fx_rates(base_currency, quote_currency, effective_at DESC);
+
+-- Record both sides of a transfer. amount stays in the source currency;
+-- destination_amount is what the destination was credited.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS source_currency CHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_currency CHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS destination_amount DECIMAL(20,8);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate_id INTEGER REFERENCES fx_rates(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate DECIMAL(24,12);
+
+UPDATE transactions SET source_currency = 'USD', destination_currency = 'USD', destination_amount = amount
+WHERE destination_amount IS NULL;
+
+ALTER TABLE transactions ALTER COLUMN source_currency SET NOT NULL;
+ALTER TABLE transactions ALTER COLUMN destination_currency SET NOT NULL;
+ALTER TABLE transactions ALTER COLUMN destination_amount SET NOT NULL;
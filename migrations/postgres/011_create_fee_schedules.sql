@@ -0,0 +1,41 @@
+-- Account types select the fee schedule of accounts that have none of their own
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS account_type VARCHAR(32) NOT NULL DEFAULT 'STANDARD'
+    CHECK (account_type ~ '^[A-Z0-9_]+$');
+
+-- Create fee_schedules table. A schedule applies either to one account or to
+-- every account of a type, and at most one of each is active at a time.
+CREATE TABLE IF NOT EXISTS fee_schedules (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER REFERENCES accounts(id),
+    account_type VARCHAR(32),
+    fee_type VARCHAR(16) NOT NULL CHECK (fee_type IN ('FLAT', 'PERCENTAGE', 'TIERED')),
+    flat_amount DECIMAL(20,8) CHECK (flat_amount >= 0),
+    percentage DECIMAL(9,6) CHECK (percentage >= 0 AND percentage <= 100),
+    tiers JSONB,
+    min_fee DECIMAL(20,8) CHECK (min_fee >= 0),
+    max_fee DECIMAL(20,8) CHECK (max_fee >= 0),
+    revenue_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK ((account_id IS NULL) != (account_type IS NULL)),
+    CHECK (fee_type != 'FLAT' OR flat_amount IS NOT NULL),
+    CHECK (fee_type != 'PERCENTAGE' OR percentage IS NOT NULL),
+    CHECK (fee_type != 'TIERED' OR jsonb_typeof(tiers) = 'array'),
+    CHECK (min_fee IS NULL OR max_fee IS NULL OR min_fee <= max_fee)
+);
+
+-- Create indexes allowing one active schedule per account and per type
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fee_schedules_active_account ON fee_schedules(account_id)
+    WHERE active AND account_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_fee_schedules_active_account_type ON fee_schedules(account_type)
+    WHERE active AND account_type IS NOT NULL;
+
+-- Record the fee charged on each transfer as its own line, next to the
+-- schedule that priced it and the account it was credited to
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee DECIMAL(20,8) NOT NULL DEFAULT 0 CHECK (fee >= 0);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_schedule_id INTEGER REFERENCES fee_schedules(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_account_id INTEGER REFERENCES accounts(id);
+
+-- Create index for fee revenue reporting
+CREATE INDEX IF NOT EXISTS idx_transactions_fee_account_id ON transactions(fee_account_id) WHERE fee_account_id IS NOT NULL;
@@ -0,0 +1,18 @@
+-- Create account_limits table. Every limit is optional; NULL means no limit.
+-- Caps are in the account's currency and cover outgoing transfer amounts
+-- over rolling windows ending at the time of the transfer.
+CREATE TABLE IF NOT EXISTS account_limits (
+    account_id INTEGER PRIMARY KEY REFERENCES accounts(id),
+    max_transaction_amount DECIMAL(20,8) CHECK (max_transaction_amount > 0),
+    daily_limit DECIMAL(20,8) CHECK (daily_limit > 0),
+    weekly_limit DECIMAL(20,8) CHECK (weekly_limit > 0),
+    monthly_limit DECIMAL(20,8) CHECK (monthly_limit > 0),
+    velocity_count INTEGER CHECK (velocity_count > 0),
+    velocity_window_seconds INTEGER CHECK (velocity_window_seconds > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK ((velocity_count IS NULL) = (velocity_window_seconds IS NULL))
+);
+
+-- Create index for summing an account's recent outflows
+CREATE INDEX IF NOT EXISTS idx_transactions_source_created_at ON transactions(source_account_id, created_at);
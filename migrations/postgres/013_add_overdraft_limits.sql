@@ -0,0 +1,6 @@
+-- Overdraft limits let an account's balance go negative, down to
+-- -overdraft_limit. Accounts default to no overdraft.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS overdraft_limit DECIMAL(20,8) NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+
+-- Create index for listing overdrawn accounts
+CREATE INDEX IF NOT EXISTS idx_accounts_overdrawn ON accounts(id) WHERE balance < 0;
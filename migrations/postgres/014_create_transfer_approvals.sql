@@ -0,0 +1,48 @@
+-- Create transfer_approvals table. Transfers above the approval threshold
+-- wait here until a second principal approves or rejects them, or they
+-- expire. Approved transfers link the transaction they executed as.
+CREATE TABLE IF NOT EXISTS transfer_approvals (
+    id SERIAL PRIMARY KEY,
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount > 0),
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED', 'EXPIRED', 'EXECUTED', 'FAILED')),
+    requested_by VARCHAR(255) NOT NULL,
+    decided_by VARCHAR(255),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    transaction_id INTEGER,
+    last_error_code INTEGER,
+    last_error VARCHAR(255),
+    idempotency_key VARCHAR(255) UNIQUE,
+    request_hash CHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    CHECK (source_account_id != destination_account_id),
+    CHECK (decided_by IS NULL OR decided_by != requested_by)
+);
+
+-- Create index for the sweeper expiring pending approvals
+CREATE INDEX IF NOT EXISTS idx_transfer_approvals_pending ON transfer_approvals(expires_at) WHERE status = 'PENDING';
+
+-- Create index for listing the approvals of a source account
+CREATE INDEX IF NOT EXISTS idx_transfer_approvals_source ON transfer_approvals(source_account_id, id DESC);
+
+-- Create transfer_approval_events table, the append-only audit trail of every
+-- request, decision and outcome. principal is NULL for system events such as
+-- expiry.
+CREATE TABLE IF NOT EXISTS transfer_approval_events (
+    id SERIAL PRIMARY KEY,
+    approval_id INTEGER NOT NULL REFERENCES transfer_approvals(id),
+    event VARCHAR(16) NOT NULL
+        CHECK (event IN ('REQUESTED', 'APPROVED', 'REJECTED', 'EXPIRED', 'EXECUTED', 'FAILED')),
+    principal VARCHAR(255),
+    comment VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create index for reading the trail of an approval
+CREATE INDEX IF NOT EXISTS idx_transfer_approval_events_approval ON transfer_approval_events(approval_id, id);
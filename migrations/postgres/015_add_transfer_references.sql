@@ -0,0 +1,21 @@
+-- Transfers can carry a free-text memo, a client reference correlating them
+-- with the caller's own records, such as an invoice ID, and a JSON object of
+-- metadata. Scheduled transfers and approvals keep them until they execute.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS memo VARCHAR(255);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS client_reference VARCHAR(128);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS metadata JSONB CHECK (jsonb_typeof(metadata) = 'object');
+
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS memo VARCHAR(255);
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS client_reference VARCHAR(128);
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS metadata JSONB CHECK (jsonb_typeof(metadata) = 'object');
+
+ALTER TABLE transfer_approvals ADD COLUMN IF NOT EXISTS memo VARCHAR(255);
+ALTER TABLE transfer_approvals ADD COLUMN IF NOT EXISTS client_reference VARCHAR(128);
+ALTER TABLE transfer_approvals ADD COLUMN IF NOT EXISTS metadata JSONB CHECK (jsonb_typeof(metadata) = 'object');
+
+-- A client reference is unique among the transfers of a source account.
+-- Declined attempts are left out so a declined transfer can be retried under
+-- the same reference.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transactions_client_reference
+    ON transactions(source_account_id, client_reference)
+    WHERE client_reference IS NOT NULL AND status != 'FAILED';
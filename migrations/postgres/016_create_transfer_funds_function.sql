@@ -0,0 +1,101 @@
+-- transfer_funds applies a plain transfer in a single statement, so a client
+-- needs one round trip inside its transaction instead of two locks, two
+-- updates and an insert. Both accounts are locked in ascending ID
+-- order, like the locking path, so the two paths cannot deadlock each other.
+--
+-- Only same-currency transfers from accounts without account limits or an
+-- active fee schedule are applied; anything else reports INELIGIBLE without
+-- changing a row, and the caller falls back to the locking path. A transfer
+-- the source cannot fund is recorded as FAILED with p_failure_code, as the
+-- locking path records declines. Under READ COMMITTED every statement of the
+-- function takes a fresh snapshot, so holds committed while waiting for the
+-- locks are seen. Under REPEATABLE READ or SERIALIZABLE the statements share
+-- the transaction's snapshot, and a row changed while its lock was awaited
+-- fails the transaction with a serialization error for the caller to retry.
+CREATE OR REPLACE FUNCTION transfer_funds(
+    p_source_account_id INTEGER,
+    p_destination_account_id INTEGER,
+    p_amount DECIMAL(20,8),
+    p_memo VARCHAR(255),
+    p_client_reference VARCHAR(128),
+    p_metadata JSONB,
+    p_failure_code INTEGER
+) RETURNS TABLE (
+    outcome VARCHAR(32),
+    transaction_id INTEGER,
+    currency CHAR(3),
+    source_balance DECIMAL(20,8),
+    destination_balance DECIMAL(20,8),
+    created_at TIMESTAMP WITH TIME ZONE
+) AS $$
+DECLARE
+    v_source accounts%ROWTYPE;
+    v_dest accounts%ROWTYPE;
+    v_held DECIMAL(20,8);
+BEGIN
+    PERFORM 1 FROM accounts a
+    WHERE a.id IN (p_source_account_id, p_destination_account_id)
+    ORDER BY a.id
+    FOR UPDATE;
+
+    SELECT * INTO v_source FROM accounts a WHERE a.id = p_source_account_id;
+    IF NOT FOUND THEN
+        outcome := 'SOURCE_NOT_FOUND';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    SELECT * INTO v_dest FROM accounts a WHERE a.id = p_destination_account_id;
+    IF NOT FOUND THEN
+        outcome := 'DESTINATION_NOT_FOUND';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    IF v_source.currency <> v_dest.currency
+        OR EXISTS (SELECT 1 FROM account_limits l WHERE l.account_id = v_source.id)
+        OR EXISTS (
+            SELECT 1 FROM fee_schedules fs
+            WHERE fs.active
+                AND (fs.account_id = v_source.id OR (fs.account_id IS NULL AND fs.account_type = v_source.account_type))
+        ) THEN
+        outcome := 'INELIGIBLE';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    SELECT COALESCE(SUM(h.amount), 0) INTO v_held
+    FROM holds h
+    WHERE h.account_id = v_source.id AND h.status = 'ACTIVE' AND h.expires_at > NOW();
+
+    currency := v_source.currency;
+
+    IF v_source.balance - v_held + v_source.overdraft_limit < p_amount THEN
+        INSERT INTO transactions (source_account_id, destination_account_id, amount, source_currency, destination_currency,
+            destination_amount, status, failure_code, memo, client_reference, metadata, created_at, updated_at)
+        VALUES (v_source.id, v_dest.id, p_amount, v_source.currency, v_dest.currency,
+            p_amount, 'FAILED', p_failure_code, p_memo, p_client_reference, p_metadata, NOW(), NOW());
+
+        outcome := 'INSUFFICIENT_FUNDS';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    UPDATE accounts a SET balance = a.balance - p_amount, updated_at = NOW()
+    WHERE a.id = v_source.id
+    RETURNING a.balance INTO source_balance;
+
+    UPDATE accounts a SET balance = a.balance + p_amount, updated_at = NOW()
+    WHERE a.id = v_dest.id
+    RETURNING a.balance INTO destination_balance;
+
+    INSERT INTO transactions (source_account_id, destination_account_id, amount, source_currency, destination_currency,
+        destination_amount, fee, status, memo, client_reference, metadata, created_at, updated_at)
+    VALUES (v_source.id, v_dest.id, p_amount, v_source.currency, v_dest.currency,
+        p_amount, 0, 'COMPLETED', p_memo, p_client_reference, p_metadata, NOW(), NOW())
+    RETURNING transactions.id, transactions.created_at INTO transaction_id, created_at;
+
+    outcome := 'COMPLETED';
+    RETURN NEXT;
+END;
+$$ LANGUAGE plpgsql;
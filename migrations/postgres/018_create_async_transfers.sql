@@ -0,0 +1,45 @@
+-- Create async_transfers table (transfers accepted with 202 and applied by
+-- the async transfer workers). A QUEUED transfer is claimed once
+-- next_attempt_at has passed; attempts that may succeed later requeue it.
+-- When callback_url is set the final state is POSTed there, retried while
+-- callback_status is PENDING.
+CREATE TABLE IF NOT EXISTS async_transfers (
+    id SERIAL PRIMARY KEY,
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount > 0),
+    status VARCHAR(16) NOT NULL DEFAULT 'QUEUED'
+        CHECK (status IN ('QUEUED', 'PROCESSING', 'COMPLETED', 'FAILED')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error_code INTEGER,
+    last_error VARCHAR(255),
+    transaction_id INTEGER,
+    source_balance DECIMAL(20,8),
+    destination_balance DECIMAL(20,8),
+    callback_url VARCHAR(2048),
+    callback_status VARCHAR(16) CHECK (callback_status IN ('PENDING', 'DELIVERED', 'FAILED')),
+    callback_attempts INTEGER NOT NULL DEFAULT 0,
+    callback_next_attempt_at TIMESTAMP WITH TIME ZONE,
+    idempotency_key VARCHAR(255) UNIQUE,
+    request_hash CHAR(64),
+    memo VARCHAR(255),
+    client_reference VARCHAR(128),
+    metadata JSONB CHECK (jsonb_typeof(metadata) = 'object'),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    CHECK (source_account_id != destination_account_id),
+    CHECK ((callback_url IS NULL) = (callback_status IS NULL))
+);
+
+-- Create index for the workers picking up queued transfers
+CREATE INDEX IF NOT EXISTS idx_async_transfers_queued ON async_transfers(next_attempt_at) WHERE status = 'QUEUED';
+
+-- Create index for recovering transfers left PROCESSING by a crashed worker
+CREATE INDEX IF NOT EXISTS idx_async_transfers_processing ON async_transfers(updated_at) WHERE status = 'PROCESSING';
+
+-- Create index for the callback sender picking up undelivered callbacks
+CREATE INDEX IF NOT EXISTS idx_async_transfers_callbacks ON async_transfers(callback_next_attempt_at) WHERE callback_status = 'PENDING';
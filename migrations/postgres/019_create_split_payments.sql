@@ -0,0 +1,34 @@
+-- Create split_payments table (one debit of a source shared between several
+-- destinations). The source is debited amount plus fee once; each
+-- destination's share is a transaction linked through split_payment_id.
+-- allocation records whether the shares were sent as amounts or percentages.
+CREATE TABLE IF NOT EXISTS split_payments (
+    id SERIAL PRIMARY KEY,
+    source_account_id INTEGER NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    allocation VARCHAR(16) NOT NULL CHECK (allocation IN ('AMOUNT', 'PERCENTAGE')),
+    leg_count INTEGER NOT NULL CHECK (leg_count > 0),
+    fee DECIMAL(20,8) NOT NULL DEFAULT 0,
+    fee_schedule_id INTEGER,
+    fee_account_id INTEGER,
+    memo VARCHAR(255),
+    client_reference VARCHAR(128),
+    metadata JSONB CHECK (jsonb_typeof(metadata) = 'object'),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (fee_schedule_id) REFERENCES fee_schedules(id),
+    FOREIGN KEY (fee_account_id) REFERENCES accounts(id)
+);
+
+-- A split's client reference is unique among the splits of its source, as
+-- transfer client references are
+CREATE UNIQUE INDEX IF NOT EXISTS idx_split_payments_client_reference
+    ON split_payments(source_account_id, client_reference)
+    WHERE client_reference IS NOT NULL;
+
+-- Link each leg to its split payment
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS split_payment_id INTEGER REFERENCES split_payments(id);
+
+-- Create index for listing the legs of a split payment
+CREATE INDEX IF NOT EXISTS idx_transactions_split_payment_id ON transactions(split_payment_id) WHERE split_payment_id IS NOT NULL;
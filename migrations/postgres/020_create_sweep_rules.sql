@@ -0,0 +1,41 @@
+-- Create sweep_rules table (operator rules moving balance between two accounts on a schedule)
+CREATE TABLE IF NOT EXISTS sweep_rules (
+    id SERIAL PRIMARY KEY,
+    rule_type VARCHAR(16) NOT NULL CHECK (rule_type IN ('SWEEP_EXCESS', 'TOP_UP')),
+    source_account_id INTEGER NOT NULL,
+    destination_account_id INTEGER NOT NULL,
+    threshold DECIMAL(20,8) NOT NULL CHECK (threshold >= 0),
+    min_amount DECIMAL(20,8) CHECK (min_amount > 0),
+    frequency VARCHAR(16) NOT NULL
+        CHECK (frequency IN ('INTERVAL', 'DAILY', 'WEEKLY', 'MONTHLY', 'END_OF_MONTH')),
+    interval_seconds INTEGER CHECK (interval_seconds > 0),
+    day_of_week INTEGER CHECK (day_of_week BETWEEN 0 AND 6),
+    day_of_month INTEGER CHECK (day_of_month BETWEEN 1 AND 31),
+    start_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'CANCELLED')),
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_status VARCHAR(16) CHECK (last_run_status IN ('SWEPT', 'NOTHING_TO_SWEEP', 'FAILED')),
+    last_transaction_id INTEGER REFERENCES transactions(id),
+    last_error_code INTEGER,
+    last_error VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    FOREIGN KEY (source_account_id) REFERENCES accounts(id),
+    FOREIGN KEY (destination_account_id) REFERENCES accounts(id),
+    CHECK (source_account_id != destination_account_id)
+);
+
+-- Create index for the scheduler picking up due rules
+CREATE INDEX IF NOT EXISTS idx_sweep_rules_due ON sweep_rules(next_run_at) WHERE status = 'ACTIVE';
+
+-- Create indexes for listing the sweep rules of an account
+CREATE INDEX IF NOT EXISTS idx_sweep_rules_source ON sweep_rules(source_account_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_sweep_rules_destination ON sweep_rules(destination_account_id, id DESC);
+
+-- Tag each transfer with the sweep rule that generated it
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS sweep_rule_id INTEGER REFERENCES sweep_rules(id);
+
+-- Create index for listing the transfers of a sweep rule
+CREATE INDEX IF NOT EXISTS idx_transactions_sweep_rule_id ON transactions(sweep_rule_id) WHERE sweep_rule_id IS NOT NULL;
+
@@ -0,0 +1,38 @@
+-- Create risk_rules table (declarative checks run on a transfer before it commits)
+CREATE TABLE IF NOT EXISTS risk_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    rule_type VARCHAR(20) NOT NULL
+        CHECK (rule_type IN ('AMOUNT_THRESHOLD', 'NEW_ACCOUNT_AGE', 'BLOCKLIST', 'ROUND_AMOUNT')),
+    action VARCHAR(8) NOT NULL CHECK (action IN ('ALLOW', 'DENY', 'FLAG')),
+    priority INTEGER NOT NULL DEFAULT 100,
+    account_id INTEGER REFERENCES accounts(id),
+    account_side VARCHAR(16) NOT NULL DEFAULT 'EITHER' CHECK (account_side IN ('SOURCE', 'DESTINATION', 'EITHER')),
+    amount DECIMAL(20,8) CHECK (amount > 0),
+    currency CHAR(3),
+    max_account_age_seconds INTEGER CHECK (max_account_age_seconds > 0),
+    blocked_account_ids INTEGER[],
+    round_multiple DECIMAL(20,8) CHECK (round_multiple > 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (rule_type != 'AMOUNT_THRESHOLD' OR amount IS NOT NULL),
+    CHECK (rule_type != 'NEW_ACCOUNT_AGE' OR max_account_age_seconds IS NOT NULL),
+    CHECK (rule_type != 'BLOCKLIST' OR cardinality(blocked_account_ids) > 0),
+    CHECK (rule_type != 'ROUND_AMOUNT' OR round_multiple IS NOT NULL)
+);
+
+-- Create index for loading the enabled rules in evaluation order
+CREATE INDEX IF NOT EXISTS idx_risk_rules_enabled ON risk_rules(priority, id) WHERE enabled;
+
+-- Create risk_rule_flags table (transfers let through by a FLAG rule, for review)
+CREATE TABLE IF NOT EXISTS risk_rule_flags (
+    id SERIAL PRIMARY KEY,
+    risk_rule_id INTEGER NOT NULL REFERENCES risk_rules(id),
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create indexes for listing the flags of a rule or a transfer
+CREATE INDEX IF NOT EXISTS idx_risk_rule_flags_rule ON risk_rule_flags(risk_rule_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_risk_rule_flags_transaction ON risk_rule_flags(transaction_id);
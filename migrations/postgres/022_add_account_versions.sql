@@ -0,0 +1,27 @@
+-- Count balance changes so clients can make a transfer conditional on the
+-- account not having changed since they read it. A hot account's version is
+-- its own plus the versions of its balance shards, so credits to a shard
+-- never touch the account row.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE account_balance_shards ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
+
+-- Bump the version whenever the balance changes, whichever statement or
+-- function changes it
+CREATE OR REPLACE FUNCTION bump_balance_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.balance IS DISTINCT FROM OLD.balance THEN
+        NEW.version := OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_accounts_balance_version ON accounts;
+CREATE TRIGGER trg_accounts_balance_version
+    BEFORE UPDATE OF balance ON accounts
+    FOR EACH ROW EXECUTE FUNCTION bump_balance_version();
+
+DROP TRIGGER IF EXISTS trg_account_balance_shards_version ON account_balance_shards;
+CREATE TRIGGER trg_account_balance_shards_version
+    BEFORE UPDATE OF balance ON account_balance_shards
+    FOR EACH ROW EXECUTE FUNCTION bump_balance_version();
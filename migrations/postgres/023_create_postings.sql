@@ -0,0 +1,212 @@
+-- Double-entry general ledger. Every movement of money is a journal whose
+-- postings add up to zero in each currency: a transfer debits its source and
+-- credits its destination and fee account, and an account's initial balance
+-- is credited against the system equity account. A cross-currency transfer
+-- goes through the system FX position account, which takes in the source
+-- currency and pays out the destination currency. accounts.balance stays the
+-- running total the transfer path locks and checks; summing an account's
+-- postings gives the same figure.
+CREATE TABLE IF NOT EXISTS journals (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL CHECK (kind IN ('TRANSFER', 'SPLIT_PAYMENT', 'OPENING_BALANCE')),
+    transaction_id INTEGER UNIQUE REFERENCES transactions(id),
+    split_payment_id INTEGER UNIQUE REFERENCES split_payments(id),
+    account_id INTEGER UNIQUE REFERENCES accounts(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    -- Each kind points at the record it journals and nothing else
+    CHECK (
+        (kind = 'TRANSFER' AND transaction_id IS NOT NULL AND split_payment_id IS NULL AND account_id IS NULL)
+        OR (kind = 'SPLIT_PAYMENT' AND split_payment_id IS NOT NULL AND transaction_id IS NULL AND account_id IS NULL)
+        OR (kind = 'OPENING_BALANCE' AND account_id IS NOT NULL AND transaction_id IS NULL AND split_payment_id IS NULL)
+    )
+);
+
+-- A posting is a signed change to one account: negative debits it and
+-- positive credits it. System accounts have no accounts row and are named
+-- by system_account instead, one of each per currency.
+CREATE TABLE IF NOT EXISTS postings (
+    id BIGSERIAL PRIMARY KEY,
+    journal_id INTEGER NOT NULL REFERENCES journals(id),
+    account_id INTEGER REFERENCES accounts(id),
+    system_account VARCHAR(32) CHECK (system_account IN ('EQUITY', 'FX_POSITION')),
+    currency CHAR(3) NOT NULL,
+    amount DECIMAL(20,8) NOT NULL CHECK (amount <> 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK ((account_id IS NULL) <> (system_account IS NULL))
+);
+
+-- Create index for reading a journal's postings
+CREATE INDEX IF NOT EXISTS idx_postings_journal_id ON postings(journal_id);
+
+-- Create index for an account's ledger, newest first
+CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings(account_id, id DESC) WHERE account_id IS NOT NULL;
+
+-- A journal must balance in every currency by the time its transaction
+-- commits. The check is deferred so the postings can be written one by one.
+CREATE OR REPLACE FUNCTION check_journal_balanced() RETURNS TRIGGER AS $$
+DECLARE
+    v_currency CHAR(3);
+    v_total DECIMAL(20,8);
+BEGIN
+    SELECT p.currency, SUM(p.amount) INTO v_currency, v_total
+    FROM postings p
+    WHERE p.journal_id = NEW.journal_id
+    GROUP BY p.currency
+    HAVING SUM(p.amount) <> 0
+    LIMIT 1;
+
+    IF FOUND THEN
+        RAISE EXCEPTION 'journal % does not balance: % postings add up to %', NEW.journal_id, v_currency, v_total
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_postings_balanced ON postings;
+CREATE CONSTRAINT TRIGGER trg_postings_balanced
+    AFTER INSERT ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
+
+-- The ledger is append-only: mistakes are corrected by reversing transfers,
+-- which post journals of their own
+CREATE OR REPLACE FUNCTION reject_ledger_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% rows cannot be changed once posted', TG_TABLE_NAME
+        USING ERRCODE = 'check_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_journals_append_only ON journals;
+CREATE TRIGGER trg_journals_append_only
+    BEFORE UPDATE OR DELETE ON journals
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_change();
+
+DROP TRIGGER IF EXISTS trg_postings_append_only ON postings;
+CREATE TRIGGER trg_postings_append_only
+    BEFORE UPDATE OR DELETE ON postings
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_change();
+
+-- Open the ledger of every account that has none yet at its current
+-- balance, so each account's postings add up to its balance from here on
+WITH opened AS (
+    INSERT INTO journals (kind, account_id, created_at)
+    SELECT 'OPENING_BALANCE', a.id, NOW()
+    FROM accounts a
+    WHERE a.balance + COALESCE((SELECT SUM(s.balance) FROM account_balance_shards s WHERE s.account_id = a.id), 0) <> 0
+        AND NOT EXISTS (SELECT 1 FROM journals j WHERE j.account_id = a.id)
+        AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.account_id = a.id)
+    RETURNING id, account_id
+)
+INSERT INTO postings (journal_id, account_id, system_account, currency, amount)
+SELECT o.id, entry.account_id, entry.system_account, a.currency, entry.amount
+FROM opened o
+JOIN accounts a ON a.id = o.account_id
+CROSS JOIN LATERAL (
+    SELECT a.balance + COALESCE((SELECT SUM(s.balance) FROM account_balance_shards s WHERE s.account_id = a.id), 0) AS balance
+) b
+CROSS JOIN LATERAL (
+    VALUES (a.id, NULL::VARCHAR(32), b.balance),
+        (NULL::INTEGER, 'EQUITY'::VARCHAR(32), -b.balance)
+) AS entry(account_id, system_account, amount);
+
+-- transfer_funds posts the journal of the transfers it completes
+CREATE OR REPLACE FUNCTION transfer_funds(
+    p_source_account_id INTEGER,
+    p_destination_account_id INTEGER,
+    p_amount DECIMAL(20,8),
+    p_memo VARCHAR(255),
+    p_client_reference VARCHAR(128),
+    p_metadata JSONB,
+    p_failure_code INTEGER
+) RETURNS TABLE (
+    outcome VARCHAR(32),
+    transaction_id INTEGER,
+    currency CHAR(3),
+    source_balance DECIMAL(20,8),
+    destination_balance DECIMAL(20,8),
+    created_at TIMESTAMP WITH TIME ZONE
+) AS $$
+DECLARE
+    v_source accounts%ROWTYPE;
+    v_dest accounts%ROWTYPE;
+    v_held DECIMAL(20,8);
+    v_journal_id INTEGER;
+BEGIN
+    PERFORM 1 FROM accounts a
+    WHERE a.id IN (p_source_account_id, p_destination_account_id)
+    ORDER BY a.id
+    FOR UPDATE;
+
+    SELECT * INTO v_source FROM accounts a WHERE a.id = p_source_account_id;
+    IF NOT FOUND THEN
+        outcome := 'SOURCE_NOT_FOUND';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    SELECT * INTO v_dest FROM accounts a WHERE a.id = p_destination_account_id;
+    IF NOT FOUND THEN
+        outcome := 'DESTINATION_NOT_FOUND';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    IF v_source.currency <> v_dest.currency
+        OR v_source.balance_shards > 0
+        OR v_dest.balance_shards > 0
+        OR EXISTS (SELECT 1 FROM account_limits l WHERE l.account_id = v_source.id)
+        OR EXISTS (
+            SELECT 1 FROM fee_schedules fs
+            WHERE fs.active
+                AND (fs.account_id = v_source.id OR (fs.account_id IS NULL AND fs.account_type = v_source.account_type))
+        ) THEN
+        outcome := 'INELIGIBLE';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    SELECT COALESCE(SUM(h.amount), 0) INTO v_held
+    FROM holds h
+    WHERE h.account_id = v_source.id AND h.status = 'ACTIVE' AND h.expires_at > NOW();
+
+    currency := v_source.currency;
+
+    IF v_source.balance - v_held + v_source.overdraft_limit < p_amount THEN
+        INSERT INTO transactions (source_account_id, destination_account_id, amount, source_currency, destination_currency,
+            destination_amount, status, failure_code, memo, client_reference, metadata, created_at, updated_at)
+        VALUES (v_source.id, v_dest.id, p_amount, v_source.currency, v_dest.currency,
+            p_amount, 'FAILED', p_failure_code, p_memo, p_client_reference, p_metadata, NOW(), NOW());
+
+        outcome := 'INSUFFICIENT_FUNDS';
+        RETURN NEXT;
+        RETURN;
+    END IF;
+
+    UPDATE accounts a SET balance = a.balance - p_amount, updated_at = NOW()
+    WHERE a.id = v_source.id
+    RETURNING a.balance INTO source_balance;
+
+    UPDATE accounts a SET balance = a.balance + p_amount, updated_at = NOW()
+    WHERE a.id = v_dest.id
+    RETURNING a.balance INTO destination_balance;
+
+    INSERT INTO transactions (source_account_id, destination_account_id, amount, source_currency, destination_currency,
+        destination_amount, fee, status, memo, client_reference, metadata, created_at, updated_at)
+    VALUES (v_source.id, v_dest.id, p_amount, v_source.currency, v_dest.currency,
+        p_amount, 0, 'COMPLETED', p_memo, p_client_reference, p_metadata, NOW(), NOW())
+    RETURNING transactions.id, transactions.created_at INTO transaction_id, created_at;
+
+    INSERT INTO journals (kind, transaction_id, created_at)
+    VALUES ('TRANSFER', transaction_id, created_at)
+    RETURNING journals.id INTO v_journal_id;
+
+    INSERT INTO postings (journal_id, account_id, currency, amount, created_at)
+    VALUES (v_journal_id, v_source.id, v_source.currency, -p_amount, created_at),
+        (v_journal_id, v_dest.id, v_dest.currency, p_amount, created_at);
+
+    outcome := 'COMPLETED';
+    RETURN NEXT;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+-- Create index for the executor retrying approved transfers whose execution
+-- was interrupted
+CREATE INDEX IF NOT EXISTS idx_transfer_approvals_approved ON transfer_approvals(updated_at) WHERE status = 'APPROVED';
@@ -1,15 +1,59 @@
 package models
 
 import (
+	"regexp"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
-// Account represents a bank account in the system
+// DefaultCurrency is the ISO-4217 currency of accounts created without one
+const DefaultCurrency = "USD"
+
+// DefaultAccountType is the type of accounts created without one. Types
+// select the fee schedule of accounts that have none of their own.
+const DefaultAccountType = "STANDARD"
+
+// accountTypePattern matches the CHECK constraint on accounts.account_type
+var accountTypePattern = regexp.MustCompile(`^[A-Z0-9_]{1,32}$`)
+
+// Account represents a bank account in the system. InitialBalance holds the
+// ledger balance once the account exists; AvailableBalance is the ledger
+// balance less active holds and is only filled in on reads. The balance may
+// go negative down to -OverdraftLimit. A hot account has its balance split
+// over BalanceShards rows so concurrent credits do not contend; it is 0 for
+// other accounts. Version goes up every time the balance changes and is only
+// filled in on reads.
 type Account struct {
-	ID             int             `json:"account_id" db:"id"`
-	InitialBalance decimal.Decimal `json:"initial_balance" db:"balance"`
-	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+	ID               int             `json:"account_id" db:"id"`
+	InitialBalance   decimal.Decimal `json:"initial_balance" db:"balance"`
+	AvailableBalance decimal.Decimal `json:"available_balance" db:"-"`
+	OverdraftLimit   decimal.Decimal `json:"overdraft_limit" db:"overdraft_limit"`
+	Currency         string          `json:"currency" db:"currency"`
+	AccountType      string          `json:"account_type" db:"account_type"`
+	BalanceShards    int             `json:"balance_shards" db:"balance_shards"`
+	Version          int64           `json:"version" db:"version"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// IsValidAccountType reports whether t can be stored as an account type
+func IsValidAccountType(t string) bool {
+	return accountTypePattern.MatchString(t)
+}
+
+// AvailableCredit returns the part of the overdraft limit not yet drawn on.
+// Funds reserved by holds draw on it like debits do.
+func (a *Account) AvailableCredit() decimal.Decimal {
+	if a.AvailableBalance.IsNegative() {
+		return decimal.Max(a.OverdraftLimit.Add(a.AvailableBalance), decimal.Zero)
+	}
+	return a.OverdraftLimit
+}
+
+// AccountFilter narrows an account listing. AfterID is the keyset cursor, as
+// in TransferFilter.
+type AccountFilter struct {
+	AfterID int
+	Limit   int
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Rolling windows of the outflow caps
+const (
+	DailyLimitWindow   = 24 * time.Hour
+	WeeklyLimitWindow  = 7 * 24 * time.Hour
+	MonthlyLimitWindow = 30 * 24 * time.Hour
+)
+
+// AccountLimits caps the transfers an account may send. Nil fields are not
+// enforced. Amounts are in the account's currency and cover transfer amounts
+// only, not fees. VelocityCount transfers are allowed per rolling
+// VelocityWindowSeconds. Reversals are exempt and do not count.
+type AccountLimits struct {
+	AccountID             int              `json:"account_id" db:"account_id"`
+	MaxTransactionAmount  *decimal.Decimal `json:"max_transaction_amount,omitempty" db:"max_transaction_amount"`
+	DailyLimit            *decimal.Decimal `json:"daily_limit,omitempty" db:"daily_limit"`
+	WeeklyLimit           *decimal.Decimal `json:"weekly_limit,omitempty" db:"weekly_limit"`
+	MonthlyLimit          *decimal.Decimal `json:"monthly_limit,omitempty" db:"monthly_limit"`
+	VelocityCount         *int             `json:"velocity_count,omitempty" db:"velocity_count"`
+	VelocityWindowSeconds *int             `json:"velocity_window_seconds,omitempty" db:"velocity_window_seconds"`
+	CreatedAt             time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// AccountOutflow is what an account has already sent within each window
+type AccountOutflow struct {
+	Daily         decimal.Decimal
+	Weekly        decimal.Decimal
+	Monthly       decimal.Decimal
+	VelocityCount int
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// AsyncTransferStatus is the lifecycle state of an asynchronous transfer
+type AsyncTransferStatus string
+
+const (
+	AsyncTransferStatusQueued     AsyncTransferStatus = "QUEUED"
+	AsyncTransferStatusProcessing AsyncTransferStatus = "PROCESSING"
+	AsyncTransferStatusCompleted  AsyncTransferStatus = "COMPLETED"
+	AsyncTransferStatusFailed     AsyncTransferStatus = "FAILED"
+)
+
+// IsFinal reports whether the transfer has stopped changing
+func (s AsyncTransferStatus) IsFinal() bool {
+	return s == AsyncTransferStatusCompleted || s == AsyncTransferStatusFailed
+}
+
+// CallbackStatus is how far the final state of an asynchronous transfer has
+// got to its callback URL
+type CallbackStatus string
+
+const (
+	CallbackStatusPending   CallbackStatus = "PENDING"
+	CallbackStatusDelivered CallbackStatus = "DELIVERED"
+	CallbackStatusFailed    CallbackStatus = "FAILED"
+)
+
+// AsyncTransfer is a transfer accepted for processing in the background. A
+// QUEUED transfer is picked up by a worker once NextAttemptAt has passed.
+// TransactionID and the balances are set once it is COMPLETED; a FAILED
+// transfer carries the code and message of its last error. When CallbackURL
+// is set, the final state is POSTed there and CallbackStatus tracks the
+// delivery.
+type AsyncTransfer struct {
+	ID                   int                 `json:"async_transfer_id" db:"id"`
+	SourceAccountID      int                 `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int                 `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal     `json:"amount" db:"amount"`
+	Status               AsyncTransferStatus `json:"status" db:"status"`
+	Attempts             int                 `json:"attempts" db:"attempts"`
+	NextAttemptAt        time.Time           `json:"-" db:"next_attempt_at"`
+	LastErrorCode        *int                `json:"last_error_code,omitempty" db:"last_error_code"`
+	LastError            *string             `json:"last_error,omitempty" db:"last_error"`
+	TransactionID        *int                `json:"transaction_id,omitempty" db:"transaction_id"`
+	SourceBalance        *decimal.Decimal    `json:"source_balance,omitempty" db:"source_balance"`
+	DestinationBalance   *decimal.Decimal    `json:"destination_balance,omitempty" db:"destination_balance"`
+	CallbackURL          *string             `json:"callback_url,omitempty" db:"callback_url"`
+	CallbackStatus       *CallbackStatus     `json:"callback_status,omitempty" db:"callback_status"`
+	CallbackAttempts     int                 `json:"callback_attempts,omitempty" db:"callback_attempts"`
+	CreatedAt            time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time           `json:"updated_at" db:"updated_at"`
+	TransferReference
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// feeScale matches the precision amounts are stored with
+const feeScale = 8
+
+// FeeType decides how a fee schedule prices a transfer
+type FeeType string
+
+const (
+	// FeeTypeFlat charges FlatAmount whatever the transfer amount
+	FeeTypeFlat FeeType = "FLAT"
+	// FeeTypePercentage charges Percentage percent of the amount
+	FeeTypePercentage FeeType = "PERCENTAGE"
+	// FeeTypeTiered prices the whole amount with the first tier it fits in
+	FeeTypeTiered FeeType = "TIERED"
+)
+
+// IsValid reports whether t is one of the known fee types
+func (t FeeType) IsValid() bool {
+	switch t {
+	case FeeTypeFlat, FeeTypePercentage, FeeTypeTiered:
+		return true
+	}
+	return false
+}
+
+// FeeTier prices amounts up to and including UpTo. The last tier of a
+// schedule has no UpTo and covers everything above the previous one.
+type FeeTier struct {
+	UpTo       *decimal.Decimal `json:"up_to,omitempty"`
+	FlatAmount decimal.Decimal  `json:"flat_amount"`
+	Percentage decimal.Decimal  `json:"percentage"`
+}
+
+// FeeSchedule prices transfers debiting an account. A schedule applies either
+// to one account or to every account of AccountType; an account's own
+// schedule takes precedence over the one for its type. Fees are charged in
+// the source currency and credited to RevenueAccountID.
+type FeeSchedule struct {
+	ID               int              `json:"fee_schedule_id" db:"id"`
+	AccountID        *int             `json:"account_id,omitempty" db:"account_id"`
+	AccountType      *string          `json:"account_type,omitempty" db:"account_type"`
+	FeeType          FeeType          `json:"fee_type" db:"fee_type"`
+	FlatAmount       *decimal.Decimal `json:"flat_amount,omitempty" db:"flat_amount"`
+	Percentage       *decimal.Decimal `json:"percentage,omitempty" db:"percentage"`
+	Tiers            []FeeTier        `json:"tiers,omitempty" db:"tiers"`
+	MinFee           *decimal.Decimal `json:"min_fee,omitempty" db:"min_fee"`
+	MaxFee           *decimal.Decimal `json:"max_fee,omitempty" db:"max_fee"`
+	RevenueAccountID int              `json:"revenue_account_id" db:"revenue_account_id"`
+	Active           bool             `json:"active" db:"active"`
+	CreatedAt        time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// FeeScheduleFilter narrows a fee schedule listing. Zero values are ignored.
+// AfterID is the keyset cursor, as in TransferFilter.
+type FeeScheduleFilter struct {
+	AccountID   int
+	AccountType string
+	Active      *bool
+	AfterID     int
+	Limit       int
+}
+
+// Fee returns the fee for transferring amount, clamped to MinFee and MaxFee
+// and rounded to the precision amounts are stored with.
+func (s *FeeSchedule) Fee(amount decimal.Decimal) decimal.Decimal {
+	var fee decimal.Decimal
+
+	switch s.FeeType {
+	case FeeTypeFlat:
+		fee = *s.FlatAmount
+	case FeeTypePercentage:
+		fee = percentOf(amount, *s.Percentage)
+	case FeeTypeTiered:
+		for _, tier := range s.Tiers {
+			if tier.UpTo == nil || amount.LessThanOrEqual(*tier.UpTo) {
+				fee = tier.FlatAmount.Add(percentOf(amount, tier.Percentage))
+				break
+			}
+		}
+	}
+
+	if s.MinFee != nil && fee.LessThan(*s.MinFee) {
+		fee = *s.MinFee
+	}
+	if s.MaxFee != nil && fee.GreaterThan(*s.MaxFee) {
+		fee = *s.MaxFee
+	}
+
+	return fee.Round(feeScale)
+}
+
+func percentOf(amount, percentage decimal.Decimal) decimal.Decimal {
+	return amount.Mul(percentage).Div(decimal.NewFromInt(100))
+}
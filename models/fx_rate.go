@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// FXRate converts one unit of BaseCurrency into Rate units of QuoteCurrency.
+// Rates are never updated in place: a new rate for the pair takes over from
+// its EffectiveAt, so transfers keep pointing at the rate they used.
+type FXRate struct {
+	ID            int             `json:"fx_rate_id" db:"id"`
+	BaseCurrency  string          `json:"base_currency" db:"base_currency"`
+	QuoteCurrency string          `json:"quote_currency" db:"quote_currency"`
+	Rate          decimal.Decimal `json:"rate" db:"rate"`
+	EffectiveAt   time.Time       `json:"effective_at" db:"effective_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// FXRateFilter narrows an FX rate listing. Zero values are ignored. AfterID
+// is the keyset cursor, as in TransferFilter.
+type FXRateFilter struct {
+	BaseCurrency  string
+	QuoteCurrency string
+	AfterID       int
+	Limit         int
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// HoldStatus is the lifecycle state of a hold
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "ACTIVE"
+	HoldStatusCaptured HoldStatus = "CAPTURED"
+	HoldStatusVoided   HoldStatus = "VOIDED"
+	HoldStatusExpired  HoldStatus = "EXPIRED"
+)
+
+// Hold reserves funds on an account without moving them. Only ACTIVE holds
+// that have not passed ExpiresAt reduce the account's available balance.
+type Hold struct {
+	ID                   int              `json:"hold_id" db:"id"`
+	AccountID            int              `json:"source_account_id" db:"account_id"`
+	DestinationAccountID int              `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal  `json:"amount" db:"amount"`
+	CapturedAmount       *decimal.Decimal `json:"captured_amount,omitempty" db:"captured_amount"`
+	Status               HoldStatus       `json:"status" db:"status"`
+	TransactionID        *int             `json:"transaction_id,omitempty" db:"transaction_id"`
+	ExpiresAt            time.Time        `json:"expires_at" db:"expires_at"`
+	CreatedAt            time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Prefixes of the idempotency keys the system derives for the transfers it
+// runs on a client's behalf. They share the idempotency_keys table with
+// client keys, so clients may not send keys starting with them.
+const (
+	IdempotencyKeyPrefixScheduledTransfer = "scheduled-transfer:"
+	IdempotencyKeyPrefixTransferApproval  = "transfer-approval:"
+	IdempotencyKeyPrefixAsyncTransfer     = "async-transfer:"
+)
+
+var reservedIdempotencyKeyPrefixes = []string{
+	IdempotencyKeyPrefixScheduledTransfer,
+	IdempotencyKeyPrefixTransferApproval,
+	IdempotencyKeyPrefixAsyncTransfer,
+}
+
+// IdempotencyKey identifies a client request that must be applied at most once
+type IdempotencyKey struct {
+	Key         string    `json:"key" db:"key"`
+	RequestHash string    `json:"request_hash" db:"request_hash"`
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// ReservedIdempotencyKeyPrefix returns the system prefix key starts with, or
+// "" if a client may use it
+func ReservedIdempotencyKeyPrefix(key string) string {
+	for _, prefix := range reservedIdempotencyKeyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
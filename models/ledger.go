@@ -0,0 +1,115 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// JournalKind is what a journal records
+type JournalKind string
+
+const (
+	// JournalKindTransfer records one completed transfer
+	JournalKindTransfer JournalKind = "TRANSFER"
+	// JournalKindSplitPayment records a split payment: the single debit of
+	// its source and the credits of all its legs
+	JournalKindSplitPayment JournalKind = "SPLIT_PAYMENT"
+	// JournalKindOpeningBalance records the balance an account was opened with
+	JournalKindOpeningBalance JournalKind = "OPENING_BALANCE"
+)
+
+// SystemAccount names a ledger account the system keeps for itself. System
+// accounts have no accounts row; there is one of each per currency.
+type SystemAccount string
+
+const (
+	// SystemAccountEquity is the other side of initial balances
+	SystemAccountEquity SystemAccount = "EQUITY"
+	// SystemAccountFXPosition takes in the source currency and pays out the
+	// destination currency of cross-currency transfers
+	SystemAccountFXPosition SystemAccount = "FX_POSITION"
+)
+
+// Posting is a signed change to one ledger account: a negative Amount debits
+// it and a positive one credits it. Exactly one of AccountID and
+// SystemAccount is set.
+type Posting struct {
+	ID            int             `json:"posting_id" db:"id"`
+	JournalID     int             `json:"journal_id" db:"journal_id"`
+	AccountID     *int            `json:"account_id,omitempty" db:"account_id"`
+	SystemAccount *SystemAccount  `json:"system_account,omitempty" db:"system_account"`
+	Currency      string          `json:"currency" db:"currency"`
+	Amount        decimal.Decimal `json:"amount" db:"amount"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Journal is one balanced entry in the general ledger: its postings add up
+// to zero in every currency. It points at the transfer, split payment or
+// account it records, depending on Kind.
+type Journal struct {
+	ID             int         `json:"journal_id" db:"id"`
+	Kind           JournalKind `json:"kind" db:"kind"`
+	TransactionID  *int        `json:"transaction_id,omitempty" db:"transaction_id"`
+	SplitPaymentID *int        `json:"split_payment_id,omitempty" db:"split_payment_id"`
+	AccountID      *int        `json:"account_id,omitempty" db:"account_id"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	Postings       []Posting   `json:"postings"`
+}
+
+// TransferPostings are the postings of a completed transfer. The source is
+// debited Amount and the destination credited DestinationAmount, with the
+// FX position account between them when the currencies differ, and any fee
+// is a separate debit of the source credited to the fee account. Zero
+// amounts are left out.
+func TransferPostings(transfer *Transfer) []Posting {
+	var postings []Posting
+	postings = appendPosting(postings, accountPosting(transfer.SourceAccountID, transfer.SourceCurrency, transfer.Amount.Neg()))
+	if transfer.SourceCurrency != transfer.DestinationCurrency {
+		postings = appendPosting(postings, systemPosting(SystemAccountFXPosition, transfer.SourceCurrency, transfer.Amount))
+		postings = appendPosting(postings, systemPosting(SystemAccountFXPosition, transfer.DestinationCurrency, transfer.DestinationAmount.Neg()))
+	}
+	postings = appendPosting(postings, accountPosting(transfer.DestinationAccountID, transfer.DestinationCurrency, transfer.DestinationAmount))
+	return appendFeePostings(postings, transfer.SourceAccountID, transfer.FeeAccountID, transfer.SourceCurrency, transfer.Fee)
+}
+
+// SplitPaymentPostings are the postings of a completed split payment: those
+// of every leg, then the fee priced on the whole amount.
+func SplitPaymentPostings(split *SplitPayment) []Posting {
+	var postings []Posting
+	for _, leg := range split.Legs {
+		postings = append(postings, TransferPostings(leg)...)
+	}
+	return appendFeePostings(postings, split.SourceAccountID, split.FeeAccountID, split.Currency, split.Fee)
+}
+
+// OpeningBalancePostings credit a new account its initial balance against
+// the equity account. An account opened empty has none.
+func OpeningBalancePostings(account *Account) []Posting {
+	var postings []Posting
+	postings = appendPosting(postings, accountPosting(account.ID, account.Currency, account.InitialBalance))
+	return appendPosting(postings, systemPosting(SystemAccountEquity, account.Currency, account.InitialBalance.Neg()))
+}
+
+func appendFeePostings(postings []Posting, sourceAccountID int, feeAccountID *int, currency string, fee decimal.Decimal) []Posting {
+	if feeAccountID == nil {
+		return postings
+	}
+	postings = appendPosting(postings, accountPosting(sourceAccountID, currency, fee.Neg()))
+	return appendPosting(postings, accountPosting(*feeAccountID, currency, fee))
+}
+
+func appendPosting(postings []Posting, posting Posting) []Posting {
+	if posting.Amount.IsZero() {
+		return postings
+	}
+	return append(postings, posting)
+}
+
+func accountPosting(accountID int, currency string, amount decimal.Decimal) Posting {
+	return Posting{AccountID: &accountID, Currency: currency, Amount: amount}
+}
+
+func systemPosting(account SystemAccount, currency string, amount decimal.Decimal) Posting {
+	return Posting{SystemAccount: &account, Currency: currency, Amount: amount}
+}
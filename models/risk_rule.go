@@ -0,0 +1,210 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// RiskRuleType is the condition a risk rule checks a transfer for
+type RiskRuleType string
+
+const (
+	// RiskRuleTypeAmountThreshold matches transfers of Amount or more
+	RiskRuleTypeAmountThreshold RiskRuleType = "AMOUNT_THRESHOLD"
+	// RiskRuleTypeNewAccountAge matches transfers where an account on
+	// AccountSide was opened less than MaxAccountAgeSeconds ago
+	RiskRuleTypeNewAccountAge RiskRuleType = "NEW_ACCOUNT_AGE"
+	// RiskRuleTypeBlocklist matches transfers where an account on
+	// AccountSide is one of BlockedAccountIDs
+	RiskRuleTypeBlocklist RiskRuleType = "BLOCKLIST"
+	// RiskRuleTypeRoundAmount matches transfers whose amount is a multiple
+	// of RoundMultiple, and at least Amount if that is set
+	RiskRuleTypeRoundAmount RiskRuleType = "ROUND_AMOUNT"
+)
+
+// IsValid reports whether t is one of the known rule types
+func (t RiskRuleType) IsValid() bool {
+	switch t {
+	case RiskRuleTypeAmountThreshold, RiskRuleTypeNewAccountAge, RiskRuleTypeBlocklist, RiskRuleTypeRoundAmount:
+		return true
+	}
+	return false
+}
+
+// RiskAction is what a matching risk rule does with the transfer
+type RiskAction string
+
+const (
+	// RiskActionAllow lets the transfer through without checking the rules
+	// after it
+	RiskActionAllow RiskAction = "ALLOW"
+	// RiskActionDeny declines the transfer
+	RiskActionDeny RiskAction = "DENY"
+	// RiskActionFlag lets the transfer through and records it for review
+	RiskActionFlag RiskAction = "FLAG"
+)
+
+// IsValid reports whether a is one of the known actions
+func (a RiskAction) IsValid() bool {
+	switch a {
+	case RiskActionAllow, RiskActionDeny, RiskActionFlag:
+		return true
+	}
+	return false
+}
+
+// RiskAccountSide is which side of a transfer a rule looks at
+type RiskAccountSide string
+
+const (
+	RiskAccountSideSource      RiskAccountSide = "SOURCE"
+	RiskAccountSideDestination RiskAccountSide = "DESTINATION"
+	RiskAccountSideEither      RiskAccountSide = "EITHER"
+)
+
+// IsValid reports whether s is one of the known sides
+func (s RiskAccountSide) IsValid() bool {
+	switch s {
+	case RiskAccountSideSource, RiskAccountSideDestination, RiskAccountSideEither:
+		return true
+	}
+	return false
+}
+
+// RiskRule is a declarative check run on a transfer before it commits.
+// Enabled rules are evaluated in ascending Priority, then ID. A rule with
+// AccountID only applies to transfers touching that account, and one with
+// Currency only to transfers debited in that currency. Amounts are in the
+// source currency.
+type RiskRule struct {
+	ID                   int              `json:"risk_rule_id" db:"id"`
+	Name                 string           `json:"name" db:"name"`
+	Type                 RiskRuleType     `json:"rule_type" db:"rule_type"`
+	Action               RiskAction       `json:"action" db:"action"`
+	Priority             int              `json:"priority" db:"priority"`
+	AccountID            *int             `json:"account_id,omitempty" db:"account_id"`
+	AccountSide          RiskAccountSide  `json:"account_side" db:"account_side"`
+	Amount               *decimal.Decimal `json:"amount,omitempty" db:"amount"`
+	Currency             *string          `json:"currency,omitempty" db:"currency"`
+	MaxAccountAgeSeconds *int             `json:"max_account_age_seconds,omitempty" db:"max_account_age_seconds"`
+	BlockedAccountIDs    []int            `json:"blocked_account_ids,omitempty" db:"blocked_account_ids"`
+	RoundMultiple        *decimal.Decimal `json:"round_multiple,omitempty" db:"round_multiple"`
+	Enabled              bool             `json:"enabled" db:"enabled"`
+	CreatedAt            time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time        `json:"updated_at" db:"updated_at"`
+}
+
+// RiskRuleFlag records a transfer a FLAG rule matched
+type RiskRuleFlag struct {
+	ID            int       `json:"flag_id" db:"id"`
+	RiskRuleID    int       `json:"risk_rule_id" db:"risk_rule_id"`
+	TransactionID int       `json:"transaction_id" db:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// RiskInput is what risk rules see of a transfer. The opening times are
+// only needed by NEW_ACCOUNT_AGE rules.
+type RiskInput struct {
+	SourceAccountID      int
+	DestinationAccountID int
+	Amount               decimal.Decimal
+	Currency             string
+	SourceOpenedAt       time.Time
+	DestinationOpenedAt  time.Time
+	Now                  time.Time
+}
+
+// RiskDecision is the outcome of evaluating the rules. Rule is the ALLOW or
+// DENY rule that decided it, nil when no such rule matched, and Flags are
+// the FLAG rules that matched before it.
+type RiskDecision struct {
+	Action RiskAction
+	Rule   *RiskRule
+	Flags  []*RiskRule
+}
+
+// EvaluateRiskRules runs rules, already in evaluation order, against in.
+// The first matching ALLOW or DENY rule decides; a transfer no such rule
+// matches is allowed.
+func EvaluateRiskRules(rules []RiskRule, in *RiskInput) RiskDecision {
+	decision := RiskDecision{Action: RiskActionAllow}
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(in) {
+			continue
+		}
+		if rule.Action == RiskActionFlag {
+			decision.Flags = append(decision.Flags, rule)
+			continue
+		}
+		decision.Action = rule.Action
+		decision.Rule = rule
+		break
+	}
+	return decision
+}
+
+// NeedsAccountAge reports whether any of rules looks at when accounts were
+// opened
+func NeedsAccountAge(rules []RiskRule) bool {
+	for i := range rules {
+		if rules[i].Type == RiskRuleTypeNewAccountAge {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the rule's condition holds for in
+func (r *RiskRule) Matches(in *RiskInput) bool {
+	if r.AccountID != nil && *r.AccountID != in.SourceAccountID && *r.AccountID != in.DestinationAccountID {
+		return false
+	}
+	if r.Currency != nil && *r.Currency != in.Currency {
+		return false
+	}
+
+	switch r.Type {
+	case RiskRuleTypeAmountThreshold:
+		return r.Amount != nil && in.Amount.GreaterThanOrEqual(*r.Amount)
+
+	case RiskRuleTypeNewAccountAge:
+		if r.MaxAccountAgeSeconds == nil {
+			return false
+		}
+		maxAge := time.Duration(*r.MaxAccountAgeSeconds) * time.Second
+		return r.onSide(in, func(_ int, openedAt time.Time) bool {
+			return in.Now.Sub(openedAt) < maxAge
+		})
+
+	case RiskRuleTypeBlocklist:
+		return r.onSide(in, func(accountID int, _ time.Time) bool {
+			for _, blocked := range r.BlockedAccountIDs {
+				if blocked == accountID {
+					return true
+				}
+			}
+			return false
+		})
+
+	case RiskRuleTypeRoundAmount:
+		if r.RoundMultiple == nil || !r.RoundMultiple.IsPositive() {
+			return false
+		}
+		if r.Amount != nil && in.Amount.LessThan(*r.Amount) {
+			return false
+		}
+		return in.Amount.IsPositive() && in.Amount.Mod(*r.RoundMultiple).IsZero()
+	}
+
+	return false
+}
+
+// onSide reports whether check holds for an account on the rule's side
+func (r *RiskRule) onSide(in *RiskInput, check func(accountID int, openedAt time.Time) bool) bool {
+	if r.AccountSide != RiskAccountSideDestination && check(in.SourceAccountID, in.SourceOpenedAt) {
+		return true
+	}
+	return r.AccountSide != RiskAccountSideSource && check(in.DestinationAccountID, in.DestinationOpenedAt)
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ScheduledTransferStatus is the lifecycle state of a scheduled transfer
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferStatusPending    ScheduledTransferStatus = "PENDING"
+	ScheduledTransferStatusProcessing ScheduledTransferStatus = "PROCESSING"
+	ScheduledTransferStatusExecuted   ScheduledTransferStatus = "EXECUTED"
+	ScheduledTransferStatusFailed     ScheduledTransferStatus = "FAILED"
+	ScheduledTransferStatusCancelled  ScheduledTransferStatus = "CANCELLED"
+)
+
+// IsValid reports whether s is one of the known statuses
+func (s ScheduledTransferStatus) IsValid() bool {
+	switch s {
+	case ScheduledTransferStatusPending, ScheduledTransferStatusProcessing, ScheduledTransferStatusExecuted,
+		ScheduledTransferStatusFailed, ScheduledTransferStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// ScheduledTransfer is a transfer to be executed at ExecuteAt. A PENDING
+// transfer is picked up once NextAttemptAt has passed; failed attempts that
+// may succeed later push NextAttemptAt forward. TransactionID links the
+// transfer that was finally executed, which carries the schedule's
+// TransferReference.
+type ScheduledTransfer struct {
+	ID                   int                     `json:"scheduled_transfer_id" db:"id"`
+	SourceAccountID      int                     `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int                     `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal         `json:"amount" db:"amount"`
+	ExecuteAt            time.Time               `json:"execute_at" db:"execute_at"`
+	Status               ScheduledTransferStatus `json:"status" db:"status"`
+	Attempts             int                     `json:"attempts" db:"attempts"`
+	NextAttemptAt        time.Time               `json:"next_attempt_at" db:"next_attempt_at"`
+	LastErrorCode        *int                    `json:"last_error_code,omitempty" db:"last_error_code"`
+	LastError            *string                 `json:"last_error,omitempty" db:"last_error"`
+	TransactionID        *int                    `json:"transaction_id,omitempty" db:"transaction_id"`
+	CreatedAt            time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at" db:"updated_at"`
+	TransferReference
+}
+
+// ScheduledTransferFilter narrows a scheduled transfer listing. Zero values
+// are ignored. AfterID is the keyset cursor, as in TransferFilter.
+type ScheduledTransferFilter struct {
+	SourceAccountID      int
+	DestinationAccountID int
+	AccountID            int
+	Status               ScheduledTransferStatus
+	AfterID              int
+	Limit                int
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// splitScale matches the precision amounts are stored with
+const splitScale = 8
+
+// SplitAllocation is how the shares of a split payment were given
+type SplitAllocation string
+
+const (
+	// SplitAllocationAmount gives every destination an explicit amount
+	SplitAllocationAmount SplitAllocation = "AMOUNT"
+	// SplitAllocationPercentage gives every destination a percentage of the
+	// total, allocated by AllocateByPercentage
+	SplitAllocationPercentage SplitAllocation = "PERCENTAGE"
+)
+
+// SplitPayment debits Amount plus Fee from the source once and credits each
+// destination its share. Every share is a leg: a Transfer from the source
+// linked through SplitPaymentID. The fee is priced on the whole amount, so
+// the legs carry none.
+type SplitPayment struct {
+	ID              int             `json:"split_payment_id" db:"id"`
+	SourceAccountID int             `json:"source_account_id" db:"source_account_id"`
+	Amount          decimal.Decimal `json:"amount" db:"amount"`
+	Currency        string          `json:"currency" db:"currency"`
+	Allocation      SplitAllocation `json:"allocation" db:"allocation"`
+	Fee             decimal.Decimal `json:"fee" db:"fee"`
+	FeeScheduleID   *int            `json:"fee_schedule_id,omitempty" db:"fee_schedule_id"`
+	FeeAccountID    *int            `json:"fee_account_id,omitempty" db:"fee_account_id"`
+	CreatedAt       time.Time       `json:"created_at" db:"created_at"`
+	Legs            []*Transfer     `json:"legs"`
+	TransferReference
+}
+
+// AllocateByPercentage splits total into one share per percentage. total
+// must have at most 8 decimal places and the percentages must add up to 100.
+// Each share is first rounded down to the
+// stored precision; the units of 1e-8 this leaves over go one each to the
+// shares that lost the most to rounding, and between equal losses to the
+// earlier share. The same input therefore always gives the same shares, and
+// they always add up to total.
+func AllocateByPercentage(total decimal.Decimal, percentages []decimal.Decimal) []decimal.Decimal {
+	shares := make([]decimal.Decimal, len(percentages))
+	losses := make([]decimal.Decimal, len(percentages))
+	allocated := decimal.Zero
+
+	for i, percentage := range percentages {
+		// Shifting rather than dividing by 100 keeps the share exact
+		exact := total.Mul(percentage).Shift(-2)
+		shares[i] = exact.RoundFloor(splitScale)
+		losses[i] = exact.Sub(shares[i])
+		allocated = allocated.Add(shares[i])
+	}
+
+	order := make([]int, len(percentages))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return losses[order[a]].GreaterThan(losses[order[b]])
+	})
+
+	unit := decimal.New(1, -splitScale)
+	remainder := total.Sub(allocated)
+	for i := 0; remainder.GreaterThanOrEqual(unit) && len(order) > 0; i++ {
+		shares[order[i%len(order)]] = shares[order[i%len(order)]].Add(unit)
+		remainder = remainder.Sub(unit)
+	}
+
+	return shares
+}
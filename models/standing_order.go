@@ -0,0 +1,189 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// StandingOrderFrequency is the recurrence rule of a standing order
+type StandingOrderFrequency string
+
+const (
+	// StandingOrderFrequencyInterval repeats every IntervalSeconds from StartAt
+	StandingOrderFrequencyInterval StandingOrderFrequency = "INTERVAL"
+	// StandingOrderFrequencyDaily runs every day at StartAt's time of day
+	StandingOrderFrequencyDaily StandingOrderFrequency = "DAILY"
+	// StandingOrderFrequencyWeekly runs on DayOfWeek (0 is Sunday)
+	StandingOrderFrequencyWeekly StandingOrderFrequency = "WEEKLY"
+	// StandingOrderFrequencyMonthly runs on DayOfMonth, or the last day of
+	// shorter months
+	StandingOrderFrequencyMonthly StandingOrderFrequency = "MONTHLY"
+	// StandingOrderFrequencyEndOfMonth runs on the last day of every month
+	StandingOrderFrequencyEndOfMonth StandingOrderFrequency = "END_OF_MONTH"
+)
+
+// IsValid reports whether f is one of the known frequencies
+func (f StandingOrderFrequency) IsValid() bool {
+	switch f {
+	case StandingOrderFrequencyInterval, StandingOrderFrequencyDaily, StandingOrderFrequencyWeekly,
+		StandingOrderFrequencyMonthly, StandingOrderFrequencyEndOfMonth:
+		return true
+	}
+	return false
+}
+
+// StandingOrderStatus is the lifecycle state of a standing order
+type StandingOrderStatus string
+
+const (
+	StandingOrderStatusActive    StandingOrderStatus = "ACTIVE"
+	StandingOrderStatusCompleted StandingOrderStatus = "COMPLETED"
+	StandingOrderStatusCancelled StandingOrderStatus = "CANCELLED"
+)
+
+// IsValid reports whether s is one of the known statuses
+func (s StandingOrderStatus) IsValid() bool {
+	switch s {
+	case StandingOrderStatusActive, StandingOrderStatusCompleted, StandingOrderStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// StandingOrderFailurePolicy decides what happens when a run is declined.
+// SKIP gives up on the occurrence straight away; RETRY tries it again up to
+// MaxRetries times before skipping it.
+type StandingOrderFailurePolicy string
+
+const (
+	StandingOrderFailurePolicySkip  StandingOrderFailurePolicy = "SKIP"
+	StandingOrderFailurePolicyRetry StandingOrderFailurePolicy = "RETRY"
+)
+
+// StandingOrderRunStatus is the outcome of one execution attempt
+type StandingOrderRunStatus string
+
+const (
+	StandingOrderRunStatusSucceeded StandingOrderRunStatus = "SUCCEEDED"
+	// StandingOrderRunStatusFailed is a declined attempt that will be retried
+	StandingOrderRunStatusFailed StandingOrderRunStatus = "FAILED"
+	// StandingOrderRunStatusSkipped is a declined attempt after which the
+	// occurrence was given up
+	StandingOrderRunStatusSkipped StandingOrderRunStatus = "SKIPPED"
+)
+
+// StandingOrder generates a transfer on every occurrence of its recurrence
+// rule until EndAt has passed or MaxRuns transfers have been executed.
+// NextRunAt is the occurrence due next and NextAttemptAt is when it is tried,
+// which is later than NextRunAt while a declined run is being retried.
+type StandingOrder struct {
+	ID                   int                        `json:"standing_order_id" db:"id"`
+	SourceAccountID      int                        `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int                        `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal            `json:"amount" db:"amount"`
+	Frequency            StandingOrderFrequency     `json:"frequency" db:"frequency"`
+	IntervalSeconds      *int                       `json:"interval_seconds,omitempty" db:"interval_seconds"`
+	DayOfWeek            *int                       `json:"day_of_week,omitempty" db:"day_of_week"`
+	DayOfMonth           *int                       `json:"day_of_month,omitempty" db:"day_of_month"`
+	StartAt              time.Time                  `json:"start_at" db:"start_at"`
+	EndAt                *time.Time                 `json:"end_at,omitempty" db:"end_at"`
+	MaxRuns              *int                       `json:"max_runs,omitempty" db:"max_runs"`
+	ExecutedRuns         int                        `json:"executed_runs" db:"executed_runs"`
+	FailurePolicy        StandingOrderFailurePolicy `json:"failure_policy" db:"failure_policy"`
+	MaxRetries           int                        `json:"max_retries" db:"max_retries"`
+	RetryIntervalSeconds int                        `json:"retry_interval_seconds" db:"retry_interval_seconds"`
+	RetryCount           int                        `json:"retry_count" db:"retry_count"`
+	Status               StandingOrderStatus        `json:"status" db:"status"`
+	NextRunAt            time.Time                  `json:"next_run_at" db:"next_run_at"`
+	NextAttemptAt        time.Time                  `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt            time.Time                  `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time                  `json:"updated_at" db:"updated_at"`
+}
+
+// StandingOrderRun is one execution attempt of a standing order
+type StandingOrderRun struct {
+	ID              int                    `json:"run_id" db:"id"`
+	StandingOrderID int                    `json:"standing_order_id" db:"standing_order_id"`
+	ScheduledFor    time.Time              `json:"scheduled_for" db:"scheduled_for"`
+	Attempt         int                    `json:"attempt" db:"attempt"`
+	Status          StandingOrderRunStatus `json:"status" db:"status"`
+	TransactionID   *int                   `json:"transaction_id,omitempty" db:"transaction_id"`
+	ErrorCode       *int                   `json:"error_code,omitempty" db:"error_code"`
+	Error           *string                `json:"error,omitempty" db:"error"`
+	CreatedAt       time.Time              `json:"created_at" db:"created_at"`
+}
+
+// StandingOrderFilter narrows a standing order listing. Zero values are
+// ignored. AfterID is the keyset cursor, as in TransferFilter.
+type StandingOrderFilter struct {
+	SourceAccountID      int
+	DestinationAccountID int
+	AccountID            int
+	Status               StandingOrderStatus
+	AfterID              int
+	Limit                int
+}
+
+// FirstOccurrence returns the first occurrence at or after StartAt
+func (o *StandingOrder) FirstOccurrence() time.Time {
+	return o.NextOccurrence(o.StartAt.Add(-time.Nanosecond))
+}
+
+// NextOccurrence returns the first occurrence strictly after after. Times of
+// day come from StartAt and calendar rules are evaluated in UTC.
+func (o *StandingOrder) NextOccurrence(after time.Time) time.Time {
+	start := o.StartAt.UTC()
+	after = after.UTC()
+	if after.Before(start) {
+		after = start.Add(-time.Nanosecond)
+	}
+
+	atClock := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+	}
+
+	switch o.Frequency {
+	case StandingOrderFrequencyInterval:
+		step := time.Duration(*o.IntervalSeconds) * time.Second
+		if after.Before(start) {
+			return start
+		}
+		return start.Add((after.Sub(start)/step + 1) * step)
+
+	case StandingOrderFrequencyDaily:
+		next := atClock(after.Year(), after.Month(), after.Day())
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+
+	case StandingOrderFrequencyWeekly:
+		next := atClock(after.Year(), after.Month(), after.Day())
+		next = next.AddDate(0, 0, (*o.DayOfWeek-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+
+	case StandingOrderFrequencyMonthly, StandingOrderFrequencyEndOfMonth:
+		day := 31
+		if o.Frequency == StandingOrderFrequencyMonthly {
+			day = *o.DayOfMonth
+		}
+		onDay := func(year int, month time.Month) time.Time {
+			// Day 0 of the following month is the last day of this one
+			lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			return atClock(year, month, min(day, lastDay))
+		}
+		next := onDay(after.Year(), after.Month())
+		if !next.After(after) {
+			firstOfMonth := time.Date(after.Year(), after.Month(), 1, 0, 0, 0, 0, time.UTC)
+			nextMonth := firstOfMonth.AddDate(0, 1, 0)
+			next = onDay(nextMonth.Year(), nextMonth.Month())
+		}
+		return next
+	}
+
+	return after
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// SweepRuleType decides which balance a sweep rule watches and which way
+// the money moves
+type SweepRuleType string
+
+const (
+	// SweepRuleTypeSweepExcess moves everything the source holds above
+	// Threshold to the destination
+	SweepRuleTypeSweepExcess SweepRuleType = "SWEEP_EXCESS"
+	// SweepRuleTypeTopUp moves whatever the destination lacks to reach
+	// Threshold from the source
+	SweepRuleTypeTopUp SweepRuleType = "TOP_UP"
+)
+
+// IsValid reports whether t is one of the known rule types
+func (t SweepRuleType) IsValid() bool {
+	switch t {
+	case SweepRuleTypeSweepExcess, SweepRuleTypeTopUp:
+		return true
+	}
+	return false
+}
+
+// SweepRuleStatus is the lifecycle state of a sweep rule
+type SweepRuleStatus string
+
+const (
+	SweepRuleStatusActive    SweepRuleStatus = "ACTIVE"
+	SweepRuleStatusCancelled SweepRuleStatus = "CANCELLED"
+)
+
+// IsValid reports whether s is one of the known statuses
+func (s SweepRuleStatus) IsValid() bool {
+	switch s {
+	case SweepRuleStatusActive, SweepRuleStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// SweepRunStatus is the outcome of the latest run of a sweep rule
+type SweepRunStatus string
+
+const (
+	SweepRunStatusSwept SweepRunStatus = "SWEPT"
+	// SweepRunStatusNothingToSweep is a run where the balances were already
+	// where the rule wants them, or the amount was below MinAmount
+	SweepRunStatusNothingToSweep SweepRunStatus = "NOTHING_TO_SWEEP"
+	// SweepRunStatusFailed is a run whose transfer was declined
+	SweepRunStatusFailed SweepRunStatus = "FAILED"
+)
+
+// SweepRule moves balance between two accounts of the same currency on
+// every occurrence of its recurrence rule, which works as for standing
+// orders. The amount is worked out from the balances when the rule runs,
+// and runs moving less than MinAmount are skipped. Every transfer a rule
+// makes carries its ID as SweepRuleID.
+type SweepRule struct {
+	ID                   int                    `json:"sweep_rule_id" db:"id"`
+	Type                 SweepRuleType          `json:"rule_type" db:"rule_type"`
+	SourceAccountID      int                    `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int                    `json:"destination_account_id" db:"destination_account_id"`
+	Threshold            decimal.Decimal        `json:"threshold" db:"threshold"`
+	MinAmount            *decimal.Decimal       `json:"min_amount,omitempty" db:"min_amount"`
+	Frequency            StandingOrderFrequency `json:"frequency" db:"frequency"`
+	IntervalSeconds      *int                   `json:"interval_seconds,omitempty" db:"interval_seconds"`
+	DayOfWeek            *int                   `json:"day_of_week,omitempty" db:"day_of_week"`
+	DayOfMonth           *int                   `json:"day_of_month,omitempty" db:"day_of_month"`
+	StartAt              time.Time              `json:"start_at" db:"start_at"`
+	Status               SweepRuleStatus        `json:"status" db:"status"`
+	NextRunAt            time.Time              `json:"next_run_at" db:"next_run_at"`
+	LastRunAt            *time.Time             `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastRunStatus        *SweepRunStatus        `json:"last_run_status,omitempty" db:"last_run_status"`
+	LastTransactionID    *int                   `json:"last_transaction_id,omitempty" db:"last_transaction_id"`
+	LastErrorCode        *int                   `json:"last_error_code,omitempty" db:"last_error_code"`
+	LastError            *string                `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt            time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// SweepRuleFilter narrows a sweep rule listing. Zero values are ignored.
+// AfterID is the keyset cursor, as in TransferFilter.
+type SweepRuleFilter struct {
+	SourceAccountID      int
+	DestinationAccountID int
+	AccountID            int
+	Status               SweepRuleStatus
+	AfterID              int
+	Limit                int
+}
+
+// SweepAmount returns how much the rule moves given the source's available
+// balance and the destination's balance, or zero if the run has nothing to
+// move.
+func (r *SweepRule) SweepAmount(sourceAvailable, destBalance decimal.Decimal) decimal.Decimal {
+	var amount decimal.Decimal
+	switch r.Type {
+	case SweepRuleTypeSweepExcess:
+		amount = sourceAvailable.Sub(r.Threshold)
+	case SweepRuleTypeTopUp:
+		amount = r.Threshold.Sub(destBalance)
+	}
+
+	if !amount.IsPositive() || (r.MinAmount != nil && amount.LessThan(*r.MinAmount)) {
+		return decimal.Zero
+	}
+	return amount
+}
+
+// FirstOccurrence returns the first occurrence at or after StartAt
+func (r *SweepRule) FirstOccurrence() time.Time {
+	return r.schedule().FirstOccurrence()
+}
+
+// NextOccurrence returns the first occurrence strictly after after
+func (r *SweepRule) NextOccurrence(after time.Time) time.Time {
+	return r.schedule().NextOccurrence(after)
+}
+
+// schedule returns the rule's recurrence as a standing order, so both share
+// one calculation of occurrences
+func (r *SweepRule) schedule() *StandingOrder {
+	return &StandingOrder{
+		Frequency:       r.Frequency,
+		IntervalSeconds: r.IntervalSeconds,
+		DayOfWeek:       r.DayOfWeek,
+		DayOfMonth:      r.DayOfMonth,
+		StartAt:         r.StartAt,
+	}
+}
@@ -6,12 +6,106 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// Transfer represents a completed transfer transaction in the system
+// TransferStatus is the lifecycle state of a transfer
+type TransferStatus string
+
+const (
+	TransferStatusPending   TransferStatus = "PENDING"
+	TransferStatusCompleted TransferStatus = "COMPLETED"
+	TransferStatusFailed    TransferStatus = "FAILED"
+	TransferStatusReversed  TransferStatus = "REVERSED"
+	TransferStatusCancelled TransferStatus = "CANCELLED"
+)
+
+// transferTransitions lists the states each status may move to. Statuses
+// missing from the map are terminal.
+var transferTransitions = map[TransferStatus][]TransferStatus{
+	TransferStatusPending:   {TransferStatusCompleted, TransferStatusFailed, TransferStatusCancelled},
+	TransferStatusCompleted: {TransferStatusReversed},
+}
+
+// CanTransitionTo reports whether a transfer in status s may move to next
+func (s TransferStatus) CanTransitionTo(next TransferStatus) bool {
+	for _, allowed := range transferTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid reports whether s is one of the known statuses
+func (s TransferStatus) IsValid() bool {
+	switch s {
+	case TransferStatusPending, TransferStatusCompleted, TransferStatusFailed, TransferStatusReversed, TransferStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// TransferReference is the caller's own description of a transfer. Memo is
+// free text, ClientReference correlates the transfer with the caller's
+// records, such as an invoice ID, and is unique among the transfers of a
+// source account, and Metadata is any JSON object.
+type TransferReference struct {
+	Memo            *string        `json:"memo,omitempty" db:"memo"`
+	ClientReference *string        `json:"client_reference,omitempty" db:"client_reference"`
+	Metadata        map[string]any `json:"metadata,omitempty" db:"metadata"`
+}
+
+// TransferPrecondition makes a transfer conditional on its source account
+// being as the client last read it: still at SourceVersion, still holding
+// SourceBalance, or both. Nil fields are not checked.
+type TransferPrecondition struct {
+	SourceVersion *int64
+	SourceBalance *decimal.Decimal
+}
+
+// Transfer represents a transfer transaction in the system. Declined
+// attempts are kept as FAILED transfers with the code that declined them.
+// Amount is debited in the source currency and DestinationAmount credited in
+// the destination currency; they differ only when FXRate converted between
+// the two. Fee is debited from the source on top of Amount and credited to
+// FeeAccountID. Precondition is only checked when the transfer is applied
+// and is not stored.
 type Transfer struct {
-	ID                  int             `json:"id" db:"id"`
-	SourceAccountID     int             `json:"source_account_id" db:"source_account_id"`
-	DestinationAccountID int            `json:"destination_account_id" db:"destination_account_id"`
-	Amount              decimal.Decimal `json:"amount" db:"amount"`
-	CreatedAt           time.Time       `json:"created_at" db:"created_at"`
-	UpdatedAt           time.Time       `json:"updated_at" db:"updated_at"`
+	ID                   int                   `json:"id" db:"id"`
+	SourceAccountID      int                   `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int                   `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal       `json:"amount" db:"amount"`
+	SourceCurrency       string                `json:"source_currency" db:"source_currency"`
+	DestinationCurrency  string                `json:"destination_currency" db:"destination_currency"`
+	DestinationAmount    decimal.Decimal       `json:"destination_amount" db:"destination_amount"`
+	FXRateID             *int                  `json:"fx_rate_id,omitempty" db:"fx_rate_id"`
+	FXRate               *decimal.Decimal      `json:"fx_rate,omitempty" db:"fx_rate"`
+	Fee                  decimal.Decimal       `json:"fee" db:"fee"`
+	FeeScheduleID        *int                  `json:"fee_schedule_id,omitempty" db:"fee_schedule_id"`
+	FeeAccountID         *int                  `json:"fee_account_id,omitempty" db:"fee_account_id"`
+	ReversalOf           *int                  `json:"reversal_of,omitempty" db:"reversal_of"`
+	BatchID              *int                  `json:"batch_id,omitempty" db:"batch_id"`
+	SplitPaymentID       *int                  `json:"split_payment_id,omitempty" db:"split_payment_id"`
+	SweepRuleID          *int                  `json:"sweep_rule_id,omitempty" db:"sweep_rule_id"`
+	Status               TransferStatus        `json:"status" db:"status"`
+	FailureCode          *int                  `json:"failure_code,omitempty" db:"failure_code"`
+	CreatedAt            time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time             `json:"updated_at" db:"updated_at"`
+	Precondition         *TransferPrecondition `json:"-" db:"-"`
+	TransferReference
+}
+
+// TransferFilter narrows a transfer history query. Zero values are ignored.
+// AfterID is the keyset cursor: only transfers with a smaller ID are returned.
+type TransferFilter struct {
+	SourceAccountID      int
+	DestinationAccountID int
+	AccountID            int
+	MinAmount            *decimal.Decimal
+	MaxAmount            *decimal.Decimal
+	CreatedFrom          *time.Time
+	CreatedTo            *time.Time
+	Status               TransferStatus
+	ClientReference      string
+	SweepRuleID          int
+	AfterID              int
+	Limit                int
 }
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TransferApprovalStatus is the lifecycle state of a transfer approval
+type TransferApprovalStatus string
+
+const (
+	TransferApprovalStatusPending  TransferApprovalStatus = "PENDING"
+	TransferApprovalStatusApproved TransferApprovalStatus = "APPROVED"
+	TransferApprovalStatusRejected TransferApprovalStatus = "REJECTED"
+	TransferApprovalStatusExpired  TransferApprovalStatus = "EXPIRED"
+	TransferApprovalStatusExecuted TransferApprovalStatus = "EXECUTED"
+	TransferApprovalStatusFailed   TransferApprovalStatus = "FAILED"
+)
+
+// IsValid reports whether s is one of the known statuses
+func (s TransferApprovalStatus) IsValid() bool {
+	switch s {
+	case TransferApprovalStatusPending, TransferApprovalStatusApproved, TransferApprovalStatusRejected,
+		TransferApprovalStatusExpired, TransferApprovalStatusExecuted, TransferApprovalStatusFailed:
+		return true
+	}
+	return false
+}
+
+// TransferApprovalEventType is what an audit trail entry records
+type TransferApprovalEventType string
+
+const (
+	TransferApprovalEventRequested TransferApprovalEventType = "REQUESTED"
+	TransferApprovalEventApproved  TransferApprovalEventType = "APPROVED"
+	TransferApprovalEventRejected  TransferApprovalEventType = "REJECTED"
+	TransferApprovalEventExpired   TransferApprovalEventType = "EXPIRED"
+	TransferApprovalEventExecuted  TransferApprovalEventType = "EXECUTED"
+	TransferApprovalEventFailed    TransferApprovalEventType = "FAILED"
+)
+
+// TransferApproval is a transfer above the approval threshold waiting for a
+// second principal. RequestedBy is the maker; DecidedBy is the checker who
+// approved or rejected it and is never the maker. An approved transfer is
+// APPROVED while it executes and then EXECUTED, linking TransactionID, or
+// FAILED with the code that declined it.
+type TransferApproval struct {
+	ID                   int                     `json:"approval_id" db:"id"`
+	SourceAccountID      int                     `json:"source_account_id" db:"source_account_id"`
+	DestinationAccountID int                     `json:"destination_account_id" db:"destination_account_id"`
+	Amount               decimal.Decimal         `json:"amount" db:"amount"`
+	Status               TransferApprovalStatus  `json:"status" db:"status"`
+	RequestedBy          string                  `json:"requested_by" db:"requested_by"`
+	DecidedBy            *string                 `json:"decided_by,omitempty" db:"decided_by"`
+	ExpiresAt            time.Time               `json:"expires_at" db:"expires_at"`
+	TransactionID        *int                    `json:"transaction_id,omitempty" db:"transaction_id"`
+	LastErrorCode        *int                    `json:"last_error_code,omitempty" db:"last_error_code"`
+	LastError            *string                 `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt            time.Time               `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at" db:"updated_at"`
+	Events               []TransferApprovalEvent `json:"events,omitempty" db:"-"`
+	TransferReference
+}
+
+// TransferApprovalEvent is one entry of an approval's audit trail. Principal
+// is nil for events raised by the system, such as expiry.
+type TransferApprovalEvent struct {
+	ID         int                       `json:"event_id" db:"id"`
+	ApprovalID int                       `json:"-" db:"approval_id"`
+	Event      TransferApprovalEventType `json:"event" db:"event"`
+	Principal  *string                   `json:"principal,omitempty" db:"principal"`
+	Comment    *string                   `json:"comment,omitempty" db:"comment"`
+	CreatedAt  time.Time                 `json:"created_at" db:"created_at"`
+}
+
+// TransferApprovalFilter narrows an approval listing. Zero values are
+// ignored. AfterID is the keyset cursor, as in TransferFilter.
+type TransferApprovalFilter struct {
+	SourceAccountID int
+	Status          TransferApprovalStatus
+	AfterID         int
+	Limit           int
+}
@@ -69,8 +69,12 @@ func createHandleReqArg(fun handlerFun, ctx *gin.Context) (any, error) {
 	}
 	ctx.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-	if err = jsoniter.Unmarshal(bodyBytes, reqArg); err != nil {
-		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "unmarshal request body err: %v", err)
+	// An empty body leaves every field at its zero value; the validator still
+	// rejects requests that have required fields.
+	if len(bytes.TrimSpace(bodyBytes)) > 0 {
+		if err = jsoniter.Unmarshal(bodyBytes, reqArg); err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "unmarshal request body err: %v", err)
+		}
 	}
 
 	validate := validator.New()
@@ -24,12 +24,23 @@ type RespAdapter interface {
 	PackError(c *gin.Context, err error)
 }
 
+// StatusCoder is implemented by response data that is sent with a success
+// status other than 200, such as 202 for work accepted to run later
+type StatusCoder interface {
+	StatusCode() int
+}
+
 type StdRespAdapter struct{}
 
 func (h *StdRespAdapter) PackData(c *gin.Context, data any) {
 	c.Set(RespCtxCodeLabel, codes.Success.Code)
 	c.Set(RespCtxMsgLabel, codes.Success.Msg)
-	c.AbortWithStatusJSON(http.StatusOK, data)
+
+	statusCode := http.StatusOK
+	if coder, ok := data.(StatusCoder); ok {
+		statusCode = coder.StatusCode()
+	}
+	c.AbortWithStatusJSON(statusCode, data)
 }
 
 func (h *StdRespAdapter) PackError(c *gin.Context, err error) {
@@ -79,6 +90,140 @@ func mapErrorToStatusCode(err error) int {
 		return http.StatusNotFound
 	case codes.ErrDestinationAccountNotFound.Code:
 		return http.StatusNotFound
+	case codes.ErrIdempotencyKeyReused.Code:
+		return http.StatusConflict
+	case codes.ErrInvalidTransferID.Code:
+		return http.StatusBadRequest
+	case codes.ErrTransferNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrReverseOfReversal.Code:
+		return http.StatusBadRequest
+	case codes.ErrReversalExceedsAmount.Code:
+		return http.StatusConflict
+	case codes.ErrInvalidTransferStatus.Code:
+		return http.StatusConflict
+
+	// Hold Codes
+	case codes.ErrInvalidHoldID.Code:
+		return http.StatusBadRequest
+	case codes.ErrHoldNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrHoldNotActive.Code:
+		return http.StatusConflict
+	case codes.ErrCaptureExceedsHold.Code:
+		return http.StatusBadRequest
+
+	// Scheduled Transfer Codes
+	case codes.ErrInvalidScheduledTransferID.Code:
+		return http.StatusBadRequest
+	case codes.ErrScheduledTransferNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrScheduledTransferNotPending.Code:
+		return http.StatusConflict
+
+	// Standing Order Codes
+	case codes.ErrInvalidStandingOrderID.Code:
+		return http.StatusBadRequest
+	case codes.ErrStandingOrderNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrStandingOrderNotActive.Code:
+		return http.StatusConflict
+
+	// FX Rate Codes
+	case codes.ErrFXRateUnavailable.Code:
+		return http.StatusBadRequest
+	case codes.ErrInvalidFXRateID.Code:
+		return http.StatusBadRequest
+	case codes.ErrFXRateNotFound.Code:
+		return http.StatusNotFound
+
+	// Fee Schedule Codes
+	case codes.ErrInvalidFeeScheduleID.Code:
+		return http.StatusBadRequest
+	case codes.ErrFeeScheduleNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrFeeScheduleNotActive.Code:
+		return http.StatusConflict
+
+	// Account Limit Codes
+	case codes.ErrTransactionLimitExceeded.Code:
+		return http.StatusBadRequest
+	case codes.ErrDailyLimitExceeded.Code:
+		return http.StatusBadRequest
+	case codes.ErrWeeklyLimitExceeded.Code:
+		return http.StatusBadRequest
+	case codes.ErrMonthlyLimitExceeded.Code:
+		return http.StatusBadRequest
+	case codes.ErrVelocityLimitExceeded.Code:
+		return http.StatusTooManyRequests
+
+	// Transfer Approval Codes
+	case codes.ErrInvalidApprovalID.Code:
+		return http.StatusBadRequest
+	case codes.ErrApprovalNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrApprovalNotPending.Code:
+		return http.StatusConflict
+	case codes.ErrPrincipalRequired.Code:
+		return http.StatusUnauthorized
+	case codes.ErrSelfApproval.Code:
+		return http.StatusForbidden
+	case codes.ErrApprovalRequired.Code:
+		return http.StatusBadRequest
+
+	// Transfer Reference Codes
+	case codes.ErrDuplicateClientReference.Code:
+		return http.StatusConflict
+
+	// Database Transaction Codes
+	case codes.ErrTransactionConflict.Code:
+		return http.StatusServiceUnavailable
+
+	// Hot Account Codes
+	case codes.ErrInvalidBalanceShards.Code:
+		return http.StatusBadRequest
+
+	// Async Transfer Codes
+	case codes.ErrInvalidAsyncTransferID.Code:
+		return http.StatusBadRequest
+	case codes.ErrAsyncTransferNotFound.Code:
+		return http.StatusNotFound
+
+	// Split Payment Codes
+	case codes.ErrInvalidSplitPaymentID.Code:
+		return http.StatusBadRequest
+	case codes.ErrSplitPaymentNotFound.Code:
+		return http.StatusNotFound
+
+	// Sweep Rule Codes
+	case codes.ErrInvalidSweepRuleID.Code:
+		return http.StatusBadRequest
+	case codes.ErrSweepRuleNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrSweepRuleNotActive.Code:
+		return http.StatusConflict
+	case codes.ErrSweepCurrencyMismatch.Code:
+		return http.StatusBadRequest
+	case codes.ErrSweepAboveApprovalThreshold.Code:
+		return http.StatusForbidden
+
+	// Risk Rule Codes
+	case codes.ErrRiskRuleDenied.Code:
+		return http.StatusForbidden
+	case codes.ErrInvalidRiskRuleID.Code:
+		return http.StatusBadRequest
+	case codes.ErrRiskRuleNotFound.Code:
+		return http.StatusNotFound
+	case codes.ErrRiskRuleNameTaken.Code:
+		return http.StatusConflict
+
+	// Conditional Transfer Codes
+	case codes.ErrPreconditionFailed.Code:
+		return http.StatusPreconditionFailed
+
+	// Ledger Codes
+	case codes.ErrJournalNotFound.Code:
+		return http.StatusNotFound
 		
 	default:
 		return http.StatusInternalServerError
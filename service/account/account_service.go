@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/Nauman-S/Internal-Transfers-System/codes"
 	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
 	"github.com/Nauman-S/Internal-Transfers-System/storage"
 	log "github.com/sirupsen/logrus"
 )
@@ -22,6 +23,7 @@ func CreateAccount(c *gin.Context, req *CreateAccountRequest) (*CreateAccountRes
 	log.WithFields(log.Fields{
 		"account_id": account.ID,
 		"balance":    account.InitialBalance.String(),
+		"currency":   account.Currency,
 	}).Info("Attempting to create account")
 
 	repo, err := getRepo(c)
@@ -82,10 +84,249 @@ func GetAccountByID(c *gin.Context) (*GetAccountResponse, error) {
 		"balance":    account.InitialBalance.String(),
 	}).Info("Account retrieved successfully")
 
-	return &GetAccountResponse{
-		AccountID: account.ID,
-		Balance:   account.InitialBalance.String(),
-	}, nil
+	c.Header("ETag", accountETag(account.Version))
+
+	resp := toGetAccountResponse(account)
+	return &resp, nil
+}
+
+func SetOverdraftLimit(c *gin.Context, req *SetOverdraftLimitRequest) (*GetAccountResponse, error) {
+	accountID, err := parseAccountID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := parseOverdraftLimit(req.OverdraftLimit)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Overdraft limit request validation failed")
+		return nil, err
+	}
+
+	repo, err := getRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get account repository from context")
+		return nil, err
+	}
+
+	account, err := repo.SetOverdraftLimit(c.Request.Context(), accountID, limit)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Failed to set overdraft limit")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":      account.ID,
+		"overdraft_limit": account.OverdraftLimit.String(),
+	}).Info("Overdraft limit updated successfully")
+
+	resp := toGetAccountResponse(account)
+	return &resp, nil
+}
+
+// SetBalanceShards marks the account hot, so credits to it are spread over
+// several balance rows instead of queueing on one.
+func SetBalanceShards(c *gin.Context, req *SetBalanceShardsRequest) (*GetAccountResponse, error) {
+	accountID, err := parseAccountID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get account repository from context")
+		return nil, err
+	}
+
+	account, err := repo.SetBalanceShards(c.Request.Context(), accountID, req.Shards)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Failed to set balance shards")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"account_id":     account.ID,
+		"balance_shards": account.BalanceShards,
+	}).Info("Balance shards updated successfully")
+
+	resp := toGetAccountResponse(account)
+	return &resp, nil
+}
+
+// ListOverdrawnAccounts reports every account whose ledger balance is
+// currently negative.
+func ListOverdrawnAccounts(c *gin.Context) (*ListOverdrawnAccountsResponse, error) {
+	var req ListOverdrawnAccountsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid overdrawn accounts query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid overdrawn accounts query")
+		return nil, err
+	}
+
+	repo, err := getRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get account repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	accounts, err := repo.ListOverdrawnAccounts(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list overdrawn accounts from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListOverdrawnAccountsResponse{}
+	if len(accounts) > limit {
+		accounts = accounts[:limit]
+		resp.NextCursor = encodeCursor(accounts[limit-1].ID)
+	}
+	resp.Accounts = make([]GetAccountResponse, 0, len(accounts))
+	for i := range accounts {
+		resp.Accounts = append(resp.Accounts, toGetAccountResponse(&accounts[i]))
+	}
+
+	return resp, nil
+}
+
+// ListAccountPostings pages through the account's ledger: every posting
+// that moved its balance, newest first.
+func ListAccountPostings(c *gin.Context) (*ListAccountPostingsResponse, error) {
+	accountID, err := parseAccountID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var req ListAccountPostingsRequest
+	if err = c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid account postings query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid account postings query")
+		return nil, err
+	}
+
+	repo, err := getRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get account repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	postings, err := repo.ListAccountPostings(c.Request.Context(), accountID, filter)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Failed to list account postings from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if len(postings) == 0 {
+		exists, err := repo.AccountExists(c.Request.Context(), accountID)
+		if err != nil {
+			log.WithError(err).WithField("account_id", accountID).Error("Failed to check account existence")
+			return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+		}
+		if !exists {
+			log.WithField("account_id", accountID).Warn("Account not found")
+			return nil, codes.ErrAccountNotFound
+		}
+	}
+
+	resp := &ListAccountPostingsResponse{}
+	if len(postings) > limit {
+		postings = postings[:limit]
+		resp.NextCursor = encodeCursor(postings[limit-1].ID)
+	}
+	resp.Postings = postings
+
+	return resp, nil
+}
+
+func SetAccountLimits(c *gin.Context, req *SetAccountLimitsRequest) (*models.AccountLimits, error) {
+	accountID, err := parseAccountID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := req.ToAccountLimits(accountID)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Account limits request validation failed")
+		return nil, err
+	}
+
+	repo, err := getRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get account repository from context")
+		return nil, err
+	}
+
+	limits, err = repo.SetAccountLimits(c.Request.Context(), limits)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Failed to set account limits")
+		return nil, err
+	}
+
+	log.WithField("account_id", accountID).Info("Account limits updated successfully")
+
+	return limits, nil
+}
+
+// GetAccountLimits returns the account's limits. An account without limits
+// returns an empty set.
+func GetAccountLimits(c *gin.Context) (*models.AccountLimits, error) {
+	accountID, err := parseAccountID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get account repository from context")
+		return nil, err
+	}
+
+	limits, err := repo.GetAccountLimits(c.Request.Context(), accountID)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Failed to get account limits from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+	if limits != nil {
+		return limits, nil
+	}
+
+	exists, err := repo.AccountExists(c.Request.Context(), accountID)
+	if err != nil {
+		log.WithError(err).WithField("account_id", accountID).Error("Failed to check account existence")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+	if !exists {
+		log.WithField("account_id", accountID).Warn("Account not found")
+		return nil, codes.ErrAccountNotFound
+	}
+
+	return &models.AccountLimits{AccountID: accountID}, nil
+}
+
+func parseAccountID(c *gin.Context) (int, error) {
+	accountIDStr := c.Param("account_id")
+	accountID, err := strconv.Atoi(accountIDStr)
+	if err != nil || accountID <= 0 {
+		log.WithError(err).WithField("account_id", accountIDStr).Error("Invalid account ID format")
+		return 0, codes.ErrInvalidAccountID
+	}
+	return accountID, nil
 }
 
 func getRepo(c *gin.Context) (*storage.AccountRepository, error) {
@@ -1,6 +1,9 @@
 package account
 
 import (
+	"encoding/base64"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -8,16 +11,91 @@ import (
 	"github.com/Nauman-S/Internal-Transfers-System/models"
 )
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
 type CreateAccountRequest struct {
 	AccountID      int    `json:"account_id" validate:"required,min=0"`
 	InitialBalance string `json:"initial_balance" validate:"required,numeric"`
+	// Currency is an ISO-4217 code and defaults to models.DefaultCurrency
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
+	// AccountType selects the fee schedule for accounts without their own
+	// and defaults to models.DefaultAccountType
+	AccountType string `json:"account_type" validate:"omitempty,max=32"`
+	// OverdraftLimit is how far the balance may go negative and defaults to 0
+	OverdraftLimit string `json:"overdraft_limit" validate:"omitempty,numeric"`
 }
 
 type CreateAccountResponse struct{}
 
+// GetAccountResponse reports the ledger balance and the available balance,
+// which excludes funds reserved by active holds. Balance is the ledger
+// balance, kept for existing clients. AvailableCredit is the part of the
+// overdraft limit not yet drawn on. Version changes with every change to
+// the balance and is also sent as the ETag of GET /accounts/:account_id.
 type GetAccountResponse struct {
-	AccountID int    `json:"account_id"`
-	Balance   string `json:"balance"`
+	AccountID        int    `json:"account_id"`
+	Balance          string `json:"balance"`
+	LedgerBalance    string `json:"ledger_balance"`
+	AvailableBalance string `json:"available_balance"`
+	OverdraftLimit   string `json:"overdraft_limit"`
+	AvailableCredit  string `json:"available_credit"`
+	Currency         string `json:"currency"`
+	AccountType      string `json:"account_type"`
+	BalanceShards    int    `json:"balance_shards,omitempty"`
+	Version          int64  `json:"version"`
+}
+
+// SetBalanceShardsRequest is the body of PUT /accounts/:account_id/balance-shards
+type SetBalanceShardsRequest struct {
+	Shards int `json:"shards" validate:"required,min=2"`
+}
+
+// SetOverdraftLimitRequest is the body of PUT /accounts/:account_id/overdraft
+type SetOverdraftLimitRequest struct {
+	OverdraftLimit string `json:"overdraft_limit" validate:"required,numeric"`
+}
+
+// ListOverdrawnAccountsRequest holds the query parameters of
+// GET /accounts/overdrawn
+type ListOverdrawnAccountsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListOverdrawnAccountsResponse is one page of overdrawn accounts. NextCursor
+// is empty on the last page.
+type ListOverdrawnAccountsResponse struct {
+	Accounts   []GetAccountResponse `json:"accounts"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// ListAccountPostingsRequest holds the query parameters of
+// GET /accounts/:account_id/postings
+type ListAccountPostingsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListAccountPostingsResponse is one page of an account's ledger, newest
+// posting first. NextCursor is empty on the last page.
+type ListAccountPostingsResponse struct {
+	Postings   []models.Posting `json:"postings"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// SetAccountLimitsRequest is the body of PUT /accounts/:account_id/limits.
+// It replaces every limit; omitted limits are removed. Amounts are in the
+// account's currency. VelocityCount and VelocityWindowSeconds go together.
+type SetAccountLimitsRequest struct {
+	MaxTransactionAmount  string `json:"max_transaction_amount" validate:"omitempty,numeric,gt=0"`
+	DailyLimit            string `json:"daily_limit" validate:"omitempty,numeric,gt=0"`
+	WeeklyLimit           string `json:"weekly_limit" validate:"omitempty,numeric,gt=0"`
+	MonthlyLimit          string `json:"monthly_limit" validate:"omitempty,numeric,gt=0"`
+	VelocityCount         int    `json:"velocity_count" validate:"omitempty,min=1"`
+	VelocityWindowSeconds int    `json:"velocity_window_seconds" validate:"omitempty,min=1"`
 }
 
 func (req *CreateAccountRequest) ToAccount() (*models.Account, error) {
@@ -30,11 +108,152 @@ func (req *CreateAccountRequest) ToAccount() (*models.Account, error) {
 		return nil, codes.ErrNegativeBalance
 	}
 	
+	currency := req.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
+	accountType := strings.ToUpper(req.AccountType)
+	if accountType == "" {
+		accountType = models.DefaultAccountType
+	}
+	if !models.IsValidAccountType(accountType) {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "account_type may only contain letters, digits and underscores")
+	}
+
+	overdraftLimit := decimal.Zero
+	if req.OverdraftLimit != "" {
+		overdraftLimit, err = parseOverdraftLimit(req.OverdraftLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	now := time.Now()
 	return &models.Account{
 		ID:             req.AccountID,
 		InitialBalance: balance,
+		OverdraftLimit: overdraftLimit,
+		Currency:       currency,
+		AccountType:    accountType,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}, nil
 }
+
+func (req *SetAccountLimitsRequest) ToAccountLimits(accountID int) (*models.AccountLimits, error) {
+	limits := &models.AccountLimits{AccountID: accountID}
+
+	amounts := []struct {
+		field string
+		value string
+		dest  **decimal.Decimal
+	}{
+		{"max_transaction_amount", req.MaxTransactionAmount, &limits.MaxTransactionAmount},
+		{"daily_limit", req.DailyLimit, &limits.DailyLimit},
+		{"weekly_limit", req.WeeklyLimit, &limits.WeeklyLimit},
+		{"monthly_limit", req.MonthlyLimit, &limits.MonthlyLimit},
+	}
+	for _, a := range amounts {
+		if a.value == "" {
+			continue
+		}
+		amount, err := decimal.NewFromString(a.value)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid %s format: %v", a.field, err)
+		}
+		if !amount.IsPositive() {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "%s must be positive", a.field)
+		}
+		*a.dest = &amount
+	}
+
+	if (req.VelocityCount == 0) != (req.VelocityWindowSeconds == 0) {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "velocity_count and velocity_window_seconds must be set together")
+	}
+	if req.VelocityCount > 0 {
+		limits.VelocityCount = &req.VelocityCount
+		limits.VelocityWindowSeconds = &req.VelocityWindowSeconds
+	}
+
+	return limits, nil
+}
+
+func (req *ListOverdrawnAccountsRequest) ToFilter() (models.AccountFilter, error) {
+	return parseListFilter(req.Cursor, req.Limit)
+}
+
+func (req *ListAccountPostingsRequest) ToFilter() (models.AccountFilter, error) {
+	return parseListFilter(req.Cursor, req.Limit)
+}
+
+func parseListFilter(cursor string, limit int) (models.AccountFilter, error) {
+	filter := models.AccountFilter{Limit: defaultListLimit}
+
+	if limit > maxListLimit {
+		return filter, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+	if limit > 0 {
+		filter.Limit = limit
+	}
+
+	if cursor != "" {
+		afterID, err := decodeCursor(cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+func parseOverdraftLimit(value string) (decimal.Decimal, error) {
+	limit, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, codes.NewWithMsg(codes.ErrInvalidParams, "invalid overdraft_limit format: %v", err)
+	}
+	if limit.IsNegative() {
+		return decimal.Zero, codes.NewWithMsg(codes.ErrInvalidParams, "overdraft_limit must not be negative")
+	}
+	return limit, nil
+}
+
+func toGetAccountResponse(account *models.Account) GetAccountResponse {
+	return GetAccountResponse{
+		AccountID:        account.ID,
+		Balance:          account.InitialBalance.String(),
+		LedgerBalance:    account.InitialBalance.String(),
+		AvailableBalance: account.AvailableBalance.String(),
+		OverdraftLimit:   account.OverdraftLimit.String(),
+		AvailableCredit:  account.AvailableCredit().String(),
+		Currency:         account.Currency,
+		AccountType:      account.AccountType,
+		BalanceShards:    account.BalanceShards,
+		Version:          account.Version,
+	}
+}
+
+// accountETag is the strong entity tag of an account version. Clients send
+// the version back, as expected_source_version, to make a transfer
+// conditional on it.
+func accountETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return id, nil
+}
@@ -0,0 +1,230 @@
+package fee_schedules
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+const (
+	maxFeeTiers = 20
+
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// CreateFeeScheduleRequest is the body of POST /fee-schedules. Exactly one of
+// AccountID and AccountType picks what the schedule applies to. FLAT
+// schedules need FlatAmount, PERCENTAGE ones Percentage and TIERED ones
+// Tiers; MinFee and MaxFee optionally clamp the result.
+type CreateFeeScheduleRequest struct {
+	AccountID        int              `json:"account_id" validate:"omitempty,min=1"`
+	AccountType      string           `json:"account_type" validate:"omitempty,max=32"`
+	FeeType          string           `json:"fee_type" validate:"required"`
+	FlatAmount       string           `json:"flat_amount" validate:"omitempty,numeric"`
+	Percentage       string           `json:"percentage" validate:"omitempty,numeric"`
+	Tiers            []FeeTierRequest `json:"tiers" validate:"omitempty,dive"`
+	MinFee           string           `json:"min_fee" validate:"omitempty,numeric"`
+	MaxFee           string           `json:"max_fee" validate:"omitempty,numeric"`
+	RevenueAccountID int              `json:"revenue_account_id" validate:"required,min=1"`
+}
+
+// FeeTierRequest is one tier of a TIERED schedule. Tiers are listed in
+// ascending UpTo order and only the last one may omit it.
+type FeeTierRequest struct {
+	UpTo       string `json:"up_to" validate:"omitempty,numeric"`
+	FlatAmount string `json:"flat_amount" validate:"omitempty,numeric"`
+	Percentage string `json:"percentage" validate:"omitempty,numeric"`
+}
+
+// ListFeeSchedulesRequest holds the query parameters of GET /fee-schedules
+type ListFeeSchedulesRequest struct {
+	AccountID   int    `form:"account_id" binding:"omitempty,min=1"`
+	AccountType string `form:"account_type"`
+	Active      *bool  `form:"active"`
+	Cursor      string `form:"cursor"`
+	Limit       int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListFeeSchedulesResponse is one page of fee schedules. NextCursor is empty
+// on the last page.
+type ListFeeSchedulesResponse struct {
+	FeeSchedules []models.FeeSchedule `json:"fee_schedules"`
+	NextCursor   string               `json:"next_cursor,omitempty"`
+}
+
+func (req *CreateFeeScheduleRequest) ToFeeSchedule() (*models.FeeSchedule, error) {
+	schedule := &models.FeeSchedule{
+		FeeType:          models.FeeType(req.FeeType),
+		RevenueAccountID: req.RevenueAccountID,
+	}
+
+	switch {
+	case req.AccountID > 0 && req.AccountType != "":
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "set either account_id or account_type, not both")
+	case req.AccountID > 0:
+		schedule.AccountID = &req.AccountID
+	case req.AccountType != "":
+		accountType := strings.ToUpper(req.AccountType)
+		if !models.IsValidAccountType(accountType) {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "account_type may only contain letters, digits and underscores")
+		}
+		schedule.AccountType = &accountType
+	default:
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "account_id or account_type is required")
+	}
+
+	var err error
+	switch schedule.FeeType {
+	case models.FeeTypeFlat:
+		if schedule.FlatAmount, err = parseFee("flat_amount", req.FlatAmount, true); err != nil {
+			return nil, err
+		}
+	case models.FeeTypePercentage:
+		if schedule.Percentage, err = parsePercentage("percentage", req.Percentage, true); err != nil {
+			return nil, err
+		}
+	case models.FeeTypeTiered:
+		if schedule.Tiers, err = parseTiers(req.Tiers); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "fee_type must be FLAT, PERCENTAGE or TIERED")
+	}
+
+	if schedule.MinFee, err = parseFee("min_fee", req.MinFee, false); err != nil {
+		return nil, err
+	}
+	if schedule.MaxFee, err = parseFee("max_fee", req.MaxFee, false); err != nil {
+		return nil, err
+	}
+	if schedule.MinFee != nil && schedule.MaxFee != nil && schedule.MinFee.GreaterThan(*schedule.MaxFee) {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "min_fee must not exceed max_fee")
+	}
+
+	return schedule, nil
+}
+
+func parseTiers(reqTiers []FeeTierRequest) ([]models.FeeTier, error) {
+	if len(reqTiers) == 0 {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "tiers are required for TIERED schedules")
+	}
+	if len(reqTiers) > maxFeeTiers {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "a schedule may have at most %d tiers", maxFeeTiers)
+	}
+
+	tiers := make([]models.FeeTier, 0, len(reqTiers))
+	for i, reqTier := range reqTiers {
+		var tier models.FeeTier
+		last := i == len(reqTiers)-1
+
+		switch {
+		case reqTier.UpTo == "" && !last:
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "only the last tier may omit up_to")
+		case reqTier.UpTo != "" && last:
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "the last tier must omit up_to so every amount is priced")
+		case reqTier.UpTo != "":
+			upTo, err := parseFee("up_to", reqTier.UpTo, true)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 && !upTo.GreaterThan(*tiers[i-1].UpTo) {
+				return nil, codes.NewWithMsg(codes.ErrInvalidParams, "tiers must be in ascending up_to order")
+			}
+			tier.UpTo = upTo
+		}
+
+		if flat, err := parseFee("flat_amount", reqTier.FlatAmount, false); err != nil {
+			return nil, err
+		} else if flat != nil {
+			tier.FlatAmount = *flat
+		}
+		if percentage, err := parsePercentage("percentage", reqTier.Percentage, false); err != nil {
+			return nil, err
+		} else if percentage != nil {
+			tier.Percentage = *percentage
+		}
+
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// parseFee parses a non-negative amount. An empty value is nil unless the
+// field is required.
+func parseFee(field, value string, required bool) (*decimal.Decimal, error) {
+	if value == "" {
+		if required {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "%s is required", field)
+		}
+		return nil, nil
+	}
+
+	amount, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid %s format: %v", field, err)
+	}
+	if amount.IsNegative() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "%s must not be negative", field)
+	}
+	return &amount, nil
+}
+
+func parsePercentage(field, value string, required bool) (*decimal.Decimal, error) {
+	percentage, err := parseFee(field, value, required)
+	if err != nil || percentage == nil {
+		return percentage, err
+	}
+	if percentage.GreaterThan(decimal.NewFromInt(100)) {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "%s must be at most 100", field)
+	}
+	return percentage, nil
+}
+
+func (req *ListFeeSchedulesRequest) ToFilter() (models.FeeScheduleFilter, error) {
+	filter := models.FeeScheduleFilter{
+		AccountID:   req.AccountID,
+		AccountType: strings.ToUpper(req.AccountType),
+		Active:      req.Active,
+		Limit:       defaultListLimit,
+	}
+
+	if req.Limit > maxListLimit {
+		return filter, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+	if req.Limit > 0 {
+		filter.Limit = req.Limit
+	}
+
+	if req.Cursor != "" {
+		afterID, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return id, nil
+}
@@ -0,0 +1,158 @@
+package fee_schedules
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateFeeSchedule(c *gin.Context, req *CreateFeeScheduleRequest) (*models.FeeSchedule, error) {
+	schedule, err := req.ToFeeSchedule()
+	if err != nil {
+		log.WithError(err).Error("Fee schedule request validation failed")
+		return nil, err
+	}
+
+	repo, err := getFeeScheduleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get fee schedule repository from context")
+		return nil, err
+	}
+
+	schedule, err = repo.CreateFeeSchedule(c.Request.Context(), schedule)
+	if err != nil {
+		log.WithError(err).Error("Fee schedule creation failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"fee_schedule_id":    schedule.ID,
+		"fee_type":           schedule.FeeType,
+		"revenue_account_id": schedule.RevenueAccountID,
+	}).Info("Fee schedule created successfully")
+
+	return schedule, nil
+}
+
+func ListFeeSchedules(c *gin.Context) (*ListFeeSchedulesResponse, error) {
+	var req ListFeeSchedulesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid fee schedule query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid fee schedule query")
+		return nil, err
+	}
+
+	repo, err := getFeeScheduleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get fee schedule repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	schedules, err := repo.ListFeeSchedules(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list fee schedules from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListFeeSchedulesResponse{FeeSchedules: schedules}
+	if len(schedules) > limit {
+		resp.FeeSchedules = schedules[:limit]
+		resp.NextCursor = encodeCursor(resp.FeeSchedules[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func GetFeeScheduleByID(c *gin.Context) (*models.FeeSchedule, error) {
+	scheduleID, err := parseFeeScheduleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getFeeScheduleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get fee schedule repository from context")
+		return nil, err
+	}
+
+	schedule, err := repo.GetFeeScheduleByID(c.Request.Context(), scheduleID)
+	if err != nil {
+		log.WithError(err).WithField("fee_schedule_id", scheduleID).Error("Failed to get fee schedule from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if schedule == nil {
+		log.WithField("fee_schedule_id", scheduleID).Warn("Fee schedule not found")
+		return nil, codes.ErrFeeScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+func DeactivateFeeSchedule(c *gin.Context) (*models.FeeSchedule, error) {
+	scheduleID, err := parseFeeScheduleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getFeeScheduleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get fee schedule repository from context")
+		return nil, err
+	}
+
+	schedule, err := repo.DeactivateFeeSchedule(c.Request.Context(), scheduleID)
+	if err != nil {
+		log.WithError(err).WithField("fee_schedule_id", scheduleID).Error("Deactivating fee schedule failed")
+		return nil, err
+	}
+
+	log.WithField("fee_schedule_id", schedule.ID).Info("Fee schedule deactivated successfully")
+
+	return schedule, nil
+}
+
+func parseFeeScheduleID(c *gin.Context) (int, error) {
+	scheduleIDStr := c.Param("fee_schedule_id")
+	scheduleID, err := strconv.Atoi(scheduleIDStr)
+	if err != nil || scheduleID <= 0 {
+		log.WithError(err).WithField("fee_schedule_id", scheduleIDStr).Error("Invalid fee schedule ID format")
+		return 0, codes.ErrInvalidFeeScheduleID
+	}
+	return scheduleID, nil
+}
+
+func getFeeScheduleRepo(c *gin.Context) (*storage.FeeScheduleRepository, error) {
+	appConfigInterface, exists := c.Get("appConfig")
+	if !exists {
+		log.Error("App config not found in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	appConfig, ok := appConfigInterface.(*config.ApplicationConfig)
+	if !ok {
+		log.Error("Invalid app config type in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	if appConfig.FeeScheduleRepository == nil {
+		log.Error("Fee schedule repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.FeeScheduleRepository, nil
+}
@@ -0,0 +1,113 @@
+package fx_rates
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// CreateFXRateRequest is the body of POST /fx-rates. Rate converts one unit
+// of BaseCurrency into QuoteCurrency. EffectiveAt defaults to now; a future
+// value stages the rate without replacing the current one yet.
+type CreateFXRateRequest struct {
+	BaseCurrency  string `json:"base_currency" validate:"required,iso4217"`
+	QuoteCurrency string `json:"quote_currency" validate:"required,iso4217"`
+	Rate          string `json:"rate" validate:"required,numeric,gt=0"`
+	EffectiveAt   string `json:"effective_at"`
+}
+
+// ListFXRatesRequest holds the query parameters of GET /fx-rates
+type ListFXRatesRequest struct {
+	BaseCurrency  string `form:"base_currency" binding:"omitempty,iso4217"`
+	QuoteCurrency string `form:"quote_currency" binding:"omitempty,iso4217"`
+	Cursor        string `form:"cursor"`
+	Limit         int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListFXRatesResponse is one page of FX rates. NextCursor is empty on the
+// last page.
+type ListFXRatesResponse struct {
+	FXRates    []models.FXRate `json:"fx_rates"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func (req *CreateFXRateRequest) ToFXRate() (*models.FXRate, error) {
+	if req.BaseCurrency == req.QuoteCurrency {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "base_currency and quote_currency must differ")
+	}
+
+	rate, err := decimal.NewFromString(req.Rate)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid rate format: %v", err)
+	}
+	if !rate.IsPositive() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "rate must be positive")
+	}
+
+	fxRate := &models.FXRate{
+		BaseCurrency:  req.BaseCurrency,
+		QuoteCurrency: req.QuoteCurrency,
+		Rate:          rate,
+		EffectiveAt:   time.Now(),
+	}
+
+	if req.EffectiveAt != "" {
+		fxRate.EffectiveAt, err = time.Parse(time.RFC3339, req.EffectiveAt)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "effective_at must be an RFC3339 timestamp")
+		}
+	}
+
+	return fxRate, nil
+}
+
+func (req *ListFXRatesRequest) ToFilter() (models.FXRateFilter, error) {
+	filter := models.FXRateFilter{
+		BaseCurrency:  req.BaseCurrency,
+		QuoteCurrency: req.QuoteCurrency,
+		Limit:         defaultListLimit,
+	}
+
+	if req.Limit > maxListLimit {
+		return filter, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+	if req.Limit > 0 {
+		filter.Limit = req.Limit
+	}
+
+	if req.Cursor != "" {
+		afterID, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return id, nil
+}
@@ -0,0 +1,130 @@
+package fx_rates
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateFXRate(c *gin.Context, req *CreateFXRateRequest) (*models.FXRate, error) {
+	rate, err := req.ToFXRate()
+	if err != nil {
+		log.WithError(err).Error("FX rate request validation failed")
+		return nil, err
+	}
+
+	repo, err := getFXRateRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get FX rate repository from context")
+		return nil, err
+	}
+
+	rate, err = repo.CreateFXRate(c.Request.Context(), rate)
+	if err != nil {
+		log.WithError(err).Error("Failed to store FX rate")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	log.WithFields(log.Fields{
+		"fx_rate_id":     rate.ID,
+		"base_currency":  rate.BaseCurrency,
+		"quote_currency": rate.QuoteCurrency,
+		"rate":           rate.Rate.String(),
+		"effective_at":   rate.EffectiveAt.Format(time.RFC3339),
+	}).Info("FX rate created successfully")
+
+	return rate, nil
+}
+
+func ListFXRates(c *gin.Context) (*ListFXRatesResponse, error) {
+	var req ListFXRatesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid FX rate query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid FX rate query")
+		return nil, err
+	}
+
+	repo, err := getFXRateRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get FX rate repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	rates, err := repo.ListFXRates(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list FX rates from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListFXRatesResponse{FXRates: rates}
+	if len(rates) > limit {
+		resp.FXRates = rates[:limit]
+		resp.NextCursor = encodeCursor(resp.FXRates[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func GetFXRateByID(c *gin.Context) (*models.FXRate, error) {
+	rateIDStr := c.Param("fx_rate_id")
+	rateID, err := strconv.Atoi(rateIDStr)
+	if err != nil || rateID <= 0 {
+		log.WithError(err).WithField("fx_rate_id", rateIDStr).Error("Invalid FX rate ID format")
+		return nil, codes.ErrInvalidFXRateID
+	}
+
+	repo, err := getFXRateRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get FX rate repository from context")
+		return nil, err
+	}
+
+	rate, err := repo.GetFXRateByID(c.Request.Context(), rateID)
+	if err != nil {
+		log.WithError(err).WithField("fx_rate_id", rateID).Error("Failed to get FX rate from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if rate == nil {
+		log.WithField("fx_rate_id", rateID).Warn("FX rate not found")
+		return nil, codes.ErrFXRateNotFound
+	}
+
+	return rate, nil
+}
+
+func getFXRateRepo(c *gin.Context) (*storage.FXRateRepository, error) {
+	appConfigInterface, exists := c.Get("appConfig")
+	if !exists {
+		log.Error("App config not found in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	appConfig, ok := appConfigInterface.(*config.ApplicationConfig)
+	if !ok {
+		log.Error("Invalid app config type in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	if appConfig.FXRateRepository == nil {
+		log.Error("FX rate repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.FXRateRepository, nil
+}
@@ -0,0 +1,102 @@
+package holds
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// AuthorizeHoldRequest reserves funds on the source account for a later
+// capture to the destination. ExpiresInSeconds falls back to the configured
+// default hold lifetime.
+type AuthorizeHoldRequest struct {
+	SourceAccountID      int    `json:"source_account_id" validate:"required,min=1"`
+	DestinationAccountID int    `json:"destination_account_id" validate:"required,min=1"`
+	Amount               string `json:"amount" validate:"required,numeric,gt=0"`
+	ExpiresInSeconds     int    `json:"expires_in_seconds" validate:"omitempty,min=1"`
+}
+
+// CaptureHoldRequest captures part of a hold. An empty amount captures it in full.
+type CaptureHoldRequest struct {
+	Amount string `json:"amount,omitempty" validate:"omitempty,numeric"`
+}
+
+type HoldResponse struct {
+	HoldID               int    `json:"hold_id"`
+	Status               string `json:"status"`
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	CapturedAmount       string `json:"captured_amount,omitempty"`
+	AvailableBalance     string `json:"available_balance,omitempty"`
+	TransactionID        int    `json:"transaction_id,omitempty"`
+	SourceBalance        string `json:"source_balance,omitempty"`
+	DestinationBalance   string `json:"destination_balance,omitempty"`
+	ExpiresAt            string `json:"expires_at"`
+	CreatedAt            string `json:"created_at"`
+}
+
+func (req *AuthorizeHoldRequest) ToHold(defaultTTL time.Duration) (*models.Hold, error) {
+	if req.SourceAccountID == req.DestinationAccountID {
+		return nil, codes.ErrSameAccountTransfer
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid amount format: %v", err)
+	}
+
+	if !amount.IsPositive() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount must be positive")
+	}
+
+	ttl := defaultTTL
+	if req.ExpiresInSeconds > 0 {
+		ttl = time.Duration(req.ExpiresInSeconds) * time.Second
+	}
+
+	return &models.Hold{
+		AccountID:            req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               amount,
+		ExpiresAt:            time.Now().Add(ttl),
+	}, nil
+}
+
+func (req *CaptureHoldRequest) ParseAmount() (*decimal.Decimal, error) {
+	if req.Amount == "" {
+		return nil, nil
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid amount format: %v", err)
+	}
+
+	if !amount.IsPositive() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount must be positive")
+	}
+
+	return &amount, nil
+}
+
+func NewHoldResponse(hold *models.Hold) *HoldResponse {
+	resp := &HoldResponse{
+		HoldID:               hold.ID,
+		Status:               string(hold.Status),
+		SourceAccountID:      hold.AccountID,
+		DestinationAccountID: hold.DestinationAccountID,
+		Amount:               hold.Amount.String(),
+		ExpiresAt:            hold.ExpiresAt.Format(time.RFC3339),
+		CreatedAt:            hold.CreatedAt.Format(time.RFC3339),
+	}
+	if hold.CapturedAmount != nil {
+		resp.CapturedAmount = hold.CapturedAmount.String()
+	}
+	if hold.TransactionID != nil {
+		resp.TransactionID = *hold.TransactionID
+	}
+	return resp
+}
@@ -0,0 +1,183 @@
+package holds
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func AuthorizeHold(c *gin.Context, req *AuthorizeHoldRequest) (*HoldResponse, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	hold, err := req.ToHold(appConfig.HoldDefaultTTL)
+	if err != nil {
+		log.WithError(err).Error("Hold request validation failed")
+		return nil, err
+	}
+
+	if appConfig.RequiresApproval(hold.Amount) {
+		log.WithField("amount", hold.Amount.String()).Error("Hold exceeds approval threshold")
+		return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "holds above the approval threshold are not allowed; submit the transfer through POST /transactions")
+	}
+
+	repo, err := getHoldRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get hold repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source_account_id":      hold.AccountID,
+		"destination_account_id": hold.DestinationAccountID,
+		"amount":                 hold.Amount.String(),
+	}).Info("Authorizing hold")
+
+	available, err := repo.AuthorizeHold(c.Request.Context(), hold)
+	if err != nil {
+		log.WithError(err).WithField("source_account_id", hold.AccountID).Error("Hold authorization failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"hold_id":           hold.ID,
+		"source_account_id": hold.AccountID,
+		"available_balance": available.String(),
+	}).Info("Hold authorized successfully")
+
+	resp := NewHoldResponse(hold)
+	resp.AvailableBalance = available.String()
+	return resp, nil
+}
+
+func CaptureHold(c *gin.Context, req *CaptureHoldRequest) (*HoldResponse, error) {
+	holdID, err := parseHoldID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := req.ParseAmount()
+	if err != nil {
+		log.WithError(err).Error("Capture request validation failed")
+		return nil, err
+	}
+
+	repo, err := getHoldRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get hold repository from context")
+		return nil, err
+	}
+
+	hold, transfer, sourceBalance, destBalance, err := repo.CaptureHold(c.Request.Context(), holdID, amount)
+	if err != nil {
+		log.WithError(err).WithField("hold_id", holdID).Error("Hold capture failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"hold_id":        hold.ID,
+		"transaction_id": transfer.ID,
+		"amount":         transfer.Amount.String(),
+	}).Info("Hold captured successfully")
+
+	resp := NewHoldResponse(hold)
+	resp.SourceBalance = sourceBalance.String()
+	resp.DestinationBalance = destBalance.String()
+	return resp, nil
+}
+
+func VoidHold(c *gin.Context) (*HoldResponse, error) {
+	holdID, err := parseHoldID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getHoldRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get hold repository from context")
+		return nil, err
+	}
+
+	hold, err := repo.VoidHold(c.Request.Context(), holdID)
+	if err != nil {
+		log.WithError(err).WithField("hold_id", holdID).Error("Hold void failed")
+		return nil, err
+	}
+
+	log.WithField("hold_id", hold.ID).Info("Hold voided successfully")
+
+	return NewHoldResponse(hold), nil
+}
+
+func GetHoldByID(c *gin.Context) (*HoldResponse, error) {
+	holdID, err := parseHoldID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getHoldRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get hold repository from context")
+		return nil, err
+	}
+
+	hold, err := repo.GetHoldByID(c.Request.Context(), holdID)
+	if err != nil {
+		log.WithError(err).WithField("hold_id", holdID).Error("Failed to get hold from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if hold == nil {
+		log.WithField("hold_id", holdID).Warn("Hold not found")
+		return nil, codes.ErrHoldNotFound
+	}
+
+	return NewHoldResponse(hold), nil
+}
+
+func parseHoldID(c *gin.Context) (int, error) {
+	holdIDStr := c.Param("hold_id")
+	holdID, err := strconv.Atoi(holdIDStr)
+	if err != nil || holdID <= 0 {
+		log.WithError(err).WithField("hold_id", holdIDStr).Error("Invalid hold ID format")
+		return 0, codes.ErrInvalidHoldID
+	}
+	return holdID, nil
+}
+
+func getAppConfig(c *gin.Context) (*config.ApplicationConfig, error) {
+	appConfigInterface, exists := c.Get("appConfig")
+	if !exists {
+		log.Error("App config not found in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	appConfig, ok := appConfigInterface.(*config.ApplicationConfig)
+	if !ok {
+		log.Error("Invalid app config type in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig, nil
+}
+
+func getHoldRepo(c *gin.Context) (*storage.HoldRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.HoldRepository == nil {
+		log.Error("Hold repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.HoldRepository, nil
+}
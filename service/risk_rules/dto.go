@@ -0,0 +1,202 @@
+package risk_rules
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+const (
+	// defaultPriority places rules that do not ask for a priority after
+	// those that do, in the order they were created
+	defaultPriority = 100
+
+	maxBlockedAccounts = 1000
+
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// CreateRiskRuleRequest is the body of POST /risk-rules. Which of the
+// condition fields are required depends on RuleType: Amount for
+// AMOUNT_THRESHOLD, MaxAccountAgeSeconds for NEW_ACCOUNT_AGE,
+// BlockedAccountIDs for BLOCKLIST and RoundMultiple for ROUND_AMOUNT, which
+// also takes Amount as the smallest amount it checks. AccountID and
+// Currency narrow any rule. Rules are enabled unless Enabled is false.
+type CreateRiskRuleRequest struct {
+	Name                 string `json:"name" validate:"required,max=64"`
+	RuleType             string `json:"rule_type" validate:"required"`
+	Action               string `json:"action" validate:"required"`
+	Priority             *int   `json:"priority"`
+	AccountID            int    `json:"account_id" validate:"omitempty,min=1"`
+	AccountSide          string `json:"account_side"`
+	Amount               string `json:"amount" validate:"omitempty,numeric"`
+	Currency             string `json:"currency" validate:"omitempty,iso4217"`
+	MaxAccountAgeSeconds int    `json:"max_account_age_seconds" validate:"omitempty,min=1"`
+	BlockedAccountIDs    []int  `json:"blocked_account_ids" validate:"omitempty,dive,min=1"`
+	RoundMultiple        string `json:"round_multiple" validate:"omitempty,numeric"`
+	Enabled              *bool  `json:"enabled"`
+}
+
+// ListRiskRulesRequest holds the query parameters of GET /risk-rules
+type ListRiskRulesRequest struct {
+	EnabledOnly bool `form:"enabled_only"`
+}
+
+// ListRiskRulesResponse lists rules in the order they are evaluated
+type ListRiskRulesResponse struct {
+	RiskRules []models.RiskRule `json:"risk_rules"`
+}
+
+// ReloadRiskRulesResponse is the result of POST /risk-rules/reload
+type ReloadRiskRulesResponse struct {
+	Loaded int `json:"loaded"`
+}
+
+// ListFlagsRequest holds the query parameters of
+// GET /risk-rules/:risk_rule_id/flags
+type ListFlagsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListFlagsResponse is one page of the transfers a rule flagged, newest
+// first
+type ListFlagsResponse struct {
+	Flags      []models.RiskRuleFlag `json:"flags"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+func (req *CreateRiskRuleRequest) ToRiskRule() (*models.RiskRule, error) {
+	rule := &models.RiskRule{
+		Name:        strings.TrimSpace(req.Name),
+		Type:        models.RiskRuleType(req.RuleType),
+		Action:      models.RiskAction(req.Action),
+		Priority:    defaultPriority,
+		AccountSide: models.RiskAccountSideEither,
+		Enabled:     true,
+	}
+
+	if rule.Name == "" {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "name must not be blank")
+	}
+	if !rule.Type.IsValid() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "rule_type must be AMOUNT_THRESHOLD, NEW_ACCOUNT_AGE, BLOCKLIST or ROUND_AMOUNT")
+	}
+	if !rule.Action.IsValid() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "action must be ALLOW, DENY or FLAG")
+	}
+
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.AccountID > 0 {
+		rule.AccountID = &req.AccountID
+	}
+	if req.Currency != "" {
+		currency := strings.ToUpper(req.Currency)
+		rule.Currency = &currency
+	}
+
+	if req.AccountSide != "" {
+		rule.AccountSide = models.RiskAccountSide(req.AccountSide)
+		if !rule.AccountSide.IsValid() {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "account_side must be SOURCE, DESTINATION or EITHER")
+		}
+	}
+
+	var err error
+	if req.Amount != "" {
+		if rule.Amount, err = parsePositive("amount", req.Amount); err != nil {
+			return nil, err
+		}
+	}
+	if req.RoundMultiple != "" {
+		if rule.RoundMultiple, err = parsePositive("round_multiple", req.RoundMultiple); err != nil {
+			return nil, err
+		}
+	}
+	if req.MaxAccountAgeSeconds > 0 {
+		rule.MaxAccountAgeSeconds = &req.MaxAccountAgeSeconds
+	}
+	if len(req.BlockedAccountIDs) > maxBlockedAccounts {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "a blocklist may hold at most %d accounts", maxBlockedAccounts)
+	}
+	rule.BlockedAccountIDs = req.BlockedAccountIDs
+
+	switch rule.Type {
+	case models.RiskRuleTypeAmountThreshold:
+		if rule.Amount == nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount is required for AMOUNT_THRESHOLD rules")
+		}
+	case models.RiskRuleTypeNewAccountAge:
+		if rule.MaxAccountAgeSeconds == nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "max_account_age_seconds is required for NEW_ACCOUNT_AGE rules")
+		}
+	case models.RiskRuleTypeBlocklist:
+		if len(rule.BlockedAccountIDs) == 0 {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "blocked_account_ids is required for BLOCKLIST rules")
+		}
+	case models.RiskRuleTypeRoundAmount:
+		if rule.RoundMultiple == nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "round_multiple is required for ROUND_AMOUNT rules")
+		}
+	}
+
+	if rule.MaxAccountAgeSeconds != nil && rule.Type != models.RiskRuleTypeNewAccountAge {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "max_account_age_seconds only applies to NEW_ACCOUNT_AGE rules")
+	}
+	if len(rule.BlockedAccountIDs) > 0 && rule.Type != models.RiskRuleTypeBlocklist {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "blocked_account_ids only applies to BLOCKLIST rules")
+	}
+	if rule.RoundMultiple != nil && rule.Type != models.RiskRuleTypeRoundAmount {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "round_multiple only applies to ROUND_AMOUNT rules")
+	}
+
+	return rule, nil
+}
+
+func parsePositive(field, value string) (*decimal.Decimal, error) {
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid %s format: %v", field, err)
+	}
+	if !parsed.IsPositive() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "%s must be positive", field)
+	}
+	return &parsed, nil
+}
+
+func parseLimit(limit int) (int, error) {
+	if limit == 0 {
+		return defaultListLimit, nil
+	}
+	if limit > maxListLimit {
+		return 0, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+	return limit, nil
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return id, nil
+}
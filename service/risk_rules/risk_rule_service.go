@@ -0,0 +1,252 @@
+package risk_rules
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateRiskRule(c *gin.Context, req *CreateRiskRuleRequest) (*models.RiskRule, error) {
+	rule, err := req.ToRiskRule()
+	if err != nil {
+		log.WithError(err).Error("Risk rule request validation failed")
+		return nil, err
+	}
+
+	repo, err := getRiskRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get risk rule repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"name":      rule.Name,
+		"rule_type": rule.Type,
+		"action":    rule.Action,
+	}).Info("Creating risk rule")
+
+	if err = repo.CreateRiskRule(c.Request.Context(), rule); err != nil {
+		log.WithError(err).WithField("name", rule.Name).Error("Risk rule creation failed")
+		return nil, err
+	}
+
+	log.WithField("risk_rule_id", rule.ID).Info("Risk rule created successfully")
+
+	reloadRiskRules(c, repo)
+
+	return rule, nil
+}
+
+func ListRiskRules(c *gin.Context) (*ListRiskRulesResponse, error) {
+	var req ListRiskRulesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid risk rule query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	repo, err := getRiskRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get risk rule repository from context")
+		return nil, err
+	}
+
+	rules, err := repo.ListRiskRules(c.Request.Context(), req.EnabledOnly)
+	if err != nil {
+		log.WithError(err).Error("Failed to list risk rules from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	return &ListRiskRulesResponse{RiskRules: rules}, nil
+}
+
+func GetRiskRuleByID(c *gin.Context) (*models.RiskRule, error) {
+	ruleID, err := parseRiskRuleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getRiskRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get risk rule repository from context")
+		return nil, err
+	}
+
+	return getRiskRule(c, repo, ruleID)
+}
+
+func EnableRiskRule(c *gin.Context) (*models.RiskRule, error) {
+	return setRiskRuleEnabled(c, true)
+}
+
+func DisableRiskRule(c *gin.Context) (*models.RiskRule, error) {
+	return setRiskRuleEnabled(c, false)
+}
+
+// ReloadRiskRules loads the enabled rules into this server right away
+// rather than on the next periodic reload, for rules changed directly in
+// the database.
+func ReloadRiskRules(c *gin.Context) (*ReloadRiskRulesResponse, error) {
+	repo, err := getRiskRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get risk rule repository from context")
+		return nil, err
+	}
+
+	loaded, err := repo.ReloadRiskRules(c.Request.Context())
+	if err != nil {
+		log.WithError(err).Error("Failed to reload risk rules")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	log.WithField("loaded", loaded).Info("Risk rules reloaded")
+
+	return &ReloadRiskRulesResponse{Loaded: loaded}, nil
+}
+
+func ListRiskRuleFlags(c *gin.Context) (*ListFlagsResponse, error) {
+	ruleID, err := parseRiskRuleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var req ListFlagsRequest
+	if err = c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid risk rule flag query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	limit, err := parseLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterID int
+	if req.Cursor != "" {
+		afterID, err = decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+	}
+
+	repo, err := getRiskRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get risk rule repository from context")
+		return nil, err
+	}
+
+	if _, err = getRiskRule(c, repo, ruleID); err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	flags, err := repo.ListRiskRuleFlags(c.Request.Context(), ruleID, afterID, limit+1)
+	if err != nil {
+		log.WithError(err).WithField("risk_rule_id", ruleID).Error("Failed to list risk rule flags from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListFlagsResponse{Flags: flags}
+	if len(flags) > limit {
+		resp.Flags = flags[:limit]
+		resp.NextCursor = encodeCursor(resp.Flags[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func setRiskRuleEnabled(c *gin.Context, enabled bool) (*models.RiskRule, error) {
+	ruleID, err := parseRiskRuleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getRiskRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get risk rule repository from context")
+		return nil, err
+	}
+
+	rule, err := repo.SetRiskRuleEnabled(c.Request.Context(), ruleID, enabled)
+	if err != nil {
+		log.WithError(err).WithField("risk_rule_id", ruleID).Error("Updating risk rule failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"risk_rule_id": rule.ID,
+		"enabled":      rule.Enabled,
+	}).Info("Risk rule updated successfully")
+
+	reloadRiskRules(c, repo)
+
+	return rule, nil
+}
+
+// reloadRiskRules puts a rule change into effect on this server. If the
+// reload fails the change is already stored, so it is only logged and the
+// periodic reload picks the change up.
+func reloadRiskRules(c *gin.Context, repo *storage.RiskRuleRepository) {
+	if _, err := repo.ReloadRiskRules(c.Request.Context()); err != nil {
+		log.WithError(err).Error("Failed to reload risk rules after a change")
+	}
+}
+
+func getRiskRule(c *gin.Context, repo *storage.RiskRuleRepository, ruleID int) (*models.RiskRule, error) {
+	rule, err := repo.GetRiskRuleByID(c.Request.Context(), ruleID)
+	if err != nil {
+		log.WithError(err).WithField("risk_rule_id", ruleID).Error("Failed to get risk rule from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if rule == nil {
+		log.WithField("risk_rule_id", ruleID).Warn("Risk rule not found")
+		return nil, codes.ErrRiskRuleNotFound
+	}
+
+	return rule, nil
+}
+
+func parseRiskRuleID(c *gin.Context) (int, error) {
+	ruleIDStr := c.Param("risk_rule_id")
+	ruleID, err := strconv.Atoi(ruleIDStr)
+	if err != nil || ruleID <= 0 {
+		log.WithError(err).WithField("risk_rule_id", ruleIDStr).Error("Invalid risk rule ID format")
+		return 0, codes.ErrInvalidRiskRuleID
+	}
+	return ruleID, nil
+}
+
+func getAppConfig(c *gin.Context) (*config.ApplicationConfig, error) {
+	appConfigInterface, exists := c.Get("appConfig")
+	if !exists {
+		log.Error("App config not found in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	appConfig, ok := appConfigInterface.(*config.ApplicationConfig)
+	if !ok {
+		log.Error("Invalid app config type in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig, nil
+}
+
+func getRiskRuleRepo(c *gin.Context) (*storage.RiskRuleRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.RiskRuleRepository == nil {
+		log.Error("Risk rule repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.RiskRuleRepository, nil
+}
@@ -0,0 +1,214 @@
+package standing_orders
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+const (
+	// minIntervalSeconds keeps INTERVAL orders from running more often than
+	// the executor can reasonably poll
+	minIntervalSeconds = 60
+
+	defaultMaxRetries           = 3
+	defaultRetryIntervalSeconds = 3600
+
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// CreateStandingOrderRequest is the body of POST /standing-orders. StartAt
+// defaults to now and sets the time of day of calendar rules. DayOfWeek is
+// required for WEEKLY orders, DayOfMonth for MONTHLY ones and
+// IntervalSeconds for INTERVAL ones.
+type CreateStandingOrderRequest struct {
+	SourceAccountID      int    `json:"source_account_id" validate:"required,min=1"`
+	DestinationAccountID int    `json:"destination_account_id" validate:"required,min=1"`
+	Amount               string `json:"amount" validate:"required,numeric,gt=0"`
+	Frequency            string `json:"frequency" validate:"required"`
+	IntervalSeconds      int    `json:"interval_seconds" validate:"omitempty,min=1"`
+	DayOfWeek            *int   `json:"day_of_week" validate:"omitempty,min=0,max=6"`
+	DayOfMonth           int    `json:"day_of_month" validate:"omitempty,min=1,max=31"`
+	StartAt              string `json:"start_at"`
+	EndAt                string `json:"end_at"`
+	MaxRuns              int    `json:"max_runs" validate:"omitempty,min=1"`
+	FailurePolicy        string `json:"failure_policy"`
+	MaxRetries           int    `json:"max_retries" validate:"omitempty,min=1"`
+	RetryIntervalSeconds int    `json:"retry_interval_seconds" validate:"omitempty,min=1"`
+}
+
+// ListStandingOrdersRequest holds the query parameters of GET /standing-orders
+type ListStandingOrdersRequest struct {
+	SourceAccountID      int    `form:"source_account_id" binding:"omitempty,min=1"`
+	DestinationAccountID int    `form:"destination_account_id" binding:"omitempty,min=1"`
+	AccountID            int    `form:"account_id" binding:"omitempty,min=1"`
+	Status               string `form:"status"`
+	Cursor               string `form:"cursor"`
+	Limit                int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListStandingOrdersResponse is one page of standing orders. NextCursor is
+// empty on the last page.
+type ListStandingOrdersResponse struct {
+	StandingOrders []models.StandingOrder `json:"standing_orders"`
+	NextCursor     string                 `json:"next_cursor,omitempty"`
+}
+
+// ListRunsRequest holds the query parameters of
+// GET /standing-orders/:standing_order_id/runs
+type ListRunsRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListRunsResponse is one page of an order's execution history, newest first
+type ListRunsResponse struct {
+	Runs       []models.StandingOrderRun `json:"runs"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+func (req *CreateStandingOrderRequest) ToStandingOrder() (*models.StandingOrder, error) {
+	if req.SourceAccountID == req.DestinationAccountID {
+		return nil, codes.ErrSameAccountTransfer
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid amount format: %v", err)
+	}
+	if !amount.IsPositive() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount must be positive")
+	}
+
+	order := &models.StandingOrder{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               amount,
+		Frequency:            models.StandingOrderFrequency(req.Frequency),
+		StartAt:              time.Now().UTC().Truncate(time.Second),
+	}
+
+	switch order.Frequency {
+	case models.StandingOrderFrequencyInterval:
+		if req.IntervalSeconds < minIntervalSeconds {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "interval_seconds must be at least %d for INTERVAL orders", minIntervalSeconds)
+		}
+		order.IntervalSeconds = &req.IntervalSeconds
+	case models.StandingOrderFrequencyWeekly:
+		if req.DayOfWeek == nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "day_of_week is required for WEEKLY orders")
+		}
+		order.DayOfWeek = req.DayOfWeek
+	case models.StandingOrderFrequencyMonthly:
+		if req.DayOfMonth == 0 {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "day_of_month is required for MONTHLY orders")
+		}
+		order.DayOfMonth = &req.DayOfMonth
+	case models.StandingOrderFrequencyDaily, models.StandingOrderFrequencyEndOfMonth:
+	default:
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "unknown frequency %q", req.Frequency)
+	}
+
+	if req.StartAt != "" {
+		order.StartAt, err = time.Parse(time.RFC3339, req.StartAt)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "start_at must be an RFC3339 timestamp")
+		}
+	}
+
+	if req.EndAt != "" {
+		endAt, err := time.Parse(time.RFC3339, req.EndAt)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "end_at must be an RFC3339 timestamp")
+		}
+		if endAt.Before(order.FirstOccurrence()) {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "end_at is before the first run")
+		}
+		order.EndAt = &endAt
+	}
+
+	if req.MaxRuns > 0 {
+		order.MaxRuns = &req.MaxRuns
+	}
+
+	order.FailurePolicy = models.StandingOrderFailurePolicy(req.FailurePolicy)
+	order.RetryIntervalSeconds = defaultRetryIntervalSeconds
+	switch order.FailurePolicy {
+	case "", models.StandingOrderFailurePolicySkip:
+		order.FailurePolicy = models.StandingOrderFailurePolicySkip
+	case models.StandingOrderFailurePolicyRetry:
+		order.MaxRetries = defaultMaxRetries
+		if req.MaxRetries > 0 {
+			order.MaxRetries = req.MaxRetries
+		}
+		if req.RetryIntervalSeconds > 0 {
+			order.RetryIntervalSeconds = req.RetryIntervalSeconds
+		}
+	default:
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "failure_policy must be SKIP or RETRY")
+	}
+
+	return order, nil
+}
+
+func (req *ListStandingOrdersRequest) ToFilter() (models.StandingOrderFilter, error) {
+	filter := models.StandingOrderFilter{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		AccountID:            req.AccountID,
+	}
+
+	limit, err := parseLimit(req.Limit)
+	if err != nil {
+		return filter, err
+	}
+	filter.Limit = limit
+
+	if req.Status != "" {
+		filter.Status = models.StandingOrderStatus(req.Status)
+		if !filter.Status.IsValid() {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "unknown status %q", req.Status)
+		}
+	}
+
+	if req.Cursor != "" {
+		filter.AfterID, err = decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+	}
+
+	return filter, nil
+}
+
+func parseLimit(limit int) (int, error) {
+	if limit == 0 {
+		return defaultListLimit, nil
+	}
+	if limit > maxListLimit {
+		return 0, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+	return limit, nil
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return id, nil
+}
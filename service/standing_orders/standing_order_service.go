@@ -0,0 +1,239 @@
+package standing_orders
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateStandingOrder(c *gin.Context, req *CreateStandingOrderRequest) (*models.StandingOrder, error) {
+	order, err := req.ToStandingOrder()
+	if err != nil {
+		log.WithError(err).Error("Standing order request validation failed")
+		return nil, err
+	}
+
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	if appConfig.RequiresApproval(order.Amount) {
+		log.WithField("amount", order.Amount.String()).Error("Standing order exceeds approval threshold")
+		return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "standing orders above the approval threshold are not allowed")
+	}
+
+	repo, err := getStandingOrderRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get standing order repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source_account_id":      order.SourceAccountID,
+		"destination_account_id": order.DestinationAccountID,
+		"amount":                 order.Amount.String(),
+		"frequency":              order.Frequency,
+	}).Info("Creating standing order")
+
+	if err = repo.CreateStandingOrder(c.Request.Context(), order); err != nil {
+		log.WithError(err).WithField("source_account_id", order.SourceAccountID).Error("Standing order creation failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"standing_order_id": order.ID,
+		"next_run_at":       order.NextRunAt.Format(time.RFC3339),
+	}).Info("Standing order created successfully")
+
+	return order, nil
+}
+
+func ListStandingOrders(c *gin.Context) (*ListStandingOrdersResponse, error) {
+	var req ListStandingOrdersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid standing order query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid standing order query")
+		return nil, err
+	}
+
+	repo, err := getStandingOrderRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get standing order repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	orders, err := repo.ListStandingOrders(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list standing orders from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListStandingOrdersResponse{StandingOrders: orders}
+	if len(orders) > limit {
+		resp.StandingOrders = orders[:limit]
+		resp.NextCursor = encodeCursor(resp.StandingOrders[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func GetStandingOrderByID(c *gin.Context) (*models.StandingOrder, error) {
+	orderID, err := parseStandingOrderID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getStandingOrderRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get standing order repository from context")
+		return nil, err
+	}
+
+	return getStandingOrder(c, repo, orderID)
+}
+
+func CancelStandingOrder(c *gin.Context) (*models.StandingOrder, error) {
+	orderID, err := parseStandingOrderID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getStandingOrderRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get standing order repository from context")
+		return nil, err
+	}
+
+	order, err := repo.CancelStandingOrder(c.Request.Context(), orderID)
+	if err != nil {
+		log.WithError(err).WithField("standing_order_id", orderID).Error("Cancelling standing order failed")
+		return nil, err
+	}
+
+	log.WithField("standing_order_id", order.ID).Info("Standing order cancelled successfully")
+
+	return order, nil
+}
+
+func ListStandingOrderRuns(c *gin.Context) (*ListRunsResponse, error) {
+	orderID, err := parseStandingOrderID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var req ListRunsRequest
+	if err = c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid standing order run query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	limit, err := parseLimit(req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterID int
+	if req.Cursor != "" {
+		afterID, err = decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+	}
+
+	repo, err := getStandingOrderRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get standing order repository from context")
+		return nil, err
+	}
+
+	if _, err = getStandingOrder(c, repo, orderID); err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	runs, err := repo.ListStandingOrderRuns(c.Request.Context(), orderID, afterID, limit+1)
+	if err != nil {
+		log.WithError(err).WithField("standing_order_id", orderID).Error("Failed to list standing order runs from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListRunsResponse{Runs: runs}
+	if len(runs) > limit {
+		resp.Runs = runs[:limit]
+		resp.NextCursor = encodeCursor(resp.Runs[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func getStandingOrder(c *gin.Context, repo *storage.StandingOrderRepository, orderID int) (*models.StandingOrder, error) {
+	order, err := repo.GetStandingOrderByID(c.Request.Context(), orderID)
+	if err != nil {
+		log.WithError(err).WithField("standing_order_id", orderID).Error("Failed to get standing order from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if order == nil {
+		log.WithField("standing_order_id", orderID).Warn("Standing order not found")
+		return nil, codes.ErrStandingOrderNotFound
+	}
+
+	return order, nil
+}
+
+func parseStandingOrderID(c *gin.Context) (int, error) {
+	orderIDStr := c.Param("standing_order_id")
+	orderID, err := strconv.Atoi(orderIDStr)
+	if err != nil || orderID <= 0 {
+		log.WithError(err).WithField("standing_order_id", orderIDStr).Error("Invalid standing order ID format")
+		return 0, codes.ErrInvalidStandingOrderID
+	}
+	return orderID, nil
+}
+
+func getAppConfig(c *gin.Context) (*config.ApplicationConfig, error) {
+	appConfigInterface, exists := c.Get("appConfig")
+	if !exists {
+		log.Error("App config not found in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	appConfig, ok := appConfigInterface.(*config.ApplicationConfig)
+	if !ok {
+		log.Error("Invalid app config type in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig, nil
+}
+
+func getStandingOrderRepo(c *gin.Context) (*storage.StandingOrderRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.StandingOrderRepository == nil {
+		log.Error("Standing order repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.StandingOrderRepository, nil
+}
@@ -0,0 +1,180 @@
+package sweep_rules
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+const (
+	// minIntervalSeconds keeps INTERVAL rules from running more often than
+	// the scheduler can reasonably poll
+	minIntervalSeconds = 60
+
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// CreateSweepRuleRequest is the body of POST /sweep-rules. A SWEEP_EXCESS
+// rule moves whatever the source holds above Threshold to the destination;
+// a TOP_UP rule moves whatever the destination lacks to reach Threshold from
+// the source. Runs that would move less than MinAmount are skipped. The
+// schedule fields work as for standing orders.
+type CreateSweepRuleRequest struct {
+	RuleType             string `json:"rule_type" validate:"required"`
+	SourceAccountID      int    `json:"source_account_id" validate:"required,min=1"`
+	DestinationAccountID int    `json:"destination_account_id" validate:"required,min=1"`
+	Threshold            string `json:"threshold" validate:"required,numeric"`
+	MinAmount            string `json:"min_amount" validate:"omitempty,numeric,gt=0"`
+	Frequency            string `json:"frequency" validate:"required"`
+	IntervalSeconds      int    `json:"interval_seconds" validate:"omitempty,min=1"`
+	DayOfWeek            *int   `json:"day_of_week" validate:"omitempty,min=0,max=6"`
+	DayOfMonth           int    `json:"day_of_month" validate:"omitempty,min=1,max=31"`
+	StartAt              string `json:"start_at"`
+}
+
+// ListSweepRulesRequest holds the query parameters of GET /sweep-rules
+type ListSweepRulesRequest struct {
+	SourceAccountID      int    `form:"source_account_id" binding:"omitempty,min=1"`
+	DestinationAccountID int    `form:"destination_account_id" binding:"omitempty,min=1"`
+	AccountID            int    `form:"account_id" binding:"omitempty,min=1"`
+	Status               string `form:"status"`
+	Cursor               string `form:"cursor"`
+	Limit                int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListSweepRulesResponse is one page of sweep rules. NextCursor is empty on
+// the last page.
+type ListSweepRulesResponse struct {
+	SweepRules []models.SweepRule `json:"sweep_rules"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+func (req *CreateSweepRuleRequest) ToSweepRule() (*models.SweepRule, error) {
+	if req.SourceAccountID == req.DestinationAccountID {
+		return nil, codes.ErrSameAccountTransfer
+	}
+
+	rule := &models.SweepRule{
+		Type:                 models.SweepRuleType(req.RuleType),
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Frequency:            models.StandingOrderFrequency(req.Frequency),
+		StartAt:              time.Now().UTC().Truncate(time.Second),
+	}
+
+	if !rule.Type.IsValid() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "rule_type must be SWEEP_EXCESS or TOP_UP")
+	}
+
+	threshold, err := decimal.NewFromString(req.Threshold)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid threshold format: %v", err)
+	}
+	if threshold.IsNegative() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "threshold must not be negative")
+	}
+	rule.Threshold = threshold
+
+	if req.MinAmount != "" {
+		minAmount, err := decimal.NewFromString(req.MinAmount)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid min_amount format: %v", err)
+		}
+		if !minAmount.IsPositive() {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "min_amount must be positive")
+		}
+		rule.MinAmount = &minAmount
+	}
+
+	switch rule.Frequency {
+	case models.StandingOrderFrequencyInterval:
+		if req.IntervalSeconds < minIntervalSeconds {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "interval_seconds must be at least %d for INTERVAL rules", minIntervalSeconds)
+		}
+		rule.IntervalSeconds = &req.IntervalSeconds
+	case models.StandingOrderFrequencyWeekly:
+		if req.DayOfWeek == nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "day_of_week is required for WEEKLY rules")
+		}
+		rule.DayOfWeek = req.DayOfWeek
+	case models.StandingOrderFrequencyMonthly:
+		if req.DayOfMonth == 0 {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "day_of_month is required for MONTHLY rules")
+		}
+		rule.DayOfMonth = &req.DayOfMonth
+	case models.StandingOrderFrequencyDaily, models.StandingOrderFrequencyEndOfMonth:
+	default:
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "unknown frequency %q", req.Frequency)
+	}
+
+	if req.StartAt != "" {
+		rule.StartAt, err = time.Parse(time.RFC3339, req.StartAt)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "start_at must be an RFC3339 timestamp")
+		}
+	}
+
+	return rule, nil
+}
+
+func (req *ListSweepRulesRequest) ToFilter() (models.SweepRuleFilter, error) {
+	filter := models.SweepRuleFilter{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		AccountID:            req.AccountID,
+	}
+
+	limit, err := parseLimit(req.Limit)
+	if err != nil {
+		return filter, err
+	}
+	filter.Limit = limit
+
+	if req.Status != "" {
+		filter.Status = models.SweepRuleStatus(req.Status)
+		if !filter.Status.IsValid() {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "unknown status %q", req.Status)
+		}
+	}
+
+	if req.Cursor != "" {
+		filter.AfterID, err = decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+	}
+
+	return filter, nil
+}
+
+func parseLimit(limit int) (int, error) {
+	if limit == 0 {
+		return defaultListLimit, nil
+	}
+	if limit > maxListLimit {
+		return 0, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+	return limit, nil
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return id, nil
+}
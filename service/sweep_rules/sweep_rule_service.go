@@ -0,0 +1,174 @@
+package sweep_rules
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateSweepRule(c *gin.Context, req *CreateSweepRuleRequest) (*models.SweepRule, error) {
+	rule, err := req.ToSweepRule()
+	if err != nil {
+		log.WithError(err).Error("Sweep rule request validation failed")
+		return nil, err
+	}
+
+	repo, err := getSweepRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get sweep rule repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"rule_type":              rule.Type,
+		"source_account_id":      rule.SourceAccountID,
+		"destination_account_id": rule.DestinationAccountID,
+		"threshold":              rule.Threshold.String(),
+		"frequency":              rule.Frequency,
+	}).Info("Creating sweep rule")
+
+	if err = repo.CreateSweepRule(c.Request.Context(), rule); err != nil {
+		log.WithError(err).WithField("source_account_id", rule.SourceAccountID).Error("Sweep rule creation failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"sweep_rule_id": rule.ID,
+		"next_run_at":   rule.NextRunAt.Format(time.RFC3339),
+	}).Info("Sweep rule created successfully")
+
+	return rule, nil
+}
+
+func ListSweepRules(c *gin.Context) (*ListSweepRulesResponse, error) {
+	var req ListSweepRulesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid sweep rule query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid sweep rule query")
+		return nil, err
+	}
+
+	repo, err := getSweepRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get sweep rule repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	rules, err := repo.ListSweepRules(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list sweep rules from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListSweepRulesResponse{SweepRules: rules}
+	if len(rules) > limit {
+		resp.SweepRules = rules[:limit]
+		resp.NextCursor = encodeCursor(resp.SweepRules[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func GetSweepRuleByID(c *gin.Context) (*models.SweepRule, error) {
+	ruleID, err := parseSweepRuleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getSweepRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get sweep rule repository from context")
+		return nil, err
+	}
+
+	rule, err := repo.GetSweepRuleByID(c.Request.Context(), ruleID)
+	if err != nil {
+		log.WithError(err).WithField("sweep_rule_id", ruleID).Error("Failed to get sweep rule from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if rule == nil {
+		log.WithField("sweep_rule_id", ruleID).Warn("Sweep rule not found")
+		return nil, codes.ErrSweepRuleNotFound
+	}
+
+	return rule, nil
+}
+
+func CancelSweepRule(c *gin.Context) (*models.SweepRule, error) {
+	ruleID, err := parseSweepRuleID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getSweepRuleRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get sweep rule repository from context")
+		return nil, err
+	}
+
+	rule, err := repo.CancelSweepRule(c.Request.Context(), ruleID)
+	if err != nil {
+		log.WithError(err).WithField("sweep_rule_id", ruleID).Error("Cancelling sweep rule failed")
+		return nil, err
+	}
+
+	log.WithField("sweep_rule_id", rule.ID).Info("Sweep rule cancelled successfully")
+
+	return rule, nil
+}
+
+func parseSweepRuleID(c *gin.Context) (int, error) {
+	ruleIDStr := c.Param("sweep_rule_id")
+	ruleID, err := strconv.Atoi(ruleIDStr)
+	if err != nil || ruleID <= 0 {
+		log.WithError(err).WithField("sweep_rule_id", ruleIDStr).Error("Invalid sweep rule ID format")
+		return 0, codes.ErrInvalidSweepRuleID
+	}
+	return ruleID, nil
+}
+
+func getAppConfig(c *gin.Context) (*config.ApplicationConfig, error) {
+	appConfigInterface, exists := c.Get("appConfig")
+	if !exists {
+		log.Error("App config not found in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	appConfig, ok := appConfigInterface.(*config.ApplicationConfig)
+	if !ok {
+		log.Error("Invalid app config type in context")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig, nil
+}
+
+func getSweepRuleRepo(c *gin.Context) (*storage.SweepRuleRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.SweepRuleRepository == nil {
+		log.Error("Sweep rule repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.SweepRuleRepository, nil
+}
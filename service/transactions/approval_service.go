@@ -0,0 +1,296 @@
+package transactions
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// approvalOutcomeTimeout bounds recording the outcome of an approved
+// transfer once it has run
+const approvalOutcomeTimeout = 5 * time.Second
+
+// retryableApprovalCodes are failures that say nothing about the transfer
+// itself. An approved transfer failing on one of them stays APPROVED and is
+// run again by the approval executor.
+var retryableApprovalCodes = map[int]bool{
+	codes.ErrSystem.Code:              true,
+	codes.ErrTimeout.Code:             true,
+	codes.ErrTransactionConflict.Code: true,
+}
+
+// requestTransferApproval stores a transfer above the approval threshold
+// until a second principal decides it. The principal making the request is
+// recorded as its maker.
+func requestTransferApproval(c *gin.Context, appConfig *config.ApplicationConfig, req *TransferRequest, amount decimal.Decimal, idempotencyKey *models.IdempotencyKey) (*TransferResponse, error) {
+	principal, err := parsePrincipal(c.GetHeader(PrincipalHeader))
+	if err != nil {
+		log.WithError(err).Error("Transfer needing approval has no principal")
+		return nil, err
+	}
+
+	repo, err := getApprovalRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer approval repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source_account_id":      req.SourceAccountID,
+		"destination_account_id": req.DestinationAccountID,
+		"amount":                 amount.String(),
+		"requested_by":           principal,
+	}).Info("Transfer exceeds approval threshold, requesting approval")
+
+	approval, err := repo.RequestApproval(c.Request.Context(), &models.TransferApproval{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               amount,
+		RequestedBy:          principal,
+		ExpiresAt:            time.Now().Add(appConfig.ApprovalTTL),
+		TransferReference:    req.Reference(),
+	}, idempotencyKey)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"source_account_id":      req.SourceAccountID,
+			"destination_account_id": req.DestinationAccountID,
+		}).Error("Requesting transfer approval failed")
+		return nil, err
+	}
+
+	log.WithField("approval_id", approval.ID).Info("Transfer approval requested successfully")
+
+	return NewApprovalTransferResponse(approval), nil
+}
+
+func ListApprovals(c *gin.Context) (*ListApprovalsResponse, error) {
+	var req ListApprovalsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid transfer approval query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid transfer approval query")
+		return nil, err
+	}
+
+	repo, err := getApprovalRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer approval repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	approvals, err := repo.ListApprovals(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list transfer approvals from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListApprovalsResponse{Approvals: approvals}
+	if len(approvals) > limit {
+		resp.Approvals = approvals[:limit]
+		resp.NextCursor = encodeCursor(resp.Approvals[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+// GetApprovalByID returns the approval together with its audit trail
+func GetApprovalByID(c *gin.Context) (*models.TransferApproval, error) {
+	approvalID, err := parseApprovalID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getApprovalRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer approval repository from context")
+		return nil, err
+	}
+
+	approval, err := repo.GetApprovalByID(c.Request.Context(), approvalID)
+	if err != nil {
+		log.WithError(err).WithField("approval_id", approvalID).Error("Failed to get transfer approval from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if approval == nil {
+		log.WithField("approval_id", approvalID).Warn("Transfer approval not found")
+		return nil, codes.ErrApprovalNotFound
+	}
+
+	return approval, nil
+}
+
+// ApproveTransfer records the checker's approval and executes the transfer
+// through ExecuteApprovedTransfer. A transfer declined on execution, for
+// example for insufficient funds, leaves the approval FAILED and returns the
+// decline.
+func ApproveTransfer(c *gin.Context, req *ApprovalDecisionRequest) (*models.TransferApproval, error) {
+	approvalID, principal, err := parseApprovalDecision(c)
+	if err != nil {
+		return nil, err
+	}
+
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	repo, err := getApprovalRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer approval repository from context")
+		return nil, err
+	}
+
+	if _, err = getTransferRepo(c); err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	approval, err := repo.ApproveTransfer(c.Request.Context(), approvalID, principal, req.CommentOrNil())
+	if err != nil {
+		log.WithError(err).WithField("approval_id", approvalID).Error("Approving transfer failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"approval_id": approval.ID,
+		"decided_by":  principal,
+	}).Info("Transfer approved, executing")
+
+	return ExecuteApprovedTransfer(c.Request.Context(), appConfig, approval)
+}
+
+// ExecuteApprovedTransfer runs the transfer of an APPROVED approval through
+// ProcessTransfer under the approval's idempotency key, so running it again
+// after its outcome was lost replays the transfer instead of moving the
+// money twice. The outcome is recorded on a context detached from ctx, so a
+// request that times out or disconnects once the transfer is applied still
+// links it. Only a business decline fails the approval; any other error
+// leaves it APPROVED for the approval executor to retry.
+func ExecuteApprovedTransfer(ctx context.Context, appConfig *config.ApplicationConfig, approval *models.TransferApproval) (*models.TransferApproval, error) {
+	repo := appConfig.TransferApprovalRepository
+
+	transfer, sourceBalance, destBalance, err := appConfig.TransferRepository.ProcessTransfer(ctx, &models.Transfer{
+		SourceAccountID:      approval.SourceAccountID,
+		DestinationAccountID: approval.DestinationAccountID,
+		Amount:               approval.Amount,
+		TransferReference:    approval.TransferReference,
+	}, approvalIdempotencyKey(approval, appConfig.IdempotencyKeyTTL))
+
+	outcomeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), approvalOutcomeTimeout)
+	defer cancel()
+
+	if err != nil {
+		if retryableApprovalCodes[codes.GetCode(err)] {
+			log.WithError(err).WithField("approval_id", approval.ID).Warn("Approved transfer attempt failed, will retry")
+			if retryErr := repo.RetryApproval(outcomeCtx, approval.ID, err); retryErr != nil {
+				log.WithError(retryErr).WithField("approval_id", approval.ID).Error("Failed to record transfer approval attempt")
+			}
+			return nil, err
+		}
+
+		log.WithError(err).WithField("approval_id", approval.ID).Error("Approved transfer failed")
+		if _, markErr := repo.MarkApprovalFailed(outcomeCtx, approval.ID, err); markErr != nil {
+			log.WithError(markErr).WithField("approval_id", approval.ID).Error("Failed to record transfer approval outcome")
+		}
+		return nil, err
+	}
+
+	executed, err := repo.MarkApprovalExecuted(outcomeCtx, approval.ID, transfer.ID)
+	if err != nil {
+		log.WithError(err).WithField("approval_id", approval.ID).Error("Failed to record transfer approval outcome")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"approval_id":         executed.ID,
+		"transaction_id":      transfer.ID,
+		"source_balance":      sourceBalance.String(),
+		"destination_balance": destBalance.String(),
+	}).Info("Approved transfer executed successfully")
+
+	return executed, nil
+}
+
+// RejectTransfer records the checker's rejection; the transfer never runs
+func RejectTransfer(c *gin.Context, req *ApprovalDecisionRequest) (*models.TransferApproval, error) {
+	approvalID, principal, err := parseApprovalDecision(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getApprovalRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer approval repository from context")
+		return nil, err
+	}
+
+	approval, err := repo.RejectTransfer(c.Request.Context(), approvalID, principal, req.CommentOrNil())
+	if err != nil {
+		log.WithError(err).WithField("approval_id", approvalID).Error("Rejecting transfer failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"approval_id": approval.ID,
+		"decided_by":  principal,
+	}).Info("Transfer rejected successfully")
+
+	return approval, nil
+}
+
+func parseApprovalDecision(c *gin.Context) (int, string, error) {
+	approvalID, err := parseApprovalID(c)
+	if err != nil {
+		return 0, "", err
+	}
+
+	principal, err := parsePrincipal(c.GetHeader(PrincipalHeader))
+	if err != nil {
+		log.WithError(err).WithField("approval_id", approvalID).Error("Transfer approval decision has no principal")
+		return 0, "", err
+	}
+
+	return approvalID, principal, nil
+}
+
+func parseApprovalID(c *gin.Context) (int, error) {
+	approvalIDStr := c.Param("approval_id")
+	approvalID, err := strconv.Atoi(approvalIDStr)
+	if err != nil || approvalID <= 0 {
+		log.WithError(err).WithField("approval_id", approvalIDStr).Error("Invalid approval ID format")
+		return 0, codes.ErrInvalidApprovalID
+	}
+	return approvalID, nil
+}
+
+func getApprovalRepo(c *gin.Context) (*storage.TransferApprovalRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.TransferApprovalRepository == nil {
+		log.Error("Transfer approval repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.TransferApprovalRepository, nil
+}
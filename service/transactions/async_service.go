@@ -0,0 +1,101 @@
+package transactions
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// enqueueTransfer stores the transfer for the async transfer workers in
+// cmd/server, which apply it without the request deadline.
+func enqueueTransfer(c *gin.Context, req *TransferRequest, amount decimal.Decimal, idempotencyKey *models.IdempotencyKey) (*TransferResponse, error) {
+	repo, err := getAsyncTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get async transfer repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source_account_id":      req.SourceAccountID,
+		"destination_account_id": req.DestinationAccountID,
+		"amount":                 amount.String(),
+	}).Info("Queueing async transfer")
+
+	async := &models.AsyncTransfer{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               amount,
+		TransferReference:    req.Reference(),
+	}
+	if req.CallbackURL != "" {
+		async.CallbackURL = &req.CallbackURL
+	}
+
+	async, err = repo.EnqueueTransfer(c.Request.Context(), async, idempotencyKey)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"source_account_id":      req.SourceAccountID,
+			"destination_account_id": req.DestinationAccountID,
+		}).Error("Queueing async transfer failed")
+		return nil, err
+	}
+
+	log.WithField("async_transfer_id", async.ID).Info("Async transfer queued successfully")
+
+	return NewAsyncTransferResponse(async), nil
+}
+
+func GetAsyncTransferByID(c *gin.Context) (*models.AsyncTransfer, error) {
+	asyncID, err := parseAsyncTransferID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getAsyncTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get async transfer repository from context")
+		return nil, err
+	}
+
+	async, err := repo.GetAsyncTransferByID(c.Request.Context(), asyncID)
+	if err != nil {
+		log.WithError(err).WithField("async_transfer_id", asyncID).Error("Failed to get async transfer from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if async == nil {
+		log.WithField("async_transfer_id", asyncID).Warn("Async transfer not found")
+		return nil, codes.ErrAsyncTransferNotFound
+	}
+
+	return async, nil
+}
+
+func parseAsyncTransferID(c *gin.Context) (int, error) {
+	asyncIDStr := c.Param("async_transfer_id")
+	asyncID, err := strconv.Atoi(asyncIDStr)
+	if err != nil || asyncID <= 0 {
+		log.WithError(err).WithField("async_transfer_id", asyncIDStr).Error("Invalid async transfer ID format")
+		return 0, codes.ErrInvalidAsyncTransferID
+	}
+	return asyncID, nil
+}
+
+func getAsyncTransferRepo(c *gin.Context) (*storage.AsyncTransferRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.AsyncTransferRepository == nil {
+		log.Error("Async transfer repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.AsyncTransferRepository, nil
+}
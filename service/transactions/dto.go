@@ -1,26 +1,132 @@
 package transactions
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	jsoniter "github.com/json-iterator/go"
 	"github.com/shopspring/decimal"
 	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
 )
 
+const (
+	IdempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
+
+	// PrincipalHeader names who is making a request. Transfers that need
+	// approval record it as the maker, and approvals as the checker.
+	PrincipalHeader    = "X-Principal"
+	maxPrincipalLength = 255
+
+	defaultListLimit = 50
+	maxListLimit     = 200
+
+	maxBatchLegs = 500
+
+	// maxSplitLegs bounds the destinations of a split payment, and
+	// splitValueScale is the most decimal places its amounts and
+	// percentages may have
+	maxSplitLegs    = 100
+	splitValueScale = 8
+
+	maxMetadataBytes = 4096
+
+	maxCallbackURLLength = 2048
+)
+
+// TransferRequest is the body of POST /transactions. A future ExecuteAt
+// (RFC3339) schedules the transfer instead of applying it immediately.
+// Async queues the transfer for the async transfer workers and answers 202
+// straight away; CallbackURL, only allowed with Async, is POSTed the final
+// state. Memo, ClientReference and Metadata are optional and stored with the
+// transfer; ClientReference must be unique among the source's transfers.
+// ExpectedSourceVersion and ExpectedSourceBalance make the transfer
+// conditional on the source still being at that version, as last read from
+// GET /accounts/:account_id, or holding that balance. They are only allowed
+// on transfers applied straight away.
 type TransferRequest struct {
-	SourceAccountID      int    `json:"source_account_id" validate:"required,min=1"`
-	DestinationAccountID int    `json:"destination_account_id" validate:"required,min=1"`
-	Amount              string `json:"amount" validate:"required,numeric,gt=0"`
+	SourceAccountID       int            `json:"source_account_id" validate:"required,min=1"`
+	DestinationAccountID  int            `json:"destination_account_id" validate:"required,min=1"`
+	Amount                string         `json:"amount" validate:"required,numeric,gt=0"`
+	ExecuteAt             string         `json:"execute_at,omitempty"`
+	Memo                  string         `json:"memo,omitempty" validate:"max=255"`
+	ClientReference       string         `json:"client_reference,omitempty" validate:"max=128"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	Async                 bool           `json:"async,omitempty"`
+	CallbackURL           string         `json:"callback_url,omitempty" validate:"omitempty,url"`
+	ExpectedSourceVersion *int64         `json:"expected_source_version,omitempty" validate:"omitempty,min=1"`
+	ExpectedSourceBalance string         `json:"expected_source_balance,omitempty" validate:"omitempty,numeric"`
 }
 
-// TransferResponse represents the response after processing a transfer
+// TransferResponse represents the response after processing a transfer. A
+// scheduled transfer has no transaction or balances yet; it is PENDING and
+// carries its scheduled_transfer_id instead. A transfer waiting for approval
+// is PENDING with its approval_id and the time the approval expires. An
+// async transfer is QUEUED with its async_transfer_id and sent with 202.
+// Amount is in the source currency; a cross-currency transfer also reports
+// the destination amount and the FX rate it was converted at. Fee is charged
+// to the source on top of Amount.
 type TransferResponse struct {
-	TransactionID       int    `json:"transaction_id"`
+	TransactionID       int    `json:"transaction_id,omitempty"`
+	ScheduledTransferID int    `json:"scheduled_transfer_id,omitempty"`
+	ApprovalID          int    `json:"approval_id,omitempty"`
+	AsyncTransferID     int    `json:"async_transfer_id,omitempty"`
 	Status             string `json:"status"`
-	SourceBalance      string `json:"source_balance"`
-	DestinationBalance string `json:"destination_balance"`
+	SourceBalance      string `json:"source_balance,omitempty"`
+	DestinationBalance string `json:"destination_balance,omitempty"`
 	Amount             string `json:"amount"`
+	SourceCurrency      string `json:"source_currency,omitempty"`
+	DestinationCurrency string `json:"destination_currency,omitempty"`
+	DestinationAmount   string `json:"destination_amount,omitempty"`
+	FXRateID            int    `json:"fx_rate_id,omitempty"`
+	FXRate              string `json:"fx_rate,omitempty"`
+	Fee                 string `json:"fee,omitempty"`
+	FeeAccountID        int    `json:"fee_account_id,omitempty"`
+	ReversalOf         int    `json:"reversal_of,omitempty"`
+	ExecuteAt          string `json:"execute_at,omitempty"`
+	ExpiresAt           string `json:"expires_at,omitempty"`
 	CreatedAt          string `json:"created_at"`
+	models.TransferReference
+}
+
+// QuoteResponse previews a transfer POST /transactions/quote was asked
+// about without moving any money. The balances are what the two accounts
+// would hold straight after the transfer at the current balances, rates and
+// fees; a later transfer may differ if any of those change first.
+// RequiresApproval is set when POST /transactions would wait for a second
+// principal before applying the transfer.
+type QuoteResponse struct {
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	SourceCurrency       string `json:"source_currency"`
+	DestinationCurrency  string `json:"destination_currency"`
+	DestinationAmount    string `json:"destination_amount"`
+	FXRateID             int    `json:"fx_rate_id,omitempty"`
+	FXRate               string `json:"fx_rate,omitempty"`
+	Fee                  string `json:"fee"`
+	FeeAccountID         int    `json:"fee_account_id,omitempty"`
+	SourceBalance        string `json:"source_balance"`
+	DestinationBalance   string `json:"destination_balance"`
+	RequiresApproval     bool   `json:"requires_approval"`
+}
+
+// StatusCode is 202 for a transfer queued to run asynchronously
+func (resp *TransferResponse) StatusCode() int {
+	if resp.AsyncTransferID > 0 {
+		return http.StatusAccepted
+	}
+	return http.StatusOK
 }
 
 func (req *TransferRequest) ValidateRequest() error {
@@ -37,16 +143,825 @@ func (req *TransferRequest) ValidateRequest() error {
 		return codes.NewWithMsg(codes.ErrInvalidParams, "amount must be positive")
 	}
 
+	if req.ExecuteAt != "" {
+		if _, err = time.Parse(time.RFC3339, req.ExecuteAt); err != nil {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "execute_at must be an RFC3339 timestamp")
+		}
+		if req.Async {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "async cannot be combined with execute_at")
+		}
+	}
+
+	if req.CallbackURL != "" {
+		if !req.Async {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "callback_url is only allowed with async")
+		}
+		if len(req.CallbackURL) > maxCallbackURLLength {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "callback_url must be at most %d characters", maxCallbackURLLength)
+		}
+		callbackURL, err := url.Parse(req.CallbackURL)
+		if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "callback_url must be an absolute http or https URL")
+		}
+	}
+
+	if req.ExpectedSourceBalance != "" {
+		if _, err = decimal.NewFromString(req.ExpectedSourceBalance); err != nil {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "invalid expected_source_balance format: %v", err)
+		}
+	}
+	if req.Precondition() != nil && (req.ExecuteAt != "" || req.Async) {
+		return codes.NewWithMsg(codes.ErrInvalidParams, "expected_source_version and expected_source_balance cannot be combined with execute_at or async")
+	}
+
+	return validateReference(req.ClientReference, req.Metadata)
+}
+
+// validateReference checks the parts of a transfer reference the validate
+// tags cannot: a blank client reference and the size of the metadata.
+func validateReference(clientReference string, metadata map[string]any) error {
+	if clientReference != "" && strings.TrimSpace(clientReference) == "" {
+		return codes.NewWithMsg(codes.ErrInvalidParams, "client_reference must not be blank")
+	}
+
+	if len(metadata) > 0 {
+		encoded, err := jsoniter.Marshal(metadata)
+		if err != nil {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "invalid metadata: %v", err)
+		}
+		if len(encoded) > maxMetadataBytes {
+			return codes.NewWithMsg(codes.ErrInvalidParams, "metadata must be at most %d bytes of JSON", maxMetadataBytes)
+		}
+	}
+
 	return nil
 }
 
-func (req *TransferRequest) ToResponse(transactionID int, sourceBalance, destBalance decimal.Decimal) *TransferResponse {
-	return &TransferResponse{
-		TransactionID:       transactionID,
-		Status:             "COMPLETED",
+// Reference returns the memo, client reference and metadata to store with
+// the transfer, leaving out those that were not given.
+func (req *TransferRequest) Reference() models.TransferReference {
+	return newReference(req.Memo, req.ClientReference, req.Metadata)
+}
+
+func newReference(memo, clientReference string, metadata map[string]any) models.TransferReference {
+	var reference models.TransferReference
+	if memo != "" {
+		reference.Memo = &memo
+	}
+	if clientReference != "" {
+		reference.ClientReference = &clientReference
+	}
+	if len(metadata) > 0 {
+		reference.Metadata = metadata
+	}
+	return reference
+}
+
+func (req *TransferRequest) ToTransfer(amount decimal.Decimal) *models.Transfer {
+	return &models.Transfer{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               amount,
+		Precondition:         req.Precondition(),
+		TransferReference:    req.Reference(),
+	}
+}
+
+// Precondition returns what the source must still look like for the
+// transfer to go ahead, or nil for an unconditional transfer. An expected
+// balance that does not parse is left out; ValidateRequest rejects it.
+func (req *TransferRequest) Precondition() *models.TransferPrecondition {
+	if req.ExpectedSourceVersion == nil && req.ExpectedSourceBalance == "" {
+		return nil
+	}
+
+	precondition := &models.TransferPrecondition{SourceVersion: req.ExpectedSourceVersion}
+	if balance, err := decimal.NewFromString(req.ExpectedSourceBalance); err == nil {
+		precondition.SourceBalance = &balance
+	}
+	return precondition
+}
+
+// ToQuoteResponse echoes the requested amount like ToResponse
+func (req *TransferRequest) ToQuoteResponse(transfer *models.Transfer, sourceBalance, destBalance decimal.Decimal, requiresApproval bool) *QuoteResponse {
+	resp := &QuoteResponse{
+		SourceAccountID:      transfer.SourceAccountID,
+		DestinationAccountID: transfer.DestinationAccountID,
+		Amount:               req.Amount,
+		SourceCurrency:       transfer.SourceCurrency,
+		DestinationCurrency:  transfer.DestinationCurrency,
+		DestinationAmount:    transfer.DestinationAmount.String(),
+		Fee:                  transfer.Fee.String(),
+		SourceBalance:        sourceBalance.String(),
+		DestinationBalance:   destBalance.String(),
+		RequiresApproval:     requiresApproval,
+	}
+	if transfer.FXRateID != nil {
+		resp.FXRateID = *transfer.FXRateID
+	}
+	if transfer.FXRate != nil {
+		resp.FXRate = transfer.FXRate.String()
+	}
+	if transfer.FeeAccountID != nil {
+		resp.FeeAccountID = *transfer.FeeAccountID
+	}
+	return resp
+}
+
+// ScheduledExecuteAt returns when the transfer should run, or nil when it
+// should run now. An execute_at that has already passed runs immediately.
+func (req *TransferRequest) ScheduledExecuteAt() *time.Time {
+	if req.ExecuteAt == "" {
+		return nil
+	}
+
+	executeAt, err := time.Parse(time.RFC3339, req.ExecuteAt)
+	if err != nil || !executeAt.After(time.Now()) {
+		return nil
+	}
+	return &executeAt
+}
+
+// IdempotencyKey builds the key stored with the transfer. The request hash
+// lets a replay with a different body be told apart from a genuine retry.
+func (req *TransferRequest) IdempotencyKey(key string, ttl time.Duration) (*models.IdempotencyKey, error) {
+	return newIdempotencyKey(key, ttl, req)
+}
+
+func newIdempotencyKey(key string, ttl time.Duration, request any) (*models.IdempotencyKey, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "idempotency key must be at most %d characters", maxIdempotencyKeyLength)
+	}
+
+	// Keys the system derives for scheduled, approved and async transfers
+	// live in the same table; a client claiming one could block or hijack
+	// the transfer it belongs to
+	if prefix := models.ReservedIdempotencyKeyPrefix(key); prefix != "" {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "idempotency key must not start with %q", prefix)
+	}
+
+	// The standard library config sorts map keys, so metadata hashes the same
+	// whatever order its keys were sent in
+	body, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(request)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrSystem, "failed to hash request: %v", err)
+	}
+	hash := sha256.Sum256(body)
+
+	return &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		ExpiresAt:   time.Now().Add(ttl),
+	}, nil
+}
+
+// ToResponse echoes the requested amount string rather than the normalised
+// decimal so clients get back exactly what they sent.
+func (req *TransferRequest) ToResponse(transfer *models.Transfer, sourceBalance, destBalance decimal.Decimal) *TransferResponse {
+	resp := NewTransferResponse(transfer, sourceBalance, destBalance)
+	resp.Amount = req.Amount
+	return resp
+}
+
+func NewTransferResponse(transfer *models.Transfer, sourceBalance, destBalance decimal.Decimal) *TransferResponse {
+	resp := &TransferResponse{
+		TransactionID:       transfer.ID,
+		Status:             string(transfer.Status),
 		SourceBalance:      sourceBalance.String(),
 		DestinationBalance: destBalance.String(),
-		Amount:             req.Amount,
-		CreatedAt:          time.Now().Format(time.RFC3339),
+		Amount:             transfer.Amount.String(),
+		SourceCurrency:      transfer.SourceCurrency,
+		DestinationCurrency: transfer.DestinationCurrency,
+		DestinationAmount:   transfer.DestinationAmount.String(),
+		Fee:                 transfer.Fee.String(),
+		CreatedAt:          transfer.CreatedAt.Format(time.RFC3339),
+		TransferReference:   transfer.TransferReference,
+	}
+	if transfer.FXRateID != nil {
+		resp.FXRateID = *transfer.FXRateID
+	}
+	if transfer.FXRate != nil {
+		resp.FXRate = transfer.FXRate.String()
+	}
+	if transfer.FeeAccountID != nil {
+		resp.FeeAccountID = *transfer.FeeAccountID
+	}
+	if transfer.ReversalOf != nil {
+		resp.ReversalOf = *transfer.ReversalOf
+	}
+	return resp
+}
+
+func NewScheduledTransferResponse(scheduled *models.ScheduledTransfer) *TransferResponse {
+	return &TransferResponse{
+		ScheduledTransferID: scheduled.ID,
+		Status:              string(models.TransferStatusPending),
+		Amount:              scheduled.Amount.String(),
+		ExecuteAt:           scheduled.ExecuteAt.Format(time.RFC3339),
+		CreatedAt:           scheduled.CreatedAt.Format(time.RFC3339),
+		TransferReference:   scheduled.TransferReference,
+	}
+}
+
+func NewAsyncTransferResponse(async *models.AsyncTransfer) *TransferResponse {
+	return &TransferResponse{
+		AsyncTransferID:   async.ID,
+		Status:            string(async.Status),
+		Amount:            async.Amount.String(),
+		CreatedAt:         async.CreatedAt.Format(time.RFC3339),
+		TransferReference: async.TransferReference,
+	}
+}
+
+func NewApprovalTransferResponse(approval *models.TransferApproval) *TransferResponse {
+	return &TransferResponse{
+		ApprovalID:        approval.ID,
+		Status:            string(approval.Status),
+		Amount:            approval.Amount.String(),
+		ExpiresAt:         approval.ExpiresAt.Format(time.RFC3339),
+		CreatedAt:         approval.CreatedAt.Format(time.RFC3339),
+		TransferReference: approval.TransferReference,
+	}
+}
+
+// BatchTransferRequest is the body of POST /transactions/batch. Legs are
+// applied in order and committed all together or not at all.
+type BatchTransferRequest struct {
+	Legs []TransferRequest `json:"legs" validate:"required,min=1,dive"`
+}
+
+type BatchLegResponse struct {
+	TransactionID        int    `json:"transaction_id"`
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	DestinationAmount    string `json:"destination_amount"`
+	Fee                  string `json:"fee"`
+	models.TransferReference
+}
+
+type AccountBalance struct {
+	AccountID int    `json:"account_id"`
+	Balance   string `json:"balance"`
+}
+
+// BatchTransferResponse lists the committed legs and the final balance of
+// every account the batch touched, ordered by account ID.
+type BatchTransferResponse struct {
+	BatchID   int                `json:"batch_id"`
+	Status    string             `json:"status"`
+	Legs      []BatchLegResponse `json:"legs"`
+	Balances  []AccountBalance   `json:"balances"`
+	CreatedAt string             `json:"created_at"`
+}
+
+func (req *BatchTransferRequest) ToTransfers() ([]*models.Transfer, error) {
+	if len(req.Legs) > maxBatchLegs {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "a batch may have at most %d legs", maxBatchLegs)
+	}
+
+	transfers := make([]*models.Transfer, 0, len(req.Legs))
+	for i := range req.Legs {
+		leg := &req.Legs[i]
+		if err := leg.ValidateRequest(); err != nil {
+			var codeErr codes.CodeError
+			if errors.As(err, &codeErr) {
+				return nil, codes.NewWithMsg(codeErr, "leg %d: %s", i, codeErr.Msg)
+			}
+			return nil, err
+		}
+
+		// Every leg commits with the batch, so a leg cannot be scheduled or queued
+		if leg.ExecuteAt != "" {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: execute_at is not supported in a batch", i)
+		}
+		if leg.Async {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: async is not supported in a batch", i)
+		}
+		if leg.CallbackURL != "" {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: callback_url is not supported in a batch", i)
+		}
+
+		amount, err := decimal.NewFromString(leg.Amount)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: invalid amount format", i)
+		}
+
+		transfers = append(transfers, leg.ToTransfer(amount))
+	}
+
+	return transfers, nil
+}
+
+func NewBatchTransferResponse(batchID int, transfers []*models.Transfer, balances map[int]decimal.Decimal) *BatchTransferResponse {
+	resp := &BatchTransferResponse{
+		BatchID:  batchID,
+		Status:   string(models.TransferStatusCompleted),
+		Legs:     make([]BatchLegResponse, 0, len(transfers)),
+		Balances: make([]AccountBalance, 0, len(balances)),
+	}
+
+	for _, transfer := range transfers {
+		resp.Legs = append(resp.Legs, BatchLegResponse{
+			TransactionID:        transfer.ID,
+			SourceAccountID:      transfer.SourceAccountID,
+			DestinationAccountID: transfer.DestinationAccountID,
+			Amount:               transfer.Amount.String(),
+			DestinationAmount:    transfer.DestinationAmount.String(),
+			Fee:                  transfer.Fee.String(),
+			TransferReference:    transfer.TransferReference,
+		})
+	}
+	if len(transfers) > 0 {
+		resp.CreatedAt = transfers[0].CreatedAt.Format(time.RFC3339)
+	}
+
+	for accountID, balance := range balances {
+		resp.Balances = append(resp.Balances, AccountBalance{
+			AccountID: accountID,
+			Balance:   balance.String(),
+		})
+	}
+	sort.Slice(resp.Balances, func(i, j int) bool {
+		return resp.Balances[i].AccountID < resp.Balances[j].AccountID
+	})
+
+	return resp
+}
+
+// SplitPaymentRequest is the body of POST /transactions/split. Every leg gives
+// either an amount or a percentage of Amount, and all legs must give the
+// same. Amount is required with percentages; with amounts it may be left
+// out, but when given it must equal their sum. Memo, ClientReference and
+// Metadata describe the whole payment; a leg may add its own memo.
+type SplitPaymentRequest struct {
+	SourceAccountID int               `json:"source_account_id" validate:"required,min=1"`
+	Amount          string            `json:"amount,omitempty" validate:"omitempty,numeric"`
+	Legs            []SplitLegRequest `json:"legs" validate:"required,min=1,dive"`
+	Memo            string            `json:"memo,omitempty" validate:"max=255"`
+	ClientReference string            `json:"client_reference,omitempty" validate:"max=128"`
+	Metadata        map[string]any    `json:"metadata,omitempty"`
+}
+
+type SplitLegRequest struct {
+	DestinationAccountID int    `json:"destination_account_id" validate:"required,min=1"`
+	Amount               string `json:"amount,omitempty" validate:"omitempty,numeric"`
+	Percentage           string `json:"percentage,omitempty" validate:"omitempty,numeric"`
+	Memo                 string `json:"memo,omitempty" validate:"max=255"`
+}
+
+type SplitLegResponse struct {
+	TransactionID        int     `json:"transaction_id"`
+	DestinationAccountID int     `json:"destination_account_id"`
+	Amount               string  `json:"amount"`
+	DestinationCurrency  string  `json:"destination_currency"`
+	DestinationAmount    string  `json:"destination_amount"`
+	FXRate               string  `json:"fx_rate,omitempty"`
+	Memo                 *string `json:"memo,omitempty"`
+}
+
+// SplitPaymentResponse is a committed split payment with its legs in request
+// order. Balances, ordered by account ID, are only returned when the payment
+// is made.
+type SplitPaymentResponse struct {
+	SplitPaymentID  int                `json:"split_payment_id"`
+	Status          string             `json:"status"`
+	SourceAccountID int                `json:"source_account_id"`
+	Amount          string             `json:"amount"`
+	Currency        string             `json:"currency"`
+	Allocation      string             `json:"allocation"`
+	Fee             string             `json:"fee"`
+	FeeAccountID    *int               `json:"fee_account_id,omitempty"`
+	Legs            []SplitLegResponse `json:"legs"`
+	Balances        []AccountBalance   `json:"balances,omitempty"`
+	CreatedAt       string             `json:"created_at"`
+	models.TransferReference
+}
+
+// ToSplitPayment validates the request and works out every leg's amount. With
+// percentages the shares are allocated by models.AllocateByPercentage.
+func (req *SplitPaymentRequest) ToSplitPayment() (*models.SplitPayment, error) {
+	if len(req.Legs) > maxSplitLegs {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "a split payment may have at most %d legs", maxSplitLegs)
+	}
+
+	if err := validateReference(req.ClientReference, req.Metadata); err != nil {
+		return nil, err
+	}
+
+	allocation := models.SplitAllocationAmount
+	if len(req.Legs) > 0 && req.Legs[0].Percentage != "" {
+		allocation = models.SplitAllocationPercentage
+	}
+
+	destinations := make(map[int]bool, len(req.Legs))
+	values := make([]decimal.Decimal, len(req.Legs))
+	sum := decimal.Zero
+	for i, leg := range req.Legs {
+		if leg.DestinationAccountID == req.SourceAccountID {
+			return nil, codes.NewWithMsg(codes.ErrSameAccountTransfer, "leg %d: %s", i, codes.ErrSameAccountTransfer.Msg)
+		}
+		if destinations[leg.DestinationAccountID] {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: account %d already has a leg", i, leg.DestinationAccountID)
+		}
+		destinations[leg.DestinationAccountID] = true
+
+		value := leg.Amount
+		if allocation == models.SplitAllocationPercentage {
+			value = leg.Percentage
+		}
+		if (leg.Amount == "") == (leg.Percentage == "") || value == "" {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: every leg must give an amount or, for all legs, a percentage", i)
+		}
+
+		parsed, err := parseSplitValue(value)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: %s", i, err.Error())
+		}
+		values[i] = parsed
+		sum = sum.Add(parsed)
+	}
+
+	var total decimal.Decimal
+	if req.Amount != "" {
+		parsed, err := parseSplitValue(req.Amount)
+		if err != nil {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount: %s", err.Error())
+		}
+		total = parsed
+	}
+
+	amounts := values
+	switch allocation {
+	case models.SplitAllocationAmount:
+		if req.Amount != "" && !total.Equal(sum) {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg amounts add up to %s, not %s", sum.String(), total.String())
+		}
+		total = sum
+	case models.SplitAllocationPercentage:
+		if req.Amount == "" {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount is required when legs give percentages")
+		}
+		if !sum.Equal(decimal.NewFromInt(100)) {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg percentages add up to %s, not 100", sum.String())
+		}
+		amounts = models.AllocateByPercentage(total, values)
+	}
+
+	split := &models.SplitPayment{
+		SourceAccountID:   req.SourceAccountID,
+		Amount:            total,
+		Allocation:        allocation,
+		Legs:              make([]*models.Transfer, 0, len(req.Legs)),
+		TransferReference: newReference(req.Memo, req.ClientReference, req.Metadata),
+	}
+	for i, leg := range req.Legs {
+		if !amounts[i].IsPositive() {
+			return nil, codes.NewWithMsg(codes.ErrInvalidParams, "leg %d: share of %s is too small", i, total.String())
+		}
+		split.Legs = append(split.Legs, &models.Transfer{
+			SourceAccountID:      req.SourceAccountID,
+			DestinationAccountID: leg.DestinationAccountID,
+			Amount:               amounts[i],
+			TransferReference:    newReference(leg.Memo, "", nil),
+		})
+	}
+
+	return split, nil
+}
+
+// parseSplitValue parses a leg amount or percentage, or the total. Values
+// are positive and have at most 8 decimal places, so shares are exact.
+func parseSplitValue(value string) (decimal.Decimal, error) {
+	parsed, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("invalid number %q", value)
+	}
+	if !parsed.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%s must be positive", value)
+	}
+	if !parsed.Equal(parsed.Round(splitValueScale)) {
+		return decimal.Zero, fmt.Errorf("%s has more than %d decimal places", value, splitValueScale)
+	}
+	return parsed, nil
+}
+
+func NewSplitPaymentResponse(split *models.SplitPayment, balances map[int]decimal.Decimal) *SplitPaymentResponse {
+	resp := &SplitPaymentResponse{
+		SplitPaymentID:    split.ID,
+		Status:            string(models.TransferStatusCompleted),
+		SourceAccountID:   split.SourceAccountID,
+		Amount:            split.Amount.String(),
+		Currency:          split.Currency,
+		Allocation:        string(split.Allocation),
+		Fee:               split.Fee.String(),
+		FeeAccountID:      split.FeeAccountID,
+		Legs:              make([]SplitLegResponse, 0, len(split.Legs)),
+		CreatedAt:         split.CreatedAt.Format(time.RFC3339),
+		TransferReference: split.TransferReference,
+	}
+
+	for _, leg := range split.Legs {
+		legResp := SplitLegResponse{
+			TransactionID:        leg.ID,
+			DestinationAccountID: leg.DestinationAccountID,
+			Amount:               leg.Amount.String(),
+			DestinationCurrency:  leg.DestinationCurrency,
+			DestinationAmount:    leg.DestinationAmount.String(),
+			Memo:                 leg.Memo,
+		}
+		if leg.FXRate != nil {
+			legResp.FXRate = leg.FXRate.String()
+		}
+		resp.Legs = append(resp.Legs, legResp)
+	}
+
+	for accountID, balance := range balances {
+		resp.Balances = append(resp.Balances, AccountBalance{
+			AccountID: accountID,
+			Balance:   balance.String(),
+		})
+	}
+	sort.Slice(resp.Balances, func(i, j int) bool {
+		return resp.Balances[i].AccountID < resp.Balances[j].AccountID
+	})
+
+	return resp
+}
+
+// ReverseTransferRequest is the body of POST /transactions/:transaction_id/reverse.
+// An empty amount reverses everything that has not been reversed yet.
+type ReverseTransferRequest struct {
+	Amount string `json:"amount,omitempty" validate:"omitempty,numeric"`
+}
+
+func (req *ReverseTransferRequest) ParseAmount() (*decimal.Decimal, error) {
+	if req.Amount == "" {
+		return nil, nil
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid amount format: %v", err)
+	}
+
+	if !amount.IsPositive() {
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "amount must be positive")
+	}
+
+	return &amount, nil
+}
+
+// IdempotencyKey hashes the target transaction together with the body, so
+// the same key cannot be replayed against a different transfer.
+func (req *ReverseTransferRequest) IdempotencyKey(key string, ttl time.Duration, transferID int) (*models.IdempotencyKey, error) {
+	return newIdempotencyKey(key, ttl, struct {
+		TransactionID int    `json:"transaction_id"`
+		Amount        string `json:"amount"`
+	}{transferID, req.Amount})
+}
+
+// ListTransfersRequest holds the query parameters of GET /transactions
+type ListTransfersRequest struct {
+	SourceAccountID      int    `form:"source_account_id" binding:"omitempty,min=1"`
+	DestinationAccountID int    `form:"destination_account_id" binding:"omitempty,min=1"`
+	AccountID            int    `form:"account_id" binding:"omitempty,min=1"`
+	MinAmount            string `form:"min_amount" binding:"omitempty,numeric"`
+	MaxAmount            string `form:"max_amount" binding:"omitempty,numeric"`
+	From                 string `form:"from"`
+	To                   string `form:"to"`
+	Status               string `form:"status"`
+	ClientReference      string `form:"client_reference"`
+	SweepRuleID          int    `form:"sweep_rule_id" binding:"omitempty,min=1"`
+	Cursor               string `form:"cursor"`
+	Limit                int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListTransfersResponse is one page of transfer history. NextCursor is empty
+// on the last page.
+type ListTransfersResponse struct {
+	Transfers  []models.Transfer `json:"transfers"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func (req *ListTransfersRequest) ToFilter() (models.TransferFilter, error) {
+	filter := models.TransferFilter{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		AccountID:            req.AccountID,
+		ClientReference:      req.ClientReference,
+		SweepRuleID:          req.SweepRuleID,
+		Limit:                req.Limit,
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		return filter, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+
+	if req.MinAmount != "" {
+		minAmount, err := decimal.NewFromString(req.MinAmount)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid min_amount: %v", err)
+		}
+		filter.MinAmount = &minAmount
+	}
+	if req.MaxAmount != "" {
+		maxAmount, err := decimal.NewFromString(req.MaxAmount)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid max_amount: %v", err)
+		}
+		filter.MaxAmount = &maxAmount
+	}
+
+	if req.From != "" {
+		from, err := time.Parse(time.RFC3339, req.From)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "from must be an RFC3339 timestamp")
+		}
+		filter.CreatedFrom = &from
+	}
+	if req.To != "" {
+		to, err := time.Parse(time.RFC3339, req.To)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "to must be an RFC3339 timestamp")
+		}
+		filter.CreatedTo = &to
+	}
+
+	if req.Status != "" {
+		filter.Status = models.TransferStatus(req.Status)
+		if !filter.Status.IsValid() {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "unknown status %q", req.Status)
+		}
+	}
+
+	if req.Cursor != "" {
+		afterID, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+// ListScheduledTransfersRequest holds the query parameters of
+// GET /transactions/scheduled
+type ListScheduledTransfersRequest struct {
+	SourceAccountID      int    `form:"source_account_id" binding:"omitempty,min=1"`
+	DestinationAccountID int    `form:"destination_account_id" binding:"omitempty,min=1"`
+	AccountID            int    `form:"account_id" binding:"omitempty,min=1"`
+	Status               string `form:"status"`
+	Cursor               string `form:"cursor"`
+	Limit                int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListScheduledTransfersResponse is one page of scheduled transfers.
+// NextCursor is empty on the last page.
+type ListScheduledTransfersResponse struct {
+	ScheduledTransfers []models.ScheduledTransfer `json:"scheduled_transfers"`
+	NextCursor         string                     `json:"next_cursor,omitempty"`
+}
+
+func (req *ListScheduledTransfersRequest) ToFilter() (models.ScheduledTransferFilter, error) {
+	filter := models.ScheduledTransferFilter{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		AccountID:            req.AccountID,
+		Limit:                req.Limit,
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		return filter, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+
+	if req.Status != "" {
+		filter.Status = models.ScheduledTransferStatus(req.Status)
+		if !filter.Status.IsValid() {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "unknown status %q", req.Status)
+		}
+	}
+
+	if req.Cursor != "" {
+		afterID, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+func encodeCursor(transferID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(transferID)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	transferID, err := strconv.Atoi(string(raw))
+	if err != nil || transferID <= 0 {
+		return 0, codes.ErrInvalidParams
+	}
+	return transferID, nil
+}
+
+// ApprovalDecisionRequest is the optional body of the approve and reject
+// endpoints. Comment is kept in the approval's audit trail.
+type ApprovalDecisionRequest struct {
+	Comment string `json:"comment,omitempty" validate:"max=255"`
+}
+
+// ListApprovalsRequest holds the query parameters of
+// GET /transactions/approvals
+type ListApprovalsRequest struct {
+	SourceAccountID int    `form:"source_account_id" binding:"omitempty,min=1"`
+	Status          string `form:"status"`
+	Cursor          string `form:"cursor"`
+	Limit           int    `form:"limit" binding:"omitempty,min=1"`
+}
+
+// ListApprovalsResponse is one page of transfer approvals. NextCursor is
+// empty on the last page.
+type ListApprovalsResponse struct {
+	Approvals  []models.TransferApproval `json:"approvals"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+func (req *ApprovalDecisionRequest) CommentOrNil() *string {
+	if req.Comment == "" {
+		return nil
+	}
+	return &req.Comment
+}
+
+func (req *ListApprovalsRequest) ToFilter() (models.TransferApprovalFilter, error) {
+	filter := models.TransferApprovalFilter{
+		SourceAccountID: req.SourceAccountID,
+		Limit:           req.Limit,
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		return filter, codes.NewWithMsg(codes.ErrInvalidParams, "limit must be at most %d", maxListLimit)
+	}
+
+	if req.Status != "" {
+		filter.Status = models.TransferApprovalStatus(req.Status)
+		if !filter.Status.IsValid() {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "unknown status %q", req.Status)
+		}
+	}
+
+	if req.Cursor != "" {
+		afterID, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return filter, codes.NewWithMsg(codes.ErrInvalidParams, "invalid cursor")
+		}
+		filter.AfterID = afterID
+	}
+
+	return filter, nil
+}
+
+// parsePrincipal returns the caller named by the X-Principal header
+func parsePrincipal(header string) (string, error) {
+	principal := strings.TrimSpace(header)
+	if principal == "" {
+		return "", codes.ErrPrincipalRequired
+	}
+	if len(principal) > maxPrincipalLength {
+		return "", codes.NewWithMsg(codes.ErrInvalidParams, "%s must be at most %d characters", PrincipalHeader, maxPrincipalLength)
+	}
+	return principal, nil
+}
+
+// approvalIdempotencyKey derives a key from the approval ID, so however often
+// an approved transfer is submitted it moves the money at most once.
+func approvalIdempotencyKey(approval *models.TransferApproval, ttl time.Duration) *models.IdempotencyKey {
+	key := fmt.Sprintf("%s%d", models.IdempotencyKeyPrefixTransferApproval, approval.ID)
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d:%s", key, approval.SourceAccountID, approval.DestinationAccountID, approval.Amount.String())))
+
+	return &models.IdempotencyKey{
+		Key:         key,
+		RequestHash: hex.EncodeToString(hash[:]),
+		ExpiresAt:   time.Now().Add(ttl),
 	}
 }
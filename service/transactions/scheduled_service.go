@@ -0,0 +1,160 @@
+package transactions
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduleTransfer stores a future-dated transfer for the executor in
+// cmd/server to run at executeAt.
+func scheduleTransfer(c *gin.Context, req *TransferRequest, amount decimal.Decimal, executeAt time.Time, idempotencyKey *models.IdempotencyKey) (*TransferResponse, error) {
+	repo, err := getScheduledTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get scheduled transfer repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source_account_id":      req.SourceAccountID,
+		"destination_account_id": req.DestinationAccountID,
+		"amount":                 amount.String(),
+		"execute_at":             executeAt.Format(time.RFC3339),
+	}).Info("Scheduling transfer")
+
+	scheduled, err := repo.ScheduleTransfer(c.Request.Context(), &models.ScheduledTransfer{
+		SourceAccountID:      req.SourceAccountID,
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               amount,
+		ExecuteAt:            executeAt,
+		TransferReference:    req.Reference(),
+	}, idempotencyKey)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"source_account_id":      req.SourceAccountID,
+			"destination_account_id": req.DestinationAccountID,
+		}).Error("Scheduling transfer failed")
+		return nil, err
+	}
+
+	log.WithField("scheduled_transfer_id", scheduled.ID).Info("Transfer scheduled successfully")
+
+	return NewScheduledTransferResponse(scheduled), nil
+}
+
+func ListScheduledTransfers(c *gin.Context) (*ListScheduledTransfersResponse, error) {
+	var req ListScheduledTransfersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid scheduled transfer query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid scheduled transfer query")
+		return nil, err
+	}
+
+	repo, err := getScheduledTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get scheduled transfer repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	scheduledTransfers, err := repo.ListScheduledTransfers(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list scheduled transfers from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListScheduledTransfersResponse{ScheduledTransfers: scheduledTransfers}
+	if len(scheduledTransfers) > limit {
+		resp.ScheduledTransfers = scheduledTransfers[:limit]
+		resp.NextCursor = encodeCursor(resp.ScheduledTransfers[limit-1].ID)
+	}
+
+	return resp, nil
+}
+
+func GetScheduledTransferByID(c *gin.Context) (*models.ScheduledTransfer, error) {
+	scheduledID, err := parseScheduledTransferID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getScheduledTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get scheduled transfer repository from context")
+		return nil, err
+	}
+
+	scheduled, err := repo.GetScheduledTransferByID(c.Request.Context(), scheduledID)
+	if err != nil {
+		log.WithError(err).WithField("scheduled_transfer_id", scheduledID).Error("Failed to get scheduled transfer from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if scheduled == nil {
+		log.WithField("scheduled_transfer_id", scheduledID).Warn("Scheduled transfer not found")
+		return nil, codes.ErrScheduledTransferNotFound
+	}
+
+	return scheduled, nil
+}
+
+func CancelScheduledTransfer(c *gin.Context) (*models.ScheduledTransfer, error) {
+	scheduledID, err := parseScheduledTransferID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := getScheduledTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get scheduled transfer repository from context")
+		return nil, err
+	}
+
+	scheduled, err := repo.CancelScheduledTransfer(c.Request.Context(), scheduledID)
+	if err != nil {
+		log.WithError(err).WithField("scheduled_transfer_id", scheduledID).Error("Cancelling scheduled transfer failed")
+		return nil, err
+	}
+
+	log.WithField("scheduled_transfer_id", scheduled.ID).Info("Scheduled transfer cancelled successfully")
+
+	return scheduled, nil
+}
+
+func parseScheduledTransferID(c *gin.Context) (int, error) {
+	scheduledIDStr := c.Param("scheduled_transfer_id")
+	scheduledID, err := strconv.Atoi(scheduledIDStr)
+	if err != nil || scheduledID <= 0 {
+		log.WithError(err).WithField("scheduled_transfer_id", scheduledIDStr).Error("Invalid scheduled transfer ID format")
+		return 0, codes.ErrInvalidScheduledTransferID
+	}
+	return scheduledID, nil
+}
+
+func getScheduledTransferRepo(c *gin.Context) (*storage.ScheduledTransferRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if appConfig.ScheduledTransferRepository == nil {
+		log.Error("Scheduled transfer repository not found in app config")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
+	}
+
+	return appConfig.ScheduledTransferRepository, nil
+}
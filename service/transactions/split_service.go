@@ -0,0 +1,85 @@
+package transactions
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateSplitPayment(c *gin.Context, req *SplitPaymentRequest) (*SplitPaymentResponse, error) {
+	split, err := req.ToSplitPayment()
+	if err != nil {
+		log.WithError(err).Error("Split payment request validation failed")
+		return nil, err
+	}
+
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	if appConfig.RequiresApproval(split.Amount) {
+		log.WithField("amount", split.Amount.String()).Error("Split payment exceeds approval threshold")
+		return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "split payments above the approval threshold are not supported")
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"source_account_id": split.SourceAccountID,
+		"amount":            split.Amount.String(),
+		"legs":              len(split.Legs),
+	}).Info("Processing split payment request")
+
+	balances, err := repo.ProcessSplitPayment(c.Request.Context(), split)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"source_account_id": split.SourceAccountID,
+			"amount":            split.Amount.String(),
+		}).Error("Split payment processing failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"split_payment_id": split.ID,
+		"legs":             len(split.Legs),
+		"fee":              split.Fee.String(),
+	}).Info("Split payment completed successfully")
+
+	return NewSplitPaymentResponse(split, balances), nil
+}
+
+func GetSplitPaymentByID(c *gin.Context) (*SplitPaymentResponse, error) {
+	splitIDStr := c.Param("split_payment_id")
+	splitID, err := strconv.Atoi(splitIDStr)
+	if err != nil || splitID <= 0 {
+		log.WithError(err).WithField("split_payment_id", splitIDStr).Error("Invalid split payment ID format")
+		return nil, codes.ErrInvalidSplitPaymentID
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	split, err := repo.GetSplitPaymentByID(c.Request.Context(), splitID)
+	if err != nil {
+		log.WithError(err).WithField("split_payment_id", splitID).Error("Failed to get split payment from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if split == nil {
+		log.WithField("split_payment_id", splitID).Warn("Split payment not found")
+		return nil, codes.ErrSplitPaymentNotFound
+	}
+
+	return NewSplitPaymentResponse(split, nil), nil
+}
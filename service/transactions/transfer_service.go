@@ -1,10 +1,13 @@
 package transactions
 
 import (
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
 	"github.com/Nauman-S/Internal-Transfers-System/codes"
 	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
 	"github.com/Nauman-S/Internal-Transfers-System/storage"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,12 +20,23 @@ func CreateTransfer(c *gin.Context, req *TransferRequest) (*TransferResponse, er
 		return nil, err
 	}
 
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
 	repo, err := getTransferRepo(c)
 	if err != nil {
 		log.WithError(err).Error("Failed to get transfer repository from context")
 		return nil, err
 	}
 
+	idempotencyKey, err := req.IdempotencyKey(c.GetHeader(IdempotencyKeyHeader), appConfig.IdempotencyKeyTTL)
+	if err != nil {
+		log.WithError(err).Error("Invalid idempotency key")
+		return nil, err
+	}
 
 	amount, err := decimal.NewFromString(req.Amount)
 	if err != nil {
@@ -30,13 +44,39 @@ func CreateTransfer(c *gin.Context, req *TransferRequest) (*TransferResponse, er
 		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid amount format")
 	}
 
+	executeAt := req.ScheduledExecuteAt()
+
+	if appConfig.RequiresApproval(amount) {
+		if executeAt != nil {
+			log.WithField("amount", amount.String()).Error("Transfer needing approval cannot be scheduled")
+			return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "transfers above the approval threshold cannot be scheduled")
+		}
+		if req.Async {
+			log.WithField("amount", amount.String()).Error("Transfer needing approval cannot be queued")
+			return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "transfers above the approval threshold cannot be async")
+		}
+		if req.Precondition() != nil {
+			log.WithField("amount", amount.String()).Error("Transfer needing approval cannot be conditional")
+			return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "transfers above the approval threshold cannot have an expected source version or balance")
+		}
+		return requestTransferApproval(c, appConfig, req, amount, idempotencyKey)
+	}
+
+	if executeAt != nil {
+		return scheduleTransfer(c, req, amount, *executeAt, idempotencyKey)
+	}
+
+	if req.Async {
+		return enqueueTransfer(c, req, amount, idempotencyKey)
+	}
+
 	log.WithFields(log.Fields{
 		"source_account_id":      req.SourceAccountID,
 		"destination_account_id": req.DestinationAccountID,
 		"amount":                amount.String(),
 	}).Info("Processing transfer request")
 
-	transfer, sourceBalance, destBalance, err := repo.ProcessTransfer(c.Request.Context(), req.SourceAccountID, req.DestinationAccountID, amount)
+	transfer, sourceBalance, destBalance, err := repo.ProcessTransfer(c.Request.Context(), req.ToTransfer(amount), idempotencyKey)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"source_account_id":      req.SourceAccountID,
@@ -55,10 +95,243 @@ func CreateTransfer(c *gin.Context, req *TransferRequest) (*TransferResponse, er
 		"destination_balance":   destBalance.String(),
 	}).Info("Transfer completed successfully")
 
-	return req.ToResponse(transfer.ID, sourceBalance, destBalance), nil
+	return req.ToResponse(transfer, sourceBalance, destBalance), nil
 }
 
-func getTransferRepo(c *gin.Context) (*storage.TransferRepository, error) {
+// QuoteTransfer checks a transfer as CreateTransfer would apply it right now
+// and reports its amounts and the resulting balances without applying it.
+// It takes the same body; execute_at, async and callback_url are validated
+// but the quote is always at the current balances, and no Idempotency-Key
+// is needed since nothing is stored.
+func QuoteTransfer(c *gin.Context, req *TransferRequest) (*QuoteResponse, error) {
+	err := req.ValidateRequest()
+	if err != nil {
+		log.WithError(err).Error("Transfer quote request validation failed")
+		return nil, err
+	}
+
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		log.WithError(err).Error("Failed to parse transfer amount")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid amount format")
+	}
+
+	transfer, sourceBalance, destBalance, err := repo.QuoteTransfer(c.Request.Context(), req.ToTransfer(amount))
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"source_account_id":      req.SourceAccountID,
+			"destination_account_id": req.DestinationAccountID,
+			"amount":                 amount.String(),
+		}).Warn("Transfer quote declined")
+		return nil, err
+	}
+
+	return req.ToQuoteResponse(transfer, sourceBalance, destBalance, appConfig.RequiresApproval(amount)), nil
+}
+
+func CreateBatchTransfer(c *gin.Context, req *BatchTransferRequest) (*BatchTransferResponse, error) {
+	transfers, err := req.ToTransfers()
+	if err != nil {
+		log.WithError(err).Error("Batch transfer request validation failed")
+		return nil, err
+	}
+
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	for i, transfer := range transfers {
+		if appConfig.RequiresApproval(transfer.Amount) {
+			log.WithField("leg", i).Error("Batch leg exceeds approval threshold")
+			return nil, codes.NewWithMsg(codes.ErrApprovalRequired, "leg %d is above the approval threshold and must be submitted on its own", i)
+		}
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	log.WithField("legs", len(transfers)).Info("Processing batch transfer request")
+
+	batchID, balances, err := repo.ProcessBatch(c.Request.Context(), transfers)
+	if err != nil {
+		log.WithError(err).WithField("legs", len(transfers)).Error("Batch transfer processing failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"batch_id": batchID,
+		"legs":     len(transfers),
+	}).Info("Batch transfer completed successfully")
+
+	return NewBatchTransferResponse(batchID, transfers, balances), nil
+}
+
+func GetTransferByID(c *gin.Context) (*models.Transfer, error) {
+	transferIDStr := c.Param("transaction_id")
+	transferID, err := strconv.Atoi(transferIDStr)
+	if err != nil || transferID <= 0 {
+		log.WithError(err).WithField("transaction_id", transferIDStr).Error("Invalid transaction ID format")
+		return nil, codes.ErrInvalidTransferID
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	transfer, err := repo.GetTransferByID(c.Request.Context(), transferID)
+	if err != nil {
+		log.WithError(err).WithField("transaction_id", transferID).Error("Failed to get transfer from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	if transfer == nil {
+		log.WithField("transaction_id", transferID).Warn("Transfer not found")
+		return nil, codes.ErrTransferNotFound
+	}
+
+	return transfer, nil
+}
+
+// GetTransferJournal returns the balanced journal that posted the transfer
+// to the general ledger.
+func GetTransferJournal(c *gin.Context) (*models.Journal, error) {
+	transferIDStr := c.Param("transaction_id")
+	transferID, err := strconv.Atoi(transferIDStr)
+	if err != nil || transferID <= 0 {
+		log.WithError(err).WithField("transaction_id", transferIDStr).Error("Invalid transaction ID format")
+		return nil, codes.ErrInvalidTransferID
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	journal, err := repo.GetTransferJournal(c.Request.Context(), transferID)
+	if err != nil {
+		log.WithError(err).WithField("transaction_id", transferID).Error("Failed to get transfer journal")
+		return nil, err
+	}
+
+	return journal, nil
+}
+
+func ReverseTransfer(c *gin.Context, req *ReverseTransferRequest) (*TransferResponse, error) {
+	transferIDStr := c.Param("transaction_id")
+	transferID, err := strconv.Atoi(transferIDStr)
+	if err != nil || transferID <= 0 {
+		log.WithError(err).WithField("transaction_id", transferIDStr).Error("Invalid transaction ID format")
+		return nil, codes.ErrInvalidTransferID
+	}
+
+	amount, err := req.ParseAmount()
+	if err != nil {
+		log.WithError(err).Error("Reversal request validation failed")
+		return nil, err
+	}
+
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get app config from context")
+		return nil, err
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	idempotencyKey, err := req.IdempotencyKey(c.GetHeader(IdempotencyKeyHeader), appConfig.IdempotencyKeyTTL, transferID)
+	if err != nil {
+		log.WithError(err).Error("Invalid idempotency key")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"transaction_id": transferID,
+		"amount":         req.Amount,
+	}).Info("Processing reversal request")
+
+	reversal, sourceBalance, destBalance, err := repo.ReverseTransfer(c.Request.Context(), transferID, amount, idempotencyKey)
+	if err != nil {
+		log.WithError(err).WithField("transaction_id", transferID).Error("Reversal processing failed")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"transaction_id": transferID,
+		"reversal_id":    reversal.ID,
+		"amount":         reversal.Amount.String(),
+	}).Info("Reversal completed successfully")
+
+	return NewTransferResponse(reversal, sourceBalance, destBalance), nil
+}
+
+func ListTransfers(c *gin.Context) (*ListTransfersResponse, error) {
+	var req ListTransfersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.WithError(err).Error("Invalid transfer history query")
+		return nil, codes.NewWithMsg(codes.ErrInvalidParams, "invalid query params: %v", err)
+	}
+
+	filter, err := req.ToFilter()
+	if err != nil {
+		log.WithError(err).Error("Invalid transfer history query")
+		return nil, err
+	}
+
+	repo, err := getTransferRepo(c)
+	if err != nil {
+		log.WithError(err).Error("Failed to get transfer repository from context")
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	transfers, err := repo.ListTransfers(c.Request.Context(), filter)
+	if err != nil {
+		log.WithError(err).Error("Failed to list transfers from database")
+		return nil, codes.NewWithMsg(codes.ErrSystem, "database error: %v", err)
+	}
+
+	resp := &ListTransfersResponse{Transfers: transfers}
+	if len(transfers) > limit {
+		resp.Transfers = transfers[:limit]
+		resp.NextCursor = encodeCursor(resp.Transfers[limit-1].ID)
+	}
+
+	log.WithFields(log.Fields{
+		"count":       len(resp.Transfers),
+		"next_cursor": resp.NextCursor,
+	}).Info("Transfers listed successfully")
+
+	return resp, nil
+}
+
+func getAppConfig(c *gin.Context) (*config.ApplicationConfig, error) {
 	appConfigInterface, exists := c.Get("appConfig")
 	if !exists {
 		log.Error("App config not found in context")
@@ -70,6 +343,15 @@ func getTransferRepo(c *gin.Context) (*storage.TransferRepository, error) {
 		log.Error("Invalid app config type in context")
 		return nil, codes.NewWithMsg(codes.ErrSystem, "internal configuration error")
 	}
+
+	return appConfig, nil
+}
+
+func getTransferRepo(c *gin.Context) (*storage.TransferRepository, error) {
+	appConfig, err := getAppConfig(c)
+	if err != nil {
+		return nil, err
+	}
 	
 	if appConfig.TransferRepository == nil {
 		log.Error("Transfer repository not found in app config")
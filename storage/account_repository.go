@@ -4,12 +4,40 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
 	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
 )
 
+// accountColumns is the column list of accounts a read by accountScanTargets.
+// The available balance is the ledger balance less active holds.
+const accountColumns = `a.id, ` + accountBalance + `, ` + accountBalance + ` - (` + activeHoldsSum + `), a.overdraft_limit, a.currency, a.account_type, a.balance_shards, ` + accountVersion + `, a.created_at, a.updated_at`
+
+// accountBalance is the ledger balance of account a: its own balance plus
+// whatever hot account credits have landed on its balance shards
+const accountBalance = `(a.balance + (` + shardBalanceSum + `))`
+
+// accountVersion is the version of account a: its own plus those of its
+// balance shards, each bumped by a trigger whenever its balance changes
+const accountVersion = `(a.version + (
+	SELECT COALESCE(SUM(s.version), 0)
+	FROM account_balance_shards s
+	WHERE s.account_id = a.id))`
+
+// shardBalanceSum is a scalar subquery for the balance held on the shards of
+// account a.id. It is 0 for accounts that are not hot.
+const shardBalanceSum = `
+	SELECT COALESCE(SUM(s.balance), 0)
+	FROM account_balance_shards s
+	WHERE s.account_id = a.id`
+
+// maxBalanceShards bounds how many rows a hot account's balance is split over
+const maxBalanceShards = 64
+
 type AccountRepository struct {
 	db *pgxpool.Pool
 }
@@ -20,14 +48,25 @@ func NewAccountRepository(db *DB) *AccountRepository {
 	}
 }
 
+// CreateAccount stores acc and posts its initial balance against the equity
+// account. It returns false if the account ID is already taken.
 func (r *AccountRepository) CreateAccount(ctx context.Context, acc *models.Account) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO accounts (id, balance, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)`
+		INSERT INTO accounts (id, balance, currency, account_type, overdraft_limit, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	_, err := r.db.Exec(ctx, query,
+	_, err = tx.Exec(ctx, query,
 		acc.ID,
 		acc.InitialBalance,
+		acc.Currency,
+		acc.AccountType,
+		acc.OverdraftLimit,
 		acc.CreatedAt,
 		acc.UpdatedAt,
 	)
@@ -39,22 +78,32 @@ func (r *AccountRepository) CreateAccount(ctx context.Context, acc *models.Accou
 		return false, fmt.Errorf("failed to create account: %w", err)
 	}
 
+	if postings := models.OpeningBalancePostings(acc); len(postings) > 0 {
+		err = postJournalTx(ctx, tx, &models.Journal{
+			Kind:      models.JournalKindOpeningBalance,
+			AccountID: &acc.ID,
+			Postings:  postings,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return true, nil
 }
 
 func (r *AccountRepository) GetAccountByID(ctx context.Context, accountID int) (*models.Account, error) {
 	query := `
-		SELECT id, balance, created_at, updated_at
-		FROM accounts
-		WHERE id = $1`
+		SELECT ` + accountColumns + `
+		FROM accounts a
+		WHERE a.id = $1`
 
 	var acc models.Account
-	err := r.db.QueryRow(ctx, query, accountID).Scan(
-		&acc.ID,
-		&acc.InitialBalance,
-		&acc.CreatedAt,
-		&acc.UpdatedAt,
-	)
+	err := r.db.QueryRow(ctx, query, accountID).Scan(accountScanTargets(&acc)...)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -66,6 +115,131 @@ func (r *AccountRepository) GetAccountByID(ctx context.Context, accountID int) (
 	return &acc, nil
 }
 
+// SetOverdraftLimit changes how far the account's balance may go negative.
+// Lowering the limit below what the account already owes is allowed; the
+// account cannot send funds until it is back within the limit.
+func (r *AccountRepository) SetOverdraftLimit(ctx context.Context, accountID int, limit decimal.Decimal) (*models.Account, error) {
+	query := `
+		UPDATE accounts a SET overdraft_limit = $1, updated_at = NOW()
+		WHERE a.id = $2
+		RETURNING ` + accountColumns
+
+	var acc models.Account
+	err := r.db.QueryRow(ctx, query, limit, accountID).Scan(accountScanTargets(&acc)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to set overdraft limit: %w", err)
+	}
+
+	return &acc, nil
+}
+
+// ListOverdrawnAccounts returns the accounts whose ledger balance is negative,
+// newest ID first, using the same keyset pagination as ListTransfers.
+func (r *AccountRepository) ListOverdrawnAccounts(ctx context.Context, filter models.AccountFilter) ([]models.Account, error) {
+	args := []any{filter.Limit}
+	query := `SELECT ` + accountColumns + ` FROM accounts a WHERE (a.balance < 0 OR a.balance_shards > 0) AND ` + accountBalance + ` < 0`
+	if filter.AfterID > 0 {
+		args = append(args, filter.AfterID)
+		query += fmt.Sprintf(" AND a.id < $%d", len(args))
+	}
+	query += " ORDER BY a.id DESC LIMIT $1"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overdrawn accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []models.Account{}
+	for rows.Next() {
+		var acc models.Account
+		if err = rows.Scan(accountScanTargets(&acc)...); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, acc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list overdrawn accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// ListAccountPostings returns a page of the account's ledger, newest posting
+// first. filter.AfterID is a posting ID.
+func (r *AccountRepository) ListAccountPostings(ctx context.Context, accountID int, filter models.AccountFilter) ([]models.Posting, error) {
+	args := []any{filter.Limit, accountID}
+	query := `SELECT ` + postingColumns + ` FROM postings WHERE account_id = $2`
+	if filter.AfterID > 0 {
+		args = append(args, filter.AfterID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	query += " ORDER BY id DESC LIMIT $1"
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list postings: %w", err)
+	}
+
+	return scanPostings(rows)
+}
+
+// SetBalanceShards marks the account hot, splitting its balance over shards
+// rows that concurrent credits are spread across. The count can only grow:
+// transfers in flight may credit any shard they saw, so none can be removed.
+func (r *AccountRepository) SetBalanceShards(ctx context.Context, accountID, shards int) (*models.Account, error) {
+	if shards < 2 || shards > maxBalanceShards {
+		return nil, codes.NewWithMsg(codes.ErrInvalidBalanceShards, "shards must be between 2 and %d", maxBalanceShards)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	err = tx.QueryRow(ctx, `
+		SELECT balance_shards FROM accounts WHERE id = $1 FOR UPDATE
+	`, accountID).Scan(&current)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to lock account: %w", err)
+	}
+	if shards < current {
+		return nil, codes.NewWithMsg(codes.ErrInvalidBalanceShards, "account already has %d balance shards, which cannot be reduced", current)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO account_balance_shards (account_id, shard, balance, updated_at)
+		SELECT $1, shard, 0, NOW() FROM generate_series(0, $2 - 1) AS shard
+		ON CONFLICT (account_id, shard) DO NOTHING
+	`, accountID, shards)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create balance shards: %w", err)
+	}
+
+	var acc models.Account
+	err = tx.QueryRow(ctx, `
+		UPDATE accounts a SET balance_shards = $1, updated_at = NOW()
+		WHERE a.id = $2
+		RETURNING `+accountColumns, shards, accountID).Scan(accountScanTargets(&acc)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set balance shards: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &acc, nil
+}
+
 func (r *AccountRepository) AccountExists(ctx context.Context, accountID int) (bool, error) {
 	query := `SELECT EXISTS(SELECT 1 FROM accounts WHERE id = $1)`
 
@@ -77,3 +251,182 @@ func (r *AccountRepository) AccountExists(ctx context.Context, accountID int) (b
 
 	return exists, nil
 }
+
+// accountLimitsColumns is the column list read by accountLimitsScanTargets
+const accountLimitsColumns = `account_id, max_transaction_amount, daily_limit, weekly_limit, monthly_limit, velocity_count, velocity_window_seconds, created_at, updated_at`
+
+// SetAccountLimits replaces every limit of the account. Limits left nil are
+// removed.
+func (r *AccountRepository) SetAccountLimits(ctx context.Context, limits *models.AccountLimits) (*models.AccountLimits, error) {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO account_limits (account_id, max_transaction_amount, daily_limit, weekly_limit, monthly_limit,
+			velocity_count, velocity_window_seconds, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (account_id) DO UPDATE SET
+			max_transaction_amount = EXCLUDED.max_transaction_amount,
+			daily_limit = EXCLUDED.daily_limit,
+			weekly_limit = EXCLUDED.weekly_limit,
+			monthly_limit = EXCLUDED.monthly_limit,
+			velocity_count = EXCLUDED.velocity_count,
+			velocity_window_seconds = EXCLUDED.velocity_window_seconds,
+			updated_at = NOW()
+		RETURNING `+accountLimitsColumns+`
+	`, limits.AccountID, limits.MaxTransactionAmount, limits.DailyLimit, limits.WeeklyLimit, limits.MonthlyLimit,
+		limits.VelocityCount, limits.VelocityWindowSeconds).Scan(accountLimitsScanTargets(limits)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "23503") {
+			return nil, codes.ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to set account limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// GetAccountLimits returns the account's limits, or nil if none were set.
+func (r *AccountRepository) GetAccountLimits(ctx context.Context, accountID int) (*models.AccountLimits, error) {
+	limits, err := scanAccountLimits(r.db.QueryRow(ctx, `
+		SELECT `+accountLimitsColumns+` FROM account_limits WHERE account_id = $1
+	`, accountID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// checkAccountLimitsTx enforces the limits of source, which must be locked,
+// against sending amount. The lock serializes transfers from the account, so
+// concurrent requests see each other's outflows and cannot jointly pass a
+// cap. Breaches name the remaining allowance.
+func checkAccountLimitsTx(ctx context.Context, tx pgx.Tx, source *lockedAccount, amount decimal.Decimal) error {
+	if !source.limitsLoaded {
+		limits, err := scanAccountLimits(tx.QueryRow(ctx, `
+			SELECT `+accountLimitsColumns+` FROM account_limits WHERE account_id = $1
+		`, source.ID))
+		if err != nil {
+			return fmt.Errorf("failed to load account limits: %w", err)
+		}
+		source.Limits = limits
+		source.limitsLoaded = true
+	}
+
+	limits := source.Limits
+	if limits == nil {
+		return nil
+	}
+
+	if limits.MaxTransactionAmount != nil && amount.GreaterThan(*limits.MaxTransactionAmount) {
+		return codes.NewWithMsg(codes.ErrTransactionLimitExceeded,
+			"amount exceeds the per-transaction limit of %s", limits.MaxTransactionAmount.String())
+	}
+
+	if limits.DailyLimit == nil && limits.WeeklyLimit == nil && limits.MonthlyLimit == nil && limits.VelocityCount == nil {
+		return nil
+	}
+
+	var velocityWindow time.Duration
+	if limits.VelocityWindowSeconds != nil {
+		velocityWindow = time.Duration(*limits.VelocityWindowSeconds) * time.Second
+	}
+
+	outflow, err := accountOutflowTx(ctx, tx, source.ID, velocityWindow)
+	if err != nil {
+		return err
+	}
+
+	caps := []struct {
+		limit *decimal.Decimal
+		used  decimal.Decimal
+		err   codes.CodeError
+	}{
+		{limits.DailyLimit, outflow.Daily, codes.ErrDailyLimitExceeded},
+		{limits.WeeklyLimit, outflow.Weekly, codes.ErrWeeklyLimitExceeded},
+		{limits.MonthlyLimit, outflow.Monthly, codes.ErrMonthlyLimitExceeded},
+	}
+	for _, c := range caps {
+		if c.limit == nil {
+			continue
+		}
+		remaining := decimal.Max(c.limit.Sub(c.used), decimal.Zero)
+		if amount.GreaterThan(remaining) {
+			return codes.NewWithMsg(c.err, "%s; remaining allowance is %s", c.err.Msg, remaining.String())
+		}
+	}
+
+	if limits.VelocityCount != nil && outflow.VelocityCount >= *limits.VelocityCount {
+		return codes.NewWithMsg(codes.ErrVelocityLimitExceeded,
+			"at most %d transfers are allowed every %s; remaining allowance is 0 transfers",
+			*limits.VelocityCount, velocityWindow.String())
+	}
+
+	return nil
+}
+
+// accountOutflowTx sums what accountID has sent over the rolling windows of
+// its limits. Transfers that completed count even if later reversed, while
+// reversals themselves do not. A split payment counts as one transfer
+// towards the velocity limit, however many legs it has, as it is checked
+// as one.
+func accountOutflowTx(ctx context.Context, tx pgx.Tx, accountID int, velocityWindow time.Duration) (*models.AccountOutflow, error) {
+	var outflow models.AccountOutflow
+	err := tx.QueryRow(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE created_at > NOW() - make_interval(secs => $2::FLOAT8)), 0),
+			COALESCE(SUM(amount) FILTER (WHERE created_at > NOW() - make_interval(secs => $3::FLOAT8)), 0),
+			COALESCE(SUM(amount) FILTER (WHERE created_at > NOW() - make_interval(secs => $4::FLOAT8)), 0),
+			COUNT(*) FILTER (WHERE split_payment_id IS NULL AND created_at > NOW() - make_interval(secs => $5::FLOAT8))
+				+ COUNT(DISTINCT split_payment_id) FILTER (WHERE created_at > NOW() - make_interval(secs => $5::FLOAT8))
+		FROM transactions
+		WHERE source_account_id = $1 AND reversal_of IS NULL AND status IN ('COMPLETED', 'REVERSED')
+			AND created_at > NOW() - make_interval(secs => GREATEST($4::FLOAT8, $5::FLOAT8))
+	`, accountID, models.DailyLimitWindow.Seconds(), models.WeeklyLimitWindow.Seconds(),
+		models.MonthlyLimitWindow.Seconds(), velocityWindow.Seconds()).Scan(
+		&outflow.Daily, &outflow.Weekly, &outflow.Monthly, &outflow.VelocityCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum account outflow: %w", err)
+	}
+
+	return &outflow, nil
+}
+
+func scanAccountLimits(row pgx.Row) (*models.AccountLimits, error) {
+	var limits models.AccountLimits
+	err := row.Scan(accountLimitsScanTargets(&limits)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &limits, nil
+}
+
+func accountLimitsScanTargets(limits *models.AccountLimits) []any {
+	return []any{
+		&limits.AccountID,
+		&limits.MaxTransactionAmount,
+		&limits.DailyLimit,
+		&limits.WeeklyLimit,
+		&limits.MonthlyLimit,
+		&limits.VelocityCount,
+		&limits.VelocityWindowSeconds,
+		&limits.CreatedAt,
+		&limits.UpdatedAt,
+	}
+}
+
+func accountScanTargets(acc *models.Account) []any {
+	return []any{
+		&acc.ID,
+		&acc.InitialBalance,
+		&acc.AvailableBalance,
+		&acc.OverdraftLimit,
+		&acc.Currency,
+		&acc.AccountType,
+		&acc.BalanceShards,
+		&acc.Version,
+		&acc.CreatedAt,
+		&acc.UpdatedAt,
+	}
+}
@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+)
+
+// asyncTransferColumns is the column list read by asyncTransferScanTargets
+const asyncTransferColumns = `id, source_account_id, destination_account_id, amount, status, attempts, next_attempt_at, last_error_code, last_error, transaction_id, source_balance, destination_balance, callback_url, callback_status, callback_attempts, created_at, updated_at, ` + transferReferenceColumns
+
+type AsyncTransferRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAsyncTransferRepository(db *DB) *AsyncTransferRepository {
+	return &AsyncTransferRepository{
+		db: db.pool,
+	}
+}
+
+// EnqueueTransfer stores a transfer for the async transfer workers. Both
+// accounts must exist, but funds are only checked when a worker applies it.
+// Idempotency keys work as in ScheduledTransferRepository.ScheduleTransfer:
+// a replay returns the transfer queued first.
+func (r *AsyncTransferRepository) EnqueueTransfer(ctx context.Context, async *models.AsyncTransfer, idempotencyKey *models.IdempotencyKey) (*models.AsyncTransfer, error) {
+	err := checkTransferAccountsExist(ctx, r.db, async.SourceAccountID, async.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var key, requestHash *string
+	if idempotencyKey != nil {
+		key = &idempotencyKey.Key
+		requestHash = &idempotencyKey.RequestHash
+	}
+
+	var callbackStatus *models.CallbackStatus
+	if async.CallbackURL != nil {
+		pending := models.CallbackStatusPending
+		callbackStatus = &pending
+	}
+
+	async.Status = models.AsyncTransferStatusQueued
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO async_transfers (source_account_id, destination_account_id, amount, status, next_attempt_at, callback_url, callback_status,
+			idempotency_key, request_hash, memo, client_reference, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING `+asyncTransferColumns+`
+	`, async.SourceAccountID, async.DestinationAccountID, async.Amount, async.Status, async.CallbackURL, callbackStatus,
+		key, requestHash, async.Memo, async.ClientReference, metadataArg(async.Metadata)).Scan(asyncTransferScanTargets(async)...)
+	if err == nil {
+		return async, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to queue transfer: %w", err)
+	}
+
+	// The idempotency key is already taken by an earlier transfer
+	var existing models.AsyncTransfer
+	var existingHash string
+	err = r.db.QueryRow(ctx, `
+		SELECT `+asyncTransferColumns+`, request_hash FROM async_transfers WHERE idempotency_key = $1
+	`, idempotencyKey.Key).Scan(append(asyncTransferScanTargets(&existing), &existingHash)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotent async transfer: %w", err)
+	}
+
+	if existingHash != idempotencyKey.RequestHash {
+		return nil, codes.ErrIdempotencyKeyReused
+	}
+
+	return &existing, nil
+}
+
+// GetAsyncTransferByID returns the async transfer, or nil if it does not
+// exist.
+func (r *AsyncTransferRepository) GetAsyncTransferByID(ctx context.Context, asyncID int) (*models.AsyncTransfer, error) {
+	var async models.AsyncTransfer
+	err := r.db.QueryRow(ctx, `
+		SELECT `+asyncTransferColumns+` FROM async_transfers WHERE id = $1
+	`, asyncID).Scan(asyncTransferScanTargets(&async)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get async transfer: %w", err)
+	}
+
+	return &async, nil
+}
+
+// ClaimAsyncTransfers moves up to limit queued transfers to PROCESSING, oldest
+// first, and counts the attempt. Like ClaimDueScheduledTransfers it reclaims
+// transfers left PROCESSING since before staleBefore and skips rows another
+// worker is claiming.
+func (r *AsyncTransferRepository) ClaimAsyncTransfers(ctx context.Context, limit int, staleBefore time.Time) ([]models.AsyncTransfer, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE async_transfers SET status = $1, attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM async_transfers
+			WHERE (status = $2 AND next_attempt_at <= NOW())
+				OR (status = $1 AND updated_at <= $3)
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+asyncTransferColumns+`
+	`, models.AsyncTransferStatusProcessing, models.AsyncTransferStatusQueued, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim async transfers: %w", err)
+	}
+	defer rows.Close()
+
+	var asyncTransfers []models.AsyncTransfer
+	for rows.Next() {
+		var async models.AsyncTransfer
+		if err = rows.Scan(asyncTransferScanTargets(&async)...); err != nil {
+			return nil, fmt.Errorf("failed to scan async transfer: %w", err)
+		}
+		asyncTransfers = append(asyncTransfers, async)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim async transfers: %w", err)
+	}
+
+	return asyncTransfers, nil
+}
+
+// MarkAsyncTransferCompleted links the applied transfer and the balances it
+// left, and makes the callback, if any, due.
+func (r *AsyncTransferRepository) MarkAsyncTransferCompleted(ctx context.Context, asyncID, transferID int, sourceBalance, destBalance decimal.Decimal) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE async_transfers
+		SET status = $1, transaction_id = $2, source_balance = $3, destination_balance = $4,
+			last_error_code = NULL, last_error = NULL, callback_next_attempt_at = NOW(), updated_at = NOW()
+		WHERE id = $5 AND status = $6
+	`, models.AsyncTransferStatusCompleted, transferID, sourceBalance, destBalance,
+		asyncID, models.AsyncTransferStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to mark async transfer completed: %w", err)
+	}
+	return nil
+}
+
+// RetryAsyncTransfer returns a failed attempt to QUEUED so it is picked up
+// again at nextAttemptAt.
+func (r *AsyncTransferRepository) RetryAsyncTransfer(ctx context.Context, asyncID int, nextAttemptAt time.Time, cause error) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE async_transfers
+		SET status = $1, next_attempt_at = $2, last_error_code = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $5 AND status = $6
+	`, models.AsyncTransferStatusQueued, nextAttemptAt, codes.GetCode(cause), lastErrorMessage(cause),
+		asyncID, models.AsyncTransferStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to requeue async transfer: %w", err)
+	}
+	return nil
+}
+
+// MarkAsyncTransferFailed gives up on the transfer, records why and makes the
+// callback, if any, due.
+func (r *AsyncTransferRepository) MarkAsyncTransferFailed(ctx context.Context, asyncID int, cause error) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE async_transfers
+		SET status = $1, last_error_code = $2, last_error = $3, callback_next_attempt_at = NOW(), updated_at = NOW()
+		WHERE id = $4 AND status = $5
+	`, models.AsyncTransferStatusFailed, codes.GetCode(cause), lastErrorMessage(cause),
+		asyncID, models.AsyncTransferStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to mark async transfer failed: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueCallbacks returns up to limit finished transfers whose callback is
+// due and counts the delivery attempt. The next attempt is pushed lease into
+// the future, so a sender that dies mid-delivery is retried without another
+// sender delivering the same callback meanwhile.
+func (r *AsyncTransferRepository) ClaimDueCallbacks(ctx context.Context, limit int, lease time.Duration) ([]models.AsyncTransfer, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE async_transfers
+		SET callback_attempts = callback_attempts + 1, callback_next_attempt_at = NOW() + $1::INTERVAL, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM async_transfers
+			WHERE callback_status = $2 AND callback_next_attempt_at <= NOW()
+			ORDER BY callback_next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+asyncTransferColumns+`
+	`, lease, models.CallbackStatusPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim async transfer callbacks: %w", err)
+	}
+	defer rows.Close()
+
+	var asyncTransfers []models.AsyncTransfer
+	for rows.Next() {
+		var async models.AsyncTransfer
+		if err = rows.Scan(asyncTransferScanTargets(&async)...); err != nil {
+			return nil, fmt.Errorf("failed to scan async transfer: %w", err)
+		}
+		asyncTransfers = append(asyncTransfers, async)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim async transfer callbacks: %w", err)
+	}
+
+	return asyncTransfers, nil
+}
+
+// RetryCallback makes the callback due again at nextAttemptAt
+func (r *AsyncTransferRepository) RetryCallback(ctx context.Context, asyncID int, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE async_transfers SET callback_next_attempt_at = $1, updated_at = NOW()
+		WHERE id = $2 AND callback_status = $3
+	`, nextAttemptAt, asyncID, models.CallbackStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule async transfer callback: %w", err)
+	}
+	return nil
+}
+
+// FinishCallback records that the callback was delivered, or that delivery
+// was given up on.
+func (r *AsyncTransferRepository) FinishCallback(ctx context.Context, asyncID int, status models.CallbackStatus) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE async_transfers SET callback_status = $1, callback_next_attempt_at = NULL, updated_at = NOW()
+		WHERE id = $2 AND callback_status = $3
+	`, status, asyncID, models.CallbackStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to finish async transfer callback: %w", err)
+	}
+	return nil
+}
+
+func asyncTransferScanTargets(async *models.AsyncTransfer) []any {
+	return append([]any{
+		&async.ID,
+		&async.SourceAccountID,
+		&async.DestinationAccountID,
+		&async.Amount,
+		&async.Status,
+		&async.Attempts,
+		&async.NextAttemptAt,
+		&async.LastErrorCode,
+		&async.LastError,
+		&async.TransactionID,
+		&async.SourceBalance,
+		&async.DestinationBalance,
+		&async.CallbackURL,
+		&async.CallbackStatus,
+		&async.CallbackAttempts,
+		&async.CreatedAt,
+		&async.UpdatedAt,
+	}, transferReferenceScanTargets(&async.TransferReference)...)
+}
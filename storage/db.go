@@ -7,14 +7,27 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
 	log "github.com/sirupsen/logrus"
 )
 
 type DB struct {
-	pool   *pgxpool.Pool
-	status atomic.Bool
+	pool              *pgxpool.Pool
+	status            atomic.Bool
+	txPolicy          TxPolicy
+	transferExecution TransferExecutionMode
+
+	// riskRules are the enabled risk rules in evaluation order, as last
+	// loaded by RiskRuleRepository.ReloadRiskRules. They are shared by the
+	// repositories built on this DB.
+	riskRules atomic.Pointer[[]models.RiskRule]
 }
 
+// Config describes the database to connect to. IsolationLevel, LockTimeout
+// and MaxTxRetries make up the TxPolicy of the transactions that move money;
+// their zero values keep the server's isolation level and lock timeout and
+// turn retries off. TransferExecution picks how single transfers are applied
+// and defaults to TransferExecutionLocking.
 type Config struct {
 	Host     string
 	Port     int
@@ -22,6 +35,12 @@ type Config struct {
 	Username string
 	Password string
 	SSLMode  string
+
+	IsolationLevel string
+	LockTimeout    time.Duration
+	MaxTxRetries   int
+
+	TransferExecution string
 }
 
 func InitDB(ctx context.Context, config Config) (*DB, error) {
@@ -35,6 +54,16 @@ func InitDB(ctx context.Context, config Config) (*DB, error) {
 		config.SSLMode,
 	)
 
+	isoLevel, err := ParseIsolationLevel(config.IsolationLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
+	transferExecution, err := ParseTransferExecutionMode(config.TransferExecution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
 	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse database config: %w", err)
@@ -53,6 +82,12 @@ func InitDB(ctx context.Context, config Config) (*DB, error) {
 	db := &DB{
 		pool:   pool,
 		status: atomic.Bool{},
+		txPolicy: TxPolicy{
+			IsoLevel:    isoLevel,
+			LockTimeout: config.LockTimeout,
+			MaxRetries:  config.MaxTxRetries,
+		},
+		transferExecution: transferExecution,
 	}
 
 	return db, nil
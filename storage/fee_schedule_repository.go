@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// feeScheduleColumns is the column list read by feeScheduleScanTargets
+const feeScheduleColumns = `id, account_id, account_type, fee_type, flat_amount, percentage, tiers, min_fee, max_fee, revenue_account_id, active, created_at, updated_at`
+
+type FeeScheduleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFeeScheduleRepository(db *DB) *FeeScheduleRepository {
+	return &FeeScheduleRepository{
+		db: db.pool,
+	}
+}
+
+// CreateFeeSchedule stores an active schedule and deactivates the one it
+// replaces, if any, in the same database transaction. Transfers already
+// priced keep pointing at the schedule they used.
+func (r *FeeScheduleRepository) CreateFeeSchedule(ctx context.Context, schedule *models.FeeSchedule) (*models.FeeSchedule, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var accountExists, revenueExists bool
+	err = tx.QueryRow(ctx, `
+		SELECT $1::INTEGER IS NULL OR EXISTS(SELECT 1 FROM accounts WHERE id = $1), EXISTS(SELECT 1 FROM accounts WHERE id = $2)
+	`, schedule.AccountID, schedule.RevenueAccountID).Scan(&accountExists, &revenueExists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check accounts: %w", err)
+	}
+	if !accountExists {
+		return nil, codes.ErrAccountNotFound
+	}
+	if !revenueExists {
+		return nil, codes.NewWithMsg(codes.ErrAccountNotFound, "fee revenue account %d not found", schedule.RevenueAccountID)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE fee_schedules SET active = FALSE, updated_at = NOW()
+		WHERE active AND (account_id = $1 OR account_type = $2)
+	`, schedule.AccountID, schedule.AccountType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate previous fee schedule: %w", err)
+	}
+
+	// Only tiered schedules store tiers; leave the column NULL otherwise
+	var tiers any
+	if len(schedule.Tiers) > 0 {
+		tiers = schedule.Tiers
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO fee_schedules (account_id, account_type, fee_type, flat_amount, percentage, tiers, min_fee, max_fee,
+			revenue_account_id, active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, TRUE, NOW(), NOW())
+		RETURNING `+feeScheduleColumns+`
+	`, schedule.AccountID, schedule.AccountType, schedule.FeeType, schedule.FlatAmount, schedule.Percentage, tiers,
+		schedule.MinFee, schedule.MaxFee, schedule.RevenueAccountID).Scan(feeScheduleScanTargets(schedule)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fee schedule: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// GetFeeScheduleByID returns the schedule, or nil if it does not exist.
+func (r *FeeScheduleRepository) GetFeeScheduleByID(ctx context.Context, scheduleID int) (*models.FeeSchedule, error) {
+	var schedule models.FeeSchedule
+	err := r.db.QueryRow(ctx, `
+		SELECT `+feeScheduleColumns+` FROM fee_schedules WHERE id = $1
+	`, scheduleID).Scan(feeScheduleScanTargets(&schedule)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get fee schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// ListFeeSchedules returns schedules matching filter, newest first, using the
+// same keyset pagination as ListTransfers.
+func (r *FeeScheduleRepository) ListFeeSchedules(ctx context.Context, filter models.FeeScheduleFilter) ([]models.FeeSchedule, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.AccountID > 0 {
+		addCondition("account_id = $%d", filter.AccountID)
+	}
+	if filter.AccountType != "" {
+		addCondition("account_type = $%d", filter.AccountType)
+	}
+	if filter.Active != nil {
+		addCondition("active = $%d", *filter.Active)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + feeScheduleColumns + ` FROM fee_schedules`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fee schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.FeeSchedule{}
+	for rows.Next() {
+		var schedule models.FeeSchedule
+		if err = rows.Scan(feeScheduleScanTargets(&schedule)...); err != nil {
+			return nil, fmt.Errorf("failed to scan fee schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list fee schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// DeactivateFeeSchedule stops an active schedule from pricing new transfers.
+// Accounts it applied to fall back to their type's schedule, or no fee.
+func (r *FeeScheduleRepository) DeactivateFeeSchedule(ctx context.Context, scheduleID int) (*models.FeeSchedule, error) {
+	var schedule models.FeeSchedule
+	err := r.db.QueryRow(ctx, `
+		UPDATE fee_schedules SET active = FALSE, updated_at = NOW()
+		WHERE id = $1 AND active
+		RETURNING `+feeScheduleColumns+`
+	`, scheduleID).Scan(feeScheduleScanTargets(&schedule)...)
+	if err == nil {
+		return &schedule, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to deactivate fee schedule: %w", err)
+	}
+
+	existing, err := r.GetFeeScheduleByID(ctx, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, codes.ErrFeeScheduleNotFound
+	}
+	return nil, codes.ErrFeeScheduleNotActive
+}
+
+// feeSchedulesTx returns the active schedule pricing transfers from each of
+// accountIDs, keyed by account ID. Accounts without one are missing from the
+// result.
+func feeSchedulesTx(ctx context.Context, tx pgx.Tx, accountIDs []int) (map[int]*models.FeeSchedule, error) {
+	if len(accountIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT DISTINCT ON (a.id) a.id, `+prefixColumns("fs", feeScheduleColumns)+`
+		FROM accounts a
+		JOIN fee_schedules fs ON fs.active
+			AND (fs.account_id = a.id OR (fs.account_id IS NULL AND fs.account_type = a.account_type))
+		WHERE a.id = ANY($1)
+		ORDER BY a.id, fs.account_id NULLS LAST
+	`, accountIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fee schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make(map[int]*models.FeeSchedule)
+	for rows.Next() {
+		var accountID int
+		var schedule models.FeeSchedule
+		if err = rows.Scan(append([]any{&accountID}, feeScheduleScanTargets(&schedule)...)...); err != nil {
+			return nil, fmt.Errorf("failed to scan fee schedule: %w", err)
+		}
+		schedules[accountID] = &schedule
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load fee schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// prefixColumns qualifies every column of a comma separated list with alias
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, column := range parts {
+		parts[i] = alias + "." + strings.TrimSpace(column)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func feeScheduleScanTargets(schedule *models.FeeSchedule) []any {
+	return []any{
+		&schedule.ID,
+		&schedule.AccountID,
+		&schedule.AccountType,
+		&schedule.FeeType,
+		&schedule.FlatAmount,
+		&schedule.Percentage,
+		&schedule.Tiers,
+		&schedule.MinFee,
+		&schedule.MaxFee,
+		&schedule.RevenueAccountID,
+		&schedule.Active,
+		&schedule.CreatedAt,
+		&schedule.UpdatedAt,
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// fxRateColumns is the column list read by fxRateScanTargets
+const fxRateColumns = `id, base_currency, quote_currency, rate, effective_at, created_at`
+
+// fxRateScale matches the precision of fx_rates.rate
+const fxRateScale = 12
+
+type FXRateRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFXRateRepository(db *DB) *FXRateRepository {
+	return &FXRateRepository{
+		db: db.pool,
+	}
+}
+
+// CreateFXRate stores a rate for the pair. Rates are never updated; a newer
+// effective_at supersedes the previous rate, so transfers keep pointing at
+// the rate they were converted with.
+func (r *FXRateRepository) CreateFXRate(ctx context.Context, rate *models.FXRate) (*models.FXRate, error) {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO fx_rates (base_currency, quote_currency, rate, effective_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING `+fxRateColumns+`
+	`, rate.BaseCurrency, rate.QuoteCurrency, rate.Rate, rate.EffectiveAt).Scan(fxRateScanTargets(rate)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create FX rate: %w", err)
+	}
+
+	return rate, nil
+}
+
+// GetFXRateByID returns the rate, or nil if it does not exist.
+func (r *FXRateRepository) GetFXRateByID(ctx context.Context, rateID int) (*models.FXRate, error) {
+	var rate models.FXRate
+	err := r.db.QueryRow(ctx, `
+		SELECT `+fxRateColumns+` FROM fx_rates WHERE id = $1
+	`, rateID).Scan(fxRateScanTargets(&rate)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get FX rate: %w", err)
+	}
+
+	return &rate, nil
+}
+
+// ListFXRates returns rates matching filter, newest first, using the same
+// keyset pagination as ListTransfers.
+func (r *FXRateRepository) ListFXRates(ctx context.Context, filter models.FXRateFilter) ([]models.FXRate, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.BaseCurrency != "" {
+		addCondition("base_currency = $%d", filter.BaseCurrency)
+	}
+	if filter.QuoteCurrency != "" {
+		addCondition("quote_currency = $%d", filter.QuoteCurrency)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + fxRateColumns + ` FROM fx_rates`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list FX rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []models.FXRate{}
+	for rows.Next() {
+		var rate models.FXRate
+		if err = rows.Scan(fxRateScanTargets(&rate)...); err != nil {
+			return nil, fmt.Errorf("failed to scan FX rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list FX rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// currentFXRateTx returns the newest rate for the pair that is already in
+// effect.
+func currentFXRateTx(ctx context.Context, tx pgx.Tx, baseCurrency, quoteCurrency string) (*models.FXRate, error) {
+	var rate models.FXRate
+	err := tx.QueryRow(ctx, `
+		SELECT `+fxRateColumns+` FROM fx_rates
+		WHERE base_currency = $1 AND quote_currency = $2 AND effective_at <= NOW()
+		ORDER BY effective_at DESC, id DESC
+		LIMIT 1
+	`, baseCurrency, quoteCurrency).Scan(fxRateScanTargets(&rate)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.NewWithMsg(codes.ErrFXRateUnavailable, "no exchange rate is available from %s to %s", baseCurrency, quoteCurrency)
+		}
+		return nil, fmt.Errorf("failed to get FX rate: %w", err)
+	}
+
+	return &rate, nil
+}
+
+func fxRateScanTargets(rate *models.FXRate) []any {
+	return []any{
+		&rate.ID,
+		&rate.BaseCurrency,
+		&rate.QuoteCurrency,
+		&rate.Rate,
+		&rate.EffectiveAt,
+		&rate.CreatedAt,
+	}
+}
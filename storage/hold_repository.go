@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+)
+
+// activeHoldsSum is a scalar subquery for the funds reserved on account a.id.
+// Holds past their expiry stop counting even before the sweeper marks them.
+const activeHoldsSum = `
+	SELECT COALESCE(SUM(h.amount), 0)
+	FROM holds h
+	WHERE h.account_id = a.id AND h.status = 'ACTIVE' AND h.expires_at > NOW()`
+
+// holdColumns is the column list read by scanHold
+const holdColumns = `id, account_id, destination_account_id, amount, captured_amount, status, transaction_id, expires_at, created_at, updated_at`
+
+type HoldRepository struct {
+	db        *pgxpool.Pool
+	txPolicy  TxPolicy
+	riskRules *atomic.Pointer[[]models.RiskRule]
+}
+
+func NewHoldRepository(db *DB) *HoldRepository {
+	return &HoldRepository{
+		db:        db.pool,
+		txPolicy:  db.txPolicy,
+		riskRules: &db.riskRules,
+	}
+}
+
+// AuthorizeHold reserves hold.Amount on the source account. The account row
+// is locked while the available balance is checked, so concurrent
+// authorizations and transfers cannot together overdraw it beyond its
+// overdraft limit. It returns the available balance left after the
+// reservation, which is negative when the hold draws on the overdraft. Like
+// ProcessTransfer it retries conflicts with concurrent transactions.
+func (r *HoldRepository) AuthorizeHold(ctx context.Context, hold *models.Hold) (decimal.Decimal, error) {
+	var remaining decimal.Decimal
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		var balance, overdraftLimit decimal.Decimal
+		err := tx.QueryRow(ctx, `
+			SELECT `+accountBalance+`, a.overdraft_limit FROM accounts a WHERE a.id = $1 FOR UPDATE
+		`, hold.AccountID).Scan(&balance, &overdraftLimit)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return codes.ErrSourceAccountNotFound
+			}
+			return fmt.Errorf("failed to lock source account: %w", err)
+		}
+
+		var destExists bool
+		err = tx.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM accounts WHERE id = $1)
+		`, hold.DestinationAccountID).Scan(&destExists)
+		if err != nil {
+			return fmt.Errorf("failed to check destination account: %w", err)
+		}
+		if !destExists {
+			return codes.ErrDestinationAccountNotFound
+		}
+
+		held, err := heldAmountTx(ctx, tx, hold.AccountID)
+		if err != nil {
+			return err
+		}
+
+		available := balance.Sub(held)
+		if available.Add(overdraftLimit).LessThan(hold.Amount) {
+			return codes.ErrInsufficientFunds
+		}
+
+		hold.Status = models.HoldStatusActive
+		err = tx.QueryRow(ctx, `
+			INSERT INTO holds (account_id, destination_account_id, amount, status, expires_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+			RETURNING id, created_at, updated_at
+		`, hold.AccountID, hold.DestinationAccountID, hold.Amount, hold.Status, hold.ExpiresAt).Scan(&hold.ID, &hold.CreatedAt, &hold.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create hold: %w", err)
+		}
+
+		remaining = available.Sub(hold.Amount)
+		return nil
+	})
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return remaining, nil
+}
+
+// CaptureHold moves amount (the full hold when nil) from the held account to
+// the hold's destination. The hold is released in the same database
+// transaction, so its reservation backs the transfer and any remainder is
+// returned to the available balance. Like ProcessTransfer it retries
+// conflicts with concurrent transactions.
+func (r *HoldRepository) CaptureHold(ctx context.Context, holdID int, amount *decimal.Decimal) (*models.Hold, *models.Transfer, decimal.Decimal, decimal.Decimal, error) {
+	var hold *models.Hold
+	var transfer *models.Transfer
+	var sourceBalance, destBalance decimal.Decimal
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		transfer = nil
+
+		var err error
+		hold, err = lockActiveHold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+
+		captured := hold.Amount
+		if amount != nil {
+			captured = *amount
+		}
+		if captured.GreaterThan(hold.Amount) {
+			return codes.NewWithMsg(codes.ErrCaptureExceedsHold, "capture amount exceeds the held amount %s", hold.Amount.String())
+		}
+
+		// Release the reservation before the transfer so its funds count as available
+		_, err = tx.Exec(ctx, `
+			UPDATE holds SET status = $1, captured_amount = $2, updated_at = NOW() WHERE id = $3
+		`, models.HoldStatusCaptured, captured, hold.ID)
+		if err != nil {
+			return fmt.Errorf("failed to capture hold: %w", err)
+		}
+
+		transfer = &models.Transfer{
+			SourceAccountID:      hold.AccountID,
+			DestinationAccountID: hold.DestinationAccountID,
+			Amount:               captured,
+		}
+
+		sourceBalance, destBalance, err = processTransferTx(ctx, tx, transfer, loadedRiskRules(r.riskRules))
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			UPDATE holds SET transaction_id = $1 WHERE id = $2
+			RETURNING `+holdColumns+`
+		`, transfer.ID, hold.ID).Scan(holdScanTargets(hold)...)
+		if err != nil {
+			return fmt.Errorf("failed to link hold to transfer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if transfer != nil {
+			recordDeclinedTransfer(ctx, r.db, transfer, err)
+		}
+		return nil, nil, decimal.Zero, decimal.Zero, err
+	}
+
+	return hold, transfer, sourceBalance, destBalance, nil
+}
+
+// VoidHold releases an active hold without moving any funds.
+func (r *HoldRepository) VoidHold(ctx context.Context, holdID int) (*models.Hold, error) {
+	var hold *models.Hold
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		var err error
+		hold, err = lockActiveHold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRow(ctx, `
+			UPDATE holds SET status = $1, updated_at = NOW() WHERE id = $2
+			RETURNING `+holdColumns+`
+		`, models.HoldStatusVoided, hold.ID).Scan(holdScanTargets(hold)...)
+		if err != nil {
+			return fmt.Errorf("failed to void hold: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// GetHoldByID returns the hold, or nil if it does not exist.
+func (r *HoldRepository) GetHoldByID(ctx context.Context, holdID int) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.db.QueryRow(ctx, `
+		SELECT `+holdColumns+` FROM holds WHERE id = $1
+	`, holdID).Scan(holdScanTargets(&hold)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get hold: %w", err)
+	}
+
+	return &hold, nil
+}
+
+// ExpireHolds marks active holds past their expiry as EXPIRED and returns how
+// many were changed. Expired holds already stop counting against the
+// available balance, so this only makes their status visible.
+func (r *HoldRepository) ExpireHolds(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE holds SET status = $1, updated_at = NOW()
+		WHERE status = $2 AND expires_at <= NOW()
+	`, models.HoldStatusExpired, models.HoldStatusActive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire holds: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// lockActiveHold locks the hold row and checks it can still be captured or
+// voided.
+func lockActiveHold(ctx context.Context, tx pgx.Tx, holdID int) (*models.Hold, error) {
+	var hold models.Hold
+	var expired bool
+	err := tx.QueryRow(ctx, `
+		SELECT `+holdColumns+`, expires_at <= NOW() FROM holds WHERE id = $1 FOR UPDATE
+	`, holdID).Scan(append(holdScanTargets(&hold), &expired)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.ErrHoldNotFound
+		}
+		return nil, fmt.Errorf("failed to lock hold: %w", err)
+	}
+
+	if hold.Status == models.HoldStatusActive && expired {
+		hold.Status = models.HoldStatusExpired
+	}
+	if hold.Status != models.HoldStatusActive {
+		return nil, codes.NewWithMsg(codes.ErrHoldNotActive, "hold is %s", hold.Status)
+	}
+
+	return &hold, nil
+}
+
+// heldAmountTx sums the active holds on an account. Call it after the account
+// row is locked so holds authorized while waiting for the lock are seen.
+func heldAmountTx(ctx context.Context, tx pgx.Tx, accountID int) (decimal.Decimal, error) {
+	var held decimal.Decimal
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM holds
+		WHERE account_id = $1 AND status = 'ACTIVE' AND expires_at > NOW()
+	`, accountID).Scan(&held)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum holds: %w", err)
+	}
+	return held, nil
+}
+
+func holdScanTargets(hold *models.Hold) []any {
+	return []any{
+		&hold.ID,
+		&hold.AccountID,
+		&hold.DestinationAccountID,
+		&hold.Amount,
+		&hold.CapturedAmount,
+		&hold.Status,
+		&hold.TransactionID,
+		&hold.ExpiresAt,
+		&hold.CreatedAt,
+		&hold.UpdatedAt,
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// postingColumns is the column list read by postingScanTargets
+const postingColumns = `id, journal_id, account_id, system_account, currency, amount, created_at`
+
+// journalColumns is the column list read by journalScanTargets
+const journalColumns = `id, kind, transaction_id, split_payment_id, account_id, created_at`
+
+// postJournalTx stores journal and its postings and fills in the journal's
+// ID and timestamp, which its postings share. The database rejects the
+// transaction at commit unless the postings add up to zero in every currency.
+func postJournalTx(ctx context.Context, tx pgx.Tx, journal *models.Journal) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO journals (kind, transaction_id, split_payment_id, account_id, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at
+	`, journal.Kind, journal.TransactionID, journal.SplitPaymentID, journal.AccountID).Scan(&journal.ID, &journal.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create journal: %w", err)
+	}
+
+	if len(journal.Postings) == 0 {
+		return nil
+	}
+
+	// One statement for all the postings keeps the transfer path to a
+	// single extra round trip
+	args := make([]any, 0, 2+4*len(journal.Postings))
+	args = append(args, journal.ID, journal.CreatedAt)
+	values := make([]string, len(journal.Postings))
+	for i := range journal.Postings {
+		posting := &journal.Postings[i]
+		posting.JournalID, posting.CreatedAt = journal.ID, journal.CreatedAt
+
+		n := len(args)
+		values[i] = fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $2)", n+1, n+2, n+3, n+4)
+		args = append(args, posting.AccountID, posting.SystemAccount, posting.Currency, posting.Amount)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO postings (journal_id, account_id, system_account, currency, amount, created_at)
+		VALUES `+strings.Join(values, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to post journal: %w", err)
+	}
+
+	return nil
+}
+
+// postTransferJournalTx posts the journal of a transfer insertTransferTx
+// has just stored
+func postTransferJournalTx(ctx context.Context, tx pgx.Tx, transfer *models.Transfer) error {
+	return postJournalTx(ctx, tx, &models.Journal{
+		Kind:          models.JournalKindTransfer,
+		TransactionID: &transfer.ID,
+		Postings:      models.TransferPostings(transfer),
+	})
+}
+
+func scanPostings(rows pgx.Rows) ([]models.Posting, error) {
+	defer rows.Close()
+
+	postings := []models.Posting{}
+	for rows.Next() {
+		var posting models.Posting
+		if err := rows.Scan(postingScanTargets(&posting)...); err != nil {
+			return nil, fmt.Errorf("failed to scan posting: %w", err)
+		}
+		postings = append(postings, posting)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get postings: %w", err)
+	}
+
+	return postings, nil
+}
+
+func postingScanTargets(posting *models.Posting) []any {
+	return []any{
+		&posting.ID,
+		&posting.JournalID,
+		&posting.AccountID,
+		&posting.SystemAccount,
+		&posting.Currency,
+		&posting.Amount,
+		&posting.CreatedAt,
+	}
+}
+
+func journalScanTargets(journal *models.Journal) []any {
+	return []any{
+		&journal.ID,
+		&journal.Kind,
+		&journal.TransactionID,
+		&journal.SplitPaymentID,
+		&journal.AccountID,
+		&journal.CreatedAt,
+	}
+}
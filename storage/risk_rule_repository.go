@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+// riskRuleColumns is the column list read by riskRuleScanTargets
+const riskRuleColumns = `id, name, rule_type, action, priority, account_id, account_side, amount, currency, max_account_age_seconds, blocked_account_ids, round_multiple, enabled, created_at, updated_at`
+
+// riskRuleFlagColumns is the column list read by riskRuleFlagScanTargets
+const riskRuleFlagColumns = `id, risk_rule_id, transaction_id, created_at`
+
+// RiskRuleRepository stores risk rules and loads the enabled ones into the
+// set ProcessTransfer checks. Changes only take effect once the rules are
+// reloaded.
+type RiskRuleRepository struct {
+	db        *pgxpool.Pool
+	riskRules *atomic.Pointer[[]models.RiskRule]
+}
+
+func NewRiskRuleRepository(db *DB) *RiskRuleRepository {
+	return &RiskRuleRepository{
+		db:        db.pool,
+		riskRules: &db.riskRules,
+	}
+}
+
+// CreateRiskRule stores rule. Rule names are unique, so a denial can name
+// the rule that declined the transfer.
+func (r *RiskRuleRepository) CreateRiskRule(ctx context.Context, rule *models.RiskRule) error {
+	if rule.AccountID != nil {
+		var exists bool
+		err := r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM accounts WHERE id = $1)`, *rule.AccountID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check account: %w", err)
+		}
+		if !exists {
+			return codes.ErrAccountNotFound
+		}
+	}
+
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO risk_rules (name, rule_type, action, priority, account_id, account_side, amount, currency, max_account_age_seconds,
+			blocked_account_ids, round_multiple, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		RETURNING `+riskRuleColumns+`
+	`, rule.Name, rule.Type, rule.Action, rule.Priority, rule.AccountID, rule.AccountSide, rule.Amount, rule.Currency,
+		rule.MaxAccountAgeSeconds, rule.BlockedAccountIDs, rule.RoundMultiple, rule.Enabled).Scan(riskRuleScanTargets(rule)...)
+	if err != nil {
+		if strings.Contains(err.Error(), "23505") {
+			return codes.NewWithMsg(codes.ErrRiskRuleNameTaken, "a risk rule named %q already exists", rule.Name)
+		}
+		return fmt.Errorf("failed to create risk rule: %w", err)
+	}
+
+	return nil
+}
+
+// GetRiskRuleByID returns the risk rule, or nil if it does not exist.
+func (r *RiskRuleRepository) GetRiskRuleByID(ctx context.Context, ruleID int) (*models.RiskRule, error) {
+	var rule models.RiskRule
+	err := r.db.QueryRow(ctx, `
+		SELECT `+riskRuleColumns+` FROM risk_rules WHERE id = $1
+	`, ruleID).Scan(riskRuleScanTargets(&rule)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get risk rule: %w", err)
+	}
+
+	return &rule, nil
+}
+
+// ListRiskRules returns every rule in evaluation order. With enabledOnly,
+// disabled rules are left out.
+func (r *RiskRuleRepository) ListRiskRules(ctx context.Context, enabledOnly bool) ([]models.RiskRule, error) {
+	query := `SELECT ` + riskRuleColumns + ` FROM risk_rules`
+	if enabledOnly {
+		query += ` WHERE enabled`
+	}
+	query += ` ORDER BY priority, id`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list risk rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.RiskRule{}
+	for rows.Next() {
+		var rule models.RiskRule
+		if err = rows.Scan(riskRuleScanTargets(&rule)...); err != nil {
+			return nil, fmt.Errorf("failed to scan risk rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list risk rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// SetRiskRuleEnabled turns a rule on or off.
+func (r *RiskRuleRepository) SetRiskRuleEnabled(ctx context.Context, ruleID int, enabled bool) (*models.RiskRule, error) {
+	var rule models.RiskRule
+	err := r.db.QueryRow(ctx, `
+		UPDATE risk_rules SET enabled = $1, updated_at = NOW() WHERE id = $2
+		RETURNING `+riskRuleColumns+`
+	`, enabled, ruleID).Scan(riskRuleScanTargets(&rule)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.ErrRiskRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to update risk rule: %w", err)
+	}
+
+	return &rule, nil
+}
+
+// ReloadRiskRules replaces the rules ProcessTransfer checks with the ones
+// enabled in the database and returns how many were loaded. Transfers
+// already running keep the rules they started with.
+func (r *RiskRuleRepository) ReloadRiskRules(ctx context.Context) (int, error) {
+	rules, err := r.ListRiskRules(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+
+	r.riskRules.Store(&rules)
+	return len(rules), nil
+}
+
+// ListRiskRuleFlags returns the transfers a FLAG rule matched, newest first.
+// afterID is the keyset cursor.
+func (r *RiskRuleRepository) ListRiskRuleFlags(ctx context.Context, ruleID, afterID, limit int) ([]models.RiskRuleFlag, error) {
+	query := `SELECT ` + riskRuleFlagColumns + ` FROM risk_rule_flags WHERE risk_rule_id = $1`
+	args := []any{ruleID}
+	if afterID > 0 {
+		args = append(args, afterID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list risk rule flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := []models.RiskRuleFlag{}
+	for rows.Next() {
+		var flag models.RiskRuleFlag
+		if err = rows.Scan(riskRuleFlagScanTargets(&flag)...); err != nil {
+			return nil, fmt.Errorf("failed to scan risk rule flag: %w", err)
+		}
+		flags = append(flags, flag)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list risk rule flags: %w", err)
+	}
+
+	return flags, nil
+}
+
+// loadedRiskRules returns the rules as of the last reload
+func loadedRiskRules(riskRules *atomic.Pointer[[]models.RiskRule]) []models.RiskRule {
+	if riskRules == nil {
+		return nil
+	}
+	rules := riskRules.Load()
+	if rules == nil {
+		return nil
+	}
+	return *rules
+}
+
+// checkRiskRulesTx evaluates rules against a transfer applied in tx but not
+// yet committed, as if it moved amount. A DENY rule fails the transfer with
+// a code naming the rule, so the caller rolls it back. Every FLAG rule that
+// matched is recorded against the transfer in tx.
+func checkRiskRulesTx(ctx context.Context, tx pgx.Tx, rules []models.RiskRule, transfer *models.Transfer, amount decimal.Decimal) error {
+	in := &models.RiskInput{
+		SourceAccountID:      transfer.SourceAccountID,
+		DestinationAccountID: transfer.DestinationAccountID,
+		Amount:               amount,
+		Currency:             transfer.SourceCurrency,
+		Now:                  time.Now(),
+	}
+
+	if models.NeedsAccountAge(rules) {
+		err := tx.QueryRow(ctx, `
+			SELECT (SELECT created_at FROM accounts WHERE id = $1), (SELECT created_at FROM accounts WHERE id = $2)
+		`, transfer.SourceAccountID, transfer.DestinationAccountID).Scan(&in.SourceOpenedAt, &in.DestinationOpenedAt)
+		if err != nil {
+			return fmt.Errorf("failed to get account opening times: %w", err)
+		}
+	}
+
+	decision := models.EvaluateRiskRules(rules, in)
+	if decision.Action == models.RiskActionDeny {
+		log.WithFields(log.Fields{
+			"risk_rule_id":           decision.Rule.ID,
+			"source_account_id":      transfer.SourceAccountID,
+			"destination_account_id": transfer.DestinationAccountID,
+			"amount":                 amount.String(),
+		}).Warn("Transfer denied by risk rule")
+		return codes.NewWithMsg(codes.ErrRiskRuleDenied, "transfer denied by risk rule %q", decision.Rule.Name)
+	}
+
+	for _, flagged := range decision.Flags {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO risk_rule_flags (risk_rule_id, transaction_id, created_at) VALUES ($1, $2, NOW())
+		`, flagged.ID, transfer.ID)
+		if err != nil {
+			return fmt.Errorf("failed to record risk rule flag: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func riskRuleScanTargets(rule *models.RiskRule) []any {
+	return []any{
+		&rule.ID,
+		&rule.Name,
+		&rule.Type,
+		&rule.Action,
+		&rule.Priority,
+		&rule.AccountID,
+		&rule.AccountSide,
+		&rule.Amount,
+		&rule.Currency,
+		&rule.MaxAccountAgeSeconds,
+		&rule.BlockedAccountIDs,
+		&rule.RoundMultiple,
+		&rule.Enabled,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	}
+}
+
+func riskRuleFlagScanTargets(flag *models.RiskRuleFlag) []any {
+	return []any{
+		&flag.ID,
+		&flag.RiskRuleID,
+		&flag.TransactionID,
+		&flag.CreatedAt,
+	}
+}
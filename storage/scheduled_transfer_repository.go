@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// scheduledTransferColumns is the column list read by scheduledTransferScanTargets
+const scheduledTransferColumns = `id, source_account_id, destination_account_id, amount, execute_at, status, attempts, next_attempt_at, last_error_code, last_error, transaction_id, created_at, updated_at, ` + transferReferenceColumns
+
+// maxLastErrorLength matches scheduled_transfers.last_error
+const maxLastErrorLength = 255
+
+type ScheduledTransferRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewScheduledTransferRepository(db *DB) *ScheduledTransferRepository {
+	return &ScheduledTransferRepository{
+		db: db.pool,
+	}
+}
+
+// ScheduleTransfer stores a transfer to be executed at scheduled.ExecuteAt.
+// Both accounts must exist, but funds are only checked on execution. When an
+// idempotency key is given it is kept with the schedule for as long as the
+// schedule exists, and a replay returns the original schedule.
+func (r *ScheduledTransferRepository) ScheduleTransfer(ctx context.Context, scheduled *models.ScheduledTransfer, idempotencyKey *models.IdempotencyKey) (*models.ScheduledTransfer, error) {
+	err := checkTransferAccountsExist(ctx, r.db, scheduled.SourceAccountID, scheduled.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var key, requestHash *string
+	if idempotencyKey != nil {
+		key = &idempotencyKey.Key
+		requestHash = &idempotencyKey.RequestHash
+	}
+
+	scheduled.Status = models.ScheduledTransferStatusPending
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO scheduled_transfers (source_account_id, destination_account_id, amount, execute_at, status, next_attempt_at, idempotency_key, request_hash,
+			memo, client_reference, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $4, $6, $7, $8, $9, $10, NOW(), NOW())
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING `+scheduledTransferColumns+`
+	`, scheduled.SourceAccountID, scheduled.DestinationAccountID, scheduled.Amount, scheduled.ExecuteAt,
+		scheduled.Status, key, requestHash, scheduled.Memo, scheduled.ClientReference,
+		metadataArg(scheduled.Metadata)).Scan(scheduledTransferScanTargets(scheduled)...)
+	if err == nil {
+		return scheduled, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to schedule transfer: %w", err)
+	}
+
+	// The idempotency key is already taken by an earlier schedule
+	var existing models.ScheduledTransfer
+	var existingHash string
+	err = r.db.QueryRow(ctx, `
+		SELECT `+scheduledTransferColumns+`, request_hash FROM scheduled_transfers WHERE idempotency_key = $1
+	`, idempotencyKey.Key).Scan(append(scheduledTransferScanTargets(&existing), &existingHash)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotent scheduled transfer: %w", err)
+	}
+
+	if existingHash != idempotencyKey.RequestHash {
+		return nil, codes.ErrIdempotencyKeyReused
+	}
+
+	return &existing, nil
+}
+
+// GetScheduledTransferByID returns the scheduled transfer, or nil if it does
+// not exist.
+func (r *ScheduledTransferRepository) GetScheduledTransferByID(ctx context.Context, scheduledID int) (*models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	err := r.db.QueryRow(ctx, `
+		SELECT `+scheduledTransferColumns+` FROM scheduled_transfers WHERE id = $1
+	`, scheduledID).Scan(scheduledTransferScanTargets(&scheduled)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get scheduled transfer: %w", err)
+	}
+
+	return &scheduled, nil
+}
+
+// ListScheduledTransfers returns scheduled transfers matching filter, newest
+// first, using the same keyset pagination as ListTransfers.
+func (r *ScheduledTransferRepository) ListScheduledTransfers(ctx context.Context, filter models.ScheduledTransferFilter) ([]models.ScheduledTransfer, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SourceAccountID > 0 {
+		addCondition("source_account_id = $%d", filter.SourceAccountID)
+	}
+	if filter.DestinationAccountID > 0 {
+		addCondition("destination_account_id = $%d", filter.DestinationAccountID)
+	}
+	if filter.AccountID > 0 {
+		args = append(args, filter.AccountID)
+		conditions = append(conditions, fmt.Sprintf("(source_account_id = $%d OR destination_account_id = $%d)", len(args), len(args)))
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + scheduledTransferColumns + ` FROM scheduled_transfers`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	scheduledTransfers := []models.ScheduledTransfer{}
+	for rows.Next() {
+		var scheduled models.ScheduledTransfer
+		if err = rows.Scan(scheduledTransferScanTargets(&scheduled)...); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer: %w", err)
+		}
+		scheduledTransfers = append(scheduledTransfers, scheduled)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list scheduled transfers: %w", err)
+	}
+
+	return scheduledTransfers, nil
+}
+
+// CancelScheduledTransfer cancels a transfer that has not started executing.
+// Once the executor has picked it up it can no longer be cancelled.
+func (r *ScheduledTransferRepository) CancelScheduledTransfer(ctx context.Context, scheduledID int) (*models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	err := r.db.QueryRow(ctx, `
+		UPDATE scheduled_transfers SET status = $1, updated_at = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING `+scheduledTransferColumns+`
+	`, models.ScheduledTransferStatusCancelled, scheduledID, models.ScheduledTransferStatusPending).Scan(scheduledTransferScanTargets(&scheduled)...)
+	if err == nil {
+		return &scheduled, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to cancel scheduled transfer: %w", err)
+	}
+
+	existing, err := r.GetScheduledTransferByID(ctx, scheduledID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, codes.ErrScheduledTransferNotFound
+	}
+	return nil, codes.NewWithMsg(codes.ErrScheduledTransferNotPending, "scheduled transfer is %s", existing.Status)
+}
+
+// ClaimDueScheduledTransfers moves up to limit due transfers to PROCESSING
+// and counts the attempt. Transfers left PROCESSING since before staleBefore
+// are claimed again, so a crashed executor does not strand them. SKIP LOCKED
+// lets several executors claim concurrently without taking the same rows.
+func (r *ScheduledTransferRepository) ClaimDueScheduledTransfers(ctx context.Context, limit int, staleBefore time.Time) ([]models.ScheduledTransfer, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE scheduled_transfers SET status = $1, attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM scheduled_transfers
+			WHERE (status = $2 AND next_attempt_at <= NOW())
+				OR (status = $1 AND updated_at <= $3)
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+scheduledTransferColumns+`
+	`, models.ScheduledTransferStatusProcessing, models.ScheduledTransferStatusPending, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled transfers: %w", err)
+	}
+	defer rows.Close()
+
+	var scheduledTransfers []models.ScheduledTransfer
+	for rows.Next() {
+		var scheduled models.ScheduledTransfer
+		if err = rows.Scan(scheduledTransferScanTargets(&scheduled)...); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled transfer: %w", err)
+		}
+		scheduledTransfers = append(scheduledTransfers, scheduled)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled transfers: %w", err)
+	}
+
+	return scheduledTransfers, nil
+}
+
+// MarkScheduledTransferExecuted links the executed transfer and finishes the
+// schedule.
+func (r *ScheduledTransferRepository) MarkScheduledTransferExecuted(ctx context.Context, scheduledID, transferID int) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE scheduled_transfers
+		SET status = $1, transaction_id = $2, last_error_code = NULL, last_error = NULL, updated_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, models.ScheduledTransferStatusExecuted, transferID, scheduledID, models.ScheduledTransferStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to mark scheduled transfer executed: %w", err)
+	}
+	return nil
+}
+
+// RetryScheduledTransfer returns a failed attempt to PENDING so it is picked
+// up again at nextAttemptAt.
+func (r *ScheduledTransferRepository) RetryScheduledTransfer(ctx context.Context, scheduledID int, nextAttemptAt time.Time, cause error) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE scheduled_transfers
+		SET status = $1, next_attempt_at = $2, last_error_code = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $5 AND status = $6
+	`, models.ScheduledTransferStatusPending, nextAttemptAt, codes.GetCode(cause), lastErrorMessage(cause),
+		scheduledID, models.ScheduledTransferStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule scheduled transfer: %w", err)
+	}
+	return nil
+}
+
+// MarkScheduledTransferFailed gives up on the schedule and records why.
+func (r *ScheduledTransferRepository) MarkScheduledTransferFailed(ctx context.Context, scheduledID int, cause error) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE scheduled_transfers
+		SET status = $1, last_error_code = $2, last_error = $3, updated_at = NOW()
+		WHERE id = $4 AND status = $5
+	`, models.ScheduledTransferStatusFailed, codes.GetCode(cause), lastErrorMessage(cause),
+		scheduledID, models.ScheduledTransferStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to mark scheduled transfer failed: %w", err)
+	}
+	return nil
+}
+
+func lastErrorMessage(err error) string {
+	msg := err.Error()
+	if len(msg) > maxLastErrorLength {
+		msg = strings.ToValidUTF8(msg[:maxLastErrorLength], "")
+	}
+	return msg
+}
+
+func scheduledTransferScanTargets(scheduled *models.ScheduledTransfer) []any {
+	return append([]any{
+		&scheduled.ID,
+		&scheduled.SourceAccountID,
+		&scheduled.DestinationAccountID,
+		&scheduled.Amount,
+		&scheduled.ExecuteAt,
+		&scheduled.Status,
+		&scheduled.Attempts,
+		&scheduled.NextAttemptAt,
+		&scheduled.LastErrorCode,
+		&scheduled.LastError,
+		&scheduled.TransactionID,
+		&scheduled.CreatedAt,
+		&scheduled.UpdatedAt,
+	}, transferReferenceScanTargets(&scheduled.TransferReference)...)
+}
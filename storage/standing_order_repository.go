@@ -0,0 +1,405 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// standingOrderColumns is the column list read by standingOrderScanTargets
+const standingOrderColumns = `id, source_account_id, destination_account_id, amount, frequency, interval_seconds, day_of_week, day_of_month, start_at, end_at, max_runs, executed_runs, failure_policy, max_retries, retry_interval_seconds, retry_count, status, next_run_at, next_attempt_at, created_at, updated_at`
+
+// standingOrderRunColumns is the column list read by standingOrderRunScanTargets
+const standingOrderRunColumns = `id, standing_order_id, scheduled_for, attempt, status, transaction_id, error_code, error, created_at`
+
+type StandingOrderRepository struct {
+	db        *pgxpool.Pool
+	txPolicy  TxPolicy
+	riskRules *atomic.Pointer[[]models.RiskRule]
+}
+
+func NewStandingOrderRepository(db *DB) *StandingOrderRepository {
+	return &StandingOrderRepository{
+		db:        db.pool,
+		txPolicy:  db.txPolicy,
+		riskRules: &db.riskRules,
+	}
+}
+
+// CreateStandingOrder stores order with its first run due at the first
+// occurrence of its rule. Both accounts must exist, but funds are only
+// checked on each run.
+func (r *StandingOrderRepository) CreateStandingOrder(ctx context.Context, order *models.StandingOrder) error {
+	err := checkTransferAccountsExist(ctx, r.db, order.SourceAccountID, order.DestinationAccountID)
+	if err != nil {
+		return err
+	}
+
+	order.Status = models.StandingOrderStatusActive
+	order.NextRunAt = order.FirstOccurrence()
+	order.NextAttemptAt = order.NextRunAt
+
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO standing_orders (source_account_id, destination_account_id, amount, frequency, interval_seconds, day_of_week, day_of_month,
+			start_at, end_at, max_runs, failure_policy, max_retries, retry_interval_seconds, status, next_run_at, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW(), NOW())
+		RETURNING `+standingOrderColumns+`
+	`, order.SourceAccountID, order.DestinationAccountID, order.Amount, order.Frequency, order.IntervalSeconds, order.DayOfWeek, order.DayOfMonth,
+		order.StartAt, order.EndAt, order.MaxRuns, order.FailurePolicy, order.MaxRetries, order.RetryIntervalSeconds, order.Status,
+		order.NextRunAt, order.NextAttemptAt).Scan(standingOrderScanTargets(order)...)
+	if err != nil {
+		return fmt.Errorf("failed to create standing order: %w", err)
+	}
+
+	return nil
+}
+
+// GetStandingOrderByID returns the standing order, or nil if it does not exist.
+func (r *StandingOrderRepository) GetStandingOrderByID(ctx context.Context, orderID int) (*models.StandingOrder, error) {
+	var order models.StandingOrder
+	err := r.db.QueryRow(ctx, `
+		SELECT `+standingOrderColumns+` FROM standing_orders WHERE id = $1
+	`, orderID).Scan(standingOrderScanTargets(&order)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get standing order: %w", err)
+	}
+
+	return &order, nil
+}
+
+// ListStandingOrders returns standing orders matching filter, newest first,
+// using the same keyset pagination as ListTransfers.
+func (r *StandingOrderRepository) ListStandingOrders(ctx context.Context, filter models.StandingOrderFilter) ([]models.StandingOrder, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SourceAccountID > 0 {
+		addCondition("source_account_id = $%d", filter.SourceAccountID)
+	}
+	if filter.DestinationAccountID > 0 {
+		addCondition("destination_account_id = $%d", filter.DestinationAccountID)
+	}
+	if filter.AccountID > 0 {
+		args = append(args, filter.AccountID)
+		conditions = append(conditions, fmt.Sprintf("(source_account_id = $%d OR destination_account_id = $%d)", len(args), len(args)))
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + standingOrderColumns + ` FROM standing_orders`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list standing orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []models.StandingOrder{}
+	for rows.Next() {
+		var order models.StandingOrder
+		if err = rows.Scan(standingOrderScanTargets(&order)...); err != nil {
+			return nil, fmt.Errorf("failed to scan standing order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list standing orders: %w", err)
+	}
+
+	return orders, nil
+}
+
+// ListStandingOrderRuns returns the execution history of an order, newest
+// first. afterID is the keyset cursor.
+func (r *StandingOrderRepository) ListStandingOrderRuns(ctx context.Context, orderID, afterID, limit int) ([]models.StandingOrderRun, error) {
+	query := `SELECT ` + standingOrderRunColumns + ` FROM standing_order_runs WHERE standing_order_id = $1`
+	args := []any{orderID}
+	if afterID > 0 {
+		args = append(args, afterID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list standing order runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []models.StandingOrderRun{}
+	for rows.Next() {
+		var run models.StandingOrderRun
+		if err = rows.Scan(standingOrderRunScanTargets(&run)...); err != nil {
+			return nil, fmt.Errorf("failed to scan standing order run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list standing order runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// CancelStandingOrder stops an active order. A run already in progress
+// holds the order row, so cancelling waits for it to finish.
+func (r *StandingOrderRepository) CancelStandingOrder(ctx context.Context, orderID int) (*models.StandingOrder, error) {
+	var order models.StandingOrder
+	err := r.db.QueryRow(ctx, `
+		UPDATE standing_orders SET status = $1, updated_at = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING `+standingOrderColumns+`
+	`, models.StandingOrderStatusCancelled, orderID, models.StandingOrderStatusActive).Scan(standingOrderScanTargets(&order)...)
+	if err == nil {
+		return &order, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to cancel standing order: %w", err)
+	}
+
+	existing, err := r.GetStandingOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, codes.ErrStandingOrderNotFound
+	}
+	return nil, codes.NewWithMsg(codes.ErrStandingOrderNotActive, "standing order is %s", existing.Status)
+}
+
+// RunDueStandingOrders executes up to limit orders whose next attempt is due
+// and returns how many runs were made. An order that fails with a system
+// error is left due and tried again on the next call.
+func (r *StandingOrderRepository) RunDueStandingOrders(ctx context.Context, limit int) (int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM standing_orders
+		WHERE status = $1 AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT $2
+	`, models.StandingOrderStatusActive, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due standing orders: %w", err)
+	}
+
+	orderIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due standing orders: %w", err)
+	}
+
+	runs := 0
+	for _, orderID := range orderIDs {
+		run, err := r.runStandingOrder(ctx, orderID)
+		if err != nil {
+			log.WithError(err).WithField("standing_order_id", orderID).Error("Standing order run failed")
+			continue
+		}
+		if run != nil {
+			runs++
+		}
+	}
+
+	return runs, nil
+}
+
+// runStandingOrder executes the order's due occurrence. The order row is
+// locked for the whole run and the transfer, the run record and the advanced
+// schedule commit together, so an occurrence is never paid twice. It returns
+// nil when the order is no longer due or another executor holds it.
+// Conflicts with concurrent transactions rerun the whole occurrence.
+func (r *StandingOrderRepository) runStandingOrder(ctx context.Context, orderID int) (*models.StandingOrderRun, error) {
+	var order models.StandingOrder
+	var run *models.StandingOrderRun
+	var transfer *models.Transfer
+	var transferErr error
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		run = nil
+
+		err := tx.QueryRow(ctx, `
+			SELECT `+standingOrderColumns+` FROM standing_orders
+			WHERE id = $1 AND status = $2 AND next_attempt_at <= NOW()
+			FOR UPDATE SKIP LOCKED
+		`, orderID, models.StandingOrderStatusActive).Scan(standingOrderScanTargets(&order)...)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+			return fmt.Errorf("failed to lock standing order: %w", err)
+		}
+
+		transfer = &models.Transfer{
+			SourceAccountID:      order.SourceAccountID,
+			DestinationAccountID: order.DestinationAccountID,
+			Amount:               order.Amount,
+		}
+
+		// The transfer runs in a savepoint so a declined attempt can still be
+		// recorded in this transaction
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to begin savepoint: %w", err)
+		}
+		_, _, transferErr = processTransferTx(ctx, savepoint, transfer, loadedRiskRules(r.riskRules))
+		if transferErr == nil {
+			err = savepoint.Commit(ctx)
+		} else {
+			err = savepoint.Rollback(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to end savepoint: %w", err)
+		}
+
+		var codeErr codes.CodeError
+		if transferErr != nil && !errors.As(transferErr, &codeErr) {
+			return transferErr
+		}
+
+		run = &models.StandingOrderRun{
+			StandingOrderID: order.ID,
+			ScheduledFor:    order.NextRunAt,
+			Attempt:         order.RetryCount + 1,
+		}
+
+		now := time.Now()
+		advance := true
+		switch {
+		case transferErr == nil:
+			run.Status = models.StandingOrderRunStatusSucceeded
+			run.TransactionID = &transfer.ID
+			order.ExecutedRuns++
+		case order.FailurePolicy == models.StandingOrderFailurePolicyRetry && order.RetryCount < order.MaxRetries:
+			run.Status = models.StandingOrderRunStatusFailed
+			order.RetryCount++
+			order.NextAttemptAt = now.Add(time.Duration(order.RetryIntervalSeconds) * time.Second)
+			advance = false
+		default:
+			run.Status = models.StandingOrderRunStatusSkipped
+		}
+
+		if transferErr != nil {
+			errorMsg := lastErrorMessage(codeErr)
+			run.ErrorCode = &codeErr.Code
+			run.Error = &errorMsg
+		}
+
+		if advance {
+			// Occurrences missed while the executor was down are not back-filled
+			after := order.NextRunAt
+			if now.After(after) {
+				after = now
+			}
+			order.RetryCount = 0
+			order.NextRunAt = order.NextOccurrence(after)
+			order.NextAttemptAt = order.NextRunAt
+
+			if (order.MaxRuns != nil && order.ExecutedRuns >= *order.MaxRuns) || (order.EndAt != nil && order.NextRunAt.After(*order.EndAt)) {
+				order.Status = models.StandingOrderStatusCompleted
+			}
+		}
+
+		err = tx.QueryRow(ctx, `
+			INSERT INTO standing_order_runs (standing_order_id, scheduled_for, attempt, status, transaction_id, error_code, error, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+			RETURNING id, created_at
+		`, run.StandingOrderID, run.ScheduledFor, run.Attempt, run.Status, run.TransactionID, run.ErrorCode, run.Error).Scan(&run.ID, &run.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record standing order run: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE standing_orders
+			SET executed_runs = $1, retry_count = $2, next_run_at = $3, next_attempt_at = $4, status = $5, updated_at = NOW()
+			WHERE id = $6
+		`, order.ExecutedRuns, order.RetryCount, order.NextRunAt, order.NextAttemptAt, order.Status, order.ID)
+		if err != nil {
+			return fmt.Errorf("failed to advance standing order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, nil
+	}
+
+	if transferErr != nil {
+		recordDeclinedTransfer(ctx, r.db, transfer, transferErr)
+	}
+
+	log.WithFields(log.Fields{
+		"standing_order_id": order.ID,
+		"run_id":            run.ID,
+		"status":            run.Status,
+		"next_run_at":       order.NextRunAt.Format(time.RFC3339),
+	}).Info("Standing order run recorded")
+
+	return run, nil
+}
+
+func standingOrderScanTargets(order *models.StandingOrder) []any {
+	return []any{
+		&order.ID,
+		&order.SourceAccountID,
+		&order.DestinationAccountID,
+		&order.Amount,
+		&order.Frequency,
+		&order.IntervalSeconds,
+		&order.DayOfWeek,
+		&order.DayOfMonth,
+		&order.StartAt,
+		&order.EndAt,
+		&order.MaxRuns,
+		&order.ExecutedRuns,
+		&order.FailurePolicy,
+		&order.MaxRetries,
+		&order.RetryIntervalSeconds,
+		&order.RetryCount,
+		&order.Status,
+		&order.NextRunAt,
+		&order.NextAttemptAt,
+		&order.CreatedAt,
+		&order.UpdatedAt,
+	}
+}
+
+func standingOrderRunScanTargets(run *models.StandingOrderRun) []any {
+	return []any{
+		&run.ID,
+		&run.StandingOrderID,
+		&run.ScheduledFor,
+		&run.Attempt,
+		&run.Status,
+		&run.TransactionID,
+		&run.ErrorCode,
+		&run.Error,
+		&run.CreatedAt,
+	}
+}
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// sweepRuleColumns is the column list read by sweepRuleScanTargets
+const sweepRuleColumns = `id, rule_type, source_account_id, destination_account_id, threshold, min_amount, frequency, interval_seconds, day_of_week, day_of_month, start_at, status, next_run_at, last_run_at, last_run_status, last_transaction_id, last_error_code, last_error, created_at, updated_at`
+
+// SweepRuleRepository stores sweep rules. Runs go through
+// TransferRepository.ProcessSweepRule, which applies them on the same
+// locked path as every other transfer.
+type SweepRuleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSweepRuleRepository(db *DB) *SweepRuleRepository {
+	return &SweepRuleRepository{
+		db: db.pool,
+	}
+}
+
+// CreateSweepRule stores rule with its first run due at the first occurrence
+// of its recurrence rule. Both accounts must exist and hold the same
+// currency, since the threshold is compared against both balances.
+func (r *SweepRuleRepository) CreateSweepRule(ctx context.Context, rule *models.SweepRule) error {
+	err := checkTransferAccountsExist(ctx, r.db, rule.SourceAccountID, rule.DestinationAccountID)
+	if err != nil {
+		return err
+	}
+
+	var sourceCurrency, destCurrency string
+	err = r.db.QueryRow(ctx, `
+		SELECT (SELECT currency FROM accounts WHERE id = $1), (SELECT currency FROM accounts WHERE id = $2)
+	`, rule.SourceAccountID, rule.DestinationAccountID).Scan(&sourceCurrency, &destCurrency)
+	if err != nil {
+		return fmt.Errorf("failed to get account currencies: %w", err)
+	}
+	if sourceCurrency != destCurrency {
+		return codes.NewWithMsg(codes.ErrSweepCurrencyMismatch, "source account holds %s but destination account holds %s", sourceCurrency, destCurrency)
+	}
+
+	rule.Status = models.SweepRuleStatusActive
+	rule.NextRunAt = rule.FirstOccurrence()
+
+	err = r.db.QueryRow(ctx, `
+		INSERT INTO sweep_rules (rule_type, source_account_id, destination_account_id, threshold, min_amount, frequency, interval_seconds,
+			day_of_week, day_of_month, start_at, status, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		RETURNING `+sweepRuleColumns+`
+	`, rule.Type, rule.SourceAccountID, rule.DestinationAccountID, rule.Threshold, rule.MinAmount, rule.Frequency, rule.IntervalSeconds,
+		rule.DayOfWeek, rule.DayOfMonth, rule.StartAt, rule.Status, rule.NextRunAt).Scan(sweepRuleScanTargets(rule)...)
+	if err != nil {
+		return fmt.Errorf("failed to create sweep rule: %w", err)
+	}
+
+	return nil
+}
+
+// GetSweepRuleByID returns the sweep rule, or nil if it does not exist.
+func (r *SweepRuleRepository) GetSweepRuleByID(ctx context.Context, ruleID int) (*models.SweepRule, error) {
+	var rule models.SweepRule
+	err := r.db.QueryRow(ctx, `
+		SELECT `+sweepRuleColumns+` FROM sweep_rules WHERE id = $1
+	`, ruleID).Scan(sweepRuleScanTargets(&rule)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get sweep rule: %w", err)
+	}
+
+	return &rule, nil
+}
+
+// ListSweepRules returns sweep rules matching filter, newest first, using
+// the same keyset pagination as ListTransfers.
+func (r *SweepRuleRepository) ListSweepRules(ctx context.Context, filter models.SweepRuleFilter) ([]models.SweepRule, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SourceAccountID > 0 {
+		addCondition("source_account_id = $%d", filter.SourceAccountID)
+	}
+	if filter.DestinationAccountID > 0 {
+		addCondition("destination_account_id = $%d", filter.DestinationAccountID)
+	}
+	if filter.AccountID > 0 {
+		args = append(args, filter.AccountID)
+		conditions = append(conditions, fmt.Sprintf("(source_account_id = $%d OR destination_account_id = $%d)", len(args), len(args)))
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + sweepRuleColumns + ` FROM sweep_rules`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sweep rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.SweepRule{}
+	for rows.Next() {
+		var rule models.SweepRule
+		if err = rows.Scan(sweepRuleScanTargets(&rule)...); err != nil {
+			return nil, fmt.Errorf("failed to scan sweep rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sweep rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// CancelSweepRule stops an active rule. A run already in progress holds the
+// rule row, so cancelling waits for it to finish.
+func (r *SweepRuleRepository) CancelSweepRule(ctx context.Context, ruleID int) (*models.SweepRule, error) {
+	var rule models.SweepRule
+	err := r.db.QueryRow(ctx, `
+		UPDATE sweep_rules SET status = $1, updated_at = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING `+sweepRuleColumns+`
+	`, models.SweepRuleStatusCancelled, ruleID, models.SweepRuleStatusActive).Scan(sweepRuleScanTargets(&rule)...)
+	if err == nil {
+		return &rule, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to cancel sweep rule: %w", err)
+	}
+
+	existing, err := r.GetSweepRuleByID(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, codes.ErrSweepRuleNotFound
+	}
+	return nil, codes.NewWithMsg(codes.ErrSweepRuleNotActive, "sweep rule is %s", existing.Status)
+}
+
+// ListDueSweepRuleIDs returns up to limit active rules whose next run is
+// due, the longest overdue first.
+func (r *SweepRuleRepository) ListDueSweepRuleIDs(ctx context.Context, limit int) ([]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM sweep_rules
+		WHERE status = $1 AND next_run_at <= NOW()
+		ORDER BY next_run_at
+		LIMIT $2
+	`, models.SweepRuleStatusActive, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due sweep rules: %w", err)
+	}
+
+	ruleIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due sweep rules: %w", err)
+	}
+
+	return ruleIDs, nil
+}
+
+func sweepRuleScanTargets(rule *models.SweepRule) []any {
+	return []any{
+		&rule.ID,
+		&rule.Type,
+		&rule.SourceAccountID,
+		&rule.DestinationAccountID,
+		&rule.Threshold,
+		&rule.MinAmount,
+		&rule.Frequency,
+		&rule.IntervalSeconds,
+		&rule.DayOfWeek,
+		&rule.DayOfMonth,
+		&rule.StartAt,
+		&rule.Status,
+		&rule.NextRunAt,
+		&rule.LastRunAt,
+		&rule.LastRunStatus,
+		&rule.LastTransactionID,
+		&rule.LastErrorCode,
+		&rule.LastError,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	}
+}
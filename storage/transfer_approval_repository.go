@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+)
+
+// transferApprovalColumns is the column list read by transferApprovalScanTargets
+const transferApprovalColumns = `id, source_account_id, destination_account_id, amount, status, requested_by, decided_by, expires_at, transaction_id, last_error_code, last_error, created_at, updated_at, ` + transferReferenceColumns
+
+type TransferApprovalRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTransferApprovalRepository(db *DB) *TransferApprovalRepository {
+	return &TransferApprovalRepository{
+		db: db.pool,
+	}
+}
+
+// RequestApproval stores a transfer waiting for a second principal and opens
+// its audit trail. Both accounts must exist, but funds are only checked when
+// the approved transfer executes. An idempotency key is kept with the
+// approval, as with scheduled transfers, and a replay returns the original.
+func (r *TransferApprovalRepository) RequestApproval(ctx context.Context, approval *models.TransferApproval, idempotencyKey *models.IdempotencyKey) (*models.TransferApproval, error) {
+	err := checkTransferAccountsExist(ctx, r.db, approval.SourceAccountID, approval.DestinationAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var key, requestHash *string
+	if idempotencyKey != nil {
+		key = &idempotencyKey.Key
+		requestHash = &idempotencyKey.RequestHash
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	approval.Status = models.TransferApprovalStatusPending
+	err = tx.QueryRow(ctx, `
+		INSERT INTO transfer_approvals (source_account_id, destination_account_id, amount, status, requested_by, expires_at,
+			idempotency_key, request_hash, memo, client_reference, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW())
+		ON CONFLICT (idempotency_key) DO NOTHING
+		RETURNING `+transferApprovalColumns+`
+	`, approval.SourceAccountID, approval.DestinationAccountID, approval.Amount, approval.Status, approval.RequestedBy,
+		approval.ExpiresAt, key, requestHash, approval.Memo, approval.ClientReference,
+		metadataArg(approval.Metadata)).Scan(transferApprovalScanTargets(approval)...)
+	if err == nil {
+		if err = recordApprovalEvent(ctx, tx, approval.ID, models.TransferApprovalEventRequested, &approval.RequestedBy, nil); err != nil {
+			return nil, err
+		}
+		if err = tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return approval, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to request transfer approval: %w", err)
+	}
+
+	// The idempotency key is already taken by an earlier approval
+	var existing models.TransferApproval
+	var existingHash string
+	err = tx.QueryRow(ctx, `
+		SELECT `+transferApprovalColumns+`, request_hash FROM transfer_approvals WHERE idempotency_key = $1
+	`, idempotencyKey.Key).Scan(append(transferApprovalScanTargets(&existing), &existingHash)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotent transfer approval: %w", err)
+	}
+
+	if existingHash != idempotencyKey.RequestHash {
+		return nil, codes.ErrIdempotencyKeyReused
+	}
+
+	return &existing, nil
+}
+
+// GetApprovalByID returns the approval with its audit trail, oldest event
+// first, or nil if it does not exist.
+func (r *TransferApprovalRepository) GetApprovalByID(ctx context.Context, approvalID int) (*models.TransferApproval, error) {
+	var approval models.TransferApproval
+	err := r.db.QueryRow(ctx, `
+		SELECT `+transferApprovalColumns+` FROM transfer_approvals WHERE id = $1
+	`, approvalID).Scan(transferApprovalScanTargets(&approval)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get transfer approval: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, approval_id, event, principal, comment, created_at
+		FROM transfer_approval_events
+		WHERE approval_id = $1
+		ORDER BY id
+	`, approvalID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transfer approval events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.TransferApprovalEvent
+		if err = rows.Scan(&event.ID, &event.ApprovalID, &event.Event, &event.Principal, &event.Comment, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer approval event: %w", err)
+		}
+		approval.Events = append(approval.Events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get transfer approval events: %w", err)
+	}
+
+	return &approval, nil
+}
+
+// ListApprovals returns approvals matching filter, newest first, using the
+// same keyset pagination as ListTransfers. Audit trails are not loaded.
+func (r *TransferApprovalRepository) ListApprovals(ctx context.Context, filter models.TransferApprovalFilter) ([]models.TransferApproval, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SourceAccountID > 0 {
+		addCondition("source_account_id = $%d", filter.SourceAccountID)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + transferApprovalColumns + ` FROM transfer_approvals`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transfer approvals: %w", err)
+	}
+	defer rows.Close()
+
+	approvals := []models.TransferApproval{}
+	for rows.Next() {
+		var approval models.TransferApproval
+		if err = rows.Scan(transferApprovalScanTargets(&approval)...); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer approval: %w", err)
+		}
+		approvals = append(approvals, approval)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list transfer approvals: %w", err)
+	}
+
+	return approvals, nil
+}
+
+// ApproveTransfer records principal's approval. The caller then executes the
+// transfer and reports the outcome with MarkApprovalExecuted,
+// MarkApprovalFailed or RetryApproval.
+func (r *TransferApprovalRepository) ApproveTransfer(ctx context.Context, approvalID int, principal string, comment *string) (*models.TransferApproval, error) {
+	return r.decideApproval(ctx, approvalID, principal, comment, models.TransferApprovalStatusApproved, models.TransferApprovalEventApproved)
+}
+
+// RejectTransfer records principal's rejection. The transfer never executes.
+func (r *TransferApprovalRepository) RejectTransfer(ctx context.Context, approvalID int, principal string, comment *string) (*models.TransferApproval, error) {
+	return r.decideApproval(ctx, approvalID, principal, comment, models.TransferApprovalStatusRejected, models.TransferApprovalEventRejected)
+}
+
+// decideApproval moves a pending approval to status. The row is locked, so
+// of two concurrent decisions only the first succeeds. The maker can never
+// decide their own request, and approvals past their expiry can no longer be
+// decided even before the sweeper marks them.
+func (r *TransferApprovalRepository) decideApproval(ctx context.Context, approvalID int, principal string, comment *string,
+	status models.TransferApprovalStatus, event models.TransferApprovalEventType) (*models.TransferApproval, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var approval models.TransferApproval
+	var expired bool
+	err = tx.QueryRow(ctx, `
+		SELECT `+transferApprovalColumns+`, expires_at <= NOW() FROM transfer_approvals WHERE id = $1 FOR UPDATE
+	`, approvalID).Scan(append(transferApprovalScanTargets(&approval), &expired)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.ErrApprovalNotFound
+		}
+		return nil, fmt.Errorf("failed to lock transfer approval: %w", err)
+	}
+
+	if approval.Status != models.TransferApprovalStatusPending {
+		return nil, codes.NewWithMsg(codes.ErrApprovalNotPending, "transfer approval is %s", approval.Status)
+	}
+	if expired {
+		return nil, codes.NewWithMsg(codes.ErrApprovalNotPending, "transfer approval expired at %s", approval.ExpiresAt.Format(time.RFC3339))
+	}
+	if approval.RequestedBy == principal {
+		return nil, codes.ErrSelfApproval
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE transfer_approvals SET status = $1, decided_by = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING `+transferApprovalColumns+`
+	`, status, principal, approvalID).Scan(transferApprovalScanTargets(&approval)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decide transfer approval: %w", err)
+	}
+
+	if err = recordApprovalEvent(ctx, tx, approvalID, event, &principal, comment); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &approval, nil
+}
+
+// MarkApprovalExecuted links the transfer an approval executed as.
+func (r *TransferApprovalRepository) MarkApprovalExecuted(ctx context.Context, approvalID, transferID int) (*models.TransferApproval, error) {
+	return r.finishApproval(ctx, approvalID, models.TransferApprovalStatusExecuted, models.TransferApprovalEventExecuted, &transferID, nil)
+}
+
+// MarkApprovalFailed records why an approved transfer could not execute.
+func (r *TransferApprovalRepository) MarkApprovalFailed(ctx context.Context, approvalID int, cause error) (*models.TransferApproval, error) {
+	return r.finishApproval(ctx, approvalID, models.TransferApprovalStatusFailed, models.TransferApprovalEventFailed, nil, cause)
+}
+
+func (r *TransferApprovalRepository) finishApproval(ctx context.Context, approvalID int, status models.TransferApprovalStatus,
+	event models.TransferApprovalEventType, transferID *int, cause error) (*models.TransferApproval, error) {
+	var errorCode *int
+	var errorMsg *string
+	if cause != nil {
+		code, msg := codes.GetCode(cause), lastErrorMessage(cause)
+		errorCode, errorMsg = &code, &msg
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var approval models.TransferApproval
+	err = tx.QueryRow(ctx, `
+		UPDATE transfer_approvals
+		SET status = $1, transaction_id = $2, last_error_code = $3, last_error = $4, updated_at = NOW()
+		WHERE id = $5 AND status = $6
+		RETURNING `+transferApprovalColumns+`
+	`, status, transferID, errorCode, errorMsg, approvalID, models.TransferApprovalStatusApproved).Scan(transferApprovalScanTargets(&approval)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.NewWithMsg(codes.ErrApprovalNotPending, "transfer approval is not awaiting execution")
+		}
+		return nil, fmt.Errorf("failed to record transfer approval outcome: %w", err)
+	}
+
+	if err = recordApprovalEvent(ctx, tx, approvalID, event, nil, errorMsg); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &approval, nil
+}
+
+// RetryApproval records why an attempt at an approved transfer failed
+// without a decline, leaving it APPROVED. Its lease restarts, so the approval
+// executor tries it again once ClaimStaleApprovals sees it as stale.
+func (r *TransferApprovalRepository) RetryApproval(ctx context.Context, approvalID int, cause error) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE transfer_approvals SET last_error_code = $1, last_error = $2, updated_at = NOW()
+		WHERE id = $3 AND status = $4
+	`, codes.GetCode(cause), lastErrorMessage(cause), approvalID, models.TransferApprovalStatusApproved)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer approval attempt: %w", err)
+	}
+	return nil
+}
+
+// ClaimStaleApprovals returns up to limit approvals left APPROVED since
+// before staleBefore, whose execution was interrupted or failed on a
+// transient error, and restarts their lease. SKIP LOCKED lets several
+// executors claim concurrently without taking the same rows.
+func (r *TransferApprovalRepository) ClaimStaleApprovals(ctx context.Context, limit int, staleBefore time.Time) ([]models.TransferApproval, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE transfer_approvals SET updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM transfer_approvals
+			WHERE status = $1 AND updated_at <= $2
+			ORDER BY updated_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+transferApprovalColumns+`
+	`, models.TransferApprovalStatusApproved, staleBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim transfer approvals: %w", err)
+	}
+	defer rows.Close()
+
+	var approvals []models.TransferApproval
+	for rows.Next() {
+		var approval models.TransferApproval
+		if err = rows.Scan(transferApprovalScanTargets(&approval)...); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer approval: %w", err)
+		}
+		approvals = append(approvals, approval)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim transfer approvals: %w", err)
+	}
+
+	return approvals, nil
+}
+
+// ExpireApprovals marks pending approvals past their expiry as EXPIRED and
+// records the expiry in their audit trails. It returns how many expired.
+func (r *TransferApprovalRepository) ExpireApprovals(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		WITH expired AS (
+			UPDATE transfer_approvals SET status = $1, updated_at = NOW()
+			WHERE status = $2 AND expires_at <= NOW()
+			RETURNING id
+		)
+		INSERT INTO transfer_approval_events (approval_id, event, created_at)
+		SELECT id, $3, NOW() FROM expired
+	`, models.TransferApprovalStatusExpired, models.TransferApprovalStatusPending, models.TransferApprovalEventExpired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire transfer approvals: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// recordApprovalEvent appends an entry to the audit trail of an approval
+func recordApprovalEvent(ctx context.Context, tx pgx.Tx, approvalID int, event models.TransferApprovalEventType, principal, comment *string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO transfer_approval_events (approval_id, event, principal, comment, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, approvalID, event, principal, comment)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer approval event: %w", err)
+	}
+	return nil
+}
+
+func transferApprovalScanTargets(approval *models.TransferApproval) []any {
+	return append([]any{
+		&approval.ID,
+		&approval.SourceAccountID,
+		&approval.DestinationAccountID,
+		&approval.Amount,
+		&approval.Status,
+		&approval.RequestedBy,
+		&approval.DecidedBy,
+		&approval.ExpiresAt,
+		&approval.TransactionID,
+		&approval.LastErrorCode,
+		&approval.LastError,
+		&approval.CreatedAt,
+		&approval.UpdatedAt,
+	}, transferReferenceScanTargets(&approval.TransferReference)...)
+}
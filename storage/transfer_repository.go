@@ -2,120 +2,1421 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/Nauman-S/Internal-Transfers-System/codes"
 	"github.com/Nauman-S/Internal-Transfers-System/models"
 	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
 )
 
+// transferColumns is the column list read by scanTransfer
+const transferColumns = `id, source_account_id, destination_account_id, amount, source_currency, destination_currency, destination_amount, fx_rate_id, fx_rate, fee, fee_schedule_id, fee_account_id, reversal_of, batch_id, split_payment_id, sweep_rule_id, status, failure_code, created_at, updated_at, ` + transferReferenceColumns
+
+// transferReferenceColumns is the column list read by transferReferenceScanTargets
+const transferReferenceColumns = `memo, client_reference, metadata`
+
+// amountScale is the number of decimal places amounts are stored with
+const amountScale = 8
+
+// declinedTransferCodes are the business failures recorded as FAILED
+// transfers. Other errors, such as unknown accounts, leave no record.
+var declinedTransferCodes = map[int]bool{
+	codes.ErrInsufficientFunds.Code:        true,
+	codes.ErrTransactionLimitExceeded.Code: true,
+	codes.ErrDailyLimitExceeded.Code:       true,
+	codes.ErrWeeklyLimitExceeded.Code:      true,
+	codes.ErrMonthlyLimitExceeded.Code:     true,
+	codes.ErrVelocityLimitExceeded.Code:    true,
+	codes.ErrRiskRuleDenied.Code:           true,
+}
+
+// errQuoteRolledBack ends the database transaction of a quote so nothing it
+// wrote is committed
+var errQuoteRolledBack = errors.New("quote rolled back")
+
+// TransferExecutionMode is how ProcessTransfer applies a single transfer
+type TransferExecutionMode string
+
+const (
+	// TransferExecutionLocking locks, checks and updates the accounts with
+	// one statement each inside a database transaction
+	TransferExecutionLocking TransferExecutionMode = "locking"
+	// TransferExecutionSingleStatement applies plain transfers with one call
+	// to the transfer_funds function instead of a statement per lock, update
+	// and insert. Transfers it cannot apply fall back to the locking path.
+	TransferExecutionSingleStatement TransferExecutionMode = "single_statement"
+)
+
+// ParseTransferExecutionMode accepts "locking" or "single_statement". An
+// empty mode is TransferExecutionLocking.
+func ParseTransferExecutionMode(mode string) (TransferExecutionMode, error) {
+	switch TransferExecutionMode(strings.ToLower(strings.TrimSpace(mode))) {
+	case "", TransferExecutionLocking:
+		return TransferExecutionLocking, nil
+	case TransferExecutionSingleStatement:
+		return TransferExecutionSingleStatement, nil
+	}
+	return "", fmt.Errorf("unknown transfer execution mode %q", mode)
+}
+
 type TransferRepository struct {
-	db *pgxpool.Pool
+	db            *pgxpool.Pool
+	txPolicy      TxPolicy
+	executionMode TransferExecutionMode
+	riskRules     *atomic.Pointer[[]models.RiskRule]
 }
 
 func NewTransferRepository(db *DB) *TransferRepository {
 	return &TransferRepository{
-		db: db.pool,
+		db:            db.pool,
+		txPolicy:      db.txPolicy,
+		executionMode: db.transferExecution,
+		riskRules:     &db.riskRules,
 	}
 }
 
 
-func (r *TransferRepository) ProcessTransfer(ctx context.Context, sourceAccountID, destAccountID int, amount decimal.Decimal) (*models.Transfer, decimal.Decimal, decimal.Decimal, error) {
-	tx, err := r.db.Begin(ctx)
+// ProcessTransfer moves transfer.Amount between the two accounts of transfer
+// atomically and fills in the rest of transfer. When an idempotency key is
+// given it is claimed in the same database transaction, and a replay of an
+// already applied request returns the original result. Conflicts with
+// concurrent transactions are retried as the repository's TxPolicy allows.
+// The loaded risk rules are checked once the transfer is applied, before it
+// commits. In TransferExecutionSingleStatement mode a transfer without an
+// idempotency key is first tried with processTransferSingleStatement, unless
+// there are risk rules or a precondition to check.
+func (r *TransferRepository) ProcessTransfer(ctx context.Context, transfer *models.Transfer, idempotencyKey *models.IdempotencyKey) (*models.Transfer, decimal.Decimal, decimal.Decimal, error) {
+	var riskRules []models.RiskRule
+	if transfer.ReversalOf == nil {
+		riskRules = loadedRiskRules(r.riskRules)
+	}
+
+	if r.executionMode == TransferExecutionSingleStatement && idempotencyKey == nil && transfer.ReversalOf == nil && len(riskRules) == 0 &&
+		transfer.Precondition == nil {
+		applied, sourceBalance, destBalance, err := r.processTransferSingleStatement(ctx, transfer)
+		if err != nil {
+			return nil, decimal.Zero, decimal.Zero, err
+		}
+		if applied {
+			return transfer, sourceBalance, destBalance, nil
+		}
+	}
+
+	request := *transfer
+	var result *models.Transfer
+	var newSourceBalance, newDestBalance decimal.Decimal
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		*transfer = request
+
+		if idempotencyKey != nil {
+			original, sourceBalance, destBalance, err := claimIdempotencyKey(ctx, tx, idempotencyKey)
+			if err != nil {
+				return err
+			}
+			if original != nil {
+				result, newSourceBalance, newDestBalance = original, sourceBalance, destBalance
+				return nil
+			}
+		}
+
+		sourceBalance, destBalance, err := processTransferTx(ctx, tx, transfer, riskRules)
+		if err != nil {
+			return err
+		}
+
+		if idempotencyKey != nil {
+			if err = recordIdempotencyKey(ctx, tx, idempotencyKey, transfer.ID, sourceBalance, destBalance); err != nil {
+				return err
+			}
+		}
+
+		result, newSourceBalance, newDestBalance = transfer, sourceBalance, destBalance
+		return nil
+	})
 	if err != nil {
-		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to begin transaction: %w", err)
+		recordDeclinedTransfer(ctx, r.db, transfer, err)
+		return nil, decimal.Zero, decimal.Zero, err
 	}
-	defer tx.Rollback(ctx)
 
+	return result, newSourceBalance, newDestBalance, nil
+}
+
+// QuoteTransfer runs transfer through everything ProcessTransfer would, the
+// account checks, conversion, limits, fees and risk rules, then rolls it
+// back. It fills in transfer and returns the balances the two accounts would
+// have straight after it. The transfer row is written and discarded too, so
+// a reused client reference is caught, at the cost of a gap in transfer IDs.
+func (r *TransferRepository) QuoteTransfer(ctx context.Context, transfer *models.Transfer) (*models.Transfer, decimal.Decimal, decimal.Decimal, error) {
+	riskRules := loadedRiskRules(r.riskRules)
+
+	request := *transfer
 	var sourceBalance, destBalance decimal.Decimal
-	
-	//This is to prevent deadlocks
-	if sourceAccountID < destAccountID {
 
-		err = tx.QueryRow(ctx, `
-			SELECT balance FROM accounts WHERE id = $1 FOR UPDATE
-		`, sourceAccountID).Scan(&sourceBalance)
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		*transfer = request
+
+		var err error
+		sourceBalance, destBalance, err = processTransferTx(ctx, tx, transfer, riskRules)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				return nil, decimal.Zero, decimal.Zero, codes.ErrSourceAccountNotFound
+			return err
+		}
+
+		return errQuoteRolledBack
+	})
+	if !errors.Is(err, errQuoteRolledBack) {
+		return nil, decimal.Zero, decimal.Zero, err
+	}
+
+	// The quoted transfer was never stored
+	transfer.ID = 0
+	transfer.Status = ""
+	return transfer, sourceBalance, destBalance, nil
+}
+
+// processTransferSingleStatement applies transfer with a single call to the
+// transfer_funds function, which locks, checks and updates both accounts and
+// inserts the transfer row in one statement. The call runs in a transaction
+// under the repository's TxPolicy, like the locking path, so it gets the
+// same isolation level, lock timeout and retries. It reports applied as false,
+// without changing anything, when the transfer needs what only the locking
+// path handles: currency conversion, account limits or fees. A declined
+// transfer has already been recorded as FAILED by the function.
+func (r *TransferRepository) processTransferSingleStatement(ctx context.Context, transfer *models.Transfer) (bool, decimal.Decimal, decimal.Decimal, error) {
+	var outcome string
+	var transferID *int
+	var currency *string
+	var sourceBalance, destBalance decimal.NullDecimal
+	var createdAt *time.Time
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			SELECT outcome, transaction_id, currency, source_balance, destination_balance, created_at
+			FROM transfer_funds($1, $2, $3, $4, $5, $6, $7)
+		`, transfer.SourceAccountID, transfer.DestinationAccountID, transfer.Amount, transfer.Memo, transfer.ClientReference,
+			metadataArg(transfer.Metadata), codes.ErrInsufficientFunds.Code).Scan(
+			&outcome, &transferID, &currency, &sourceBalance, &destBalance, &createdAt)
+	})
+	if err != nil {
+		if transfer.ClientReference != nil && strings.Contains(err.Error(), "23505") {
+			return false, decimal.Zero, decimal.Zero, codes.NewWithMsg(codes.ErrDuplicateClientReference,
+				"client reference %q is already used by another transfer from account %d", *transfer.ClientReference, transfer.SourceAccountID)
+		}
+		var codeErr codes.CodeError
+		if errors.As(err, &codeErr) {
+			return false, decimal.Zero, decimal.Zero, err
+		}
+		return false, decimal.Zero, decimal.Zero, fmt.Errorf("failed to apply transfer: %w", err)
+	}
+
+	switch outcome {
+	case "SOURCE_NOT_FOUND":
+		return false, decimal.Zero, decimal.Zero, codes.ErrSourceAccountNotFound
+	case "DESTINATION_NOT_FOUND":
+		return false, decimal.Zero, decimal.Zero, codes.ErrDestinationAccountNotFound
+	case "INSUFFICIENT_FUNDS":
+		return false, decimal.Zero, decimal.Zero, codes.ErrInsufficientFunds
+	case "INELIGIBLE":
+		return false, decimal.Zero, decimal.Zero, nil
+	case "COMPLETED":
+	default:
+		return false, decimal.Zero, decimal.Zero, fmt.Errorf("unexpected transfer_funds outcome %q", outcome)
+	}
+
+	transfer.ID = *transferID
+	transfer.SourceCurrency = *currency
+	transfer.DestinationCurrency = *currency
+	transfer.DestinationAmount = transfer.Amount
+	transfer.Fee = decimal.Zero
+	transfer.Status = models.TransferStatusCompleted
+	transfer.CreatedAt = *createdAt
+	transfer.UpdatedAt = *createdAt
+
+	return true, sourceBalance.Decimal, destBalance.Decimal, nil
+}
+
+// ProcessBatch applies every transfer in one database transaction: either all
+// legs are committed or none are. All accounts touched by the batch are locked
+// up front in ascending ID order, the same ordering ProcessTransfer relies on,
+// so batches cannot deadlock with each other or with single transfers. Legs
+// are applied in order and may spend funds credited by earlier legs. It
+// returns the batch ID and the final balance of every account involved.
+func (r *TransferRepository) ProcessBatch(ctx context.Context, transfers []*models.Transfer) (int, map[int]decimal.Decimal, error) {
+	requests := make([]models.Transfer, len(transfers))
+	for i, transfer := range transfers {
+		requests[i] = *transfer
+	}
+	riskRules := loadedRiskRules(r.riskRules)
+
+	var batchID int
+	var accounts map[int]*lockedAccount
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		for i, transfer := range transfers {
+			*transfer = requests[i]
+		}
+
+		var err error
+		accounts, err = lockTransferAccounts(ctx, tx, transfers...)
+		if err != nil {
+			return err
+		}
+
+		// Every leg's precondition is against the accounts as the batch
+		// found them, before any leg moved money
+		for i, transfer := range transfers {
+			if err = checkTransferPrecondition(accounts, transfer); err != nil {
+				return codes.NewWithMsg(codes.ErrPreconditionFailed, "leg %d: %s", i, codes.GetMsg(err))
 			}
-			return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to lock source account: %w", err)
 		}
 
 		err = tx.QueryRow(ctx, `
-			SELECT balance FROM accounts WHERE id = $1 FOR UPDATE
-		`, destAccountID).Scan(&destBalance)
+			INSERT INTO transfer_batches (leg_count, created_at) VALUES ($1, NOW()) RETURNING id
+		`, len(transfers)).Scan(&batchID)
+		if err != nil {
+			return fmt.Errorf("failed to create batch record: %w", err)
+		}
+
+		for i, transfer := range transfers {
+			transfer.BatchID = &batchID
+			if _, _, err = applyTransferTx(ctx, tx, accounts, transfer, riskRules); err != nil {
+				var codeErr codes.CodeError
+				if errors.As(err, &codeErr) {
+					return codes.NewWithMsg(codeErr, "leg %d: %s", i, codeErr.Msg)
+				}
+				return fmt.Errorf("leg %d: %w", i, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	balances := make(map[int]decimal.Decimal, len(accounts))
+	for id, account := range accounts {
+		balances[id] = account.Balance
+	}
+
+	return batchID, balances, nil
+}
+
+// ProcessSplitPayment debits split.Amount, plus the fee the source's schedule
+// charges on it, from the source once and credits every leg's destination
+// its share, all in one database transaction. Each leg is stored as a
+// transfer from the source linked to the split payment record. The legs'
+// amounts must add up to split.Amount. It returns the final balance of every
+// account involved.
+func (r *TransferRepository) ProcessSplitPayment(ctx context.Context, split *models.SplitPayment) (map[int]decimal.Decimal, error) {
+	request := *split
+	legRequests := make([]models.Transfer, len(split.Legs))
+	for i, leg := range split.Legs {
+		legRequests[i] = *leg
+	}
+	riskRules := loadedRiskRules(r.riskRules)
+
+	var accounts map[int]*lockedAccount
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		*split = request
+		for i, leg := range split.Legs {
+			*leg = legRequests[i]
+		}
+
+		var err error
+		accounts, err = lockTransferAccounts(ctx, tx, split.Legs...)
+		if err != nil {
+			return err
+		}
+
+		return applySplitPaymentTx(ctx, tx, accounts, split, riskRules)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[int]decimal.Decimal, len(accounts))
+	for id, account := range accounts {
+		balances[id] = account.Balance
+	}
+
+	return balances, nil
+}
+
+// applySplitPaymentTx is applyTransferTx for a split payment. Limits, holds,
+// the fee and the funds check apply to the whole amount, and the source row
+// is updated once however many legs there are. Each leg is checked against
+// riskRules as if it moved the whole amount, so splitting a payment cannot
+// take it under an amount rule. The whole split is posted as one journal.
+func applySplitPaymentTx(ctx context.Context, tx pgx.Tx, accounts map[int]*lockedAccount, split *models.SplitPayment,
+	riskRules []models.RiskRule) error {
+	source, ok := accounts[split.SourceAccountID]
+	if !ok {
+		return codes.ErrSourceAccountNotFound
+	}
+
+	dests := make([]*lockedAccount, len(split.Legs))
+	for i, leg := range split.Legs {
+		dest, ok := accounts[leg.DestinationAccountID]
+		if !ok {
+			return codes.NewWithMsg(codes.ErrDestinationAccountNotFound, "leg %d: %s", i, codes.ErrDestinationAccountNotFound.Msg)
+		}
+		if err := convertTransferTx(ctx, tx, source, dest, leg); err != nil {
+			var codeErr codes.CodeError
+			if errors.As(err, &codeErr) {
+				return codes.NewWithMsg(codeErr, "leg %d: %s", i, codeErr.Msg)
+			}
+			return fmt.Errorf("leg %d: %w", i, err)
+		}
+		dests[i] = dest
+	}
+	split.Currency = source.Currency
+
+	if err := checkAccountLimitsTx(ctx, tx, source, split.Amount); err != nil {
+		return err
+	}
+
+	held, err := heldAmountTx(ctx, tx, source.ID)
+	if err != nil {
+		return err
+	}
+
+	// The fee is priced as if the whole amount went to one destination
+	pricing := &models.Transfer{Amount: split.Amount}
+	feeAccount, err := chargeFee(accounts, source, pricing)
+	if err != nil {
+		return err
+	}
+	split.Fee, split.FeeScheduleID, split.FeeAccountID = pricing.Fee, pricing.FeeScheduleID, pricing.FeeAccountID
+
+	debit := split.Amount.Add(split.Fee)
+	if source.Balance.Sub(held).Add(source.OverdraftLimit).LessThan(debit) {
+		return codes.ErrInsufficientFunds
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE accounts SET balance = balance - $1, updated_at = NOW() WHERE id = $2
+	`, debit, source.ID)
+	if err != nil {
+		return fmt.Errorf("failed to debit source account: %w", err)
+	}
+	source.Balance = source.Balance.Sub(debit)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO split_payments (source_account_id, amount, currency, allocation, leg_count, fee, fee_schedule_id, fee_account_id,
+			memo, client_reference, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id, created_at
+	`, split.SourceAccountID, split.Amount, split.Currency, split.Allocation, len(split.Legs), split.Fee, split.FeeScheduleID,
+		split.FeeAccountID, split.Memo, split.ClientReference, metadataArg(split.Metadata)).Scan(&split.ID, &split.CreatedAt)
+	if err != nil {
+		if split.ClientReference != nil && strings.Contains(err.Error(), "23505") {
+			return codes.NewWithMsg(codes.ErrDuplicateClientReference,
+				"client reference %q is already used by another split payment from account %d", *split.ClientReference, source.ID)
+		}
+		return fmt.Errorf("failed to create split payment record: %w", err)
+	}
+
+	for i, leg := range split.Legs {
+		if err = creditAccountTx(ctx, tx, dests[i], leg.DestinationAmount); err != nil {
+			return fmt.Errorf("leg %d: failed to credit destination account: %w", i, err)
+		}
+		dests[i].Balance = dests[i].Balance.Add(leg.DestinationAmount)
+
+		leg.Fee = decimal.Zero
+		leg.SplitPaymentID = &split.ID
+		if err = insertTransferTx(ctx, tx, leg); err != nil {
+			return fmt.Errorf("leg %d: %w", i, err)
+		}
+
+		if len(riskRules) > 0 {
+			if err = checkRiskRulesTx(ctx, tx, riskRules, leg, split.Amount); err != nil {
+				var codeErr codes.CodeError
+				if errors.As(err, &codeErr) {
+					return codes.NewWithMsg(codeErr, "leg %d: %s", i, codeErr.Msg)
+				}
+				return fmt.Errorf("leg %d: %w", i, err)
+			}
+		}
+	}
+
+	if feeAccount != nil {
+		if err = creditAccountTx(ctx, tx, feeAccount, split.Fee); err != nil {
+			return fmt.Errorf("failed to credit fee revenue account: %w", err)
+		}
+		feeAccount.Balance = feeAccount.Balance.Add(split.Fee)
+	}
+
+	return postJournalTx(ctx, tx, &models.Journal{
+		Kind:           models.JournalKindSplitPayment,
+		SplitPaymentID: &split.ID,
+		Postings:       models.SplitPaymentPostings(split),
+	})
+}
+
+// GetSplitPaymentByID returns the split payment with its legs in the order
+// they were given, or nil if it does not exist.
+func (r *TransferRepository) GetSplitPaymentByID(ctx context.Context, splitID int) (*models.SplitPayment, error) {
+	var split models.SplitPayment
+	err := r.db.QueryRow(ctx, `
+		SELECT id, source_account_id, amount, currency, allocation, fee, fee_schedule_id, fee_account_id, created_at, `+transferReferenceColumns+`
+		FROM split_payments WHERE id = $1
+	`, splitID).Scan(append([]any{
+		&split.ID,
+		&split.SourceAccountID,
+		&split.Amount,
+		&split.Currency,
+		&split.Allocation,
+		&split.Fee,
+		&split.FeeScheduleID,
+		&split.FeeAccountID,
+		&split.CreatedAt,
+	}, transferReferenceScanTargets(&split.TransferReference)...)...)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get split payment: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+transferColumns+` FROM transactions WHERE split_payment_id = $1 ORDER BY id
+	`, splitID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get split payment legs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		leg, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan split payment leg: %w", err)
+		}
+		split.Legs = append(split.Legs, leg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get split payment legs: %w", err)
+	}
+
+	return &split, nil
+}
+
+// ProcessSweepRule runs the due occurrence of a sweep rule. The rule row is
+// locked for the whole run and both accounts are locked as for
+// ProcessTransfer, so the amount is worked out from balances nothing else
+// can move. A SWEEP_EXCESS rule leaves the source's held funds in place,
+// and a hot destination is topped up from its balance as of the read. Any
+// fee the source's schedule charges comes on top of the amount. The
+// transfer, tagged with the rule, commits together with the rule's outcome
+// and advanced schedule, so an occurrence never moves money twice. It
+// returns a nil rule when the rule is no longer due or another scheduler
+// holds it, and a nil transfer when the run moved nothing. No one approves a
+// sweep, so a run that would move more than a positive maxAmount, the
+// approval threshold, moves nothing and fails with
+// ErrSweepAboveApprovalThreshold. A declined transfer is recorded on the
+// rule and as a FAILED transfer, not returned.
+func (r *TransferRepository) ProcessSweepRule(ctx context.Context, ruleID int, maxAmount decimal.Decimal) (*models.SweepRule, *models.Transfer, error) {
+	var rule *models.SweepRule
+	var transfer *models.Transfer
+	var transferErr error
+	riskRules := loadedRiskRules(r.riskRules)
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		rule, transfer, transferErr = nil, nil, nil
+
+		var locked models.SweepRule
+		err := tx.QueryRow(ctx, `
+			SELECT `+sweepRuleColumns+` FROM sweep_rules
+			WHERE id = $1 AND status = $2 AND next_run_at <= NOW()
+			FOR UPDATE SKIP LOCKED
+		`, ruleID, models.SweepRuleStatusActive).Scan(sweepRuleScanTargets(&locked)...)
 		if err != nil {
 			if err == pgx.ErrNoRows {
-				return nil, decimal.Zero, decimal.Zero, codes.ErrDestinationAccountNotFound
+				return nil
+			}
+			return fmt.Errorf("failed to lock sweep rule: %w", err)
+		}
+		rule = &locked
+
+		sweep := &models.Transfer{
+			SourceAccountID:      rule.SourceAccountID,
+			DestinationAccountID: rule.DestinationAccountID,
+			SweepRuleID:          &rule.ID,
+		}
+
+		accounts, err := lockTransferAccounts(ctx, tx, sweep)
+		if err != nil {
+			return err
+		}
+		source, ok := accounts[sweep.SourceAccountID]
+		if !ok {
+			return codes.ErrSourceAccountNotFound
+		}
+		dest, ok := accounts[sweep.DestinationAccountID]
+		if !ok {
+			return codes.ErrDestinationAccountNotFound
+		}
+
+		source.Held, err = heldAmountTx(ctx, tx, source.ID)
+		if err != nil {
+			return err
+		}
+		source.heldLoaded = true
+
+		status := models.SweepRunStatusNothingToSweep
+		rule.LastTransactionID, rule.LastErrorCode, rule.LastError = nil, nil, nil
+
+		sweep.Amount = rule.SweepAmount(source.Balance.Sub(source.Held), dest.Balance)
+		if sweep.Amount.IsPositive() {
+			transfer = sweep
+
+			if maxAmount.IsPositive() && transfer.Amount.GreaterThan(maxAmount) {
+				transferErr = codes.NewWithMsg(codes.ErrSweepAboveApprovalThreshold,
+					"sweep of %s is above the approval threshold %s", transfer.Amount.String(), maxAmount.String())
+			} else {
+				// The transfer runs in a savepoint so a declined run can still
+				// be recorded on the rule in this transaction
+				savepoint, err := tx.Begin(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to begin savepoint: %w", err)
+				}
+				_, _, transferErr = applyTransferTx(ctx, savepoint, accounts, transfer, riskRules)
+				if transferErr == nil {
+					err = savepoint.Commit(ctx)
+				} else {
+					err = savepoint.Rollback(ctx)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to end savepoint: %w", err)
+				}
 			}
-			return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to lock source account: %w", err)
+
+			var codeErr codes.CodeError
+			if transferErr != nil && !errors.As(transferErr, &codeErr) {
+				return transferErr
+			}
+
+			if transferErr == nil {
+				status = models.SweepRunStatusSwept
+				rule.LastTransactionID = &transfer.ID
+			} else {
+				status = models.SweepRunStatusFailed
+				errorMsg := lastErrorMessage(codeErr)
+				rule.LastErrorCode = &codeErr.Code
+				rule.LastError = &errorMsg
+			}
+		}
+
+		// Occurrences missed while the scheduler was down are not back-filled
+		now := time.Now()
+		after := rule.NextRunAt
+		if now.After(after) {
+			after = now
 		}
-	} else {
+		rule.NextRunAt = rule.NextOccurrence(after)
+		rule.LastRunAt = &now
+		rule.LastRunStatus = &status
+
 		err = tx.QueryRow(ctx, `
-			SELECT balance FROM accounts WHERE id = $1 FOR UPDATE
-		`, destAccountID).Scan(&destBalance)
+			UPDATE sweep_rules
+			SET next_run_at = $1, last_run_at = $2, last_run_status = $3, last_transaction_id = $4, last_error_code = $5, last_error = $6,
+				updated_at = NOW()
+			WHERE id = $7
+			RETURNING updated_at
+		`, rule.NextRunAt, rule.LastRunAt, rule.LastRunStatus, rule.LastTransactionID, rule.LastErrorCode, rule.LastError,
+			rule.ID).Scan(&rule.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to advance sweep rule: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if transferErr != nil {
+		recordDeclinedTransfer(ctx, r.db, transfer, transferErr)
+		return rule, nil, nil
+	}
+
+	return rule, transfer, nil
+}
+
+// ReverseTransfer moves amount back from the destination to the source of the
+// original transfer, linking the compensating transfer to it. amount is in
+// the original source currency, and a nil amount reverses whatever has not
+// been reversed yet. The original row is locked so
+// concurrent reversals cannot together exceed the original amount.
+func (r *TransferRepository) ReverseTransfer(ctx context.Context, transferID int, amount *decimal.Decimal, idempotencyKey *models.IdempotencyKey) (*models.Transfer, decimal.Decimal, decimal.Decimal, error) {
+	var reversal, result *models.Transfer
+	var newSourceBalance, newDestBalance decimal.Decimal
+
+	err := runTx(ctx, r.db, r.txPolicy, func(tx pgx.Tx) error {
+		reversal = nil
+
+		if idempotencyKey != nil {
+			original, sourceBalance, destBalance, err := claimIdempotencyKey(ctx, tx, idempotencyKey)
+			if err != nil {
+				return err
+			}
+			if original != nil {
+				result, newSourceBalance, newDestBalance = original, sourceBalance, destBalance
+				return nil
+			}
+		}
+
+		original, err := scanTransfer(tx.QueryRow(ctx, `
+			SELECT `+transferColumns+` FROM transactions WHERE id = $1 FOR UPDATE
+		`, transferID))
 		if err != nil {
 			if err == pgx.ErrNoRows {
-				return nil, decimal.Zero, decimal.Zero, codes.ErrDestinationAccountNotFound
+				return codes.ErrTransferNotFound
 			}
-			return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to lock source account: %w", err)
+			return fmt.Errorf("failed to lock transfer: %w", err)
 		}
 
+		if original.ReversalOf != nil {
+			return codes.ErrReverseOfReversal
+		}
+
+		if original.Status != models.TransferStatusCompleted && original.Status != models.TransferStatusReversed {
+			return codes.NewWithMsg(codes.ErrInvalidTransferStatus, "cannot reverse a %s transaction", original.Status)
+		}
+
+		// A reversal's destination_amount is in the original source currency and
+		// its amount in the original destination currency
+		var reversed, reversedDestination decimal.Decimal
 		err = tx.QueryRow(ctx, `
-			SELECT balance FROM accounts WHERE id = $1 FOR UPDATE
-		`, sourceAccountID).Scan(&sourceBalance)
+			SELECT COALESCE(SUM(destination_amount), 0), COALESCE(SUM(amount), 0)
+			FROM transactions WHERE reversal_of = $1 AND status = 'COMPLETED'
+		`, transferID).Scan(&reversed, &reversedDestination)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				return nil, decimal.Zero, decimal.Zero, codes.ErrSourceAccountNotFound
+			return fmt.Errorf("failed to sum reversals: %w", err)
+		}
+
+		remaining := original.Amount.Sub(reversed)
+		reversedAmount := remaining
+		if amount != nil {
+			reversedAmount = *amount
+		}
+		if !remaining.IsPositive() || reversedAmount.GreaterThan(remaining) {
+			return codes.NewWithMsg(codes.ErrReversalExceedsAmount, "reversal amount exceeds the unreversed amount %s", remaining.String())
+		}
+
+		reversal = &models.Transfer{
+			SourceAccountID:      original.DestinationAccountID,
+			DestinationAccountID: original.SourceAccountID,
+			Amount:               reversedAmount,
+			ReversalOf:           &original.ID,
+		}
+
+		// A cross-currency transfer is reversed at its original rate, so the
+		// source gets back exactly the amount it was debited. The final reversal
+		// takes whatever is left of the credit, so rounding cannot leave dust.
+		if original.FXRate != nil {
+			inverseRate := decimal.NewFromInt(1).DivRound(*original.FXRate, fxRateScale)
+			reversal.Amount = reversedAmount.Mul(*original.FXRate).Round(amountScale)
+			if reversedAmount.Equal(remaining) {
+				reversal.Amount = original.DestinationAmount.Sub(reversedDestination)
+			}
+			reversal.DestinationAmount = reversedAmount
+			reversal.FXRateID = original.FXRateID
+			reversal.FXRate = &inverseRate
+		}
+
+		sourceBalance, destBalance, err := processTransferTx(ctx, tx, reversal, nil)
+		if err != nil {
+			return err
+		}
+
+		if reversedAmount.Equal(remaining) {
+			err = transitionTransferStatus(ctx, tx, original.ID, original.Status, models.TransferStatusReversed)
+			if err != nil {
+				return err
+			}
+		}
+
+		if idempotencyKey != nil {
+			if err = recordIdempotencyKey(ctx, tx, idempotencyKey, reversal.ID, sourceBalance, destBalance); err != nil {
+				return err
 			}
-			return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to lock source account: %w", err)
 		}
+
+		result, newSourceBalance, newDestBalance = reversal, sourceBalance, destBalance
+		return nil
+	})
+	if err != nil {
+		if reversal != nil {
+			recordDeclinedTransfer(ctx, r.db, reversal, err)
+		}
+		return nil, decimal.Zero, decimal.Zero, err
 	}
 
-	if sourceBalance.LessThan(amount) {
-		return nil, decimal.Zero, decimal.Zero, codes.ErrInsufficientFunds
+	return result, newSourceBalance, newDestBalance, nil
+}
+
+// PurgeExpiredIdempotencyKeys deletes keys whose retention window has passed
+// and returns how many were removed.
+func (r *TransferRepository) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
 	}
+	return tag.RowsAffected(), nil
+}
 
-	_, err = tx.Exec(ctx, `
-		UPDATE accounts SET balance = balance - $1, updated_at = NOW() WHERE id = $2
-	`, amount, sourceAccountID)
+// GetTransferByID returns the stored transfer, or nil if it does not exist.
+func (r *TransferRepository) GetTransferByID(ctx context.Context, transferID int) (*models.Transfer, error) {
+	transfer, err := scanTransfer(r.db.QueryRow(ctx, `
+		SELECT `+transferColumns+` FROM transactions WHERE id = $1
+	`, transferID))
 	if err != nil {
-		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to debit source account: %w", err)
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get transfer: %w", err)
 	}
 
-	_, err = tx.Exec(ctx, `
-		UPDATE accounts SET balance = balance + $1, updated_at = NOW() WHERE id = $2
-	`, amount, destAccountID)
+	return transfer, nil
+}
+
+// GetTransferJournal returns the journal that posted the transfer with its
+// postings. A split payment leg was posted with the rest of its split, so
+// its journal is the split's. It returns ErrTransferNotFound if the transfer
+// does not exist and ErrJournalNotFound if it moved no money, or moved it
+// before the ledger was introduced.
+func (r *TransferRepository) GetTransferJournal(ctx context.Context, transferID int) (*models.Journal, error) {
+	var journalID *int
+	err := r.db.QueryRow(ctx, `
+		SELECT j.id FROM transactions t
+		LEFT JOIN journals j ON j.transaction_id = t.id OR j.split_payment_id = t.split_payment_id
+		WHERE t.id = $1
+	`, transferID).Scan(&journalID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, codes.ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("failed to get journal: %w", err)
+	}
+	if journalID == nil {
+		return nil, codes.ErrJournalNotFound
+	}
+
+	var journal models.Journal
+	err = r.db.QueryRow(ctx, `SELECT `+journalColumns+` FROM journals WHERE id = $1`, *journalID).Scan(journalScanTargets(&journal)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get journal: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT `+postingColumns+` FROM postings WHERE journal_id = $1 ORDER BY id`, journal.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get postings: %w", err)
+	}
+	if journal.Postings, err = scanPostings(rows); err != nil {
+		return nil, err
+	}
+
+	return &journal, nil
+}
+
+// ListTransfers returns transfers matching filter, newest first. Pagination is
+// keyset based on the transfer ID so pages stay stable while new transfers
+// are written.
+func (r *TransferRepository) ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SourceAccountID > 0 {
+		addCondition("source_account_id = $%d", filter.SourceAccountID)
+	}
+	if filter.DestinationAccountID > 0 {
+		addCondition("destination_account_id = $%d", filter.DestinationAccountID)
+	}
+	if filter.AccountID > 0 {
+		args = append(args, filter.AccountID)
+		conditions = append(conditions, fmt.Sprintf("(source_account_id = $%d OR destination_account_id = $%d)", len(args), len(args)))
+	}
+	if filter.MinAmount != nil {
+		addCondition("amount >= $%d", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		addCondition("amount <= $%d", *filter.MaxAmount)
+	}
+	if filter.CreatedFrom != nil {
+		addCondition("created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		addCondition("created_at < $%d", *filter.CreatedTo)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+	if filter.ClientReference != "" {
+		addCondition("client_reference = $%d", filter.ClientReference)
+	}
+	if filter.SweepRuleID > 0 {
+		addCondition("sweep_rule_id = $%d", filter.SweepRuleID)
+	}
+	if filter.AfterID > 0 {
+		addCondition("id < $%d", filter.AfterID)
+	}
+
+	query := `SELECT ` + transferColumns + ` FROM transactions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to credit destination account: %w", err)
+		return nil, fmt.Errorf("failed to list transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := []models.Transfer{}
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		transfers = append(transfers, *transfer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list transfers: %w", err)
+	}
+
+	return transfers, nil
+}
+
+// claimIdempotencyKey inserts the key, or takes over an expired one. If a live
+// key already exists the original transfer is returned when the request hash
+// matches. A concurrent request holding the same key blocks here until it
+// commits or rolls back.
+func claimIdempotencyKey(ctx context.Context, tx pgx.Tx, idempotencyKey *models.IdempotencyKey) (*models.Transfer, decimal.Decimal, decimal.Decimal, error) {
+	var claimed string
+	err := tx.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, NOW(), $3)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			transaction_id = NULL,
+			source_balance = NULL,
+			destination_balance = NULL,
+			created_at = NOW(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		RETURNING key
+	`, idempotencyKey.Key, idempotencyKey.RequestHash, idempotencyKey.ExpiresAt).Scan(&claimed)
+	if err == nil {
+		return nil, decimal.Zero, decimal.Zero, nil
+	}
+	if err != pgx.ErrNoRows {
+		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to claim idempotency key: %w", err)
 	}
 
+	var requestHash string
 	var transferID int
+	var sourceBalance, destBalance decimal.Decimal
 	err = tx.QueryRow(ctx, `
-		INSERT INTO transactions (source_account_id, destination_account_id, amount, created_at, updated_at)
-		VALUES ($1, $2, $3, NOW(), NOW())
-		RETURNING id
-	`, sourceAccountID, destAccountID, amount).Scan(&transferID)
+		SELECT request_hash, transaction_id, source_balance, destination_balance
+		FROM idempotency_keys
+		WHERE key = $1
+	`, idempotencyKey.Key).Scan(&requestHash, &transferID, &sourceBalance, &destBalance)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	if requestHash != idempotencyKey.RequestHash {
+		return nil, decimal.Zero, decimal.Zero, codes.ErrIdempotencyKeyReused
+	}
+
+	transfer, err := scanTransfer(tx.QueryRow(ctx, `
+		SELECT `+transferColumns+` FROM transactions WHERE id = $1
+	`, transferID))
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to load idempotent transfer: %w", err)
+	}
+
+	return transfer, sourceBalance, destBalance, nil
+}
+
+// recordDeclinedTransfer stores a declined attempt as a FAILED transfer so it
+// stays auditable after the attempt's own database transaction rolled back.
+// It must be called after that rollback, since the insert needs the account
+// rows the attempt had locked. Declines happen after applyTransferTx has
+// converted the transfer, so its currencies are always filled in.
+func recordDeclinedTransfer(ctx context.Context, db *pgxpool.Pool, transfer *models.Transfer, cause error) {
+	var codeErr codes.CodeError
+	if !errors.As(cause, &codeErr) || !declinedTransferCodes[codeErr.Code] {
+		return
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO transactions (source_account_id, destination_account_id, amount, source_currency, destination_currency, destination_amount,
+			fx_rate_id, fx_rate, reversal_of, sweep_rule_id, status, failure_code, memo, client_reference, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW(), NOW())
+	`, transfer.SourceAccountID, transfer.DestinationAccountID, transfer.Amount, transfer.SourceCurrency, transfer.DestinationCurrency,
+		transfer.DestinationAmount, transfer.FXRateID, transfer.FXRate, transfer.ReversalOf, transfer.SweepRuleID, models.TransferStatusFailed,
+		codeErr.Code, transfer.Memo, transfer.ClientReference, metadataArg(transfer.Metadata))
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"source_account_id":      transfer.SourceAccountID,
+			"destination_account_id": transfer.DestinationAccountID,
+			"failure_code":           codeErr.Code,
+		}).Error("Failed to record declined transfer")
+	}
+}
+
+// checkTransferAccountsExist returns the transfer not-found code for whichever
+// side does not exist. It is for requests that store a transfer to run later,
+// where no account rows are locked yet.
+func checkTransferAccountsExist(ctx context.Context, db *pgxpool.Pool, sourceAccountID, destAccountID int) error {
+	var sourceExists, destExists bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM accounts WHERE id = $1), EXISTS(SELECT 1 FROM accounts WHERE id = $2)
+	`, sourceAccountID, destAccountID).Scan(&sourceExists, &destExists)
+	if err != nil {
+		return fmt.Errorf("failed to check accounts: %w", err)
+	}
+	if !sourceExists {
+		return codes.ErrSourceAccountNotFound
+	}
+	if !destExists {
+		return codes.ErrDestinationAccountNotFound
+	}
+	return nil
+}
+
+// transitionTransferStatus moves a transfer from one status to another. The
+// update only applies if the row is still in the expected status.
+func transitionTransferStatus(ctx context.Context, tx pgx.Tx, transferID int, from, to models.TransferStatus) error {
+	if !from.CanTransitionTo(to) {
+		return codes.NewWithMsg(codes.ErrInvalidTransferStatus, "cannot move transaction from %s to %s", from, to)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE transactions SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3
+	`, to, transferID, from)
+	if err != nil {
+		return fmt.Errorf("failed to update transfer status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return codes.NewWithMsg(codes.ErrInvalidTransferStatus, "transaction is no longer %s", from)
+	}
+	return nil
+}
+
+func recordIdempotencyKey(ctx context.Context, tx pgx.Tx, idempotencyKey *models.IdempotencyKey, transferID int, sourceBalance, destBalance decimal.Decimal) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET transaction_id = $1, source_balance = $2, destination_balance = $3
+		WHERE key = $4
+	`, transferID, sourceBalance, destBalance, idempotencyKey.Key)
+	if err != nil {
+		return fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+	return nil
+}
+
+func scanTransfer(row pgx.Row) (*models.Transfer, error) {
+	var transfer models.Transfer
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.SourceAccountID,
+		&transfer.DestinationAccountID,
+		&transfer.Amount,
+		&transfer.SourceCurrency,
+		&transfer.DestinationCurrency,
+		&transfer.DestinationAmount,
+		&transfer.FXRateID,
+		&transfer.FXRate,
+		&transfer.Fee,
+		&transfer.FeeScheduleID,
+		&transfer.FeeAccountID,
+		&transfer.ReversalOf,
+		&transfer.BatchID,
+		&transfer.SplitPaymentID,
+		&transfer.SweepRuleID,
+		&transfer.Status,
+		&transfer.FailureCode,
+		&transfer.CreatedAt,
+		&transfer.UpdatedAt,
+		&transfer.Memo,
+		&transfer.ClientReference,
+		&transfer.Metadata,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+func transferReferenceScanTargets(reference *models.TransferReference) []any {
+	return []any{
+		&reference.Memo,
+		&reference.ClientReference,
+		&reference.Metadata,
+	}
+}
+
+// metadataArg leaves the metadata column NULL when there is no metadata
+func metadataArg(metadata map[string]any) any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
+// processTransferTx applies transfer inside tx and fills in its ID and
+// timestamps. It returns the new source and destination balances.
+func processTransferTx(ctx context.Context, tx pgx.Tx, transfer *models.Transfer, riskRules []models.RiskRule) (decimal.Decimal, decimal.Decimal, error) {
+	accounts, err := lockTransferAccounts(ctx, tx, transfer)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	if err = checkTransferPrecondition(accounts, transfer); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	return applyTransferTx(ctx, tx, accounts, transfer, riskRules)
+}
+
+// checkTransferPrecondition fails a conditional transfer whose source has
+// changed since the client read it. The source row is locked by now, so
+// only credits to a hot source's shards can land before the transfer
+// commits. A missing source is left for applyTransferTx to report.
+func checkTransferPrecondition(accounts map[int]*lockedAccount, transfer *models.Transfer) error {
+	precondition := transfer.Precondition
+	if precondition == nil {
+		return nil
+	}
+
+	source, ok := accounts[transfer.SourceAccountID]
+	if !ok {
+		return nil
+	}
+
+	if precondition.SourceVersion != nil && *precondition.SourceVersion != source.Version {
+		return codes.NewWithMsg(codes.ErrPreconditionFailed,
+			"source account %d is at version %d, not the expected %d", source.ID, source.Version, *precondition.SourceVersion)
+	}
+	if precondition.SourceBalance != nil && !precondition.SourceBalance.Equal(source.Balance) {
+		return codes.NewWithMsg(codes.ErrPreconditionFailed,
+			"source account %d balance is no longer the expected %s", source.ID, precondition.SourceBalance.String())
+	}
+	return nil
+}
+
+// lockedAccount is an account row locked FOR UPDATE for the rest of the
+// database transaction. Balance tracks the account as transfers are applied
+// and may go negative down to -OverdraftLimit. FeeSchedule prices transfers
+// debiting the account, if it has one, and Limits are loaded on the first
+// transfer the account sends. A hot account that is only credited is read
+// without the lock; its Balance is then as of the read.
+type lockedAccount struct {
+	ID             int
+	Currency       string
+	Balance        decimal.Decimal
+	OverdraftLimit decimal.Decimal
+	BalanceShards  int
+	Version        int64
+	Held           decimal.Decimal
+	heldLoaded     bool
+	FeeSchedule    *models.FeeSchedule
+	Limits         *models.AccountLimits
+	limitsLoaded   bool
+}
+
+// lockTransferAccounts locks both sides of every transfer together with the
+// fee revenue accounts their fees are credited to. Fee schedules are looked
+// up first so the revenue accounts join the same ordered locking.
+// Reversals are not charged, so their schedules are not loaded.
+func lockTransferAccounts(ctx context.Context, tx pgx.Tx, transfers ...*models.Transfer) (map[int]*lockedAccount, error) {
+	accountIDs := make([]int, 0, len(transfers)*2)
+	debitedIDs := make([]int, 0, len(transfers))
+	var chargedIDs []int
+	for _, transfer := range transfers {
+		accountIDs = append(accountIDs, transfer.SourceAccountID, transfer.DestinationAccountID)
+		debitedIDs = append(debitedIDs, transfer.SourceAccountID)
+		if transfer.ReversalOf == nil {
+			chargedIDs = append(chargedIDs, transfer.SourceAccountID)
+		}
+	}
+
+	schedules, err := feeSchedulesTx(ctx, tx, chargedIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		accountIDs = append(accountIDs, schedule.RevenueAccountID)
+	}
+
+	accounts, err := lockAccounts(ctx, tx, accountIDs, debitedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for accountID, schedule := range schedules {
+		if account, ok := accounts[accountID]; ok {
+			account.FeeSchedule = schedule
+		}
+	}
+
+	return accounts, nil
+}
+
+// lockAccounts locks every given account in ascending ID order. Taking locks
+// in one global order is what prevents deadlocks between transfers that
+// touch the same accounts in opposite directions. Hot accounts outside
+// debitedIDs are read without a lock, since their credits go to a balance
+// shard and leave the account row alone. Accounts that do not exist are
+// missing from the result.
+func lockAccounts(ctx context.Context, tx pgx.Tx, accountIDs, debitedIDs []int) (map[int]*lockedAccount, error) {
+	// The balances come from the CTE, which returns the rows as they are
+	// once locked rather than as of the statement's snapshot. An account
+	// marked hot while its lock is awaited drops out of the CTE and is read
+	// through the second branch, where it still looks like a plain account.
+	rows, err := tx.Query(ctx, `
+		WITH locked AS (
+			SELECT id, currency, balance, overdraft_limit, balance_shards, version FROM accounts
+			WHERE id = ANY($1) AND (balance_shards = 0 OR id = ANY($2))
+			ORDER BY id
+			FOR UPDATE
+		)
+		SELECT a.id, a.currency, `+accountBalance+`, a.overdraft_limit, a.balance_shards, `+accountVersion+` FROM locked a
+		UNION ALL
+		SELECT a.id, a.currency, `+accountBalance+`, a.overdraft_limit, a.balance_shards, `+accountVersion+` FROM accounts a
+		WHERE a.id = ANY($1) AND a.id NOT IN (SELECT id FROM locked)
+	`, accountIDs, debitedIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := make(map[int]*lockedAccount, len(accountIDs))
+	for rows.Next() {
+		var account lockedAccount
+		if err = rows.Scan(&account.ID, &account.Currency, &account.Balance, &account.OverdraftLimit, &account.BalanceShards,
+			&account.Version); err != nil {
+			return nil, fmt.Errorf("failed to lock accounts: %w", err)
+		}
+		accounts[account.ID] = &account
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to lock accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// applyTransferTx debits and credits accounts already locked by
+// lockTransferAccounts, inserts the transfer row and posts its journal. The
+// source pays transfer.Amount plus the fee its schedule charges, and the fee
+// is credited to the schedule's revenue account. The applied transfer is
+// checked against riskRules before anything else sees it. The locked
+// balances are updated in place so several transfers can be applied against
+// the same locks.
+func applyTransferTx(ctx context.Context, tx pgx.Tx, accounts map[int]*lockedAccount, transfer *models.Transfer,
+	riskRules []models.RiskRule) (decimal.Decimal, decimal.Decimal, error) {
+	source, ok := accounts[transfer.SourceAccountID]
+	if !ok {
+		return decimal.Zero, decimal.Zero, codes.ErrSourceAccountNotFound
+	}
+	dest, ok := accounts[transfer.DestinationAccountID]
+	if !ok {
+		return decimal.Zero, decimal.Zero, codes.ErrDestinationAccountNotFound
+	}
+
+	if err := convertTransferTx(ctx, tx, source, dest, transfer); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	if transfer.ReversalOf == nil {
+		if err := checkAccountLimitsTx(ctx, tx, source, transfer.Amount); err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+	}
+
+	if !source.heldLoaded {
+		held, err := heldAmountTx(ctx, tx, source.ID)
+		if err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+		source.Held = held
+		source.heldLoaded = true
+	}
+
+	feeAccount, err := chargeFee(accounts, source, transfer)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	// The source may draw on its overdraft, with holds reserving part of it
+	amount := transfer.Amount
+	debit := amount.Add(transfer.Fee)
+	if source.Balance.Sub(source.Held).Add(source.OverdraftLimit).LessThan(debit) {
+		return decimal.Zero, decimal.Zero, codes.ErrInsufficientFunds
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE accounts SET balance = balance - $1, updated_at = NOW() WHERE id = $2
+	`, debit, source.ID)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("failed to debit source account: %w", err)
+	}
+
+	if err = creditAccountTx(ctx, tx, dest, transfer.DestinationAmount); err != nil {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("failed to credit destination account: %w", err)
+	}
+
+	if feeAccount != nil {
+		if err = creditAccountTx(ctx, tx, feeAccount, transfer.Fee); err != nil {
+			return decimal.Zero, decimal.Zero, fmt.Errorf("failed to credit fee revenue account: %w", err)
+		}
+	}
+
+	if err = insertTransferTx(ctx, tx, transfer); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	if err = postTransferJournalTx(ctx, tx, transfer); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	if len(riskRules) > 0 {
+		if err = checkRiskRulesTx(ctx, tx, riskRules, transfer, transfer.Amount); err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+	}
+
+	source.Balance = source.Balance.Sub(debit)
+	dest.Balance = dest.Balance.Add(transfer.DestinationAmount)
+	if feeAccount != nil {
+		feeAccount.Balance = feeAccount.Balance.Add(transfer.Fee)
+	}
+
+	return source.Balance, dest.Balance, nil
+}
+
+// insertTransferTx stores transfer as COMPLETED and fills in its ID and
+// timestamps. The accounts must already have been debited and credited.
+func insertTransferTx(ctx context.Context, tx pgx.Tx, transfer *models.Transfer) error {
+	transfer.Status = models.TransferStatusCompleted
+	err := tx.QueryRow(ctx, `
+		INSERT INTO transactions (source_account_id, destination_account_id, amount, source_currency, destination_currency, destination_amount,
+			fx_rate_id, fx_rate, fee, fee_schedule_id, fee_account_id, reversal_of, batch_id, split_payment_id, sweep_rule_id, status, memo,
+			client_reference, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, transfer.SourceAccountID, transfer.DestinationAccountID, transfer.Amount, transfer.SourceCurrency, transfer.DestinationCurrency,
+		transfer.DestinationAmount, transfer.FXRateID, transfer.FXRate, transfer.Fee, transfer.FeeScheduleID, transfer.FeeAccountID,
+		transfer.ReversalOf, transfer.BatchID, transfer.SplitPaymentID, transfer.SweepRuleID, transfer.Status, transfer.Memo,
+		transfer.ClientReference, metadataArg(transfer.Metadata)).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.UpdatedAt)
 	if err != nil {
-		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to create transfer record: %w", err)
+		// The only unique index a new transfer row can hit is the one on
+		// client references
+		if transfer.ClientReference != nil && strings.Contains(err.Error(), "23505") {
+			return codes.NewWithMsg(codes.ErrDuplicateClientReference,
+				"client reference %q is already used by another transfer from account %d", *transfer.ClientReference, transfer.SourceAccountID)
+		}
+		return fmt.Errorf("failed to create transfer record: %w", err)
 	}
+	return nil
+}
 
-	if err = tx.Commit(ctx); err != nil {
-		return nil, decimal.Zero, decimal.Zero, fmt.Errorf("failed to commit transaction: %w", err)
+// creditAccountTx adds amount to account. A hot account is credited on one
+// of its balance shards picked at random, so concurrent credits to it rarely
+// wait on each other.
+func creditAccountTx(ctx context.Context, tx pgx.Tx, account *lockedAccount, amount decimal.Decimal) error {
+	if account.BalanceShards == 0 {
+		_, err := tx.Exec(ctx, `
+			UPDATE accounts SET balance = balance + $1, updated_at = NOW() WHERE id = $2
+		`, amount, account.ID)
+		return err
 	}
 
-	newSourceBalance := sourceBalance.Sub(amount)
-	newDestBalance := destBalance.Add(amount)
+	tag, err := tx.Exec(ctx, `
+		UPDATE account_balance_shards SET balance = balance + $1, updated_at = NOW() WHERE account_id = $2 AND shard = $3
+	`, amount, account.ID, rand.IntN(account.BalanceShards))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("balance shard of account %d is missing", account.ID)
+	}
+	return nil
+}
+
+// convertTransferTx fills in the currencies and the destination amount of
+// transfer. A cross-currency transfer uses the newest rate in effect for the
+// pair, unless the caller has already fixed the rate and amounts, as
+// reversals do.
+func convertTransferTx(ctx context.Context, tx pgx.Tx, source, dest *lockedAccount, transfer *models.Transfer) error {
+	transfer.SourceCurrency = source.Currency
+	transfer.DestinationCurrency = dest.Currency
+
+	if source.Currency == dest.Currency {
+		transfer.DestinationAmount = transfer.Amount
+		return nil
+	}
 
-	transfer := &models.Transfer{
-		ID:                  transferID,
-		SourceAccountID:     sourceAccountID,
-		DestinationAccountID: destAccountID,
-		Amount:              amount,
+	if transfer.FXRate == nil {
+		rate, err := currentFXRateTx(ctx, tx, source.Currency, dest.Currency)
+		if err != nil {
+			return err
+		}
+		transfer.FXRateID = &rate.ID
+		transfer.FXRate = &rate.Rate
+	}
+
+	if transfer.DestinationAmount.IsZero() {
+		transfer.DestinationAmount = transfer.Amount.Mul(*transfer.FXRate).Round(amountScale)
+	}
+	if !transfer.DestinationAmount.IsPositive() {
+		return codes.NewWithMsg(codes.ErrInvalidParams, "amount is too small to convert from %s to %s", source.Currency, dest.Currency)
+	}
+
+	return nil
+}
+
+// chargeFee prices transfer with the source's fee schedule and returns the
+// locked revenue account to credit, or nil when there is no fee. Fees are in
+// the source currency, so the revenue account must hold the same currency.
+func chargeFee(accounts map[int]*lockedAccount, source *lockedAccount, transfer *models.Transfer) (*lockedAccount, error) {
+	transfer.Fee = decimal.Zero
+	schedule := source.FeeSchedule
+	if schedule == nil || transfer.ReversalOf != nil {
+		return nil, nil
+	}
+
+	fee := schedule.Fee(transfer.Amount)
+	if !fee.IsPositive() {
+		return nil, nil
+	}
+
+	feeAccount, ok := accounts[schedule.RevenueAccountID]
+	if !ok {
+		return nil, fmt.Errorf("fee revenue account %d of fee schedule %d not found", schedule.RevenueAccountID, schedule.ID)
+	}
+	if feeAccount.Currency != source.Currency {
+		return nil, codes.NewWithMsg(codes.ErrSystem, "fee revenue account %d does not hold %s", feeAccount.ID, source.Currency)
 	}
 
-	return transfer, newSourceBalance, newDestBalance, nil
+	transfer.Fee = fee
+	transfer.FeeScheduleID = &schedule.ID
+	transfer.FeeAccountID = &feeAccount.ID
+	return feeAccount, nil
 }
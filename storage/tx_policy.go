@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// txRetryBaseBackoff and txRetryMaxBackoff bound the jittered backoff
+	// between attempts, which doubles with every retry
+	txRetryBaseBackoff = 10 * time.Millisecond
+	txRetryMaxBackoff  = 500 * time.Millisecond
+)
+
+// retryableSQLStates are the failures after which running the whole
+// transaction again may succeed: serialization failures, deadlocks and lock
+// timeouts.
+var retryableSQLStates = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available, raised when lock_timeout expires
+}
+
+// TxPolicy is how the database transactions that move money run. A
+// transaction failing with a retryable error is rolled back and run again up
+// to MaxRetries more times. LockTimeout, when set, bounds how long a
+// statement waits for a row lock.
+type TxPolicy struct {
+	IsoLevel    pgx.TxIsoLevel
+	LockTimeout time.Duration
+	MaxRetries  int
+}
+
+// ParseIsolationLevel accepts a Postgres isolation level such as
+// "serializable" or "REPEATABLE READ". An empty level keeps the server's
+// default.
+func ParseIsolationLevel(level string) (pgx.TxIsoLevel, error) {
+	normalized := strings.ToLower(strings.Join(strings.FieldsFunc(level, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), " "))
+
+	switch normalized {
+	case "":
+		return "", nil
+	case "read committed":
+		return pgx.ReadCommitted, nil
+	case "repeatable read":
+		return pgx.RepeatableRead, nil
+	case "serializable":
+		return pgx.Serializable, nil
+	}
+	return "", fmt.Errorf("unknown isolation level %q", level)
+}
+
+// IsRetryableTxError reports whether err is a serialization failure, a
+// deadlock or a lock timeout, anywhere in its chain.
+func IsRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && retryableSQLStates[pgErr.Code]
+}
+
+// runTx runs fn in a transaction under policy and commits it. fn may be
+// called several times, so it must not keep state from an earlier attempt.
+func runTx(ctx context.Context, db *pgxpool.Pool, policy TxPolicy, fn func(tx pgx.Tx) error) error {
+	return retryConflicts(ctx, policy, func() error {
+		return runTxOnce(ctx, db, policy, fn)
+	})
+}
+
+// retryConflicts calls run until it succeeds or fails with an error that
+// is not retryable. A retry is only made when its backoff ends before the
+// context deadline; once retries run out the conflict is returned as
+// ErrTransactionConflict.
+func retryConflicts(ctx context.Context, policy TxPolicy, run func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := run()
+		if err == nil || !IsRetryableTxError(err) {
+			return err
+		}
+
+		if attempt >= policy.MaxRetries {
+			return codes.NewWithMsg(codes.ErrTransactionConflict, "%s after %d attempts: %v", codes.ErrTransactionConflict.Msg, attempt+1, err)
+		}
+
+		backoff := txRetryBackoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+			return codes.NewWithMsg(codes.ErrTransactionConflict, "%s before the deadline: %v", codes.ErrTransactionConflict.Msg, err)
+		}
+
+		log.WithError(err).WithFields(log.Fields{
+			"attempt": attempt + 1,
+			"backoff": backoff.String(),
+		}).Warn("Database transaction conflicted, retrying")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("gave up retrying transaction: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func runTxOnce(ctx context.Context, db *pgxpool.Pool, policy TxPolicy, fn func(tx pgx.Tx) error) error {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{IsoLevel: policy.IsoLevel})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if policy.LockTimeout > 0 {
+		_, err = tx.Exec(ctx, `SELECT set_config('lock_timeout', $1, true)`, fmt.Sprintf("%dms", policy.LockTimeout.Milliseconds()))
+		if err != nil {
+			return fmt.Errorf("failed to set lock timeout: %w", err)
+		}
+	}
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// txRetryBackoff picks a random wait of up to the exponential backoff for
+// the attempt, so transactions that conflicted together do not retry in step
+func txRetryBackoff(attempt int) time.Duration {
+	backoff := txRetryMaxBackoff
+	if attempt < 6 {
+		backoff = min(txRetryBaseBackoff<<attempt, txRetryMaxBackoff)
+	}
+	return time.Duration(rand.Int64N(int64(backoff))) + 1
+}
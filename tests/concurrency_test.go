@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -295,3 +296,276 @@ func TestConcurrentReadsAndWrites(t *testing.T) {
 		}
 	}
 }
+
+func TestConcurrentIdempotentTransfers(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().UnixNano()) % 100000
+
+	accounts := []CreateAccountRequest{
+		{AccountID: baseID + 50000, InitialBalance: "100.00"},
+		{AccountID: baseID + 50001, InitialBalance: "0.00"},
+	}
+
+	for _, account := range accounts {
+		reqBody, err := json.Marshal(account)
+		require.NoError(t, err)
+
+		resp, err := http.Post(
+			fmt.Sprintf("%s/accounts", ts.Server.URL),
+			"application/json",
+			bytes.NewBuffer(reqBody),
+		)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	key := fmt.Sprintf("concurrent-idem-%d", time.Now().UnixNano())
+	numRequests := 100
+	var wg sync.WaitGroup
+	transactionIDs := make(map[int]int)
+	var mu sync.Mutex
+
+	for i := 0; i < numRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp := postTransactionWithKey(t, ts, key, CreateTransactionRequest{
+				SourceAccountID:      baseID + 50000,
+				DestinationAccountID: baseID + 50001,
+				Amount:              "10.00",
+			})
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var transactionResp CreateTransactionResponse
+			err := json.NewDecoder(resp.Body).Decode(&transactionResp)
+			require.NoError(t, err)
+
+			mu.Lock()
+			transactionIDs[transactionResp.TransactionID]++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Len(t, transactionIDs, 1, "All replays should resolve to the same transaction")
+
+	resp, err := http.Get(fmt.Sprintf("%s/accounts/%d", ts.Server.URL, baseID+50000))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var accountResp GetAccountResponse
+	err = json.NewDecoder(resp.Body).Decode(&accountResp)
+	require.NoError(t, err)
+	assert.Equal(t, "90", accountResp.Balance)
+}
+
+func TestConcurrentBatchTransfers(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().UnixNano()) % 100000
+	accountIDs := []int{baseID + 60000, baseID + 60001, baseID + 60002}
+
+	for _, accountID := range accountIDs {
+		createTestAccounts(t, ts, CreateAccountRequest{AccountID: accountID, InitialBalance: "1000.00"})
+	}
+
+	numBatches := 200
+	var wg sync.WaitGroup
+
+	for i := 0; i < numBatches; i++ {
+		wg.Add(1)
+		go func(batchNum int) {
+			defer wg.Done()
+
+			// Rotate the legs so batches touch the accounts in different orders
+			a := accountIDs[batchNum%3]
+			b := accountIDs[(batchNum+1)%3]
+			c := accountIDs[(batchNum+2)%3]
+
+			status := postJSON(t, fmt.Sprintf("%s/transactions/batch", ts.Server.URL), BatchTransactionRequest{Legs: []CreateTransactionRequest{
+				{SourceAccountID: c, DestinationAccountID: a, Amount: "1.00"},
+				{SourceAccountID: a, DestinationAccountID: b, Amount: "1.00"},
+				{SourceAccountID: b, DestinationAccountID: c, Amount: "1.00"},
+			}}, nil)
+
+			assert.Equal(t, http.StatusOK, status, "Expected 200, got %d", status)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, accountID := range accountIDs {
+		assert.Equal(t, "1000", getAccountBalance(t, ts, accountID), "Account %d balance should be unchanged", accountID)
+	}
+}
+
+func TestConcurrentLimitedTransfers(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().UnixNano()) % 100000
+	sourceID, destID := baseID+70000, baseID+70001
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "1000.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	status := putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, sourceID), SetAccountLimitsRequest{DailyLimit: "100.00"})
+	require.Equal(t, http.StatusOK, status)
+
+	numTransfers := 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+
+	for i := 0; i < numTransfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+				SourceAccountID:      sourceID,
+				DestinationAccountID: destID,
+				Amount:               "10.00",
+			}, nil)
+
+			if status == http.StatusOK {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else {
+				assert.Equal(t, http.StatusBadRequest, status, "Transfers over the cap should be declined")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 10, succeeded, "Exactly the daily cap should be transferred")
+	assert.Equal(t, "900", getAccountBalance(t, ts, sourceID))
+	assert.Equal(t, "100", getAccountBalance(t, ts, destID))
+}
+
+func TestConcurrentSerializableTransfers(t *testing.T) {
+	ts := SetupTestServerWithDBConfig(t, func(config *storage.Config) {
+		config.IsolationLevel = "serializable"
+		config.LockTimeout = time.Second
+		config.MaxTxRetries = 20
+	})
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().UnixNano()) % 100000
+	accountIDs := []int{baseID + 80000, baseID + 80001, baseID + 80002}
+
+	for _, accountID := range accountIDs {
+		createTestAccounts(t, ts, CreateAccountRequest{AccountID: accountID, InitialBalance: "1000.00"})
+
+		// Limits make every transfer read the source's outflow history, which
+		// SERIALIZABLE turns into conflicts between concurrent transfers
+		status := putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, accountID), SetAccountLimitsRequest{DailyLimit: "100000.00"})
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	numTransfers := 300
+	var wg sync.WaitGroup
+
+	for i := 0; i < numTransfers; i++ {
+		wg.Add(1)
+		go func(transferNum int) {
+			defer wg.Done()
+
+			status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+				SourceAccountID:      accountIDs[transferNum%3],
+				DestinationAccountID: accountIDs[(transferNum+1)%3],
+				Amount:               "1.00",
+			}, nil)
+
+			assert.Equal(t, http.StatusOK, status, "Conflicts should be retried, got %d", status)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, accountID := range accountIDs {
+		assert.Equal(t, "1000", getAccountBalance(t, ts, accountID), "Account %d balance should be unchanged", accountID)
+	}
+}
+
+func TestConcurrentHotAccountTransfers(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().UnixNano()) % 100000
+	hotID, sinkID := baseID+85000, baseID+85001
+	payerIDs := []int{baseID + 85002, baseID + 85003, baseID + 85004, baseID + 85005}
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: hotID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: sinkID, InitialBalance: "0.00"},
+	)
+	for _, payerID := range payerIDs {
+		createTestAccounts(t, ts, CreateAccountRequest{AccountID: payerID, InitialBalance: "1000.00"})
+	}
+	status := putJSON(t, fmt.Sprintf("%s/accounts/%d/balance-shards", ts.Server.URL, hotID), map[string]int{"shards": 8})
+	require.Equal(t, http.StatusOK, status)
+
+	// Credits spread over the shards while debits drain the account through
+	// its own row, some of them declined for lack of funds
+	numCredits := 400
+	numDebits := 100
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	debited := 0
+
+	for i := 0; i < numCredits; i++ {
+		wg.Add(1)
+		go func(transferNum int) {
+			defer wg.Done()
+
+			status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+				SourceAccountID:      payerIDs[transferNum%len(payerIDs)],
+				DestinationAccountID: hotID,
+				Amount:               "1.00",
+			}, nil)
+			assert.Equal(t, http.StatusOK, status)
+		}(i)
+	}
+
+	for i := 0; i < numDebits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+				SourceAccountID:      hotID,
+				DestinationAccountID: sinkID,
+				Amount:               "2.00",
+			}, nil)
+			assert.True(t, status == http.StatusOK || status == http.StatusBadRequest,
+				"Expected 200 or 400, got %d", status)
+
+			if status == http.StatusOK {
+				mu.Lock()
+				debited++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, strconv.Itoa(numCredits-2*debited), getAccountBalance(t, ts, hotID))
+	assert.Equal(t, strconv.Itoa(2*debited), getAccountBalance(t, ts, sinkID))
+	for _, payerID := range payerIDs {
+		assert.Equal(t, strconv.Itoa(1000-numCredits/len(payerIDs)), getAccountBalance(t, ts, payerID))
+	}
+}
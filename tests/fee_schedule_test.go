@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeSchedule(t *testing.T) {
+	dec := func(value string) *decimal.Decimal {
+		d := decimal.RequireFromString(value)
+		return &d
+	}
+
+	tiered := []models.FeeTier{
+		{UpTo: dec("100"), FlatAmount: *dec("1")},
+		{UpTo: dec("1000"), Percentage: *dec("0.5")},
+		{FlatAmount: *dec("2"), Percentage: *dec("0.25")},
+	}
+
+	tests := []struct {
+		name     string
+		schedule models.FeeSchedule
+		amount   string
+		want     string
+	}{
+		{
+			name:     "flat ignores the amount",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypeFlat, FlatAmount: dec("2.50")},
+			amount:   "1000",
+			want:     "2.5",
+		},
+		{
+			name:     "percentage of the amount",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypePercentage, Percentage: dec("1.5")},
+			amount:   "200",
+			want:     "3",
+		},
+		{
+			name:     "percentage rounds to 8 places",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypePercentage, Percentage: dec("1")},
+			amount:   "0.123456789",
+			want:     "0.00123457",
+		},
+		{
+			name:     "minimum lifts small fees",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypePercentage, Percentage: dec("1"), MinFee: dec("0.5")},
+			amount:   "10",
+			want:     "0.5",
+		},
+		{
+			name:     "maximum caps large fees",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypePercentage, Percentage: dec("1"), MaxFee: dec("20")},
+			amount:   "5000",
+			want:     "20",
+		},
+		{
+			name:     "tier boundary is inclusive",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypeTiered, Tiers: tiered},
+			amount:   "100",
+			want:     "1",
+		},
+		{
+			name:     "middle tier",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypeTiered, Tiers: tiered},
+			amount:   "400",
+			want:     "2",
+		},
+		{
+			name:     "open-ended last tier",
+			schedule: models.FeeSchedule{FeeType: models.FeeTypeTiered, Tiers: tiered},
+			amount:   "2000",
+			want:     "7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee := tt.schedule.Fee(decimal.RequireFromString(tt.amount))
+			assert.Equal(t, tt.want, fee.String())
+		})
+	}
+}
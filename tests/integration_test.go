@@ -9,12 +9,16 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Nauman-S/Internal-Transfers-System/api"
 	"github.com/Nauman-S/Internal-Transfers-System/config"
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/service/transactions"
 	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -26,6 +30,13 @@ type TestServer struct {
 }
 
 func SetupTestServer(t *testing.T) *TestServer {
+	return SetupTestServerWithDBConfig(t, func(*storage.Config) {})
+}
+
+// SetupTestServerWithDBConfig lets a test change the database config, such
+// as its transaction isolation level, before connecting. It also serves
+// benchmarks.
+func SetupTestServerWithDBConfig(t testing.TB, configure func(*storage.Config)) *TestServer {
 	testConfig := storage.Config{
 		Host:     getEnvOrDefault("TEST_DB_HOST", "localhost"),
 		Port:     getEnvIntOrDefault("TEST_DB_PORT", 5432),
@@ -34,6 +45,7 @@ func SetupTestServer(t *testing.T) *TestServer {
 		Password: getEnvOrDefault("TEST_DB_PASSWORD", "password"),
 		SSLMode:  getEnvOrDefault("TEST_DB_SSL_MODE", "disable"),
 	}
+	configure(&testConfig)
 
 	ctx := context.Background()
 	db, err := storage.InitDB(ctx, testConfig)
@@ -49,6 +61,21 @@ func SetupTestServer(t *testing.T) *TestServer {
 		DB:                 db,
 		AccountRepository:  storage.NewAccountRepository(db),
 		TransferRepository: storage.NewTransferRepository(db),
+		HoldRepository:     storage.NewHoldRepository(db),
+		IdempotencyKeyTTL:  time.Hour,
+		HoldDefaultTTL:     time.Hour,
+
+		ScheduledTransferRepository: storage.NewScheduledTransferRepository(db),
+		StandingOrderRepository:     storage.NewStandingOrderRepository(db),
+		FXRateRepository:            storage.NewFXRateRepository(db),
+		FeeScheduleRepository:       storage.NewFeeScheduleRepository(db),
+		TransferApprovalRepository:  storage.NewTransferApprovalRepository(db),
+		AsyncTransferRepository:     storage.NewAsyncTransferRepository(db),
+		SweepRuleRepository:         storage.NewSweepRuleRepository(db),
+		RiskRuleRepository:          storage.NewRiskRuleRepository(db),
+
+		ApprovalThreshold: decimal.NewFromInt(100000),
+		ApprovalTTL:       time.Hour,
 	}
 
 	router := api.InitRouter(appConfig)
@@ -91,28 +118,369 @@ func getEnvIntOrDefault(key string, defaultValue int) int {
 type CreateAccountRequest struct {
 	AccountID      int    `json:"account_id"`
 	InitialBalance string `json:"initial_balance"`
+	Currency       string `json:"currency,omitempty"`
+	AccountType    string `json:"account_type,omitempty"`
+	OverdraftLimit string `json:"overdraft_limit,omitempty"`
 }
 
 type CreateAccountResponse struct{}
 
 type GetAccountResponse struct {
-	AccountID int    `json:"account_id"`
-	Balance   string `json:"balance"`
+	AccountID        int    `json:"account_id"`
+	Balance          string `json:"balance"`
+	LedgerBalance    string `json:"ledger_balance"`
+	AvailableBalance string `json:"available_balance"`
+	OverdraftLimit   string `json:"overdraft_limit"`
+	AvailableCredit  string `json:"available_credit"`
+	Currency         string `json:"currency"`
+	AccountType      string `json:"account_type"`
+	BalanceShards    int    `json:"balance_shards"`
+	Version          int64  `json:"version"`
+}
+
+type ListOverdrawnAccountsResponse struct {
+	Accounts   []GetAccountResponse `json:"accounts"`
+	NextCursor string               `json:"next_cursor,omitempty"`
 }
 
 type CreateTransactionRequest struct {
 	SourceAccountID      int    `json:"source_account_id"`
 	DestinationAccountID int    `json:"destination_account_id"`
 	Amount              string `json:"amount"`
+	ExecuteAt           string `json:"execute_at,omitempty"`
+
+	Memo            string         `json:"memo,omitempty"`
+	ClientReference string         `json:"client_reference,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+	Async           bool           `json:"async,omitempty"`
+	CallbackURL     string         `json:"callback_url,omitempty"`
+
+	ExpectedSourceVersion int64  `json:"expected_source_version,omitempty"`
+	ExpectedSourceBalance string `json:"expected_source_balance,omitempty"`
 }
 
 type CreateTransactionResponse struct {
 	TransactionID       int    `json:"transaction_id"`
+	ScheduledTransferID int    `json:"scheduled_transfer_id"`
+	AsyncTransferID     int    `json:"async_transfer_id"`
+	ApprovalID          int    `json:"approval_id"`
+	ExecuteAt           string `json:"execute_at"`
 	Status             string `json:"status"`
 	SourceBalance      string `json:"source_balance"`
 	DestinationBalance string `json:"destination_balance"`
 	Amount             string `json:"amount"`
+	SourceCurrency      string `json:"source_currency"`
+	DestinationCurrency string `json:"destination_currency"`
+	DestinationAmount   string `json:"destination_amount"`
+	FXRateID            int    `json:"fx_rate_id"`
+	FXRate              string `json:"fx_rate"`
+	Fee                 string `json:"fee"`
+	FeeAccountID        int    `json:"fee_account_id"`
+	ReversalOf         int    `json:"reversal_of"`
 	CreatedAt          string `json:"created_at"`
+
+	Memo            string         `json:"memo"`
+	ClientReference string         `json:"client_reference"`
+	Metadata        map[string]any `json:"metadata"`
+}
+
+type QuoteTransactionResponse struct {
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	SourceCurrency       string `json:"source_currency"`
+	DestinationCurrency  string `json:"destination_currency"`
+	DestinationAmount    string `json:"destination_amount"`
+	FXRateID             int    `json:"fx_rate_id"`
+	Fee                  string `json:"fee"`
+	FeeAccountID         int    `json:"fee_account_id"`
+	SourceBalance        string `json:"source_balance"`
+	DestinationBalance   string `json:"destination_balance"`
+	RequiresApproval     bool   `json:"requires_approval"`
+}
+
+type ReverseTransactionRequest struct {
+	Amount string `json:"amount,omitempty"`
+}
+
+type TransferRecord struct {
+	ID                   int    `json:"id"`
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	DestinationAmount    string `json:"destination_amount"`
+	FXRateID             int    `json:"fx_rate_id"`
+	Fee                  string `json:"fee"`
+	FeeScheduleID        int    `json:"fee_schedule_id"`
+	ReversalOf           int    `json:"reversal_of"`
+	SplitPaymentID       int    `json:"split_payment_id"`
+	SweepRuleID          int    `json:"sweep_rule_id"`
+	Status               string `json:"status"`
+	FailureCode          int    `json:"failure_code"`
+	CreatedAt            string `json:"created_at"`
+
+	Memo            string         `json:"memo"`
+	ClientReference string         `json:"client_reference"`
+	Metadata        map[string]any `json:"metadata"`
+}
+
+type ListTransactionsResponse struct {
+	Transfers  []TransferRecord `json:"transfers"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+type BatchTransactionRequest struct {
+	Legs []CreateTransactionRequest `json:"legs"`
+}
+
+type BatchTransactionResponse struct {
+	BatchID int    `json:"batch_id"`
+	Status  string `json:"status"`
+	Legs    []struct {
+		TransactionID int `json:"transaction_id"`
+	} `json:"legs"`
+	Balances []struct {
+		AccountID int    `json:"account_id"`
+		Balance   string `json:"balance"`
+	} `json:"balances"`
+}
+
+type ScheduledTransferRecord struct {
+	ScheduledTransferID  int    `json:"scheduled_transfer_id"`
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	ExecuteAt            string `json:"execute_at"`
+	Status               string `json:"status"`
+	Attempts             int    `json:"attempts"`
+	TransactionID        int    `json:"transaction_id"`
+}
+
+type ListScheduledTransactionsResponse struct {
+	ScheduledTransfers []ScheduledTransferRecord `json:"scheduled_transfers"`
+	NextCursor         string                    `json:"next_cursor"`
+}
+
+type CreateStandingOrderRequest struct {
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	Frequency            string `json:"frequency"`
+	IntervalSeconds      int    `json:"interval_seconds,omitempty"`
+	DayOfWeek            *int   `json:"day_of_week,omitempty"`
+	StartAt              string `json:"start_at,omitempty"`
+	MaxRuns              int    `json:"max_runs,omitempty"`
+	FailurePolicy        string `json:"failure_policy,omitempty"`
+	MaxRetries           int    `json:"max_retries,omitempty"`
+}
+
+type StandingOrderResponse struct {
+	StandingOrderID int    `json:"standing_order_id"`
+	Status          string `json:"status"`
+	ExecutedRuns    int    `json:"executed_runs"`
+	RetryCount      int    `json:"retry_count"`
+	NextRunAt       string `json:"next_run_at"`
+	NextAttemptAt   string `json:"next_attempt_at"`
+}
+
+type StandingOrderRunsResponse struct {
+	Runs []struct {
+		Status        string `json:"status"`
+		Attempt       int    `json:"attempt"`
+		TransactionID int    `json:"transaction_id"`
+		ErrorCode     int    `json:"error_code"`
+	} `json:"runs"`
+}
+
+type AuthorizeHoldRequest struct {
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount"`
+	ExpiresInSeconds     int    `json:"expires_in_seconds,omitempty"`
+}
+
+type CaptureHoldRequest struct {
+	Amount string `json:"amount,omitempty"`
+}
+
+type HoldResponse struct {
+	HoldID           int    `json:"hold_id"`
+	Status           string `json:"status"`
+	Amount           string `json:"amount"`
+	CapturedAmount   string `json:"captured_amount"`
+	AvailableBalance string `json:"available_balance"`
+	TransactionID    int    `json:"transaction_id"`
+}
+
+type CreateFXRateRequest struct {
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+	Rate          string `json:"rate"`
+	EffectiveAt   string `json:"effective_at,omitempty"`
+}
+
+type FXRateResponse struct {
+	FXRateID      int    `json:"fx_rate_id"`
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+	Rate          string `json:"rate"`
+}
+
+type CreateFeeScheduleRequest struct {
+	AccountID        int    `json:"account_id,omitempty"`
+	AccountType      string `json:"account_type,omitempty"`
+	FeeType          string `json:"fee_type"`
+	FlatAmount       string `json:"flat_amount,omitempty"`
+	Percentage       string `json:"percentage,omitempty"`
+	MinFee           string `json:"min_fee,omitempty"`
+	MaxFee           string `json:"max_fee,omitempty"`
+	RevenueAccountID int    `json:"revenue_account_id"`
+}
+
+type FeeScheduleResponse struct {
+	FeeScheduleID int  `json:"fee_schedule_id"`
+	Active        bool `json:"active"`
+}
+
+type SetAccountLimitsRequest struct {
+	MaxTransactionAmount  string `json:"max_transaction_amount,omitempty"`
+	DailyLimit            string `json:"daily_limit,omitempty"`
+	WeeklyLimit           string `json:"weekly_limit,omitempty"`
+	MonthlyLimit          string `json:"monthly_limit,omitempty"`
+	VelocityCount         int    `json:"velocity_count,omitempty"`
+	VelocityWindowSeconds int    `json:"velocity_window_seconds,omitempty"`
+}
+
+type TransferApprovalEvent struct {
+	Event     string `json:"event"`
+	Principal string `json:"principal"`
+	Comment   string `json:"comment"`
+}
+
+type TransferApprovalResponse struct {
+	ApprovalID    int                     `json:"approval_id"`
+	Status        string                  `json:"status"`
+	RequestedBy   string                  `json:"requested_by"`
+	DecidedBy     string                  `json:"decided_by"`
+	TransactionID int                     `json:"transaction_id"`
+	LastErrorCode int                     `json:"last_error_code"`
+	Events        []TransferApprovalEvent `json:"events"`
+}
+
+type SplitPaymentRequest struct {
+	SourceAccountID int               `json:"source_account_id"`
+	Amount          string            `json:"amount,omitempty"`
+	Legs            []SplitLegRequest `json:"legs"`
+	ClientReference string            `json:"client_reference,omitempty"`
+}
+
+type SplitLegRequest struct {
+	DestinationAccountID int    `json:"destination_account_id"`
+	Amount               string `json:"amount,omitempty"`
+	Percentage           string `json:"percentage,omitempty"`
+	Memo                 string `json:"memo,omitempty"`
+}
+
+type SplitPaymentResponse struct {
+	SplitPaymentID int    `json:"split_payment_id"`
+	Status         string `json:"status"`
+	Amount         string `json:"amount"`
+	Allocation     string `json:"allocation"`
+	Fee            string `json:"fee"`
+	Legs           []struct {
+		TransactionID        int    `json:"transaction_id"`
+		DestinationAccountID int    `json:"destination_account_id"`
+		Amount               string `json:"amount"`
+		Memo                 string `json:"memo"`
+	} `json:"legs"`
+	Balances []struct {
+		AccountID int    `json:"account_id"`
+		Balance   string `json:"balance"`
+	} `json:"balances"`
+	ClientReference string `json:"client_reference"`
+}
+
+type AsyncTransferResponse struct {
+	AsyncTransferID    int    `json:"async_transfer_id"`
+	Status             string `json:"status"`
+	Attempts           int    `json:"attempts"`
+	TransactionID      int    `json:"transaction_id"`
+	SourceBalance      string `json:"source_balance"`
+	DestinationBalance string `json:"destination_balance"`
+	LastErrorCode      int    `json:"last_error_code"`
+	CallbackURL        string `json:"callback_url"`
+	CallbackStatus     string `json:"callback_status"`
+}
+
+type CreateSweepRuleRequest struct {
+	RuleType             string `json:"rule_type"`
+	SourceAccountID      int    `json:"source_account_id"`
+	DestinationAccountID int    `json:"destination_account_id"`
+	Threshold            string `json:"threshold"`
+	MinAmount            string `json:"min_amount,omitempty"`
+	Frequency            string `json:"frequency"`
+	IntervalSeconds      int    `json:"interval_seconds,omitempty"`
+	StartAt              string `json:"start_at,omitempty"`
+}
+
+type SweepRuleResponse struct {
+	SweepRuleID       int    `json:"sweep_rule_id"`
+	RuleType          string `json:"rule_type"`
+	Status            string `json:"status"`
+	NextRunAt         string `json:"next_run_at"`
+	LastRunStatus     string `json:"last_run_status"`
+	LastTransactionID int    `json:"last_transaction_id"`
+	LastErrorCode     int    `json:"last_error_code"`
+}
+
+type CreateRiskRuleRequest struct {
+	Name              string `json:"name"`
+	RuleType          string `json:"rule_type"`
+	Action            string `json:"action"`
+	Priority          int    `json:"priority,omitempty"`
+	AccountID         int    `json:"account_id,omitempty"`
+	AccountSide       string `json:"account_side,omitempty"`
+	Amount            string `json:"amount,omitempty"`
+	BlockedAccountIDs []int  `json:"blocked_account_ids,omitempty"`
+	RoundMultiple     string `json:"round_multiple,omitempty"`
+}
+
+type RiskRuleResponse struct {
+	RiskRuleID int    `json:"risk_rule_id"`
+	Name       string `json:"name"`
+	RuleType   string `json:"rule_type"`
+	Action     string `json:"action"`
+	Enabled    bool   `json:"enabled"`
+}
+
+type RiskRuleFlagsResponse struct {
+	Flags []struct {
+		RiskRuleID    int `json:"risk_rule_id"`
+		TransactionID int `json:"transaction_id"`
+	} `json:"flags"`
+}
+
+type PostingRecord struct {
+	PostingID     int    `json:"posting_id"`
+	JournalID     int    `json:"journal_id"`
+	AccountID     int    `json:"account_id"`
+	SystemAccount string `json:"system_account"`
+	Currency      string `json:"currency"`
+	Amount        string `json:"amount"`
+}
+
+type JournalResponse struct {
+	JournalID      int             `json:"journal_id"`
+	Kind           string          `json:"kind"`
+	TransactionID  int             `json:"transaction_id"`
+	SplitPaymentID int             `json:"split_payment_id"`
+	AccountID      int             `json:"account_id"`
+	Postings       []PostingRecord `json:"postings"`
+}
+
+type ListPostingsResponse struct {
+	Postings   []PostingRecord `json:"postings"`
+	NextCursor string          `json:"next_cursor"`
 }
 
 type ErrorResponse struct {
@@ -483,3 +851,2707 @@ func TestCompleteWorkflow(t *testing.T) {
 			accountID, expectedBalances[accountID], accountResp.Balance)
 	}
 }
+
+func postTransactionWithKey(t *testing.T, ts *TestServer, key string, req CreateTransactionRequest) *http.Response {
+	reqBody, err := json.Marshal(req)
+	require.NoError(t, err)
+
+	httpReq, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/transactions/", ts.Server.URL), bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Idempotency-Key", key)
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestIdempotentTransaction(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+
+	accounts := []CreateAccountRequest{
+		{AccountID: baseID + 300, InitialBalance: "1000.00"},
+		{AccountID: baseID + 301, InitialBalance: "0.00"},
+	}
+
+	for _, account := range accounts {
+		reqBody, err := json.Marshal(account)
+		require.NoError(t, err)
+
+		resp, err := http.Post(
+			fmt.Sprintf("%s/accounts", ts.Server.URL),
+			"application/json",
+			bytes.NewBuffer(reqBody),
+		)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	key := fmt.Sprintf("idem-%d", time.Now().UnixNano())
+	transaction := CreateTransactionRequest{
+		SourceAccountID:      baseID + 300,
+		DestinationAccountID: baseID + 301,
+		Amount:              "100.00",
+	}
+
+	var responses []CreateTransactionResponse
+	for i := 0; i < 2; i++ {
+		resp := postTransactionWithKey(t, ts, key, transaction)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var transactionResp CreateTransactionResponse
+		err := json.NewDecoder(resp.Body).Decode(&transactionResp)
+		resp.Body.Close()
+		require.NoError(t, err)
+		responses = append(responses, transactionResp)
+	}
+	assert.Equal(t, responses[0], responses[1], "Replay should return the original response")
+
+	transaction.Amount = "200.00"
+	resp := postTransactionWithKey(t, ts, key, transaction)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	reserved := postTransactionWithKey(t, ts, "scheduled-transfer:1", transaction)
+	defer reserved.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, reserved.StatusCode, "Keys the system derives are reserved")
+
+	accountResp, err := http.Get(fmt.Sprintf("%s/accounts/%d", ts.Server.URL, baseID+300))
+	require.NoError(t, err)
+	defer accountResp.Body.Close()
+
+	var account GetAccountResponse
+	err = json.NewDecoder(accountResp.Body).Decode(&account)
+	require.NoError(t, err)
+	assert.Equal(t, "900", account.Balance, "Transfer should only be applied once")
+}
+
+func TestListTransactions(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+
+	accounts := []CreateAccountRequest{
+		{AccountID: baseID + 400, InitialBalance: "1000.00"},
+		{AccountID: baseID + 401, InitialBalance: "1000.00"},
+	}
+
+	for _, account := range accounts {
+		reqBody, err := json.Marshal(account)
+		require.NoError(t, err)
+
+		resp, err := http.Post(
+			fmt.Sprintf("%s/accounts", ts.Server.URL),
+			"application/json",
+			bytes.NewBuffer(reqBody),
+		)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	transactions := []CreateTransactionRequest{
+		{SourceAccountID: baseID + 400, DestinationAccountID: baseID + 401, Amount: "10.00"},
+		{SourceAccountID: baseID + 401, DestinationAccountID: baseID + 400, Amount: "20.00"},
+		{SourceAccountID: baseID + 400, DestinationAccountID: baseID + 401, Amount: "30.00"},
+	}
+
+	for _, transaction := range transactions {
+		reqBody, err := json.Marshal(transaction)
+		require.NoError(t, err)
+
+		resp, err := http.Post(
+			fmt.Sprintf("%s/transactions", ts.Server.URL),
+			"application/json",
+			bytes.NewBuffer(reqBody),
+		)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	listTransactions := func(query string) ListTransactionsResponse {
+		resp, err := http.Get(fmt.Sprintf("%s/transactions/?%s", ts.Server.URL, query))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var listResp ListTransactionsResponse
+		err = json.NewDecoder(resp.Body).Decode(&listResp)
+		require.NoError(t, err)
+		return listResp
+	}
+
+	firstPage := listTransactions(fmt.Sprintf("account_id=%d&limit=2", baseID+400))
+	require.Len(t, firstPage.Transfers, 2)
+	assert.Equal(t, "30", firstPage.Transfers[0].Amount)
+	assert.Equal(t, "20", firstPage.Transfers[1].Amount)
+	require.NotEmpty(t, firstPage.NextCursor)
+
+	secondPage := listTransactions(fmt.Sprintf("account_id=%d&limit=2&cursor=%s", baseID+400, firstPage.NextCursor))
+	require.Len(t, secondPage.Transfers, 1)
+	assert.Equal(t, "10", secondPage.Transfers[0].Amount)
+	assert.Empty(t, secondPage.NextCursor)
+
+	bySource := listTransactions(fmt.Sprintf("source_account_id=%d&min_amount=15", baseID+400))
+	require.Len(t, bySource.Transfers, 1)
+	assert.Equal(t, "30", bySource.Transfers[0].Amount)
+
+	byDestination := listTransactions(fmt.Sprintf("destination_account_id=%d", baseID+400))
+	require.Len(t, byDestination.Transfers, 1)
+	assert.Equal(t, baseID+401, byDestination.Transfers[0].SourceAccountID)
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?cursor=not-a-cursor", ts.Server.URL))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetTransaction(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+
+	accounts := []CreateAccountRequest{
+		{AccountID: baseID + 500, InitialBalance: "100.00"},
+		{AccountID: baseID + 501, InitialBalance: "0.00"},
+	}
+
+	for _, account := range accounts {
+		reqBody, err := json.Marshal(account)
+		require.NoError(t, err)
+
+		resp, err := http.Post(
+			fmt.Sprintf("%s/accounts", ts.Server.URL),
+			"application/json",
+			bytes.NewBuffer(reqBody),
+		)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	reqBody, err := json.Marshal(CreateTransactionRequest{
+		SourceAccountID:      baseID + 500,
+		DestinationAccountID: baseID + 501,
+		Amount:              "12.50",
+	})
+	require.NoError(t, err)
+
+	resp, err := http.Post(
+		fmt.Sprintf("%s/transactions", ts.Server.URL),
+		"application/json",
+		bytes.NewBuffer(reqBody),
+	)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var transactionResp CreateTransactionResponse
+	err = json.NewDecoder(resp.Body).Decode(&transactionResp)
+	require.NoError(t, err)
+
+	getResp, err := http.Get(fmt.Sprintf("%s/transactions/%d", ts.Server.URL, transactionResp.TransactionID))
+	require.NoError(t, err)
+	defer getResp.Body.Close()
+	require.Equal(t, http.StatusOK, getResp.StatusCode)
+
+	var transfer TransferRecord
+	err = json.NewDecoder(getResp.Body).Decode(&transfer)
+	require.NoError(t, err)
+	assert.Equal(t, transactionResp.TransactionID, transfer.ID)
+	assert.Equal(t, baseID+500, transfer.SourceAccountID)
+	assert.Equal(t, baseID+501, transfer.DestinationAccountID)
+	assert.Equal(t, "12.5", transfer.Amount)
+
+	createdAt, err := time.Parse(time.RFC3339, transfer.CreatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, transactionResp.CreatedAt, createdAt.Format(time.RFC3339))
+
+	tests := []struct {
+		name           string
+		transactionID  string
+		expectedStatus int
+	}{
+		{name: "Non-existent transaction", transactionID: "999999999", expectedStatus: http.StatusNotFound},
+		{name: "Invalid transaction ID", transactionID: "abc", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(fmt.Sprintf("%s/transactions/%s", ts.Server.URL, tt.transactionID))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+
+			var errorResp ErrorResponse
+			err = json.NewDecoder(resp.Body).Decode(&errorResp)
+			require.NoError(t, err)
+			assert.NotEqual(t, 0, errorResp.Code)
+		})
+	}
+}
+
+func createTestAccounts(t *testing.T, ts *TestServer, accounts ...CreateAccountRequest) {
+	for _, account := range accounts {
+		reqBody, err := json.Marshal(account)
+		require.NoError(t, err)
+
+		resp, err := http.Post(
+			fmt.Sprintf("%s/accounts", ts.Server.URL),
+			"application/json",
+			bytes.NewBuffer(reqBody),
+		)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, "Account creation failed for account %d", account.AccountID)
+	}
+}
+
+func postJSON(t *testing.T, url string, body any, out any) int {
+	reqBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode == http.StatusOK {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		require.NoError(t, err)
+	}
+	return resp.StatusCode
+}
+
+func putJSON(t *testing.T, url string, body any) int {
+	reqBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func getAccountBalance(t *testing.T, ts *TestServer, accountID int) string {
+	resp, err := http.Get(fmt.Sprintf("%s/accounts/%d", ts.Server.URL, accountID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var accountResp GetAccountResponse
+	err = json.NewDecoder(resp.Body).Decode(&accountResp)
+	require.NoError(t, err)
+	return accountResp.Balance
+}
+
+func TestReverseTransaction(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+600, baseID+601
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	var original CreateTransactionResponse
+	status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "60.00",
+	}, &original)
+	require.Equal(t, http.StatusOK, status)
+
+	reverseURL := fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, original.TransactionID)
+
+	var partial CreateTransactionResponse
+	status = postJSON(t, reverseURL, ReverseTransactionRequest{Amount: "20.00"}, &partial)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, original.TransactionID, partial.ReversalOf)
+	assert.Equal(t, "40", partial.SourceBalance)
+	assert.Equal(t, "60", partial.DestinationBalance)
+
+	var full CreateTransactionResponse
+	status = postJSON(t, reverseURL, ReverseTransactionRequest{}, &full)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "40", full.Amount)
+
+	assert.Equal(t, "100", getAccountBalance(t, ts, sourceID))
+	assert.Equal(t, "0", getAccountBalance(t, ts, destID))
+
+	status = postJSON(t, reverseURL, ReverseTransactionRequest{Amount: "1.00"}, nil)
+	assert.Equal(t, http.StatusConflict, status, "Fully reversed transfer must not be reversed again")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, partial.TransactionID), ReverseTransactionRequest{}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "A reversal must not be reversed")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/999999999/reverse", ts.Server.URL), ReverseTransactionRequest{}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	var second CreateTransactionResponse
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "50.00",
+	}, &second)
+	require.Equal(t, http.StatusOK, status)
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      destID,
+		DestinationAccountID: sourceID,
+		Amount:               "30.00",
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, second.TransactionID), ReverseTransactionRequest{}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Reversal must respect the destination's current balance")
+}
+
+func getTransaction(t *testing.T, ts *TestServer, transactionID int) TransferRecord {
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/%d", ts.Server.URL, transactionID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var transfer TransferRecord
+	err = json.NewDecoder(resp.Body).Decode(&transfer)
+	require.NoError(t, err)
+	return transfer
+}
+
+func TestTransactionStatusLifecycle(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+700, baseID+701
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "500.00",
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?source_account_id=%d&status=FAILED", ts.Server.URL, sourceID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var failed ListTransactionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&failed)
+	require.NoError(t, err)
+	require.Len(t, failed.Transfers, 1, "Declined transfer should be recorded")
+	assert.Equal(t, "FAILED", failed.Transfers[0].Status)
+	assert.Equal(t, 9, failed.Transfers[0].FailureCode)
+	assert.Equal(t, "100", getAccountBalance(t, ts, sourceID), "Declined transfer must not move money")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, failed.Transfers[0].ID), ReverseTransactionRequest{}, nil)
+	assert.Equal(t, http.StatusConflict, status, "A failed transfer cannot be reversed")
+
+	var completed CreateTransactionResponse
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "40.00",
+	}, &completed)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "COMPLETED", completed.Status)
+	assert.Equal(t, "COMPLETED", getTransaction(t, ts, completed.TransactionID).Status)
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, completed.TransactionID), ReverseTransactionRequest{Amount: "10.00"}, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "COMPLETED", getTransaction(t, ts, completed.TransactionID).Status, "Partially reversed transfer stays COMPLETED")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, completed.TransactionID), ReverseTransactionRequest{}, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "REVERSED", getTransaction(t, ts, completed.TransactionID).Status)
+}
+
+func getAccount(t *testing.T, ts *TestServer, accountID int) GetAccountResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/accounts/%d", ts.Server.URL, accountID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var accountResp GetAccountResponse
+	err = json.NewDecoder(resp.Body).Decode(&accountResp)
+	require.NoError(t, err)
+	return accountResp
+}
+
+func TestHoldLifecycle(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+800, baseID+801
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	holdsURL := fmt.Sprintf("%s/holds/", ts.Server.URL)
+
+	var hold HoldResponse
+	status := postJSON(t, holdsURL, AuthorizeHoldRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "60.00",
+	}, &hold)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ACTIVE", hold.Status)
+	assert.Equal(t, "40", hold.AvailableBalance)
+
+	account := getAccount(t, ts, sourceID)
+	assert.Equal(t, "100", account.LedgerBalance)
+	assert.Equal(t, "40", account.AvailableBalance)
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "50.00",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Transfers must only spend the available balance")
+
+	status = postJSON(t, holdsURL, AuthorizeHoldRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "50.00",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Holds must only reserve the available balance")
+
+	var captured HoldResponse
+	status = postJSON(t, fmt.Sprintf("%s/holds/%d/capture", ts.Server.URL, hold.HoldID), CaptureHoldRequest{Amount: "30.00"}, &captured)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "CAPTURED", captured.Status)
+	assert.Equal(t, "30", captured.CapturedAmount)
+	assert.NotZero(t, captured.TransactionID)
+
+	account = getAccount(t, ts, sourceID)
+	assert.Equal(t, "70", account.LedgerBalance)
+	assert.Equal(t, "70", account.AvailableBalance, "Uncaptured remainder should be released")
+	assert.Equal(t, "30", getAccountBalance(t, ts, destID))
+
+	status = postJSON(t, fmt.Sprintf("%s/holds/%d/capture", ts.Server.URL, hold.HoldID), CaptureHoldRequest{}, nil)
+	assert.Equal(t, http.StatusConflict, status, "A captured hold cannot be captured again")
+
+	var voidable HoldResponse
+	status = postJSON(t, holdsURL, AuthorizeHoldRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "20.00",
+	}, &voidable)
+	require.Equal(t, http.StatusOK, status)
+
+	var voided HoldResponse
+	status = postJSON(t, fmt.Sprintf("%s/holds/%d/void", ts.Server.URL, voidable.HoldID), nil, &voided)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "VOIDED", voided.Status)
+	assert.Equal(t, "70", getAccount(t, ts, sourceID).AvailableBalance)
+
+	var expiring HoldResponse
+	status = postJSON(t, holdsURL, AuthorizeHoldRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		ExpiresInSeconds:     1,
+	}, &expiring)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "60", getAccount(t, ts, sourceID).AvailableBalance)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	assert.Equal(t, "70", getAccount(t, ts, sourceID).AvailableBalance, "Expired holds must not reserve funds")
+	status = postJSON(t, fmt.Sprintf("%s/holds/%d/capture", ts.Server.URL, expiring.HoldID), CaptureHoldRequest{}, nil)
+	assert.Equal(t, http.StatusConflict, status, "An expired hold cannot be captured")
+}
+
+func TestBatchTransaction(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	accountA, accountB, accountC := baseID+900, baseID+901, baseID+902
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: accountA, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: accountB, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: accountC, InitialBalance: "0.00"},
+	)
+
+	batchURL := fmt.Sprintf("%s/transactions/batch", ts.Server.URL)
+
+	var batch BatchTransactionResponse
+	status := postJSON(t, batchURL, BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: accountA, DestinationAccountID: accountB, Amount: "50.00"},
+		{SourceAccountID: accountB, DestinationAccountID: accountC, Amount: "30.00"},
+		{SourceAccountID: accountA, DestinationAccountID: accountC, Amount: "10.00"},
+	}}, &batch)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotZero(t, batch.BatchID)
+	assert.Equal(t, "COMPLETED", batch.Status)
+	assert.Len(t, batch.Legs, 3)
+	require.Len(t, batch.Balances, 3)
+	assert.Equal(t, accountA, batch.Balances[0].AccountID)
+	assert.Equal(t, "40", batch.Balances[0].Balance)
+
+	assert.Equal(t, "40", getAccountBalance(t, ts, accountA))
+	assert.Equal(t, "20", getAccountBalance(t, ts, accountB))
+	assert.Equal(t, "40", getAccountBalance(t, ts, accountC))
+
+	status = postJSON(t, batchURL, BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: accountA, DestinationAccountID: accountB, Amount: "10.00"},
+		{SourceAccountID: accountB, DestinationAccountID: accountC, Amount: "1000.00"},
+	}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Batch with an unfunded leg must fail")
+
+	status = postJSON(t, batchURL, BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: accountA, DestinationAccountID: accountB, Amount: "10.00"},
+		{SourceAccountID: accountB, DestinationAccountID: baseID + 999, Amount: "1.00"},
+	}}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	assert.Equal(t, "40", getAccountBalance(t, ts, accountA), "Failed batches must not apply any leg")
+	assert.Equal(t, "20", getAccountBalance(t, ts, accountB), "Failed batches must not apply any leg")
+
+	status = postJSON(t, batchURL, BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: accountA, DestinationAccountID: accountB, Amount: "10.00"},
+		{SourceAccountID: accountA, DestinationAccountID: accountC, Amount: "5.00",
+			ExecuteAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)},
+	}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Batch legs cannot be scheduled")
+
+	status = postJSON(t, batchURL, BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: accountA, DestinationAccountID: accountB, Amount: "10.00",
+			Async: true, CallbackURL: "https://example.com/callback"},
+	}}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Batch legs cannot be queued")
+
+	assert.Equal(t, "40", getAccountBalance(t, ts, accountA), "Rejected batches must not apply any leg")
+	assert.Equal(t, "20", getAccountBalance(t, ts, accountB), "Rejected batches must not apply any leg")
+	assert.Equal(t, "40", getAccountBalance(t, ts, accountC), "Rejected batches must not apply any leg")
+
+	status = postJSON(t, batchURL, BatchTransactionRequest{}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Empty batch must be rejected")
+}
+
+func TestScheduledTransaction(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+1000, baseID+1001
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	scheduleReq := CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "25.00",
+		ExecuteAt:            time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}
+	idempotencyKey := fmt.Sprintf("scheduled-test-%d", time.Now().UnixNano())
+
+	resp := postTransactionWithKey(t, ts, idempotencyKey, scheduleReq)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var scheduled CreateTransactionResponse
+	err := json.NewDecoder(resp.Body).Decode(&scheduled)
+	require.NoError(t, err)
+	assert.Equal(t, "PENDING", scheduled.Status)
+	assert.NotZero(t, scheduled.ScheduledTransferID)
+	assert.Zero(t, scheduled.TransactionID)
+	assertSameInstant(t, scheduleReq.ExecuteAt, scheduled.ExecuteAt, "Schedule should keep execute_at")
+	assert.Equal(t, "100", getAccountBalance(t, ts, sourceID), "Scheduled transfer must not move money yet")
+
+	replay := postTransactionWithKey(t, ts, idempotencyKey, scheduleReq)
+	defer replay.Body.Close()
+	require.Equal(t, http.StatusOK, replay.StatusCode)
+
+	var replayed CreateTransactionResponse
+	err = json.NewDecoder(replay.Body).Decode(&replayed)
+	require.NoError(t, err)
+	assert.Equal(t, scheduled.ScheduledTransferID, replayed.ScheduledTransferID, "Replay should return the original schedule")
+
+	listResp, err := http.Get(fmt.Sprintf("%s/transactions/scheduled?source_account_id=%d&status=PENDING", ts.Server.URL, sourceID))
+	require.NoError(t, err)
+	defer listResp.Body.Close()
+	require.Equal(t, http.StatusOK, listResp.StatusCode)
+
+	var list ListScheduledTransactionsResponse
+	err = json.NewDecoder(listResp.Body).Decode(&list)
+	require.NoError(t, err)
+	require.Len(t, list.ScheduledTransfers, 1)
+	assert.Equal(t, scheduled.ScheduledTransferID, list.ScheduledTransfers[0].ScheduledTransferID)
+	assert.Equal(t, "25", list.ScheduledTransfers[0].Amount)
+
+	cancelURL := fmt.Sprintf("%s/transactions/scheduled/%d/cancel", ts.Server.URL, scheduled.ScheduledTransferID)
+
+	var cancelled ScheduledTransferRecord
+	status := postJSON(t, cancelURL, nil, &cancelled)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "CANCELLED", cancelled.Status)
+
+	status = postJSON(t, cancelURL, nil, nil)
+	assert.Equal(t, http.StatusConflict, status, "A cancelled transfer cannot be cancelled again")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/scheduled/%d/cancel", ts.Server.URL, 999999999), nil, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: baseID + 1999,
+		Amount:               "10.00",
+		ExecuteAt:            time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status, "Scheduling to an unknown account should fail")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		ExecuteAt:            "tomorrow",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	var immediate CreateTransactionResponse
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		ExecuteAt:            time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+	}, &immediate)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "COMPLETED", immediate.Status, "A past execute_at runs immediately")
+	assert.Equal(t, "90", getAccountBalance(t, ts, sourceID))
+}
+
+func assertSameInstant(t *testing.T, expected, actual string, msg string) {
+	expectedTime, err := time.Parse(time.RFC3339, expected)
+	require.NoError(t, err)
+	actualTime, err := time.Parse(time.RFC3339, actual)
+	require.NoError(t, err)
+	assert.True(t, expectedTime.Equal(actualTime), "%s: expected %s, got %s", msg, expected, actual)
+}
+
+func getStandingOrder(t *testing.T, ts *TestServer, orderID int) StandingOrderResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/standing-orders/%d", ts.Server.URL, orderID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var order StandingOrderResponse
+	err = json.NewDecoder(resp.Body).Decode(&order)
+	require.NoError(t, err)
+	return order
+}
+
+func getStandingOrderRuns(t *testing.T, ts *TestServer, orderID int) StandingOrderRunsResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/standing-orders/%d/runs", ts.Server.URL, orderID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var runs StandingOrderRunsResponse
+	err = json.NewDecoder(resp.Body).Decode(&runs)
+	require.NoError(t, err)
+	return runs
+}
+
+func TestStandingOrderLifecycle(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+1100, baseID+1101
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	ordersURL := fmt.Sprintf("%s/standing-orders/", ts.Server.URL)
+	startAt := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	var daily StandingOrderResponse
+	status := postJSON(t, ordersURL, CreateStandingOrderRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "30.00",
+		Frequency:            "DAILY",
+		StartAt:              startAt,
+		MaxRuns:              1,
+	}, &daily)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ACTIVE", daily.Status)
+	assertSameInstant(t, startAt, daily.NextRunAt, "The first run is due at the start")
+
+	var retrying StandingOrderResponse
+	status = postJSON(t, ordersURL, CreateStandingOrderRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "1000.00",
+		Frequency:            "INTERVAL",
+		IntervalSeconds:      3600,
+		StartAt:              startAt,
+		FailurePolicy:        "RETRY",
+		MaxRetries:           2,
+	}, &retrying)
+	require.Equal(t, http.StatusOK, status)
+
+	_, err := ts.Config.StandingOrderRepository.RunDueStandingOrders(context.Background(), 1000)
+	require.NoError(t, err)
+
+	daily = getStandingOrder(t, ts, daily.StandingOrderID)
+	assert.Equal(t, "COMPLETED", daily.Status, "Order should complete after max_runs transfers")
+	assert.Equal(t, 1, daily.ExecutedRuns)
+	assert.Equal(t, "70", getAccountBalance(t, ts, sourceID))
+	assert.Equal(t, "30", getAccountBalance(t, ts, destID))
+
+	runs := getStandingOrderRuns(t, ts, daily.StandingOrderID)
+	require.Len(t, runs.Runs, 1)
+	assert.Equal(t, "SUCCEEDED", runs.Runs[0].Status)
+	assert.NotZero(t, runs.Runs[0].TransactionID)
+
+	retrying = getStandingOrder(t, ts, retrying.StandingOrderID)
+	assert.Equal(t, "ACTIVE", retrying.Status)
+	assert.Equal(t, 1, retrying.RetryCount, "Declined run should be retried")
+	assertSameInstant(t, startAt, retrying.NextRunAt, "A retry does not move the occurrence")
+
+	runs = getStandingOrderRuns(t, ts, retrying.StandingOrderID)
+	require.Len(t, runs.Runs, 1)
+	assert.Equal(t, "FAILED", runs.Runs[0].Status)
+	assert.Equal(t, 9, runs.Runs[0].ErrorCode)
+
+	cancelURL := fmt.Sprintf("%s/standing-orders/%d/cancel", ts.Server.URL, retrying.StandingOrderID)
+	var cancelled StandingOrderResponse
+	status = postJSON(t, cancelURL, nil, &cancelled)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "CANCELLED", cancelled.Status)
+
+	status = postJSON(t, cancelURL, nil, nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	status = postJSON(t, ordersURL, CreateStandingOrderRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		Frequency:            "WEEKLY",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "WEEKLY orders need day_of_week")
+
+	status = postJSON(t, ordersURL, CreateStandingOrderRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		Frequency:            "HOURLY",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	resp, err := http.Get(fmt.Sprintf("%s/standing-orders/%d", ts.Server.URL, 999999999))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestMultiCurrencyTransfer(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	usdID, usd2ID, eurID, nokID := baseID+1200, baseID+1201, baseID+1202, baseID+1203
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: usdID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: usd2ID, InitialBalance: "0.00", Currency: "USD"},
+		CreateAccountRequest{AccountID: eurID, InitialBalance: "0.00", Currency: "EUR"},
+		CreateAccountRequest{AccountID: nokID, InitialBalance: "0.00", Currency: "NOK"},
+	)
+	assert.Equal(t, "USD", getAccount(t, ts, usdID).Currency, "Currency should default to USD")
+	assert.Equal(t, "EUR", getAccount(t, ts, eurID).Currency)
+
+	status := postJSON(t, fmt.Sprintf("%s/accounts", ts.Server.URL), CreateAccountRequest{
+		AccountID:      baseID + 1204,
+		InitialBalance: "0.00",
+		Currency:       "XYZ",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Unknown currencies should be rejected")
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+
+	var sameCurrency CreateTransactionResponse
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      usdID,
+		DestinationAccountID: usd2ID,
+		Amount:               "10.00",
+	}, &sameCurrency)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "10", sameCurrency.DestinationAmount)
+	assert.Zero(t, sameCurrency.FXRateID, "Same-currency transfers need no rate")
+
+	var staged FXRateResponse
+	status = postJSON(t, fmt.Sprintf("%s/fx-rates/", ts.Server.URL), CreateFXRateRequest{
+		BaseCurrency:  "USD",
+		QuoteCurrency: "EUR",
+		Rate:          "100",
+		EffectiveAt:   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}, &staged)
+	require.Equal(t, http.StatusOK, status)
+
+	var rate FXRateResponse
+	status = postJSON(t, fmt.Sprintf("%s/fx-rates/", ts.Server.URL), CreateFXRateRequest{
+		BaseCurrency:  "USD",
+		QuoteCurrency: "EUR",
+		Rate:          "0.9",
+	}, &rate)
+	require.Equal(t, http.StatusOK, status)
+
+	var converted CreateTransactionResponse
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      usdID,
+		DestinationAccountID: eurID,
+		Amount:               "50.00",
+	}, &converted)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "USD", converted.SourceCurrency)
+	assert.Equal(t, "EUR", converted.DestinationCurrency)
+	assert.Equal(t, "45", converted.DestinationAmount, "A future-dated rate must not be used yet")
+	assert.Equal(t, rate.FXRateID, converted.FXRateID)
+	assert.Equal(t, "40", getAccountBalance(t, ts, usdID))
+	assert.Equal(t, "45", getAccountBalance(t, ts, eurID))
+
+	record := getTransaction(t, ts, converted.TransactionID)
+	assert.Equal(t, "50", record.Amount)
+	assert.Equal(t, "45", record.DestinationAmount)
+	assert.Equal(t, rate.FXRateID, record.FXRateID)
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      usdID,
+		DestinationAccountID: nokID,
+		Amount:               "10.00",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Transfers without a rate for the pair should be rejected")
+	assert.Equal(t, "40", getAccountBalance(t, ts, usdID))
+
+	// Newer rates do not change how an existing transfer is reversed
+	status = postJSON(t, fmt.Sprintf("%s/fx-rates/", ts.Server.URL), CreateFXRateRequest{
+		BaseCurrency:  "USD",
+		QuoteCurrency: "EUR",
+		Rate:          "0.5",
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	reverseURL := fmt.Sprintf("%s/transactions/%d/reverse", ts.Server.URL, converted.TransactionID)
+
+	var partial CreateTransactionResponse
+	status = postJSON(t, reverseURL, ReverseTransactionRequest{Amount: "20.00"}, &partial)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "18", partial.Amount, "Reversal should debit the destination at the original rate")
+	assert.Equal(t, "20", partial.DestinationAmount)
+
+	var full CreateTransactionResponse
+	status = postJSON(t, reverseURL, ReverseTransactionRequest{}, &full)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "27", full.Amount)
+	assert.Equal(t, "30", full.DestinationAmount)
+
+	assert.Equal(t, "90", getAccountBalance(t, ts, usdID))
+	assert.Equal(t, "0", getAccountBalance(t, ts, eurID))
+
+	resp, err := http.Get(fmt.Sprintf("%s/fx-rates/%d", ts.Server.URL, rate.FXRateID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var fetched FXRateResponse
+	err = json.NewDecoder(resp.Body).Decode(&fetched)
+	require.NoError(t, err)
+	assert.Equal(t, "0.9", fetched.Rate)
+}
+
+func TestTransferFees(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, overrideID, destID, revenueID := baseID+1300, baseID+1301, baseID+1302, baseID+1303
+	accountType := fmt.Sprintf("FEE_TEST_%d", baseID)
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "1000.00", AccountType: accountType},
+		CreateAccountRequest{AccountID: overrideID, InitialBalance: "100.00", AccountType: accountType},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: revenueID, InitialBalance: "0.00"},
+	)
+	assert.Equal(t, accountType, getAccount(t, ts, sourceID).AccountType)
+	assert.Equal(t, "STANDARD", getAccount(t, ts, destID).AccountType, "Account type should default to STANDARD")
+
+	schedulesURL := fmt.Sprintf("%s/fee-schedules/", ts.Server.URL)
+
+	var typeSchedule FeeScheduleResponse
+	status := postJSON(t, schedulesURL, CreateFeeScheduleRequest{
+		AccountType:      accountType,
+		FeeType:          "PERCENTAGE",
+		Percentage:       "1",
+		MinFee:           "0.50",
+		MaxFee:           "2.00",
+		RevenueAccountID: revenueID,
+	}, &typeSchedule)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, typeSchedule.Active)
+
+	var accountSchedule FeeScheduleResponse
+	status = postJSON(t, schedulesURL, CreateFeeScheduleRequest{
+		AccountID:        overrideID,
+		FeeType:          "FLAT",
+		FlatAmount:       "3.00",
+		RevenueAccountID: revenueID,
+	}, &accountSchedule)
+	require.Equal(t, http.StatusOK, status)
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	transfer := func(sourceID int, amount string) CreateTransactionResponse {
+		var resp CreateTransactionResponse
+		status := postJSON(t, transactionsURL, CreateTransactionRequest{
+			SourceAccountID:      sourceID,
+			DestinationAccountID: destID,
+			Amount:               amount,
+		}, &resp)
+		require.Equal(t, http.StatusOK, status)
+		return resp
+	}
+
+	small := transfer(sourceID, "10.00")
+	assert.Equal(t, "0.5", small.Fee, "Minimum fee should apply")
+	assert.Equal(t, revenueID, small.FeeAccountID)
+	assert.Equal(t, "989.5", small.SourceBalance, "Fee is debited on top of the amount")
+	assert.Equal(t, "10", small.DestinationBalance)
+
+	medium := transfer(sourceID, "150.00")
+	assert.Equal(t, "1.5", medium.Fee)
+
+	large := transfer(sourceID, "500.00")
+	assert.Equal(t, "2", large.Fee, "Maximum fee should cap the charge")
+
+	override := transfer(overrideID, "10.00")
+	assert.Equal(t, "3", override.Fee, "The account's own schedule takes precedence over its type's")
+
+	assert.Equal(t, "7", getAccountBalance(t, ts, revenueID))
+
+	record := getTransaction(t, ts, small.TransactionID)
+	assert.Equal(t, "0.5", record.Fee)
+	assert.Equal(t, typeSchedule.FeeScheduleID, record.FeeScheduleID)
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      overrideID,
+		DestinationAccountID: destID,
+		Amount:               "90.00",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Funds must cover the amount and the fee")
+	assert.Equal(t, "87", getAccountBalance(t, ts, overrideID))
+
+	unpriced := CreateTransactionResponse{}
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      destID,
+		DestinationAccountID: sourceID,
+		Amount:               "10.00",
+	}, &unpriced)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "0", unpriced.Fee, "Accounts without a schedule pay no fee")
+
+	deactivateURL := fmt.Sprintf("%s/fee-schedules/%d/deactivate", ts.Server.URL, accountSchedule.FeeScheduleID)
+	var deactivated FeeScheduleResponse
+	status = postJSON(t, deactivateURL, nil, &deactivated)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, deactivated.Active)
+
+	status = postJSON(t, deactivateURL, nil, nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	fallback := transfer(overrideID, "10.00")
+	assert.Equal(t, "0.5", fallback.Fee, "Without its own schedule the account falls back to its type's")
+
+	status = postJSON(t, schedulesURL, CreateFeeScheduleRequest{
+		AccountID:        sourceID,
+		AccountType:      accountType,
+		FeeType:          "FLAT",
+		FlatAmount:       "1.00",
+		RevenueAccountID: revenueID,
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "A schedule applies to an account or a type, not both")
+
+	status = postJSON(t, schedulesURL, CreateFeeScheduleRequest{
+		AccountType:      accountType,
+		FeeType:          "PERCENTAGE",
+		Percentage:       "1",
+		MinFee:           "5",
+		MaxFee:           "1",
+		RevenueAccountID: revenueID,
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestAccountLimits(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID, velocityID := baseID+1400, baseID+1401, baseID+1402
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "1000.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: velocityID, InitialBalance: "1000.00"},
+	)
+
+	status := putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, sourceID), SetAccountLimitsRequest{
+		MaxTransactionAmount: "100.00",
+		DailyLimit:           "150.00",
+		MonthlyLimit:         "1000.00",
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, velocityID), SetAccountLimitsRequest{
+		VelocityCount:         2,
+		VelocityWindowSeconds: 3600,
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, destID), SetAccountLimitsRequest{
+		VelocityCount: 2,
+	})
+	assert.Equal(t, http.StatusBadRequest, status, "A velocity count needs a window")
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/999999999/limits", ts.Server.URL), SetAccountLimitsRequest{DailyLimit: "1"})
+	assert.Equal(t, http.StatusNotFound, status)
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	postTransfer := func(sourceID int, amount string) (int, ErrorResponse) {
+		reqBody, err := json.Marshal(CreateTransactionRequest{
+			SourceAccountID:      sourceID,
+			DestinationAccountID: destID,
+			Amount:               amount,
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Post(transactionsURL, "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var errResp ErrorResponse
+		if resp.StatusCode != http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+		}
+		return resp.StatusCode, errResp
+	}
+
+	status, errResp := postTransfer(sourceID, "150.00")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 34, errResp.Code, "Per-transaction maximum should apply")
+
+	status, _ = postTransfer(sourceID, "100.00")
+	require.Equal(t, http.StatusOK, status)
+
+	status, errResp = postTransfer(sourceID, "60.00")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 35, errResp.Code, "Daily cap should apply")
+	assert.Contains(t, errResp.Message, "remaining allowance is 50")
+
+	status, _ = postTransfer(sourceID, "50.00")
+	require.Equal(t, http.StatusOK, status, "Transfers up to the remaining allowance are allowed")
+	assert.Equal(t, "850", getAccountBalance(t, ts, sourceID))
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?source_account_id=%d&status=FAILED", ts.Server.URL, sourceID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var failed ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&failed))
+	assert.Len(t, failed.Transfers, 2, "Limit breaches should be recorded as declined transfers")
+
+	for i := 0; i < 2; i++ {
+		status, _ = postTransfer(velocityID, "1.00")
+		require.Equal(t, http.StatusOK, status)
+	}
+	status, errResp = postTransfer(velocityID, "1.00")
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, 38, errResp.Code)
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, velocityID), SetAccountLimitsRequest{})
+	require.Equal(t, http.StatusOK, status)
+
+	status, _ = postTransfer(velocityID, "1.00")
+	assert.Equal(t, http.StatusOK, status, "Clearing the limits lifts them")
+}
+
+// isOverdrawnListed pages through GET /accounts/overdrawn looking for accountID
+func isOverdrawnListed(t *testing.T, ts *TestServer, accountID int) bool {
+	cursor := ""
+	for {
+		resp, err := http.Get(fmt.Sprintf("%s/accounts/overdrawn?limit=200&cursor=%s", ts.Server.URL, cursor))
+		require.NoError(t, err)
+
+		var page ListOverdrawnAccountsResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		for _, account := range page.Accounts {
+			if account.AccountID == accountID {
+				assert.True(t, strings.HasPrefix(account.Balance, "-"), "Listed accounts should have a negative balance")
+				return true
+			}
+		}
+		if page.NextCursor == "" {
+			return false
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func TestOverdraft(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+1500, baseID+1501
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00", OverdraftLimit: "50.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	account := getAccount(t, ts, sourceID)
+	assert.Equal(t, "50", account.OverdraftLimit)
+	assert.Equal(t, "50", account.AvailableCredit, "An account in credit has its whole overdraft available")
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	postTransfer := func(sourceID, destID int, amount string) (int, ErrorResponse) {
+		reqBody, err := json.Marshal(CreateTransactionRequest{
+			SourceAccountID:      sourceID,
+			DestinationAccountID: destID,
+			Amount:               amount,
+		})
+		require.NoError(t, err)
+
+		resp, err := http.Post(transactionsURL, "application/json", bytes.NewBuffer(reqBody))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var errResp ErrorResponse
+		if resp.StatusCode != http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+		}
+		return resp.StatusCode, errResp
+	}
+
+	status, _ := postTransfer(sourceID, destID, "120.00")
+	require.Equal(t, http.StatusOK, status, "Transfers may draw on the overdraft")
+
+	account = getAccount(t, ts, sourceID)
+	assert.Equal(t, "-20", account.Balance)
+	assert.Equal(t, "30", account.AvailableCredit)
+
+	status, errResp := postTransfer(sourceID, destID, "30.01")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code, "Transfers beyond the overdraft limit should be declined")
+
+	assert.True(t, isOverdrawnListed(t, ts, sourceID))
+	assert.False(t, isOverdrawnListed(t, ts, destID))
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/%d/overdraft", ts.Server.URL, sourceID), map[string]string{"overdraft_limit": "-5"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/%d/overdraft", ts.Server.URL, sourceID), map[string]string{"overdraft_limit": "10"})
+	require.Equal(t, http.StatusOK, status, "The limit may be lowered below what the account owes")
+
+	account = getAccount(t, ts, sourceID)
+	assert.Equal(t, "0", account.AvailableCredit)
+
+	status, errResp = postTransfer(sourceID, destID, "1.00")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code)
+
+	status, _ = postTransfer(destID, sourceID, "20.00")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, isOverdrawnListed(t, ts, sourceID), "Repaid accounts are no longer overdrawn")
+}
+
+// postAsPrincipal posts body with the X-Principal header set, when principal
+// is not empty, and decodes the response into out or the error response.
+func postAsPrincipal(t *testing.T, url, principal string, body any, out any) (int, ErrorResponse) {
+	reqBody, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(reqBody))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if principal != "" {
+		req.Header.Set("X-Principal", principal)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var errResp ErrorResponse
+	if resp.StatusCode != http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	} else if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode, errResp
+}
+
+func getApproval(t *testing.T, ts *TestServer, approvalID int) TransferApprovalResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/approvals/%d", ts.Server.URL, approvalID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var approval TransferApprovalResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&approval))
+	return approval
+}
+
+func TestTransferApproval(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID, poorID := baseID+1600, baseID+1601, baseID+1602
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "500000.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: poorID, InitialBalance: "100.00"},
+	)
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	approvalURL := func(approvalID int, decision string) string {
+		return fmt.Sprintf("%s/transactions/approvals/%d/%s", ts.Server.URL, approvalID, decision)
+	}
+	large := CreateTransactionRequest{SourceAccountID: sourceID, DestinationAccountID: destID, Amount: "150000.00"}
+
+	status, errResp := postAsPrincipal(t, transactionsURL, "", large, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, 42, errResp.Code, "Transfers needing approval must name their maker")
+
+	var pending CreateTransactionResponse
+	status, _ = postAsPrincipal(t, transactionsURL, "alice", large, &pending)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "PENDING", pending.Status)
+	assert.NotZero(t, pending.ApprovalID)
+	assert.Zero(t, pending.TransactionID, "Nothing should execute before approval")
+	assert.Equal(t, "500000", getAccountBalance(t, ts, sourceID))
+
+	status, errResp = postAsPrincipal(t, approvalURL(pending.ApprovalID, "approve"), "alice", nil, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, 43, errResp.Code, "The maker cannot approve their own transfer")
+
+	status, _ = postAsPrincipal(t, approvalURL(pending.ApprovalID, "approve"), "", nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	var approved TransferApprovalResponse
+	status, _ = postAsPrincipal(t, approvalURL(pending.ApprovalID, "approve"), "bob", map[string]string{"comment": "verified by phone"}, &approved)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "EXECUTED", approved.Status)
+	assert.Equal(t, "bob", approved.DecidedBy)
+	assert.NotZero(t, approved.TransactionID)
+	assert.Equal(t, "350000", getAccountBalance(t, ts, sourceID))
+
+	status, errResp = postAsPrincipal(t, approvalURL(pending.ApprovalID, "approve"), "carol", nil, nil)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, 41, errResp.Code, "An approval can only be decided once")
+
+	trail := getApproval(t, ts, pending.ApprovalID)
+	require.Len(t, trail.Events, 3)
+	assert.Equal(t, TransferApprovalEvent{Event: "REQUESTED", Principal: "alice"}, trail.Events[0])
+	assert.Equal(t, TransferApprovalEvent{Event: "APPROVED", Principal: "bob", Comment: "verified by phone"}, trail.Events[1])
+	assert.Equal(t, "EXECUTED", trail.Events[2].Event)
+
+	status, _ = postAsPrincipal(t, transactionsURL, "alice", large, &pending)
+	require.Equal(t, http.StatusOK, status)
+
+	var rejected TransferApprovalResponse
+	status, _ = postAsPrincipal(t, approvalURL(pending.ApprovalID, "reject"), "bob", map[string]string{"comment": "unknown payee"}, &rejected)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "REJECTED", rejected.Status)
+	assert.Equal(t, "350000", getAccountBalance(t, ts, sourceID), "Rejected transfers never execute")
+
+	status, _ = postAsPrincipal(t, transactionsURL, "alice", CreateTransactionRequest{
+		SourceAccountID:      poorID,
+		DestinationAccountID: destID,
+		Amount:               "150000.00",
+	}, &pending)
+	require.Equal(t, http.StatusOK, status)
+
+	status, errResp = postAsPrincipal(t, approvalURL(pending.ApprovalID, "approve"), "bob", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code, "Execution failures are returned to the checker")
+
+	failed := getApproval(t, ts, pending.ApprovalID)
+	assert.Equal(t, "FAILED", failed.Status)
+	assert.Equal(t, 9, failed.LastErrorCode)
+
+	// An approval whose execution was cut off stays APPROVED until the
+	// approval executor claims it and runs it
+	status, _ = postAsPrincipal(t, transactionsURL, "alice", large, &pending)
+	require.Equal(t, http.StatusOK, status)
+
+	ctx := context.Background()
+	_, err := ts.Config.TransferApprovalRepository.ApproveTransfer(ctx, pending.ApprovalID, "bob", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "APPROVED", getApproval(t, ts, pending.ApprovalID).Status)
+	assert.Equal(t, "350000", getAccountBalance(t, ts, sourceID))
+
+	stale, err := ts.Config.TransferApprovalRepository.ClaimStaleApprovals(ctx, 100, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	var stranded *models.TransferApproval
+	for i := range stale {
+		if stale[i].ID == pending.ApprovalID {
+			stranded = &stale[i]
+		}
+	}
+	require.NotNil(t, stranded, "Stale approved transfers should be claimed")
+
+	executed, err := transactions.ExecuteApprovedTransfer(ctx, ts.Config, stranded)
+	require.NoError(t, err)
+	assert.Equal(t, models.TransferApprovalStatusExecuted, executed.Status)
+	assert.NotNil(t, executed.TransactionID)
+	assert.Equal(t, "200000", getAccountBalance(t, ts, sourceID))
+
+	scheduled := large
+	scheduled.ExecuteAt = time.Now().Add(time.Hour).Format(time.RFC3339)
+	status, errResp = postAsPrincipal(t, transactionsURL, "alice", scheduled, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 44, errResp.Code, "Transfers needing approval cannot be scheduled around it")
+
+	ts.Config.ApprovalTTL = -time.Second
+	status, _ = postAsPrincipal(t, transactionsURL, "alice", large, &pending)
+	ts.Config.ApprovalTTL = time.Hour
+	require.Equal(t, http.StatusOK, status)
+
+	status, errResp = postAsPrincipal(t, approvalURL(pending.ApprovalID, "approve"), "bob", nil, nil)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Contains(t, errResp.Message, "expired")
+
+	_, err = ts.Config.TransferApprovalRepository.ExpireApprovals(ctx)
+	require.NoError(t, err)
+
+	expired := getApproval(t, ts, pending.ApprovalID)
+	assert.Equal(t, "EXPIRED", expired.Status)
+	require.Len(t, expired.Events, 2)
+	assert.Equal(t, TransferApprovalEvent{Event: "EXPIRED"}, expired.Events[1])
+}
+
+func TestTransferReferences(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID, otherID := baseID+1700, baseID+1701, baseID+1702
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "1000.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: otherID, InitialBalance: "1000.00"},
+	)
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	invoiceRef := fmt.Sprintf("INV-%d", sourceID)
+
+	var created CreateTransactionResponse
+	status, _ := postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "100.00",
+		Memo:                 "March rent",
+		ClientReference:      invoiceRef,
+		Metadata:             map[string]any{"invoice_id": invoiceRef, "lines": 2},
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "March rent", created.Memo)
+	assert.Equal(t, invoiceRef, created.ClientReference)
+	assert.Equal(t, map[string]any{"invoice_id": invoiceRef, "lines": float64(2)}, created.Metadata)
+
+	record := getTransaction(t, ts, created.TransactionID)
+	assert.Equal(t, "March rent", record.Memo)
+	assert.Equal(t, invoiceRef, record.ClientReference)
+	assert.Equal(t, map[string]any{"invoice_id": invoiceRef, "lines": float64(2)}, record.Metadata)
+
+	status, errResp := postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "5.00",
+		ClientReference:      invoiceRef,
+	}, nil)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, 45, errResp.Code, "A client reference is unique per source account")
+	assert.Equal(t, "900", getAccountBalance(t, ts, sourceID), "The duplicate should not move any money")
+
+	status, _ = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      otherID,
+		DestinationAccountID: destID,
+		Amount:               "5.00",
+		ClientReference:      invoiceRef,
+	}, nil)
+	assert.Equal(t, http.StatusOK, status, "Other accounts may use the same client reference")
+
+	declinedRef := invoiceRef + "-2"
+	status, errResp = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "5000.00",
+		ClientReference:      declinedRef,
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code)
+
+	status, _ = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "50.00",
+		ClientReference:      declinedRef,
+	}, nil)
+	assert.Equal(t, http.StatusOK, status, "A declined transfer's reference may be used again")
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?source_account_id=%d&client_reference=%s", ts.Server.URL, sourceID, declinedRef))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Transfers, 2)
+	assert.Equal(t, "COMPLETED", list.Transfers[0].Status)
+	assert.Equal(t, "FAILED", list.Transfers[1].Status, "Declined attempts keep their reference")
+
+	status, _ = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "1.00",
+		Memo:                 strings.Repeat("m", 256),
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestSingleStatementTransfer(t *testing.T) {
+	ts := SetupTestServerWithDBConfig(t, func(config *storage.Config) {
+		config.TransferExecution = string(storage.TransferExecutionSingleStatement)
+	})
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID, feeSourceID, revenueID := baseID+1800, baseID+1801, baseID+1802, baseID+1803
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: feeSourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: revenueID, InitialBalance: "0.00"},
+	)
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+
+	var created CreateTransactionResponse
+	status := postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "40.00",
+		Memo:                 "fast path",
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "60", created.SourceBalance)
+	assert.Equal(t, "40", created.DestinationBalance)
+
+	record := getTransaction(t, ts, created.TransactionID)
+	assert.Equal(t, "COMPLETED", record.Status)
+	assert.Equal(t, "40", record.DestinationAmount)
+	assert.Equal(t, "fast path", record.Memo)
+
+	status, errResp := postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "500.00",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code)
+	assert.Equal(t, "60", getAccountBalance(t, ts, sourceID))
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?source_account_id=%d&status=FAILED", ts.Server.URL, sourceID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var list ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Transfers, 1, "The declined transfer should be recorded")
+	assert.Equal(t, 9, list.Transfers[0].FailureCode)
+
+	status, errResp = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      baseID + 1899,
+		DestinationAccountID: destID,
+		Amount:               "1.00",
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, 10, errResp.Code)
+
+	status, errResp = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: baseID + 1899,
+		Amount:               "1.00",
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, 11, errResp.Code)
+
+	status = postJSON(t, fmt.Sprintf("%s/fee-schedules/", ts.Server.URL), CreateFeeScheduleRequest{
+		AccountID:        feeSourceID,
+		FeeType:          "FLAT",
+		FlatAmount:       "2.00",
+		RevenueAccountID: revenueID,
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      feeSourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "2", created.Fee, "Transfers with fees fall back to the locking path")
+	assert.Equal(t, "88", created.SourceBalance)
+	assert.Equal(t, "2", getAccountBalance(t, ts, revenueID))
+}
+
+func TestHotAccount(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	hotID, payerID, payeeID := baseID+1900, baseID+1901, baseID+1902
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: hotID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: payerID, InitialBalance: "1000.00"},
+		CreateAccountRequest{AccountID: payeeID, InitialBalance: "0.00"},
+	)
+
+	shardsURL := fmt.Sprintf("%s/accounts/%d/balance-shards", ts.Server.URL, hotID)
+	require.Equal(t, http.StatusOK, putJSON(t, shardsURL, map[string]int{"shards": 4}))
+	assert.Equal(t, 4, getAccount(t, ts, hotID).BalanceShards)
+	assert.Equal(t, 0, getAccount(t, ts, payerID).BalanceShards)
+
+	assert.Equal(t, http.StatusBadRequest, putJSON(t, shardsURL, map[string]int{"shards": 2}), "Balance shards cannot be reduced")
+	assert.Equal(t, http.StatusBadRequest, putJSON(t, fmt.Sprintf("%s/accounts/%d/balance-shards", ts.Server.URL, payerID), map[string]int{"shards": 1000}))
+	assert.Equal(t, http.StatusNotFound, putJSON(t, fmt.Sprintf("%s/accounts/%d/balance-shards", ts.Server.URL, baseID+1999), map[string]int{"shards": 4}))
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	for i := 0; i < 10; i++ {
+		status := postJSON(t, transactionsURL, CreateTransactionRequest{
+			SourceAccountID:      payerID,
+			DestinationAccountID: hotID,
+			Amount:               "50.00",
+		}, nil)
+		require.Equal(t, http.StatusOK, status)
+	}
+	assert.Equal(t, "600", getAccountBalance(t, ts, hotID), "Credits on every shard should be summed")
+
+	var created CreateTransactionResponse
+	status := postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      hotID,
+		DestinationAccountID: payeeID,
+		Amount:               "550.00",
+	}, &created)
+	require.Equal(t, http.StatusOK, status, "A debit may spend funds credited to the shards")
+	assert.Equal(t, "50", created.SourceBalance)
+
+	status, errResp := postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      hotID,
+		DestinationAccountID: payeeID,
+		Amount:               "50.01",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code)
+
+	hot := getAccount(t, ts, hotID)
+	assert.Equal(t, "50", hot.Balance)
+	assert.Equal(t, "50", hot.AvailableBalance)
+}
+
+func getAsyncTransfer(t *testing.T, ts *TestServer, asyncID int) AsyncTransferResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/async/%d", ts.Server.URL, asyncID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var async AsyncTransferResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&async))
+	return async
+}
+
+// runAsyncTransfers does what a worker in cmd/server does for every queued
+// transfer: apply it under a derived idempotency key and record the outcome.
+func runAsyncTransfers(t *testing.T, ts *TestServer) {
+	ctx := context.Background()
+	repo := ts.Config.AsyncTransferRepository
+
+	claimed, err := repo.ClaimAsyncTransfers(ctx, 1000, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	for _, async := range claimed {
+		transfer, sourceBalance, destBalance, err := ts.Config.TransferRepository.ProcessTransfer(ctx, &models.Transfer{
+			SourceAccountID:      async.SourceAccountID,
+			DestinationAccountID: async.DestinationAccountID,
+			Amount:               async.Amount,
+			TransferReference:    async.TransferReference,
+		}, &models.IdempotencyKey{
+			Key:         fmt.Sprintf("async-transfer:%d", async.ID),
+			RequestHash: fmt.Sprintf("async-transfer:%d", async.ID),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			require.NoError(t, repo.MarkAsyncTransferFailed(ctx, async.ID, err))
+			continue
+		}
+		require.NoError(t, repo.MarkAsyncTransferCompleted(ctx, async.ID, transfer.ID, sourceBalance, destBalance))
+	}
+}
+
+func TestAsyncTransaction(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	sourceID, destID := baseID+2000, baseID+2001
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: sourceID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: destID, InitialBalance: "0.00"},
+	)
+
+	asyncReq := CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "30.00",
+		Memo:                 "async",
+		Async:                true,
+		CallbackURL:          "https://example.com/hooks/transfers",
+	}
+	idempotencyKey := fmt.Sprintf("async-test-%d", time.Now().UnixNano())
+
+	resp := postTransactionWithKey(t, ts, idempotencyKey, asyncReq)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var queued CreateTransactionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&queued))
+	assert.Equal(t, "QUEUED", queued.Status)
+	assert.NotZero(t, queued.AsyncTransferID)
+	assert.Zero(t, queued.TransactionID)
+	assert.Equal(t, "async", queued.Memo)
+	assert.Equal(t, "100", getAccountBalance(t, ts, sourceID), "Queued transfer must not move money yet")
+
+	replay := postTransactionWithKey(t, ts, idempotencyKey, asyncReq)
+	defer replay.Body.Close()
+	require.Equal(t, http.StatusAccepted, replay.StatusCode)
+
+	var replayed CreateTransactionResponse
+	require.NoError(t, json.NewDecoder(replay.Body).Decode(&replayed))
+	assert.Equal(t, queued.AsyncTransferID, replayed.AsyncTransferID, "Replay should return the queued transfer")
+
+	async := getAsyncTransfer(t, ts, queued.AsyncTransferID)
+	assert.Equal(t, "QUEUED", async.Status)
+	assert.Equal(t, "PENDING", async.CallbackStatus)
+
+	runAsyncTransfers(t, ts)
+
+	async = getAsyncTransfer(t, ts, queued.AsyncTransferID)
+	assert.Equal(t, "COMPLETED", async.Status)
+	assert.Equal(t, 1, async.Attempts)
+	assert.NotZero(t, async.TransactionID)
+	assert.Equal(t, "70", async.SourceBalance)
+	assert.Equal(t, "30", async.DestinationBalance)
+	assert.Equal(t, "70", getAccountBalance(t, ts, sourceID))
+	assert.Equal(t, "COMPLETED", getTransaction(t, ts, async.TransactionID).Status)
+
+	callbacks, err := ts.Config.AsyncTransferRepository.ClaimDueCallbacks(context.Background(), 1000, time.Minute)
+	require.NoError(t, err)
+	var callbackDue bool
+	for _, callback := range callbacks {
+		callbackDue = callbackDue || callback.ID == queued.AsyncTransferID
+	}
+	assert.True(t, callbackDue, "A finished transfer's callback should be due")
+
+	declineResp := postTransactionWithKey(t, ts, idempotencyKey+"-declined", CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "500.00",
+		Async:                true,
+	})
+	defer declineResp.Body.Close()
+	require.Equal(t, http.StatusAccepted, declineResp.StatusCode, "Funds are only checked when the transfer runs")
+
+	var declined CreateTransactionResponse
+	require.NoError(t, json.NewDecoder(declineResp.Body).Decode(&declined))
+
+	runAsyncTransfers(t, ts)
+
+	async = getAsyncTransfer(t, ts, declined.AsyncTransferID)
+	assert.Equal(t, "FAILED", async.Status)
+	assert.Equal(t, 9, async.LastErrorCode, "Insufficient funds should fail without retrying")
+	assert.Empty(t, async.CallbackStatus, "No callback was asked for")
+	assert.Equal(t, "70", getAccountBalance(t, ts, sourceID))
+
+	status := postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: baseID + 2999,
+		Amount:               "10.00",
+		Async:                true,
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status, "Queueing to an unknown account should fail")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		CallbackURL:          "https://example.com/hooks/transfers",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "A callback needs async")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		Async:                true,
+		CallbackURL:          "ftp://example.com/hooks",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Callbacks must be http or https")
+
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      sourceID,
+		DestinationAccountID: destID,
+		Amount:               "10.00",
+		Async:                true,
+		ExecuteAt:            time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Async cannot be scheduled")
+
+	resp, err = http.Get(fmt.Sprintf("%s/transactions/async/%d", ts.Server.URL, 999999999))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("%s/transactions/async/abc", ts.Server.URL))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSplitPayment(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	buyer, seller, platform, tax := baseID+2100, baseID+2101, baseID+2102, baseID+2103
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: buyer, InitialBalance: "1000.00"},
+		CreateAccountRequest{AccountID: seller, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: platform, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: tax, InitialBalance: "0.00"},
+	)
+
+	splitURL := fmt.Sprintf("%s/transactions/split", ts.Server.URL)
+	clientReference := fmt.Sprintf("ORDER-%d", time.Now().UnixNano())
+
+	var split SplitPaymentResponse
+	status := postJSON(t, splitURL, SplitPaymentRequest{
+		SourceAccountID: buyer,
+		Amount:          "100.00",
+		ClientReference: clientReference,
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: seller, Percentage: "33.33333333", Memo: "seller"},
+			{DestinationAccountID: platform, Percentage: "33.33333333"},
+			{DestinationAccountID: tax, Percentage: "33.33333334"},
+		},
+	}, &split)
+	require.Equal(t, http.StatusOK, status)
+	assert.NotZero(t, split.SplitPaymentID)
+	assert.Equal(t, "COMPLETED", split.Status)
+	assert.Equal(t, "PERCENTAGE", split.Allocation)
+	assert.Equal(t, clientReference, split.ClientReference)
+	require.Len(t, split.Legs, 3)
+	assert.Equal(t, "33.33333333", split.Legs[0].Amount)
+	assert.Equal(t, "seller", split.Legs[0].Memo)
+	assert.Equal(t, "33.33333334", split.Legs[2].Amount)
+	require.Len(t, split.Balances, 4)
+	assert.Equal(t, buyer, split.Balances[0].AccountID)
+	assert.Equal(t, "900", split.Balances[0].Balance)
+
+	assert.Equal(t, "900", getAccountBalance(t, ts, buyer))
+	assert.Equal(t, "33.33333333", getAccountBalance(t, ts, seller))
+	assert.Equal(t, "33.33333333", getAccountBalance(t, ts, platform))
+	assert.Equal(t, "33.33333334", getAccountBalance(t, ts, tax))
+
+	leg := getTransaction(t, ts, split.Legs[1].TransactionID)
+	assert.Equal(t, split.SplitPaymentID, leg.SplitPaymentID)
+	assert.Equal(t, buyer, leg.SourceAccountID)
+	assert.Equal(t, "COMPLETED", leg.Status)
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/split/%d", ts.Server.URL, split.SplitPaymentID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var fetched SplitPaymentResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&fetched))
+	assert.Equal(t, split.SplitPaymentID, fetched.SplitPaymentID)
+	assert.Equal(t, "100", fetched.Amount)
+	require.Len(t, fetched.Legs, 3)
+	assert.Equal(t, split.Legs[0].TransactionID, fetched.Legs[0].TransactionID)
+
+	var byAmount SplitPaymentResponse
+	status = postJSON(t, splitURL, SplitPaymentRequest{
+		SourceAccountID: buyer,
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: seller, Amount: "80.00"},
+			{DestinationAccountID: platform, Amount: "20.00"},
+		},
+	}, &byAmount)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "AMOUNT", byAmount.Allocation)
+	assert.Equal(t, "100", byAmount.Amount)
+	assert.Equal(t, "800", getAccountBalance(t, ts, buyer))
+
+	status, errResp := postAsPrincipal(t, splitURL, "", SplitPaymentRequest{
+		SourceAccountID: buyer,
+		ClientReference: clientReference,
+		Legs:            []SplitLegRequest{{DestinationAccountID: seller, Amount: "1.00"}},
+	}, nil)
+	assert.Equal(t, http.StatusConflict, status, "A split's client reference is unique per source")
+	assert.Equal(t, 45, errResp.Code)
+
+	status = postJSON(t, splitURL, SplitPaymentRequest{
+		SourceAccountID: buyer,
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: seller, Amount: "700.00"},
+			{DestinationAccountID: platform, Amount: "200.00"},
+		},
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "The whole split must be funded")
+
+	status = postJSON(t, splitURL, SplitPaymentRequest{
+		SourceAccountID: buyer,
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: seller, Amount: "10.00"},
+			{DestinationAccountID: baseID + 2199, Amount: "10.00"},
+		},
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "800", getAccountBalance(t, ts, buyer), "Failed splits must not apply any leg")
+	assert.Equal(t, "113.33333333", getAccountBalance(t, ts, seller), "Failed splits must not apply any leg")
+
+	invalid := []SplitPaymentRequest{
+		{SourceAccountID: buyer, Amount: "10", Legs: []SplitLegRequest{{DestinationAccountID: seller, Percentage: "60"}, {DestinationAccountID: platform, Percentage: "30"}}},
+		{SourceAccountID: buyer, Legs: []SplitLegRequest{{DestinationAccountID: seller, Percentage: "100"}}},
+		{SourceAccountID: buyer, Amount: "10", Legs: []SplitLegRequest{{DestinationAccountID: seller, Amount: "5"}, {DestinationAccountID: platform, Percentage: "50"}}},
+		{SourceAccountID: buyer, Amount: "15", Legs: []SplitLegRequest{{DestinationAccountID: seller, Amount: "5"}, {DestinationAccountID: platform, Amount: "5"}}},
+		{SourceAccountID: buyer, Legs: []SplitLegRequest{{DestinationAccountID: seller, Amount: "5"}, {DestinationAccountID: seller, Amount: "5"}}},
+		{SourceAccountID: buyer, Legs: []SplitLegRequest{{DestinationAccountID: buyer, Amount: "5"}}},
+		{SourceAccountID: buyer, Legs: []SplitLegRequest{{DestinationAccountID: seller, Amount: "0.000000001"}}},
+		{SourceAccountID: buyer},
+	}
+	for i, req := range invalid {
+		status = postJSON(t, splitURL, req, nil)
+		assert.Equal(t, http.StatusBadRequest, status, "Invalid split %d should be rejected", i)
+	}
+
+	status = putJSON(t, fmt.Sprintf("%s/accounts/%d/limits", ts.Server.URL, buyer), SetAccountLimitsRequest{
+		VelocityCount:         3,
+		VelocityWindowSeconds: 3600,
+	})
+	require.Equal(t, http.StatusOK, status)
+
+	twoLegs := SplitPaymentRequest{
+		SourceAccountID: buyer,
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: seller, Amount: "1.00"},
+			{DestinationAccountID: platform, Amount: "1.00"},
+		},
+	}
+	status = postJSON(t, splitURL, twoLegs, nil)
+	assert.Equal(t, http.StatusOK, status, "Each split payment uses one velocity slot, not one per leg")
+
+	status, errResp = postAsPrincipal(t, splitURL, "", twoLegs, nil)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, 38, errResp.Code)
+	assert.Equal(t, "798", getAccountBalance(t, ts, buyer))
+
+	resp, err = http.Get(fmt.Sprintf("%s/transactions/split/%d", ts.Server.URL, 999999999))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func getSweepRule(t *testing.T, ts *TestServer, ruleID int) SweepRuleResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/sweep-rules/%d", ts.Server.URL, ruleID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var rule SweepRuleResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rule))
+	return rule
+}
+
+// runDueSweepRules does what the scheduler in cmd/server does on every tick
+func runDueSweepRules(t *testing.T, ts *TestServer) {
+	ctx := context.Background()
+
+	ruleIDs, err := ts.Config.SweepRuleRepository.ListDueSweepRuleIDs(ctx, 1000)
+	require.NoError(t, err)
+
+	for _, ruleID := range ruleIDs {
+		_, _, err = ts.Config.TransferRepository.ProcessSweepRule(ctx, ruleID, ts.Config.ApprovalThreshold)
+		require.NoError(t, err)
+	}
+}
+
+func TestSweepRule(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	operating, reserve, payroll, funding, euro := baseID+2200, baseID+2201, baseID+2202, baseID+2203, baseID+2204
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: operating, InitialBalance: "12500.00"},
+		CreateAccountRequest{AccountID: reserve, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: payroll, InitialBalance: "120.00"},
+		CreateAccountRequest{AccountID: funding, InitialBalance: "1000.00"},
+		CreateAccountRequest{AccountID: euro, InitialBalance: "0.00", Currency: "EUR"},
+	)
+
+	rulesURL := fmt.Sprintf("%s/sweep-rules/", ts.Server.URL)
+	startAt := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	var sweep SweepRuleResponse
+	status := postJSON(t, rulesURL, CreateSweepRuleRequest{
+		RuleType:             "SWEEP_EXCESS",
+		SourceAccountID:      operating,
+		DestinationAccountID: reserve,
+		Threshold:            "10000",
+		Frequency:            "DAILY",
+		StartAt:              startAt,
+	}, &sweep)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ACTIVE", sweep.Status)
+	assertSameInstant(t, startAt, sweep.NextRunAt, "The first run is due at the start")
+
+	var topUp SweepRuleResponse
+	status = postJSON(t, rulesURL, CreateSweepRuleRequest{
+		RuleType:             "TOP_UP",
+		SourceAccountID:      funding,
+		DestinationAccountID: payroll,
+		Threshold:            "500",
+		Frequency:            "INTERVAL",
+		IntervalSeconds:      3600,
+		StartAt:              startAt,
+	}, &topUp)
+	require.Equal(t, http.StatusOK, status)
+
+	runDueSweepRules(t, ts)
+
+	assert.Equal(t, "10000", getAccountBalance(t, ts, operating), "Everything above the threshold is swept")
+	assert.Equal(t, "2500", getAccountBalance(t, ts, reserve))
+	assert.Equal(t, "500", getAccountBalance(t, ts, payroll), "The destination is topped up to the threshold")
+	assert.Equal(t, "620", getAccountBalance(t, ts, funding))
+
+	sweep = getSweepRule(t, ts, sweep.SweepRuleID)
+	assert.Equal(t, "SWEPT", sweep.LastRunStatus)
+	require.NotZero(t, sweep.LastTransactionID)
+	nextRunAt, err := time.Parse(time.RFC3339, sweep.NextRunAt)
+	require.NoError(t, err)
+	assert.True(t, nextRunAt.After(time.Now()), "The rule moves on to its next occurrence")
+
+	transfer := getTransaction(t, ts, sweep.LastTransactionID)
+	assert.Equal(t, sweep.SweepRuleID, transfer.SweepRuleID, "Generated transfers are tagged with their rule")
+	assert.Equal(t, "2500", transfer.Amount)
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?sweep_rule_id=%d", ts.Server.URL, topUp.SweepRuleID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var list ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	require.Len(t, list.Transfers, 1)
+	assert.Equal(t, "380", list.Transfers[0].Amount)
+	assert.Equal(t, topUp.SweepRuleID, list.Transfers[0].SweepRuleID)
+
+	runDueSweepRules(t, ts)
+	assert.Equal(t, "2500", getAccountBalance(t, ts, reserve), "An occurrence only runs once")
+
+	var idle SweepRuleResponse
+	status = postJSON(t, rulesURL, CreateSweepRuleRequest{
+		RuleType:             "SWEEP_EXCESS",
+		SourceAccountID:      operating,
+		DestinationAccountID: reserve,
+		Threshold:            "9999",
+		MinAmount:            "5",
+		Frequency:            "DAILY",
+		StartAt:              startAt,
+	}, &idle)
+	require.Equal(t, http.StatusOK, status)
+
+	var underfunded SweepRuleResponse
+	status = postJSON(t, rulesURL, CreateSweepRuleRequest{
+		RuleType:             "TOP_UP",
+		SourceAccountID:      funding,
+		DestinationAccountID: reserve,
+		Threshold:            "5000",
+		Frequency:            "DAILY",
+		StartAt:              startAt,
+	}, &underfunded)
+	require.Equal(t, http.StatusOK, status)
+
+	runDueSweepRules(t, ts)
+
+	idle = getSweepRule(t, ts, idle.SweepRuleID)
+	assert.Equal(t, "NOTHING_TO_SWEEP", idle.LastRunStatus, "Runs below min_amount move nothing")
+	assert.Equal(t, "10000", getAccountBalance(t, ts, operating))
+
+	underfunded = getSweepRule(t, ts, underfunded.SweepRuleID)
+	assert.Equal(t, "FAILED", underfunded.LastRunStatus)
+	assert.Equal(t, 9, underfunded.LastErrorCode)
+	assert.Equal(t, "620", getAccountBalance(t, ts, funding))
+
+	failedResp, err := http.Get(fmt.Sprintf("%s/transactions/?sweep_rule_id=%d&status=FAILED", ts.Server.URL, underfunded.SweepRuleID))
+	require.NoError(t, err)
+	defer failedResp.Body.Close()
+	require.Equal(t, http.StatusOK, failedResp.StatusCode)
+
+	var failed ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(failedResp.Body).Decode(&failed))
+	assert.Len(t, failed.Transfers, 1, "Declined runs are recorded against their rule")
+
+	treasury := baseID + 2205
+	createTestAccounts(t, ts, CreateAccountRequest{AccountID: treasury, InitialBalance: "150000.00"})
+
+	var oversized SweepRuleResponse
+	status = postJSON(t, rulesURL, CreateSweepRuleRequest{
+		RuleType:             "SWEEP_EXCESS",
+		SourceAccountID:      treasury,
+		DestinationAccountID: reserve,
+		Threshold:            "0",
+		Frequency:            "DAILY",
+		StartAt:              startAt,
+	}, &oversized)
+	require.Equal(t, http.StatusOK, status)
+
+	runDueSweepRules(t, ts)
+
+	oversized = getSweepRule(t, ts, oversized.SweepRuleID)
+	assert.Equal(t, "FAILED", oversized.LastRunStatus, "Sweeps cannot move more than the approval threshold")
+	assert.Equal(t, 62, oversized.LastErrorCode)
+	assert.Equal(t, "150000", getAccountBalance(t, ts, treasury))
+
+	cancelURL := fmt.Sprintf("%s/sweep-rules/%d/cancel", ts.Server.URL, sweep.SweepRuleID)
+	var cancelled SweepRuleResponse
+	status = postJSON(t, cancelURL, nil, &cancelled)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "CANCELLED", cancelled.Status)
+
+	status = postJSON(t, cancelURL, nil, nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, errResp := postAsPrincipal(t, rulesURL, "", CreateSweepRuleRequest{
+		RuleType:             "SWEEP_EXCESS",
+		SourceAccountID:      operating,
+		DestinationAccountID: euro,
+		Threshold:            "100",
+		Frequency:            "DAILY",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 55, errResp.Code, "Sweep rule accounts must share a currency")
+
+	status = postJSON(t, rulesURL, CreateSweepRuleRequest{
+		RuleType:             "SWEEP_EXCESS",
+		SourceAccountID:      operating,
+		DestinationAccountID: baseID + 2299,
+		Threshold:            "100",
+		Frequency:            "DAILY",
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	invalid := []CreateSweepRuleRequest{
+		{RuleType: "DRAIN", SourceAccountID: operating, DestinationAccountID: reserve, Threshold: "100", Frequency: "DAILY"},
+		{RuleType: "TOP_UP", SourceAccountID: operating, DestinationAccountID: reserve, Threshold: "-1", Frequency: "DAILY"},
+		{RuleType: "TOP_UP", SourceAccountID: operating, DestinationAccountID: operating, Threshold: "100", Frequency: "DAILY"},
+		{RuleType: "TOP_UP", SourceAccountID: operating, DestinationAccountID: reserve, Threshold: "100", Frequency: "INTERVAL", IntervalSeconds: 10},
+		{RuleType: "TOP_UP", SourceAccountID: operating, DestinationAccountID: reserve, Threshold: "100", Frequency: "HOURLY"},
+	}
+	for i, req := range invalid {
+		status = postJSON(t, rulesURL, req, nil)
+		assert.Equal(t, http.StatusBadRequest, status, "Invalid sweep rule %d should be rejected", i)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s/sweep-rules/%d", ts.Server.URL, 999999999))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRiskRules(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	payer, payee, blocked, otherPayee := baseID+2300, baseID+2301, baseID+2302, baseID+2303
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: payer, InitialBalance: "5000.00"},
+		CreateAccountRequest{AccountID: payee, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: blocked, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: otherPayee, InitialBalance: "0.00"},
+	)
+
+	rulesURL := fmt.Sprintf("%s/risk-rules/", ts.Server.URL)
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+	ruleName := func(name string) string { return fmt.Sprintf("%s-%d", name, baseID) }
+
+	var blocklist RiskRuleResponse
+	status := postJSON(t, rulesURL, CreateRiskRuleRequest{
+		Name:              ruleName("blocked-counterparty"),
+		RuleType:          "BLOCKLIST",
+		Action:            "DENY",
+		AccountID:         payer,
+		AccountSide:       "DESTINATION",
+		BlockedAccountIDs: []int{blocked},
+	}, &blocklist)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, blocklist.Enabled, "Rules are enabled by default")
+
+	var roundAmounts RiskRuleResponse
+	status = postJSON(t, rulesURL, CreateRiskRuleRequest{
+		Name:          ruleName("round-amounts"),
+		RuleType:      "ROUND_AMOUNT",
+		Action:        "FLAG",
+		AccountID:     payer,
+		RoundMultiple: "100",
+	}, &roundAmounts)
+	require.Equal(t, http.StatusOK, status)
+
+	var threshold RiskRuleResponse
+	status = postJSON(t, rulesURL, CreateRiskRuleRequest{
+		Name:      ruleName("large-transfers"),
+		RuleType:  "AMOUNT_THRESHOLD",
+		Action:    "DENY",
+		AccountID: payer,
+		Amount:    "1000",
+	}, &threshold)
+	require.Equal(t, http.StatusOK, status)
+
+	defer func() {
+		for _, ruleID := range []int{blocklist.RiskRuleID, roundAmounts.RiskRuleID, threshold.RiskRuleID} {
+			postJSON(t, fmt.Sprintf("%s%d/disable", rulesURL, ruleID), nil, nil)
+		}
+	}()
+
+	status, errResp := postAsPrincipal(t, rulesURL, "", CreateRiskRuleRequest{
+		Name:      ruleName("large-transfers"),
+		RuleType:  "AMOUNT_THRESHOLD",
+		Action:    "FLAG",
+		AccountID: payer,
+		Amount:    "1",
+	}, nil)
+	assert.Equal(t, http.StatusConflict, status, "Rule names are unique")
+	assert.Equal(t, 59, errResp.Code)
+
+	status, _ = postAsPrincipal(t, rulesURL, "", CreateRiskRuleRequest{
+		Name:     ruleName("missing-amount"),
+		RuleType: "AMOUNT_THRESHOLD",
+		Action:   "DENY",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Each rule type needs its condition")
+
+	status, errResp = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      payer,
+		DestinationAccountID: blocked,
+		Amount:               "10.50",
+	}, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, 56, errResp.Code)
+	assert.Contains(t, errResp.Message, blocklist.Name, "The denial names the rule")
+	assert.Equal(t, "5000", getAccountBalance(t, ts, payer), "A denied transfer moves nothing")
+	assert.Equal(t, "0", getAccountBalance(t, ts, blocked))
+
+	status, errResp = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      payer,
+		DestinationAccountID: payee,
+		Amount:               "1500",
+	}, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, errResp.Message, threshold.Name)
+
+	status, errResp = postAsPrincipal(t, fmt.Sprintf("%s/transactions/batch", ts.Server.URL), "", BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: payer, DestinationAccountID: payee, Amount: "10.50"},
+		{SourceAccountID: payer, DestinationAccountID: blocked, Amount: "10.50"},
+	}}, nil)
+	assert.Equal(t, http.StatusForbidden, status, "Batch legs are checked too")
+	assert.Contains(t, errResp.Message, blocklist.Name)
+
+	status, errResp = postAsPrincipal(t, fmt.Sprintf("%s/transactions/split", ts.Server.URL), "", SplitPaymentRequest{
+		SourceAccountID: payer,
+		Amount:          "1500.00",
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: payee, Percentage: "50"},
+			{DestinationAccountID: otherPayee, Percentage: "50"},
+		},
+	}, nil)
+	assert.Equal(t, http.StatusForbidden, status, "Splitting a payment does not take it under an amount rule")
+	assert.Contains(t, errResp.Message, threshold.Name)
+	assert.Equal(t, "5000", getAccountBalance(t, ts, payer), "Denied batches and splits move nothing")
+
+	var flagged CreateTransactionResponse
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      payer,
+		DestinationAccountID: payee,
+		Amount:               "200",
+	}, &flagged)
+	require.Equal(t, http.StatusOK, status, "FLAG rules let the transfer through")
+
+	var unflagged CreateTransactionResponse
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      payer,
+		DestinationAccountID: payee,
+		Amount:               "12.34",
+	}, &unflagged)
+	require.Equal(t, http.StatusOK, status)
+
+	resp, err := http.Get(fmt.Sprintf("%s%d/flags", rulesURL, roundAmounts.RiskRuleID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var flags RiskRuleFlagsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&flags))
+	require.Len(t, flags.Flags, 1, "Only the round transfer is flagged")
+	assert.Equal(t, flagged.TransactionID, flags.Flags[0].TransactionID)
+
+	var disabled RiskRuleResponse
+	status = postJSON(t, fmt.Sprintf("%s%d/disable", rulesURL, threshold.RiskRuleID), nil, &disabled)
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, disabled.Enabled)
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      payer,
+		DestinationAccountID: payee,
+		Amount:               "1500.01",
+	}, nil)
+	assert.Equal(t, http.StatusOK, status, "A disabled rule no longer applies")
+
+	var reloaded struct {
+		Loaded int `json:"loaded"`
+	}
+	status = postJSON(t, fmt.Sprintf("%sreload", rulesURL), nil, &reloaded)
+	require.Equal(t, http.StatusOK, status)
+	assert.GreaterOrEqual(t, reloaded.Loaded, 2, "The enabled rules are loaded")
+}
+
+func TestTransferQuote(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	payerID, payeeID, revenueID, gbpID, eurID := baseID+2310, baseID+2311, baseID+2312, baseID+2313, baseID+2314
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: payerID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: payeeID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: revenueID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: gbpID, InitialBalance: "500.00", Currency: "GBP"},
+		CreateAccountRequest{AccountID: eurID, InitialBalance: "0.00", Currency: "EUR"},
+	)
+
+	status := postJSON(t, fmt.Sprintf("%s/fee-schedules/", ts.Server.URL), CreateFeeScheduleRequest{
+		AccountID:        payerID,
+		FeeType:          "FLAT",
+		FlatAmount:       "2.00",
+		RevenueAccountID: revenueID,
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	quoteURL := fmt.Sprintf("%s/transactions/quote", ts.Server.URL)
+
+	var quote QuoteTransactionResponse
+	status = postJSON(t, quoteURL, CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: payeeID,
+		Amount:               "30.00",
+	}, &quote)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "30.00", quote.Amount)
+	assert.Equal(t, "2", quote.Fee)
+	assert.Equal(t, revenueID, quote.FeeAccountID)
+	assert.Equal(t, "68", quote.SourceBalance, "The projected balance includes the fee")
+	assert.Equal(t, "30", quote.DestinationBalance)
+	assert.False(t, quote.RequiresApproval)
+
+	assert.Equal(t, "100", getAccountBalance(t, ts, payerID), "A quote moves nothing")
+	assert.Equal(t, "0", getAccountBalance(t, ts, payeeID))
+	assert.Equal(t, "0", getAccountBalance(t, ts, revenueID))
+
+	var created CreateTransactionResponse
+	status = postJSON(t, fmt.Sprintf("%s/transactions/", ts.Server.URL), CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: payeeID,
+		Amount:               "30.00",
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, quote.SourceBalance, created.SourceBalance, "The transfer lands where it was quoted")
+	assert.Equal(t, quote.DestinationBalance, created.DestinationBalance)
+
+	status, errResp := postAsPrincipal(t, quoteURL, "", CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: payeeID,
+		Amount:               "67.00",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "The fee counts towards the funds needed")
+	assert.Equal(t, 9, errResp.Code)
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?source_account_id=%d&status=FAILED", ts.Server.URL, payerID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var failed ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&failed))
+	assert.Empty(t, failed.Transfers, "A declined quote is not recorded")
+
+	status, errResp = postAsPrincipal(t, quoteURL, "", CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: baseID + 2399,
+		Amount:               "1.00",
+	}, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, 11, errResp.Code)
+
+	var rate FXRateResponse
+	status = postJSON(t, fmt.Sprintf("%s/fx-rates/", ts.Server.URL), CreateFXRateRequest{
+		BaseCurrency:  "GBP",
+		QuoteCurrency: "EUR",
+		Rate:          "1.15",
+	}, &rate)
+	require.Equal(t, http.StatusOK, status)
+
+	var converted QuoteTransactionResponse
+	status = postJSON(t, quoteURL, CreateTransactionRequest{
+		SourceAccountID:      gbpID,
+		DestinationAccountID: eurID,
+		Amount:               "100",
+	}, &converted)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "GBP", converted.SourceCurrency)
+	assert.Equal(t, "EUR", converted.DestinationCurrency)
+	assert.Equal(t, "115", converted.DestinationAmount)
+	assert.Equal(t, rate.FXRateID, converted.FXRateID)
+	assert.Equal(t, "400", converted.SourceBalance)
+	assert.Equal(t, "115", converted.DestinationBalance)
+	assert.Equal(t, "0", getAccountBalance(t, ts, eurID))
+}
+
+func TestConditionalTransfer(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	payerID, payeeID, hotID := baseID+2320, baseID+2321, baseID+2322
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: payerID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: payeeID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: hotID, InitialBalance: "0.00"},
+	)
+
+	resp, err := http.Get(fmt.Sprintf("%s/accounts/%d", ts.Server.URL, payerID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var payer GetAccountResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&payer))
+	assert.Equal(t, fmt.Sprintf("%q", fmt.Sprint(payer.Version)), resp.Header.Get("ETag"), "The ETag is the account version")
+	readVersion := payer.Version
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+
+	status := postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:       payerID,
+		DestinationAccountID:  payeeID,
+		Amount:                "10.00",
+		ExpectedSourceVersion: readVersion,
+	}, nil)
+	require.Equal(t, http.StatusOK, status, "A transfer against the version just read goes ahead")
+	assert.Equal(t, readVersion+1, getAccount(t, ts, payerID).Version, "A debit bumps the version")
+
+	status, errResp := postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:       payerID,
+		DestinationAccountID:  payeeID,
+		Amount:                "10.00",
+		ExpectedSourceVersion: readVersion,
+	}, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, status, "A stale version is rejected")
+	assert.Equal(t, 60, errResp.Code)
+	assert.Equal(t, "90", getAccountBalance(t, ts, payerID))
+
+	status, _ = postAsPrincipal(t, fmt.Sprintf("%s/transactions/quote", ts.Server.URL), "", CreateTransactionRequest{
+		SourceAccountID:       payerID,
+		DestinationAccountID:  payeeID,
+		Amount:                "10.00",
+		ExpectedSourceVersion: readVersion,
+	}, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, status, "Quotes check the precondition too")
+
+	status, _ = postAsPrincipal(t, fmt.Sprintf("%s/transactions/batch", ts.Server.URL), "", BatchTransactionRequest{Legs: []CreateTransactionRequest{
+		{SourceAccountID: payerID, DestinationAccountID: payeeID, Amount: "1.00"},
+		{SourceAccountID: payerID, DestinationAccountID: payeeID, Amount: "1.00", ExpectedSourceVersion: readVersion},
+	}}, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	assert.Equal(t, "90", getAccountBalance(t, ts, payerID), "A batch with a stale leg moves nothing")
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:       payerID,
+		DestinationAccountID:  payeeID,
+		Amount:                "5.00",
+		ExpectedSourceBalance: "90.00",
+	}, nil)
+	require.Equal(t, http.StatusOK, status, "The expected balance compares by value")
+
+	status, errResp = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:       payerID,
+		DestinationAccountID:  payeeID,
+		Amount:                "5.00",
+		ExpectedSourceBalance: "90.00",
+	}, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	assert.Equal(t, 60, errResp.Code)
+
+	status, _ = postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:       payerID,
+		DestinationAccountID:  payeeID,
+		Amount:                "5.00",
+		ExpectedSourceVersion: getAccount(t, ts, payerID).Version,
+		Async:                 true,
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, status, "Only immediate transfers can be conditional")
+
+	credited := getAccount(t, ts, payerID).Version
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      payeeID,
+		DestinationAccountID: payerID,
+		Amount:               "1.00",
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, credited+1, getAccount(t, ts, payerID).Version, "A credit bumps the version")
+
+	require.Equal(t, http.StatusOK, putJSON(t, fmt.Sprintf("%s/accounts/%d/balance-shards", ts.Server.URL, hotID), map[string]int{"shards": 4}))
+	hotVersion := getAccount(t, ts, hotID).Version
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: hotID,
+		Amount:               "1.00",
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, hotVersion+1, getAccount(t, ts, hotID).Version, "Credits to a balance shard bump the hot account's version")
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:       hotID,
+		DestinationAccountID:  payeeID,
+		Amount:                "1.00",
+		ExpectedSourceVersion: hotVersion + 1,
+	}, nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestLedger(t *testing.T) {
+	ts := SetupTestServer(t)
+	defer ts.Cleanup()
+
+	baseID := int(time.Now().Unix()) % 100000
+	payerID, payeeID, gbpID, eurID, shopID := baseID+2330, baseID+2331, baseID+2332, baseID+2333, baseID+2334
+
+	createTestAccounts(t, ts,
+		CreateAccountRequest{AccountID: payerID, InitialBalance: "100.00"},
+		CreateAccountRequest{AccountID: payeeID, InitialBalance: "0.00"},
+		CreateAccountRequest{AccountID: gbpID, InitialBalance: "500.00", Currency: "GBP"},
+		CreateAccountRequest{AccountID: eurID, InitialBalance: "0.00", Currency: "EUR"},
+		CreateAccountRequest{AccountID: shopID, InitialBalance: "0.00"},
+	)
+
+	opening := getAccountPostings(t, ts, payerID, "")
+	require.Len(t, opening.Postings, 1, "The initial balance is posted")
+	assert.Equal(t, "100", opening.Postings[0].Amount)
+	assert.Equal(t, "USD", opening.Postings[0].Currency)
+	assert.Empty(t, getAccountPostings(t, ts, payeeID, "").Postings, "An account opened empty has no postings")
+
+	transactionsURL := fmt.Sprintf("%s/transactions/", ts.Server.URL)
+
+	var created CreateTransactionResponse
+	status := postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: payeeID,
+		Amount:               "40.00",
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+
+	journal := getTransferJournal(t, ts, created.TransactionID)
+	assert.Equal(t, "TRANSFER", journal.Kind)
+	assert.Equal(t, created.TransactionID, journal.TransactionID)
+	require.Len(t, journal.Postings, 2)
+	assert.Equal(t, payerID, journal.Postings[0].AccountID)
+	assert.Equal(t, "-40", journal.Postings[0].Amount)
+	assert.Equal(t, payeeID, journal.Postings[1].AccountID)
+	assert.Equal(t, "40", journal.Postings[1].Amount)
+	assertJournalBalanced(t, journal)
+
+	status = postJSON(t, fmt.Sprintf("%s/fx-rates/", ts.Server.URL), CreateFXRateRequest{
+		BaseCurrency:  "GBP",
+		QuoteCurrency: "EUR",
+		Rate:          "1.15",
+	}, nil)
+	require.Equal(t, http.StatusOK, status)
+
+	status = postJSON(t, transactionsURL, CreateTransactionRequest{
+		SourceAccountID:      gbpID,
+		DestinationAccountID: eurID,
+		Amount:               "100",
+	}, &created)
+	require.Equal(t, http.StatusOK, status)
+
+	journal = getTransferJournal(t, ts, created.TransactionID)
+	require.Len(t, journal.Postings, 4, "A conversion goes through the FX position account")
+	var fxPostings int
+	for _, posting := range journal.Postings {
+		if posting.SystemAccount == "FX_POSITION" {
+			fxPostings++
+		}
+	}
+	assert.Equal(t, 2, fxPostings)
+	assertJournalBalanced(t, journal)
+
+	var split SplitPaymentResponse
+	status = postJSON(t, fmt.Sprintf("%s/transactions/split", ts.Server.URL), SplitPaymentRequest{
+		SourceAccountID: payeeID,
+		Legs: []SplitLegRequest{
+			{DestinationAccountID: payerID, Amount: "10.00"},
+			{DestinationAccountID: shopID, Amount: "5.00"},
+		},
+	}, &split)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, split.Legs, 2)
+
+	journal = getTransferJournal(t, ts, split.Legs[1].TransactionID)
+	assert.Equal(t, "SPLIT_PAYMENT", journal.Kind, "A leg is posted with the rest of its split")
+	assert.Equal(t, split.SplitPaymentID, journal.SplitPaymentID)
+	assert.Len(t, journal.Postings, 4)
+	assertJournalBalanced(t, journal)
+
+	status, errResp := postAsPrincipal(t, transactionsURL, "", CreateTransactionRequest{
+		SourceAccountID:      payerID,
+		DestinationAccountID: payeeID,
+		Amount:               "1000.00",
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, 9, errResp.Code)
+
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/?source_account_id=%d&status=FAILED", ts.Server.URL, payerID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var failed ListTransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&failed))
+	require.Len(t, failed.Transfers, 1)
+
+	resp, err = http.Get(fmt.Sprintf("%s/transactions/%d/journal", ts.Server.URL, failed.Transfers[0].ID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "A declined transfer posts nothing")
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+	assert.Equal(t, 61, errResp.Code)
+
+	for _, accountID := range []int{payerID, payeeID, gbpID, eurID, shopID} {
+		total := decimal.Zero
+		for cursor := ""; ; {
+			page := getAccountPostings(t, ts, accountID, cursor)
+			for _, posting := range page.Postings {
+				total = total.Add(decimal.RequireFromString(posting.Amount))
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, getAccountBalance(t, ts, accountID), total.String(), "The postings of account %d add up to its balance", accountID)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("%s/accounts/%d/postings", ts.Server.URL, baseID+2399))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func getAccountPostings(t *testing.T, ts *TestServer, accountID int, cursor string) ListPostingsResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/accounts/%d/postings?limit=2&cursor=%s", ts.Server.URL, accountID, cursor))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var page ListPostingsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	return page
+}
+
+func getTransferJournal(t *testing.T, ts *TestServer, transactionID int) JournalResponse {
+	resp, err := http.Get(fmt.Sprintf("%s/transactions/%d/journal", ts.Server.URL, transactionID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var journal JournalResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&journal))
+	return journal
+}
+
+func assertJournalBalanced(t *testing.T, journal JournalResponse) {
+	totals := map[string]decimal.Decimal{}
+	for _, posting := range journal.Postings {
+		totals[posting.Currency] = totals[posting.Currency].Add(decimal.RequireFromString(posting.Amount))
+	}
+	for currency, total := range totals {
+		assert.True(t, total.IsZero(), "Journal %d postings in %s add up to %s", journal.JournalID, currency, total)
+	}
+}
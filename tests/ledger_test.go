@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalPostings(t *testing.T) {
+	d := decimal.RequireFromString
+	feeAccount := 9
+
+	// posting describes a posting as "<account> <currency> <amount>"
+	posting := func(p models.Posting) string {
+		account := "?"
+		if p.AccountID != nil {
+			account = strconv.Itoa(*p.AccountID)
+		} else if p.SystemAccount != nil {
+			account = string(*p.SystemAccount)
+		}
+		return account + " " + p.Currency + " " + p.Amount.String()
+	}
+
+	tests := []struct {
+		name     string
+		postings []models.Posting
+		want     []string
+	}{
+		{
+			name: "same currency transfer debits the source and credits the destination",
+			postings: models.TransferPostings(&models.Transfer{
+				SourceAccountID: 1, DestinationAccountID: 2, Amount: d("25.50"), DestinationAmount: d("25.50"),
+				SourceCurrency: "USD", DestinationCurrency: "USD",
+			}),
+			want: []string{"1 USD -25.5", "2 USD 25.5"},
+		},
+		{
+			name: "fee is a separate debit of the source credited to the fee account",
+			postings: models.TransferPostings(&models.Transfer{
+				SourceAccountID: 1, DestinationAccountID: 2, Amount: d("100"), DestinationAmount: d("100"),
+				SourceCurrency: "USD", DestinationCurrency: "USD", Fee: d("1.5"), FeeAccountID: &feeAccount,
+			}),
+			want: []string{"1 USD -100", "2 USD 100", "1 USD -1.5", "9 USD 1.5"},
+		},
+		{
+			name: "cross-currency transfer goes through the FX position account",
+			postings: models.TransferPostings(&models.Transfer{
+				SourceAccountID: 1, DestinationAccountID: 2, Amount: d("100"), DestinationAmount: d("115"),
+				SourceCurrency: "GBP", DestinationCurrency: "EUR",
+			}),
+			want: []string{"1 GBP -100", "FX_POSITION GBP 100", "FX_POSITION EUR -115", "2 EUR 115"},
+		},
+		{
+			name: "split payment posts every leg and the fee on the whole amount",
+			postings: models.SplitPaymentPostings(&models.SplitPayment{
+				SourceAccountID: 1, Amount: d("30"), Currency: "USD", Fee: d("0.3"), FeeAccountID: &feeAccount,
+				Legs: []*models.Transfer{
+					{SourceAccountID: 1, DestinationAccountID: 2, Amount: d("10"), DestinationAmount: d("10"), SourceCurrency: "USD", DestinationCurrency: "USD"},
+					{SourceAccountID: 1, DestinationAccountID: 3, Amount: d("20"), DestinationAmount: d("20"), SourceCurrency: "USD", DestinationCurrency: "USD"},
+				},
+			}),
+			want: []string{"1 USD -10", "2 USD 10", "1 USD -20", "3 USD 20", "1 USD -0.3", "9 USD 0.3"},
+		},
+		{
+			name:     "initial balance is credited against equity",
+			postings: models.OpeningBalancePostings(&models.Account{ID: 4, Currency: "SGD", InitialBalance: d("1000")}),
+			want:     []string{"4 SGD 1000", "EQUITY SGD -1000"},
+		},
+		{
+			name:     "account opened empty has no postings",
+			postings: models.OpeningBalancePostings(&models.Account{ID: 4, Currency: "SGD", InitialBalance: decimal.Zero}),
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			totals := map[string]decimal.Decimal{}
+			for _, p := range tt.postings {
+				got = append(got, posting(p))
+				totals[p.Currency] = totals[p.Currency].Add(p.Amount)
+			}
+			assert.Equal(t, tt.want, got)
+
+			for currency, total := range totals {
+				assert.True(t, total.IsZero(), "%s postings add up to %s", currency, total)
+			}
+		})
+	}
+}
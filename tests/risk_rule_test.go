@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskRuleEvaluation(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	amount := func(s string) *decimal.Decimal {
+		d := decimal.RequireFromString(s)
+		return &d
+	}
+	ageSeconds := 86400
+	usd := "USD"
+	merchant := 7
+
+	largeDeny := models.RiskRule{ID: 1, Name: "large-transfers", Type: models.RiskRuleTypeAmountThreshold, Action: models.RiskActionDeny, Amount: amount("10000")}
+	newPayee := models.RiskRule{ID: 2, Name: "new-payee", Type: models.RiskRuleTypeNewAccountAge, Action: models.RiskActionFlag,
+		AccountSide: models.RiskAccountSideDestination, MaxAccountAgeSeconds: &ageSeconds}
+	blocked := models.RiskRule{ID: 3, Name: "blocked-counterparties", Type: models.RiskRuleTypeBlocklist, Action: models.RiskActionDeny,
+		AccountSide: models.RiskAccountSideEither, BlockedAccountIDs: []int{99, 100}}
+	roundFlag := models.RiskRule{ID: 4, Name: "round-amounts", Type: models.RiskRuleTypeRoundAmount, Action: models.RiskActionFlag,
+		Amount: amount("1000"), RoundMultiple: amount("100")}
+	trustedMerchant := models.RiskRule{ID: 5, Name: "trusted-merchant", Type: models.RiskRuleTypeAmountThreshold, Action: models.RiskActionAllow,
+		AccountID: &merchant, Amount: amount("0.01")}
+	usdOnly := models.RiskRule{ID: 6, Name: "usd-large", Type: models.RiskRuleTypeAmountThreshold, Action: models.RiskActionDeny,
+		Amount: amount("500"), Currency: &usd}
+
+	input := func(source, dest int, amt string, destAge time.Duration) *models.RiskInput {
+		return &models.RiskInput{
+			SourceAccountID:      source,
+			DestinationAccountID: dest,
+			Amount:               decimal.RequireFromString(amt),
+			Currency:             "SGD",
+			SourceOpenedAt:       now.Add(-365 * 24 * time.Hour),
+			DestinationOpenedAt:  now.Add(-destAge),
+			Now:                  now,
+		}
+	}
+	oldAccount := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		rules     []models.RiskRule
+		in        *models.RiskInput
+		want      models.RiskAction
+		wantRule  string
+		wantFlags []string
+	}{
+		{
+			name:  "no rules allows the transfer",
+			rules: nil,
+			in:    input(1, 2, "50000", oldAccount),
+			want:  models.RiskActionAllow,
+		},
+		{
+			name:     "amount at the threshold is denied",
+			rules:    []models.RiskRule{largeDeny},
+			in:       input(1, 2, "10000", oldAccount),
+			want:     models.RiskActionDeny,
+			wantRule: "large-transfers",
+		},
+		{
+			name:  "amount below the threshold is allowed",
+			rules: []models.RiskRule{largeDeny},
+			in:    input(1, 2, "9999.99", oldAccount),
+			want:  models.RiskActionAllow,
+		},
+		{
+			name:      "new destination is flagged",
+			rules:     []models.RiskRule{newPayee},
+			in:        input(1, 2, "20", time.Hour),
+			want:      models.RiskActionAllow,
+			wantFlags: []string{"new-payee"},
+		},
+		{
+			name:  "new account age only looks at its side",
+			rules: []models.RiskRule{newPayee},
+			in: func() *models.RiskInput {
+				in := input(1, 2, "20", oldAccount)
+				in.SourceOpenedAt = now.Add(-time.Minute)
+				return in
+			}(),
+			want: models.RiskActionAllow,
+		},
+		{
+			name:     "blocked counterparty on either side is denied",
+			rules:    []models.RiskRule{blocked},
+			in:       input(100, 2, "1", oldAccount),
+			want:     models.RiskActionDeny,
+			wantRule: "blocked-counterparties",
+		},
+		{
+			name:      "round amount at the minimum is flagged",
+			rules:     []models.RiskRule{roundFlag},
+			in:        input(1, 2, "5000.00", oldAccount),
+			want:      models.RiskActionAllow,
+			wantFlags: []string{"round-amounts"},
+		},
+		{
+			name:  "uneven amount is not flagged",
+			rules: []models.RiskRule{roundFlag},
+			in:    input(1, 2, "5000.01", oldAccount),
+			want:  models.RiskActionAllow,
+		},
+		{
+			name:  "round amount below the minimum is not flagged",
+			rules: []models.RiskRule{roundFlag},
+			in:    input(1, 2, "500", oldAccount),
+			want:  models.RiskActionAllow,
+		},
+		{
+			name:      "earlier allow stops later denials but keeps earlier flags",
+			rules:     []models.RiskRule{roundFlag, trustedMerchant, largeDeny},
+			in:        input(merchant, 2, "20000", oldAccount),
+			want:      models.RiskActionAllow,
+			wantRule:  "trusted-merchant",
+			wantFlags: []string{"round-amounts"},
+		},
+		{
+			name:     "rule scoped to an account skips other transfers",
+			rules:    []models.RiskRule{trustedMerchant, largeDeny},
+			in:       input(1, 2, "20000", oldAccount),
+			want:     models.RiskActionDeny,
+			wantRule: "large-transfers",
+		},
+		{
+			name:  "rule scoped to a currency skips other currencies",
+			rules: []models.RiskRule{usdOnly},
+			in:    input(1, 2, "1000", oldAccount),
+			want:  models.RiskActionAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := models.EvaluateRiskRules(tt.rules, tt.in)
+			assert.Equal(t, tt.want, decision.Action)
+
+			if tt.wantRule == "" {
+				assert.Nil(t, decision.Rule)
+			} else if assert.NotNil(t, decision.Rule) {
+				assert.Equal(t, tt.wantRule, decision.Rule.Name)
+			}
+
+			var flags []string
+			for _, rule := range decision.Flags {
+				flags = append(flags, rule.Name)
+			}
+			assert.Equal(t, tt.wantFlags, flags)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAllocation(t *testing.T) {
+	decimals := func(values ...string) []decimal.Decimal {
+		parsed := make([]decimal.Decimal, len(values))
+		for i, value := range values {
+			parsed[i] = decimal.RequireFromString(value)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name        string
+		total       string
+		percentages []string
+		want        []string
+	}{
+		{
+			name:        "exact shares need no remainder",
+			total:       "200",
+			percentages: []string{"80", "15", "5"},
+			want:        []string{"160", "30", "10"},
+		},
+		{
+			name:        "equal losses favour earlier legs",
+			total:       "100",
+			percentages: []string{"33.33333333", "33.33333333", "33.33333334"},
+			want:        []string{"33.33333333", "33.33333333", "33.33333334"},
+		},
+		{
+			name:        "tied remainder goes to the first leg",
+			total:       "0.00000001",
+			percentages: []string{"50", "50"},
+			want:        []string{"0.00000001", "0"},
+		},
+		{
+			name:        "largest loss gets the remainder",
+			total:       "10",
+			percentages: []string{"12.5", "87.49999999", "0.00000001"},
+			want:        []string{"1.25", "8.75", "0"},
+		},
+		{
+			name:        "remainder spread over several legs",
+			total:       "0.00000010",
+			percentages: []string{"33", "33", "34"},
+			want:        []string{"0.00000003", "0.00000003", "0.00000004"},
+		},
+		{
+			name:        "single leg takes everything",
+			total:       "123.45678901",
+			percentages: []string{"100"},
+			want:        []string{"123.45678901"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total := decimal.RequireFromString(tt.total)
+			shares := models.AllocateByPercentage(total, decimals(tt.percentages...))
+
+			sum := decimal.Zero
+			got := make([]string, len(shares))
+			for i, share := range shares {
+				got[i] = share.String()
+				sum = sum.Add(share)
+			}
+			assert.Equal(t, tt.want, got)
+			assert.True(t, sum.Equal(total), "shares add up to %s, not %s", sum.String(), tt.total)
+		})
+	}
+}
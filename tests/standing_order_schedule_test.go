@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandingOrderSchedule(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("bad timestamp %q: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name  string
+		order models.StandingOrder
+		after string
+		want  string
+	}{
+		{
+			name:  "interval first run is the start",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyInterval, IntervalSeconds: intPtr(3600), StartAt: at("2025-01-01T10:00:00Z")},
+			after: "2024-12-01T00:00:00Z",
+			want:  "2025-01-01T10:00:00Z",
+		},
+		{
+			name:  "interval steps from the start",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyInterval, IntervalSeconds: intPtr(3600), StartAt: at("2025-01-01T10:00:00Z")},
+			after: "2025-01-01T12:30:00Z",
+			want:  "2025-01-01T13:00:00Z",
+		},
+		{
+			name:  "daily keeps the start time of day",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyDaily, StartAt: at("2025-01-01T09:15:00Z")},
+			after: "2025-03-10T09:15:00Z",
+			want:  "2025-03-11T09:15:00Z",
+		},
+		{
+			name:  "weekly moves to the requested weekday",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyWeekly, DayOfWeek: intPtr(int(time.Friday)), StartAt: at("2025-01-01T08:00:00Z")},
+			after: "2025-01-06T12:00:00Z",
+			want:  "2025-01-10T08:00:00Z",
+		},
+		{
+			name:  "weekly on the same weekday after the run time waits a week",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyWeekly, DayOfWeek: intPtr(int(time.Friday)), StartAt: at("2025-01-01T08:00:00Z")},
+			after: "2025-01-10T08:00:00Z",
+			want:  "2025-01-17T08:00:00Z",
+		},
+		{
+			name:  "monthly on the 31st is clamped in short months",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyMonthly, DayOfMonth: intPtr(31), StartAt: at("2025-01-01T00:00:00Z")},
+			after: "2025-01-31T00:00:00Z",
+			want:  "2025-02-28T00:00:00Z",
+		},
+		{
+			name:  "monthly on the 31st returns to the 31st",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyMonthly, DayOfMonth: intPtr(31), StartAt: at("2025-01-01T00:00:00Z")},
+			after: "2025-02-28T00:00:00Z",
+			want:  "2025-03-31T00:00:00Z",
+		},
+		{
+			name:  "end of month handles leap years",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyEndOfMonth, StartAt: at("2024-01-15T17:00:00Z")},
+			after: "2024-01-31T17:00:00Z",
+			want:  "2024-02-29T17:00:00Z",
+		},
+		{
+			name:  "end of month rolls over the year",
+			order: models.StandingOrder{Frequency: models.StandingOrderFrequencyEndOfMonth, StartAt: at("2024-01-15T17:00:00Z")},
+			after: "2024-12-31T18:00:00Z",
+			want:  "2025-01-31T17:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, at(tt.want), tt.order.NextOccurrence(at(tt.after)))
+		})
+	}
+
+	order := models.StandingOrder{Frequency: models.StandingOrderFrequencyMonthly, DayOfMonth: intPtr(5), StartAt: at("2025-01-05T06:00:00Z")}
+	assert.Equal(t, at("2025-01-05T06:00:00Z"), order.FirstOccurrence(), "A start on an occurrence is the first run")
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweepAmount(t *testing.T) {
+	minAmount := decimal.RequireFromString("5")
+
+	tests := []struct {
+		name            string
+		ruleType        models.SweepRuleType
+		threshold       string
+		minAmount       *decimal.Decimal
+		sourceAvailable string
+		destBalance     string
+		want            string
+	}{
+		{
+			name:            "sweep moves everything above the threshold",
+			ruleType:        models.SweepRuleTypeSweepExcess,
+			threshold:       "10000",
+			sourceAvailable: "12500.5",
+			destBalance:     "3",
+			want:            "2500.5",
+		},
+		{
+			name:            "sweep below the threshold moves nothing",
+			ruleType:        models.SweepRuleTypeSweepExcess,
+			threshold:       "10000",
+			sourceAvailable: "9000",
+			destBalance:     "0",
+			want:            "0",
+		},
+		{
+			name:            "sweep at the threshold moves nothing",
+			ruleType:        models.SweepRuleTypeSweepExcess,
+			threshold:       "10000",
+			sourceAvailable: "10000",
+			destBalance:     "0",
+			want:            "0",
+		},
+		{
+			name:            "top up moves what the destination lacks",
+			ruleType:        models.SweepRuleTypeTopUp,
+			threshold:       "500",
+			sourceAvailable: "100",
+			destBalance:     "120",
+			want:            "380",
+		},
+		{
+			name:            "top up of an overdrawn destination covers the overdraft",
+			ruleType:        models.SweepRuleTypeTopUp,
+			threshold:       "500",
+			sourceAvailable: "1000",
+			destBalance:     "-50",
+			want:            "550",
+		},
+		{
+			name:            "top up above the threshold moves nothing",
+			ruleType:        models.SweepRuleTypeTopUp,
+			threshold:       "500",
+			sourceAvailable: "1000",
+			destBalance:     "700",
+			want:            "0",
+		},
+		{
+			name:            "amounts below the minimum are skipped",
+			ruleType:        models.SweepRuleTypeSweepExcess,
+			threshold:       "100",
+			minAmount:       &minAmount,
+			sourceAvailable: "104.99",
+			destBalance:     "0",
+			want:            "0",
+		},
+		{
+			name:            "amounts at the minimum are moved",
+			ruleType:        models.SweepRuleTypeTopUp,
+			threshold:       "100",
+			minAmount:       &minAmount,
+			sourceAvailable: "1000",
+			destBalance:     "95",
+			want:            "5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &models.SweepRule{
+				Type:      tt.ruleType,
+				Threshold: decimal.RequireFromString(tt.threshold),
+				MinAmount: tt.minAmount,
+			}
+
+			got := rule.SweepAmount(decimal.RequireFromString(tt.sourceAvailable), decimal.RequireFromString(tt.destBalance))
+			assert.True(t, decimal.RequireFromString(tt.want).Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nauman-S/Internal-Transfers-System/models"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+// benchmarkHotAccounts is how many accounts the transfers of
+// BenchmarkProcessTransfer contend on
+const benchmarkHotAccounts = 4
+
+// BenchmarkProcessTransfer compares the locking and the single-statement
+// transfer paths under contention: parallel goroutines move small amounts
+// between a few hot accounts, so most transfers wait on another's row locks.
+// Run it with make bench.
+func BenchmarkProcessTransfer(b *testing.B) {
+	modes := []storage.TransferExecutionMode{storage.TransferExecutionLocking, storage.TransferExecutionSingleStatement}
+	for i, mode := range modes {
+		ts := SetupTestServerWithDBConfig(b, func(config *storage.Config) {
+			config.TransferExecution = string(mode)
+			config.LockTimeout = 2 * time.Second
+			config.MaxTxRetries = 3
+		})
+
+		baseID := int(time.Now().Unix())%100000 + 90000 + i*benchmarkHotAccounts
+		ctx := context.Background()
+		for id := baseID; id < baseID+benchmarkHotAccounts; id++ {
+			_, err := ts.Config.AccountRepository.CreateAccount(ctx, &models.Account{
+				ID:             id,
+				InitialBalance: decimal.NewFromInt(1000000000),
+				Currency:       models.DefaultCurrency,
+				AccountType:    models.DefaultAccountType,
+				CreatedAt:      time.Now(),
+				UpdatedAt:      time.Now(),
+			})
+			require.NoError(b, err)
+		}
+
+		b.Run(string(mode), func(b *testing.B) {
+			var failures atomic.Int64
+			amount := decimal.NewFromInt(1)
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					source := baseID + rand.IntN(benchmarkHotAccounts)
+					dest := baseID + (source-baseID+1+rand.IntN(benchmarkHotAccounts-1))%benchmarkHotAccounts
+					_, _, _, err := ts.Config.TransferRepository.ProcessTransfer(ctx, &models.Transfer{
+						SourceAccountID:      source,
+						DestinationAccountID: dest,
+						Amount:               amount,
+					}, nil)
+					if err != nil {
+						failures.Add(1)
+					}
+				}
+			})
+			b.StopTimer()
+
+			if n := failures.Load(); n > 0 {
+				b.Errorf("%d of %d transfers failed", n, b.N)
+			}
+		})
+
+		ts.Cleanup()
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/Nauman-S/Internal-Transfers-System/codes"
+	"github.com/Nauman-S/Internal-Transfers-System/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxPolicy(t *testing.T) {
+	levels := map[string]pgx.TxIsoLevel{
+		"":                pgx.TxIsoLevel(""),
+		"serializable":    pgx.Serializable,
+		"SERIALIZABLE":    pgx.Serializable,
+		"repeatable read": pgx.RepeatableRead,
+		"REPEATABLE_READ": pgx.RepeatableRead,
+		"read-committed":  pgx.ReadCommitted,
+	}
+	for level, want := range levels {
+		got, err := storage.ParseIsolationLevel(level)
+		require.NoError(t, err, level)
+		assert.Equal(t, want, got, level)
+	}
+
+	_, err := storage.ParseIsolationLevel("read uncommitted")
+	assert.Error(t, err, "Only levels Postgres actually provides are accepted")
+
+	retryable := []string{"40001", "40P01", "55P03"}
+	for _, code := range retryable {
+		err := fmt.Errorf("failed to debit source account: %w", &pgconn.PgError{Code: code})
+		assert.True(t, storage.IsRetryableTxError(err), "SQLSTATE %s should be retried through wrapping", code)
+	}
+
+	notRetryable := []error{
+		&pgconn.PgError{Code: "23505"},
+		&pgconn.PgError{Code: "23514"},
+		codes.ErrInsufficientFunds,
+		errors.New("connection refused"),
+		nil,
+	}
+	for _, err := range notRetryable {
+		assert.False(t, storage.IsRetryableTxError(err), "%v should not be retried", err)
+	}
+}
+
+func TestTransferExecutionMode(t *testing.T) {
+	modes := map[string]storage.TransferExecutionMode{
+		"":                 storage.TransferExecutionLocking,
+		"locking":          storage.TransferExecutionLocking,
+		"single_statement": storage.TransferExecutionSingleStatement,
+		"SINGLE_STATEMENT": storage.TransferExecutionSingleStatement,
+	}
+	for mode, want := range modes {
+		got, err := storage.ParseTransferExecutionMode(mode)
+		require.NoError(t, err, mode)
+		assert.Equal(t, want, got, mode)
+	}
+
+	_, err := storage.ParseTransferExecutionMode("cte")
+	assert.Error(t, err)
+}